@@ -166,6 +166,11 @@ var (
 
 	CriticalRateLimitNum            = 20
 	CriticalRateLimitDuration int64 = 20 * 60
+
+	// token 计数接口（/v1/messages/count_tokens、:countTokens）按令牌限流
+	CountTokensRateLimitEnable   bool
+	CountTokensRateLimitNum      int
+	CountTokensRateLimitDuration int64
 )
 
 var RateLimitKeyExpirationDuration = 20 * time.Minute
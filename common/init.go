@@ -98,6 +98,10 @@ func InitEnv() {
 	GlobalWebRateLimitNum = GetEnvOrDefault("GLOBAL_WEB_RATE_LIMIT", 60)
 	GlobalWebRateLimitDuration = int64(GetEnvOrDefault("GLOBAL_WEB_RATE_LIMIT_DURATION", 180))
 
+	CountTokensRateLimitEnable = GetEnvOrDefaultBool("COUNT_TOKENS_RATE_LIMIT_ENABLE", true)
+	CountTokensRateLimitNum = GetEnvOrDefault("COUNT_TOKENS_RATE_LIMIT", 120)
+	CountTokensRateLimitDuration = int64(GetEnvOrDefault("COUNT_TOKENS_RATE_LIMIT_DURATION", 60))
+
 	util.InitKey()
 	initConstantEnv()
 }
@@ -119,4 +123,16 @@ func initConstantEnv() {
 	constant.GenerateDefaultToken = GetEnvOrDefaultBool("GENERATE_DEFAULT_TOKEN", false)
 	// 是否启用错误日志
 	constant.ErrorLogEnabled = GetEnvOrDefaultBool("ERROR_LOG_ENABLED", false)
+	// /v1/files 上传文件的本地存储目录
+	constant.FileStorageDir = GetEnvOrDefaultString("FILE_STORAGE_DIR", "./data/files")
+	// 任务生成的图片、视频等媒体的本地存储目录，配置 ASSET_S3_ENDPOINT 与 ASSET_S3_BUCKET 后改用 S3 兼容存储
+	constant.AssetStorageDir = GetEnvOrDefaultString("ASSET_STORAGE_DIR", "./data/assets")
+	constant.AssetS3Endpoint = GetEnvOrDefaultString("ASSET_S3_ENDPOINT", "")
+	constant.AssetS3Region = GetEnvOrDefaultString("ASSET_S3_REGION", "us-east-1")
+	constant.AssetS3Bucket = GetEnvOrDefaultString("ASSET_S3_BUCKET", "")
+	constant.AssetS3AccessKey = GetEnvOrDefaultString("ASSET_S3_ACCESS_KEY", "")
+	constant.AssetS3SecretKey = GetEnvOrDefaultString("ASSET_S3_SECRET_KEY", "")
+	// 是否开放 /metrics 监控指标，设置 METRICS_TOKEN 后需要携带 Bearer Token 访问
+	constant.MetricsEnabled = GetEnvOrDefaultBool("METRICS_ENABLED", false)
+	constant.MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
 }
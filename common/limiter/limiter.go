@@ -12,9 +12,13 @@ import (
 //go:embed lua/rate_limit.lua
 var rateLimitScript string
 
+//go:embed lua/fixed_window.lua
+var fixedWindowScript string
+
 type RedisLimiter struct {
-	client         *redis.Client
-	limitScriptSHA string
+	client          *redis.Client
+	limitScriptSHA  string
+	windowScriptSHA string
 }
 
 var (
@@ -29,9 +33,14 @@ func New(ctx context.Context, r *redis.Client) *RedisLimiter {
 		if err != nil {
 			common.SysLog(fmt.Sprintf("Failed to load rate limit script: %v", err))
 		}
+		windowSHA, err := r.ScriptLoad(ctx, fixedWindowScript).Result()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("Failed to load fixed window script: %v", err))
+		}
 		instance = &RedisLimiter{
-			client:         r,
-			limitScriptSHA: limitSHA,
+			client:          r,
+			limitScriptSHA:  limitSHA,
+			windowScriptSHA: windowSHA,
 		}
 	})
 
@@ -67,6 +76,24 @@ func (rl *RedisLimiter) Allow(ctx context.Context, key string, opts ...Option) (
 	return result == 1, nil
 }
 
+// IncrWindow 固定窗口计数，增加 n 后返回当前窗口内的累计值与窗口剩余秒数，n 为 0 时仅查询
+func (rl *RedisLimiter) IncrWindow(ctx context.Context, key string, n int64, windowSeconds int64) (int64, int64, error) {
+	result, err := rl.client.EvalSha(
+		ctx,
+		rl.windowScriptSHA,
+		[]string{key},
+		n,
+		windowSeconds,
+	).Int64Slice()
+	if err != nil {
+		return 0, 0, fmt.Errorf("fixed window counter failed: %w", err)
+	}
+	if len(result) != 2 {
+		return 0, 0, fmt.Errorf("fixed window counter returned %d values", len(result))
+	}
+	return result[0], result[1], nil
+}
+
 // Config 配置选项模式
 type Config struct {
 	Capacity  int64
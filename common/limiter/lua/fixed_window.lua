@@ -0,0 +1,26 @@
+-- 固定窗口计数器
+-- KEYS[1]: 计数器唯一标识
+-- ARGV[1]: 本次增加的数量（为 0 时仅查询，为负数时返还）
+-- ARGV[2]: 窗口长度（秒）
+
+local key = KEYS[1]
+local requested = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local count = tonumber(redis.call('GET', key) or '0')
+if requested > 0 then
+    count = redis.call('INCRBY', key, requested)
+elseif requested < 0 and count > 0 then
+    -- 返还时累计值最低为 0
+    count = redis.call('INCRBY', key, math.max(requested, -count))
+end
+
+local ttl = redis.call('TTL', key)
+if ttl < 0 then
+    if requested ~= 0 then
+        redis.call('EXPIRE', key, window)
+    end
+    ttl = window
+end
+
+return {count, ttl}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// 网关指标定义
+
+var (
+	latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+	syncBuckets    = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+var (
+	RelayRequests = NewCounterVec("newapi_relay_requests_total",
+		"Relay requests by final channel, model, group and response status code.",
+		"channel", "model", "group", "status")
+	RelayRequestDuration = NewHistogramVec("newapi_relay_request_duration_seconds",
+		"End-to-end relay request latency in seconds.", latencyBuckets,
+		"channel", "model", "group")
+	RelayTTFT = NewHistogramVec("newapi_relay_ttft_seconds",
+		"Time to first response byte from the upstream channel in seconds.", latencyBuckets,
+		"channel", "model", "group")
+	RelayTokens = NewCounterVec("newapi_relay_tokens_total",
+		"Tokens processed by relay requests, type is prompt or completion.",
+		"channel", "model", "group", "type")
+	RelayRetries = NewCounterVec("newapi_relay_retries_total",
+		"Relay retry attempts on another channel.",
+		"model", "group")
+	UpstreamResponses = NewCounterVec("newapi_upstream_responses_total",
+		"Upstream attempts by channel, model, group and upstream status code.",
+		"channel", "model", "group", "code")
+	QuotaConsumed = NewCounterVec("newapi_quota_consumed_total",
+		"Quota consumed by successful requests.",
+		"model", "group")
+	PreConsumeRefunds = NewCounterVec("newapi_preconsume_refunds_total",
+		"Pre-consumed quota refunds after failed requests.")
+	PreConsumeRefundQuota = NewCounterVec("newapi_preconsume_refund_quota_total",
+		"Pre-consumed quota returned after failed requests.")
+	CacheSyncDuration = NewHistogramVec("newapi_cache_sync_duration_seconds",
+		"Duration of in-memory cache syncs from the database in seconds.", syncBuckets,
+		"cache")
+)
+
+// ObserveRelayAttempt 记录一次上游请求的结果，code 为 0 表示未收到上游响应
+func ObserveRelayAttempt(channelId int, modelName string, group string, code int, ttft time.Duration) {
+	channel := strconv.Itoa(channelId)
+	UpstreamResponses.Inc(channel, modelName, group, strconv.Itoa(code))
+	if ttft > 0 {
+		RelayTTFT.Observe(ttft.Seconds(), channel, modelName, group)
+	}
+}
+
+// ObserveRelayRequest 记录一次中继请求的最终结果
+func ObserveRelayRequest(channelId int, modelName string, group string, status int, duration time.Duration, retries int) {
+	channel := strconv.Itoa(channelId)
+	RelayRequests.Inc(channel, modelName, group, strconv.Itoa(status))
+	RelayRequestDuration.Observe(duration.Seconds(), channel, modelName, group)
+	if retries > 0 {
+		RelayRetries.Add(float64(retries), modelName, group)
+	}
+}
+
+// ObserveConsume 记录一次成功请求的 token 数与消耗额度
+func ObserveConsume(channelId int, modelName string, group string, promptTokens int, completionTokens int, quota int) {
+	channel := strconv.Itoa(channelId)
+	RelayTokens.Add(float64(promptTokens), channel, modelName, group, "prompt")
+	RelayTokens.Add(float64(completionTokens), channel, modelName, group, "completion")
+	QuotaConsumed.Add(float64(quota), modelName, group)
+}
+
+// ObserveCacheSync 记录一次缓存同步耗时
+func ObserveCacheSync(cache string, startTime time.Time) {
+	CacheSyncDuration.Observe(time.Since(startTime).Seconds(), cache)
+}
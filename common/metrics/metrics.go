@@ -0,0 +1,296 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 轻量的 Prometheus 指标实现，按 text exposition format 输出，指标在创建时自动注册
+
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registry     []collector
+	registryLock sync.Mutex
+)
+
+func register(c collector) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = append(registry, c)
+}
+
+// WriteAll 按注册顺序输出所有指标
+func WriteAll(w io.Writer) {
+	registryLock.Lock()
+	collectors := make([]collector, len(registry))
+	copy(collectors, registry)
+	registryLock.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names []string, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelValueEscaper.Replace(values[i]))
+		sb.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extra[i])
+		sb.WriteString(`="`)
+		sb.WriteString(extra[i+1])
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name string, help string, metricType string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+// vec 带标签的指标序列集合
+type vec[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+	lock       sync.Mutex
+	series     map[string]*T
+	labels     map[string][]string
+	newSeries  func() *T
+}
+
+func (v *vec[T]) with(labelValues []string) *T {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := seriesKey(labelValues)
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		v.labels[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+func (v *vec[T]) sortedKeys() []string {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newVec[T any](name string, help string, labelNames []string, newSeries func() *T) vec[T] {
+	return vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     make(map[string]*T),
+		labels:     make(map[string][]string),
+		newSeries:  newSeries,
+	}
+}
+
+// CounterVec 单调递增的计数器
+type CounterVec struct {
+	vec[float64]
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(name, help, labelNames, func() *float64 { return new(float64) })}
+	register(c)
+	return c
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	*c.with(labelValues) += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range c.sortedKeys() {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labelNames, c.labels[key]), formatValue(*c.series[key]))
+	}
+}
+
+// GaugeVec 可增可减的数值
+type GaugeVec struct {
+	vec[float64]
+}
+
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{vec: newVec(name, help, labelNames, func() *float64 { return new(float64) })}
+	register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	*g.with(labelValues) = value
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	*g.with(labelValues) += delta
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, key := range g.sortedKeys() {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labelNames, g.labels[key]), formatValue(*g.series[key]))
+	}
+}
+
+// GaugeSample GaugeFunc 采集到的一个序列
+type GaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc 在输出时调用回调函数采集数值，适用于队列长度等需要实时查询的指标
+type GaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	collect    func() []GaugeSample
+}
+
+func NewGaugeFunc(name string, help string, labelNames []string, collect func() []GaugeSample) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labelNames: labelNames, collect: collect}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, sample := range g.collect() {
+		if len(sample.LabelValues) != len(g.labelNames) {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labelNames, sample.LabelValues), formatValue(sample.Value))
+	}
+}
+
+// CachedCollect 包装采集函数，ttl 内复用上次的结果，避免每次抓取都执行数据库查询等耗时操作
+func CachedCollect(ttl time.Duration, collect func() []GaugeSample) func() []GaugeSample {
+	var (
+		lock        sync.Mutex
+		samples     []GaugeSample
+		collectedAt time.Time
+	)
+	return func() []GaugeSample {
+		lock.Lock()
+		defer lock.Unlock()
+		if collectedAt.IsZero() || time.Since(collectedAt) >= ttl {
+			samples = collect()
+			collectedAt = time.Now()
+		}
+		return samples
+	}
+}
+
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec 按区间统计分布，buckets 为各区间上界（升序）
+type HistogramVec struct {
+	vec[histogramSeries]
+	buckets []float64
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{buckets: buckets}
+	h.vec = newVec(name, help, labelNames, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets))}
+	})
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := h.with(labelValues)
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, key := range h.sortedKeys() {
+		s := h.series[key]
+		labelValues := h.labels[key]
+		for i, upper := range h.buckets {
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, labelValues, "le", formatValue(upper)), s.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, labelValues, "le", "+Inf"), s.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labelNames, labelValues), formatValue(s.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labelNames, labelValues), s.count)
+	}
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterVecExposition(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "Test requests.", "channel", "model")
+	c.Inc("2", "gpt-4o")
+	c.Add(2.5, "1", `a"b\c`+"\n")
+	c.Add(-1, "1", "ignored")
+	c.Inc("2", "gpt-4o")
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	want := `# HELP test_requests_total Test requests.
+# TYPE test_requests_total counter
+test_requests_total{channel="1",model="a\"b\\c\n"} 2.5
+test_requests_total{channel="2",model="gpt-4o"} 2
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestGaugeExposition(t *testing.T) {
+	g := NewGaugeVec("test_in_flight", "Test gauge.")
+	g.Set(3)
+	g.Add(-5)
+
+	var buf bytes.Buffer
+	g.write(&buf)
+	want := `# HELP test_in_flight Test gauge.
+# TYPE test_in_flight gauge
+test_in_flight -2
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	f := NewGaugeFunc("test_queue_size", "Test gauge func.", []string{"status"}, func() []GaugeSample {
+		return []GaugeSample{
+			{LabelValues: []string{"QUEUED"}, Value: 4},
+			// 标签数量不匹配的样本被丢弃
+			{LabelValues: []string{"a", "b"}, Value: 1},
+			{LabelValues: []string{"RUNNING"}, Value: math.Inf(1)},
+		}
+	})
+	buf.Reset()
+	f.write(&buf)
+	want = `# HELP test_queue_size Test gauge func.
+# TYPE test_queue_size gauge
+test_queue_size{status="QUEUED"} 4
+test_queue_size{status="RUNNING"} +Inf
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestHistogramExposition(t *testing.T) {
+	h := NewHistogramVec("test_latency_seconds", "Test histogram.", []float64{0.5, 1, 2.5}, "model")
+	h.Observe(0.2, "m")
+	h.Observe(1, "m")
+	h.Observe(3, "m")
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	// 桶计数是累计值，+Inf 桶等于样本总数
+	want := `# HELP test_latency_seconds Test histogram.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{model="m",le="0.5"} 1
+test_latency_seconds_bucket{model="m",le="1"} 2
+test_latency_seconds_bucket{model="m",le="2.5"} 2
+test_latency_seconds_bucket{model="m",le="+Inf"} 3
+test_latency_seconds_sum{model="m"} 4.2
+test_latency_seconds_count{model="m"} 3
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+// TestWriteAllFormat 检查全部已注册指标的每一行都符合 text exposition format
+func TestWriteAllFormat(t *testing.T) {
+	RelayRequests.Inc("1", "gpt-4o", "default", "200")
+	RelayRequestDuration.Observe(0.3, "1", "gpt-4o", "default")
+	ObserveCacheSync("channels", time.Now())
+
+	var buf bytes.Buffer
+	WriteAll(&buf)
+	comment := regexp.MustCompile(`^# (HELP|TYPE) [a-zA-Z_:][a-zA-Z0-9_:]* .+$`)
+	sample := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{([a-zA-Z_][a-zA-Z0-9_]*="([^"\\]|\\.)*",?)*\})? (\+Inf|-Inf|NaN|-?[0-9.eE+-]+)$`)
+	types := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			name := strings.Fields(line)[2]
+			if types[name] {
+				t.Errorf("duplicate TYPE for %s", name)
+			}
+			types[name] = true
+		}
+		if !comment.MatchString(line) && !sample.MatchString(line) {
+			t.Errorf("invalid exposition line: %q", line)
+		}
+	}
+	if !types["newapi_relay_requests_total"] || !types["newapi_cache_sync_duration_seconds"] {
+		t.Errorf("missing gateway metrics in output:\n%s", buf.String())
+	}
+}
+
+func TestCachedCollect(t *testing.T) {
+	calls := 0
+	collect := CachedCollect(time.Hour, func() []GaugeSample {
+		calls++
+		return []GaugeSample{{Value: float64(calls)}}
+	})
+	for i := 0; i < 3; i++ {
+		if samples := collect(); len(samples) != 1 || samples[0].Value != 1 {
+			t.Fatalf("unexpected samples: %+v", samples)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("collect called %d times within ttl, want 1", calls)
+	}
+
+	expired := CachedCollect(0, func() []GaugeSample {
+		calls++
+		return nil
+	})
+	expired()
+	expired()
+	if calls != 3 {
+		t.Errorf("collect called %d times with zero ttl, want 2", calls-1)
+	}
+}
@@ -0,0 +1,183 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportQueueSize    = 4096
+	exportBatchSize    = 512
+	exportInterval     = 5 * time.Second
+	exportTimeout      = 10 * time.Second
+	instrumentationLib = "one-api"
+)
+
+var (
+	exportEndpoint string
+	exportHeaders  = make(map[string]string)
+	serviceName    string
+	spanQueue      chan *Span
+	exportClient   = &http.Client{Timeout: exportTimeout}
+)
+
+// Init 读取 OTEL_* 环境变量，配置了导出地址时启用链路追踪
+func Init() {
+	endpoint := common.GetEnvOrDefaultString("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if endpoint == "" {
+		if base := common.GetEnvOrDefaultString("OTEL_EXPORTER_OTLP_ENDPOINT", ""); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return
+	}
+	exportEndpoint = endpoint
+	serviceName = common.GetEnvOrDefaultString("OTEL_SERVICE_NAME", "new-api")
+	// 格式：key1=value1,key2=value2
+	for _, pair := range strings.Split(common.GetEnvOrDefaultString("OTEL_EXPORTER_OTLP_HEADERS", ""), ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			exportHeaders[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if ratio, err := strconv.ParseFloat(common.GetEnvOrDefaultString("OTEL_TRACES_SAMPLER_ARG", "1"), 64); err == nil {
+		sampleRatio = ratio
+	}
+	spanQueue = make(chan *Span, exportQueueSize)
+	enabled = true
+	go exportLoop()
+	common.SysLog(fmt.Sprintf("tracing enabled, exporting to %s with sample ratio %g", exportEndpoint, sampleRatio))
+}
+
+func enqueueSpan(s *Span) {
+	select {
+	case spanQueue <- s:
+	default:
+		// 队列已满时丢弃，避免阻塞请求
+	}
+}
+
+func exportLoop() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	for {
+		select {
+		case s := <-spanQueue:
+			batch = append(batch, s)
+			if len(batch) < exportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := exportSpans(batch); err != nil {
+			common.SysLog("failed to export spans: " + err.Error())
+		}
+		batch = make([]*Span, 0, exportBatchSize)
+	}
+}
+
+func otlpAttributeValue(value any) map[string]any {
+	switch v := value.(type) {
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+func otlpAttributes(attributes []attribute) []map[string]any {
+	result := make([]map[string]any, 0, len(attributes))
+	for _, attr := range attributes {
+		result = append(result, map[string]any{"key": attr.key, "value": otlpAttributeValue(attr.value)})
+	}
+	return result
+}
+
+func (s *Span) toOTLP() map[string]any {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	span := map[string]any{
+		"traceId":           hex.EncodeToString(s.traceId[:]),
+		"spanId":            hex.EncodeToString(s.spanId[:]),
+		"name":              s.name,
+		"kind":              s.kind,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attributes),
+	}
+	if s.parentSpanId != [8]byte{} {
+		span["parentSpanId"] = hex.EncodeToString(s.parentSpanId[:])
+	}
+	if s.statusCode == statusCodeError {
+		span["status"] = map[string]any{"code": statusCodeError, "message": s.statusMsg}
+	} else {
+		span["status"] = map[string]any{"code": statusCodeOk}
+	}
+	return span
+}
+
+func exportSpans(spans []*Span) error {
+	otlpSpans := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, s.toOTLP())
+	}
+	payload := map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": otlpAttributes([]attribute{
+						{key: "service.name", value: serviceName},
+						{key: "service.version", value: common.Version},
+					}),
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]any{"name": instrumentationLib},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+	body, err := common.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, exportEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range exportHeaders {
+		req.Header.Set(key, value)
+	}
+	resp, err := exportClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 链路追踪：每个请求的各个处理阶段记录为一个 span，通过 OTLP/HTTP（JSON 编码）导出，
+// 未配置 OTEL_EXPORTER_OTLP_ENDPOINT 时不创建任何 span
+
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+const (
+	statusCodeOk    = 1
+	statusCodeError = 2
+)
+
+var (
+	enabled     bool
+	sampleRatio = 1.0
+)
+
+type attribute struct {
+	key   string
+	value any
+}
+
+// Span 一个处理阶段，所有方法都允许在 nil 上调用，未启用追踪时 StartSpan 返回 nil
+type Span struct {
+	traceId      [16]byte
+	spanId       [8]byte
+	parentSpanId [8]byte
+	sampled      bool
+	name         string
+	kind         int
+	start        time.Time
+	end          time.Time
+	attributes   []attribute
+	statusCode   int
+	statusMsg    string
+
+	lock   sync.Mutex
+	ended  bool
+	parent *Span
+	c      *gin.Context
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+func randomBytes(b []byte) {
+	_, _ = rand.Read(b)
+}
+
+func shouldSample() bool {
+	if sampleRatio >= 1 {
+		return true
+	}
+	if sampleRatio <= 0 {
+		return false
+	}
+	var b [8]byte
+	randomBytes(b[:])
+	var n uint64
+	for _, v := range b {
+		n = n<<8 | uint64(v)
+	}
+	return float64(n)/float64(math.MaxUint64) < sampleRatio
+}
+
+// parseTraceparent 解析 W3C traceparent：00-<trace-id>-<parent-id>-<flags>，
+// 各字段只允许小写十六进制，更高版本允许在末尾追加字段
+func parseTraceparent(value string) (traceId [16]byte, spanId [8]byte, sampled bool, ok bool) {
+	value = strings.TrimSpace(value)
+	if value != strings.ToLower(value) {
+		return
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return
+	}
+	if _, err := strconv.ParseUint(parts[0], 16, 8); err != nil || (parts[0] == "00" && len(parts) != 4) {
+		return
+	}
+	if _, err := hex.Decode(traceId[:], []byte(parts[1])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(spanId[:], []byte(parts[2])); err != nil {
+		return
+	}
+	if traceId == [16]byte{} || spanId == [8]byte{} {
+		return
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return
+	}
+	return traceId, spanId, flags&1 == 1, true
+}
+
+func currentSpan(c *gin.Context) *Span {
+	if c == nil {
+		return nil
+	}
+	span, _ := common.GetContextKeyType[*Span](c, constant.ContextKeyTraceSpan)
+	return span
+}
+
+// StartRootSpan 为请求创建根 span，请求头中携带 traceparent 时沿用上游的 trace
+func StartRootSpan(c *gin.Context, name string) *Span {
+	if !enabled {
+		return nil
+	}
+	span := &Span{name: name, kind: SpanKindServer, start: time.Now(), c: c}
+	if traceId, parentId, sampled, ok := parseTraceparent(c.GetHeader("traceparent")); ok {
+		span.traceId = traceId
+		span.parentSpanId = parentId
+		span.sampled = sampled
+	} else {
+		randomBytes(span.traceId[:])
+		span.sampled = shouldSample()
+	}
+	randomBytes(span.spanId[:])
+	common.SetContextKey(c, constant.ContextKeyTraceSpan, span)
+	return span
+}
+
+// StartSpan 在当前 span 下创建子 span，并将其设为当前 span，结束时恢复
+func StartSpan(c *gin.Context, name string) *Span {
+	return StartSpanWithKind(c, name, SpanKindInternal)
+}
+
+func StartSpanWithKind(c *gin.Context, name string, kind int) *Span {
+	parent := currentSpan(c)
+	if parent == nil {
+		return nil
+	}
+	span := &Span{
+		traceId:      parent.traceId,
+		parentSpanId: parent.spanId,
+		sampled:      parent.sampled,
+		name:         name,
+		kind:         kind,
+		start:        time.Now(),
+		parent:       parent,
+		c:            c,
+	}
+	randomBytes(span.spanId[:])
+	common.SetContextKey(c, constant.ContextKeyTraceSpan, span)
+	return span
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// SetError 标记 span 失败
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.statusCode = statusCodeError
+	s.statusMsg = message
+}
+
+// End 结束 span，可重复调用
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.lock.Unlock()
+	if s.c != nil && currentSpan(s.c) == s {
+		common.SetContextKey(s.c, constant.ContextKeyTraceSpan, s.parent)
+	}
+	if s.sampled {
+		enqueueSpan(s)
+	}
+}
+
+func (s *Span) TraceId() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceId[:])
+}
+
+func (s *Span) traceparent() string {
+	flags := 0
+	if s.sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(s.traceId[:]), hex.EncodeToString(s.spanId[:]), flags)
+}
+
+// GetTraceId 获取当前请求的 trace id，未启用追踪时返回空字符串
+func GetTraceId(c *gin.Context) string {
+	return currentSpan(c).TraceId()
+}
+
+// Inject 将当前 span 以 W3C traceparent 请求头传递给上游
+func Inject(c *gin.Context, header http.Header) {
+	span := currentSpan(c)
+	if span == nil {
+		return
+	}
+	header.Set("traceparent", span.traceparent())
+}
@@ -0,0 +1,215 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/constant"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testTraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanId  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantOk      bool
+		wantSampled bool
+	}{
+		{name: "sampled", value: "00-" + testTraceId + "-" + testSpanId + "-01", wantOk: true, wantSampled: true},
+		{name: "not sampled", value: "00-" + testTraceId + "-" + testSpanId + "-00", wantOk: true},
+		{name: "other flags", value: "00-" + testTraceId + "-" + testSpanId + "-03", wantOk: true, wantSampled: true},
+		{name: "surrounding spaces", value: " 00-" + testTraceId + "-" + testSpanId + "-01 ", wantOk: true, wantSampled: true},
+		{name: "future version with extra field", value: "cc-" + testTraceId + "-" + testSpanId + "-01-what", wantOk: true, wantSampled: true},
+		{name: "version 00 with extra field", value: "00-" + testTraceId + "-" + testSpanId + "-01-what"},
+		{name: "invalid version ff", value: "ff-" + testTraceId + "-" + testSpanId + "-01"},
+		{name: "non hex version", value: "zz-" + testTraceId + "-" + testSpanId + "-01"},
+		{name: "uppercase", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + testSpanId + "-01"},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-" + testSpanId + "-01"},
+		{name: "zero span id", value: "00-" + testTraceId + "-0000000000000000-01"},
+		{name: "short trace id", value: "00-4bf92f3577b34da6-" + testSpanId + "-01"},
+		{name: "non hex span id", value: "00-" + testTraceId + "-00f067aa0ba902bz-01"},
+		{name: "non hex flags", value: "00-" + testTraceId + "-" + testSpanId + "-0g"},
+		{name: "missing flags", value: "00-" + testTraceId + "-" + testSpanId},
+		{name: "empty", value: ""},
+	}
+	for _, tt := range tests {
+		traceId, spanId, sampled, ok := parseTraceparent(tt.value)
+		if ok != tt.wantOk || sampled != tt.wantSampled {
+			t.Errorf("%s: got ok=%v sampled=%v, want ok=%v sampled=%v", tt.name, ok, sampled, tt.wantOk, tt.wantSampled)
+			continue
+		}
+		if ok && (hex.EncodeToString(traceId[:]) != testTraceId || hex.EncodeToString(spanId[:]) != testSpanId) {
+			t.Errorf("%s: got trace %x span %x", tt.name, traceId, spanId)
+		}
+	}
+}
+
+func newTracingContext(traceparent string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if traceparent != "" {
+		c.Request.Header.Set("traceparent", traceparent)
+	}
+	return c
+}
+
+func TestSpanLifecycle(t *testing.T) {
+	enabled, sampleRatio = true, 1
+	spanQueue = make(chan *Span, 10)
+	defer func() { enabled, spanQueue = false, nil }()
+
+	c := newTracingContext("00-" + testTraceId + "-" + testSpanId + "-01")
+	root := StartRootSpan(c, "relay")
+	if root.TraceId() != testTraceId || hex.EncodeToString(root.parentSpanId[:]) != testSpanId || !root.sampled {
+		t.Fatalf("root span does not continue the incoming trace: %+v", root)
+	}
+	child := StartSpan(c, "relay_attempt")
+	if child.traceId != root.traceId || child.parentSpanId != root.spanId {
+		t.Errorf("child span is not linked to root")
+	}
+	if currentSpan(c) != child {
+		t.Errorf("child span is not the current span")
+	}
+	header := http.Header{}
+	Inject(c, header)
+	if want := "00-" + testTraceId + "-" + hex.EncodeToString(child.spanId[:]) + "-01"; header.Get("traceparent") != want {
+		t.Errorf("injected traceparent %q, want %q", header.Get("traceparent"), want)
+	}
+
+	child.End()
+	child.End()
+	if currentSpan(c) != root {
+		t.Errorf("ending child did not restore the root span")
+	}
+	root.End()
+	if len(spanQueue) != 2 {
+		t.Errorf("queued %d spans, want 2", len(spanQueue))
+	}
+
+	// 上游未采样时不导出
+	c = newTracingContext("00-" + testTraceId + "-" + testSpanId + "-00")
+	StartRootSpan(c, "relay").End()
+	if len(spanQueue) != 2 {
+		t.Errorf("unsampled span was queued")
+	}
+
+	// 未启用时所有方法在 nil 上调用
+	enabled = false
+	c = newTracingContext("")
+	if span := StartRootSpan(c, "relay"); span != nil {
+		t.Errorf("span created while tracing is disabled")
+	}
+	span := StartSpan(c, "child")
+	span.SetAttribute("key", "value")
+	span.SetError("error")
+	span.End()
+	if GetTraceId(c) != "" {
+		t.Errorf("trace id returned while tracing is disabled")
+	}
+	if _, ok := c.Get(string(constant.ContextKeyTraceSpan)); ok {
+		t.Errorf("span stored in context while tracing is disabled")
+	}
+}
+
+func TestExportSpans(t *testing.T) {
+	var body []byte
+	var authorization string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	exportEndpoint = server.URL
+	exportHeaders = map[string]string{"Authorization": "Bearer test"}
+	serviceName = "new-api-test"
+
+	_, parentId, _, _ := parseTraceparent("00-" + testTraceId + "-" + testSpanId + "-01")
+	span := &Span{name: "upstream_request", kind: SpanKindClient, parentSpanId: parentId, sampled: true}
+	span.traceId, span.spanId, _, _ = parseTraceparent("00-" + testTraceId + "-" + testSpanId + "-01")
+	span.SetAttribute("channel.id", 3)
+	span.SetAttribute("prompt_tokens", int64(12))
+	span.SetAttribute("stream", true)
+	span.SetAttribute("ratio", 0.5)
+	span.SetAttribute("model", "gpt-4o")
+	span.SetAttribute("model", "gpt-4o-mini")
+	span.SetAttribute("err", errors.New("boom"))
+	span.SetError("upstream error")
+
+	if err := exportSpans([]*Span{span}); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer test" {
+		t.Errorf("export headers not sent, got %q", authorization)
+	}
+
+	var payload struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]any `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				Spans []map[string]any `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid OTLP payload: %v\n%s", err, body)
+	}
+	if len(payload.ResourceSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("unexpected OTLP structure: %s", body)
+	}
+	serviceAttr := payload.ResourceSpans[0].Resource.Attributes[0]
+	if serviceAttr["key"] != "service.name" || serviceAttr["value"].(map[string]any)["stringValue"] != "new-api-test" {
+		t.Errorf("unexpected resource attribute: %v", serviceAttr)
+	}
+	if payload.ResourceSpans[0].ScopeSpans[0].Scope.Name != instrumentationLib {
+		t.Errorf("unexpected scope: %s", payload.ResourceSpans[0].ScopeSpans[0].Scope.Name)
+	}
+
+	otlpSpan := payload.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if otlpSpan["traceId"] != testTraceId || otlpSpan["parentSpanId"] != testSpanId || otlpSpan["kind"] != float64(SpanKindClient) {
+		t.Errorf("unexpected span ids or kind: %v", otlpSpan)
+	}
+	if status := otlpSpan["status"].(map[string]any); status["code"] != float64(statusCodeError) || status["message"] != "upstream error" {
+		t.Errorf("unexpected status: %v", status)
+	}
+	// OTLP/JSON 中 64 位整数编码为字符串，重复设置的属性只保留最后一次
+	wantAttributes := []string{
+		`{"key":"channel.id","value":{"intValue":"3"}}`,
+		`{"key":"prompt_tokens","value":{"intValue":"12"}}`,
+		`{"key":"stream","value":{"boolValue":true}}`,
+		`{"key":"ratio","value":{"doubleValue":0.5}}`,
+		`{"key":"model","value":{"stringValue":"gpt-4o-mini"}}`,
+		`{"key":"err","value":{"stringValue":"boom"}}`,
+	}
+	attributes := otlpSpan["attributes"].([]any)
+	if len(attributes) != len(wantAttributes) {
+		t.Fatalf("got %d attributes, want %d", len(attributes), len(wantAttributes))
+	}
+	for i, attr := range attributes {
+		if got, _ := json.Marshal(attr); string(got) != wantAttributes[i] {
+			t.Errorf("attribute %d: got %s, want %s", i, got, wantAttributes[i])
+		}
+	}
+
+	status = http.StatusBadRequest
+	if err := exportSpans([]*Span{span}); err == nil {
+		t.Errorf("expected error for non-2xx response")
+	}
+}
@@ -17,6 +17,13 @@ const (
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache_enabled"
+	ContextKeyTokenPayloadCapture    ContextKey = "token_payload_capture_enabled"
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
+	ContextKeyTokenLimits            ContextKey = "token_limits"
+	ContextKeyTokenCallbackUrl       ContextKey = "token_callback_url"
+
+	ContextKeyTraceSpan ContextKey = "trace_span"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -47,4 +54,24 @@ const (
 	ContextKeyUserName    ContextKey = "username"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
+
+	/* batch related keys */
+	ContextKeyBatchId            ContextKey = "batch_id"
+	ContextKeyBatchDiscountRatio ContextKey = "batch_discount_ratio"
+
+	/* routing related keys */
+	ContextKeyRoutingStrategy ContextKey = "routing_strategy"
+	ContextKeyRoutingReason   ContextKey = "routing_reason"
+
+	ContextKeyChannelBreakerProbes ContextKey = "channel_breaker_probes"
+	// 不记录渠道结果的请求（如 token 计数）不占用熔断探测名额
+	ContextKeyChannelBreakerSkipProbe ContextKey = "channel_breaker_skip_probe"
+
+	/* response cache related keys */
+	ContextKeyResponseCacheKey   ContextKey = "response_cache_key"
+	ContextKeyResponseCacheHit   ContextKey = "response_cache_hit"
+	ContextKeyResponseCacheRatio ContextKey = "response_cache_ratio"
+
+	/* payload capture related keys */
+	ContextKeyPayloadCaptured ContextKey = "payload_captured"
 )
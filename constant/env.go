@@ -13,3 +13,12 @@ var NotifyLimitCount int
 var NotificationLimitDurationMinute int
 var GenerateDefaultToken bool
 var ErrorLogEnabled bool
+var FileStorageDir string
+var AssetStorageDir string
+var AssetS3Endpoint string
+var AssetS3Region string
+var AssetS3Bucket string
+var AssetS3AccessKey string
+var AssetS3SecretKey string
+var MetricsEnabled bool
+var MetricsToken string
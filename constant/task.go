@@ -5,6 +5,8 @@ type TaskPlatform string
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+	// 通过 /v1/tasks 提交、在后台执行的中继请求
+	TaskPlatformAsync TaskPlatform = "async"
 )
 
 const (
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type assetItem struct {
+	*model.Asset
+	Url string `json:"url"`
+}
+
+type assetPageInfo struct {
+	*common.PageInfo
+	// 用户全部资源占用的存储大小（字节）
+	TotalSize int64 `json:"total_size"`
+}
+
+// GetAssetContent 通过签名链接访问转存的资源 GET /asset/:id?expires=&signature=
+func GetAssetContent(c *gin.Context) {
+	assetId := c.Param("id")
+	if err := service.VerifyAssetSignature(assetId, c.Query("expires"), c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	asset, err := model.GetAssetByAssetId(assetId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "asset_not_found",
+		})
+		return
+	}
+	reader, err := service.GetAssetStorage().Open(asset.AssetId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "open_asset_failed",
+		})
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, reader, map[string]string{
+		"Cache-Control": "private, max-age=3600",
+	})
+}
+
+func getAssetPage(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	assets, total, err := model.GetAssets(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	totalSize, err := model.SumAssetSize(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]assetItem, 0, len(assets))
+	for _, asset := range assets {
+		items = append(items, assetItem{Asset: asset, Url: service.GetAssetSignedUrl(asset)})
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, assetPageInfo{PageInfo: pageInfo, TotalSize: totalSize})
+}
+
+// GetUserAssets 获取自己的转存资源与存储占用
+func GetUserAssets(c *gin.Context) {
+	getAssetPage(c, c.GetInt("id"))
+}
+
+// GetAllAssets 管理员查询转存资源，可按 user_id 过滤
+func GetAllAssets(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getAssetPage(c, userId)
+}
@@ -0,0 +1,388 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// asyncTaskEndpoints 支持通过 /v1/tasks 在后台执行的端点及对应的中继格式
+var asyncTaskEndpoints = map[string]types.RelayFormat{
+	"/v1/chat/completions":   types.RelayFormatOpenAI,
+	"/v1/completions":        types.RelayFormatOpenAI,
+	"/v1/responses":          types.RelayFormatOpenAIResponses,
+	"/v1/messages":           types.RelayFormatClaude,
+	"/v1/embeddings":         types.RelayFormatEmbedding,
+	"/v1/images/generations": types.RelayFormatOpenAIImage,
+	"/v1/audio/speech":       types.RelayFormatOpenAIAudio,
+	"/v1/rerank":             types.RelayFormatRerank,
+	"/v1/moderations":        types.RelayFormatOpenAI,
+}
+
+// asyncTaskCancelReason 用户取消任务时记录的失败原因
+const asyncTaskCancelReason = "cancelled"
+
+type asyncTaskCreateRequest struct {
+	Endpoint string          `json:"endpoint"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// asyncTaskResult 非 JSON 输出保存为资源后，任务 data 中记录的内容
+type asyncTaskResult struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+func asyncTaskError(c *gin.Context, statusCode int, code string, err error) {
+	c.JSON(statusCode, service.TaskErrorWrapperLocal(err, code, statusCode))
+}
+
+func respondTask(c *gin.Context, task *model.Task) {
+	c.JSON(http.StatusOK, dto.TaskResponse[any]{
+		Code: dto.TaskSuccessCode,
+		Data: relay.TaskModel2Dto(task),
+	})
+}
+
+// CreateAsyncTask 将中继请求提交为后台任务 POST /v1/tasks
+func CreateAsyncTask(c *gin.Context) {
+	if !operation_setting.GetAsyncTaskSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	var req asyncTaskCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		asyncTaskError(c, http.StatusBadRequest, "invalid_request", err)
+		return
+	}
+	if _, ok := asyncTaskEndpoints[req.Endpoint]; !ok {
+		asyncTaskError(c, http.StatusBadRequest, "invalid_endpoint", fmt.Errorf("unsupported endpoint: %s", req.Endpoint))
+		return
+	}
+	// 后台任务不支持流式输出
+	var body map[string]any
+	if err := common.Unmarshal(req.Body, &body); err != nil || body == nil {
+		asyncTaskError(c, http.StatusBadRequest, "invalid_request", errors.New("body must be a json object"))
+		return
+	}
+	delete(body, "stream")
+	delete(body, "stream_options")
+	requestBody, err := common.Marshal(body)
+	if err != nil {
+		asyncTaskError(c, http.StatusBadRequest, "invalid_request", err)
+		return
+	}
+	callbackUrl, err := service.GetTaskCallbackUrl(c)
+	if err != nil {
+		asyncTaskError(c, http.StatusBadRequest, "invalid_callback_url", err)
+		return
+	}
+
+	task := &model.Task{
+		TaskID:         "task_" + common.GetRandomString(24),
+		Platform:       constant.TaskPlatformAsync,
+		UserId:         c.GetInt("id"),
+		TokenId:        c.GetInt("token_id"),
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
+		Action:         req.Endpoint,
+		Status:         model.TaskStatusSubmitted,
+		Progress:       "0%",
+		SubmitTime:     common.GetTimestamp(),
+		CallbackUrl:    callbackUrl,
+		Properties:     model.Properties{Input: string(requestBody)},
+	}
+	if err = task.Insert(); err != nil {
+		asyncTaskError(c, http.StatusInternalServerError, "insert_task_failed", err)
+		return
+	}
+	respondTask(c, task)
+}
+
+// GetAsyncTask 查询当前用户的任务 GET /v1/tasks/:id，视频、音乐等平台任务同样可查
+func GetAsyncTask(c *gin.Context) {
+	taskId := c.Param("id")
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), taskId)
+	if err != nil {
+		asyncTaskError(c, http.StatusInternalServerError, "get_task_failed", err)
+		return
+	}
+	if !exist {
+		asyncTaskError(c, http.StatusNotFound, "task_not_exist", fmt.Errorf("task not found: %s", taskId))
+		return
+	}
+	respondTask(c, task)
+}
+
+// CancelAsyncTask 取消未完成的后台任务 DELETE /v1/tasks/:id。
+// 执行中的请求会被中断，但已发往上游的请求无法撤回，取消前已完成并计费的结果仍会保存，任务转为成功
+func CancelAsyncTask(c *gin.Context) {
+	taskId := c.Param("id")
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), taskId)
+	if err != nil {
+		asyncTaskError(c, http.StatusInternalServerError, "get_task_failed", err)
+		return
+	}
+	if !exist {
+		asyncTaskError(c, http.StatusNotFound, "task_not_exist", fmt.Errorf("task not found: %s", taskId))
+		return
+	}
+	if task.Platform != constant.TaskPlatformAsync {
+		asyncTaskError(c, http.StatusBadRequest, "task_not_cancellable", fmt.Errorf("tasks of platform %s cannot be cancelled", task.Platform))
+		return
+	}
+	now := common.GetTimestamp()
+	ok, err := model.TaskUpdateUnlessStatus(task.ID, []string{model.TaskStatusSuccess, model.TaskStatusFailure}, map[string]any{
+		"status":      model.TaskStatusFailure,
+		"progress":    "100%",
+		"fail_reason": asyncTaskCancelReason,
+		"finish_time": now,
+	})
+	if err != nil {
+		asyncTaskError(c, http.StatusInternalServerError, "cancel_task_failed", err)
+		return
+	}
+	if !ok {
+		asyncTaskError(c, http.StatusConflict, "task_not_cancellable", fmt.Errorf("cannot cancel a task with status %s", task.Status))
+		return
+	}
+	task.Status = model.TaskStatusFailure
+	task.Progress = "100%"
+	task.FailReason = asyncTaskCancelReason
+	task.FinishTime = now
+	service.EnqueueTaskWebhook(task)
+	respondTask(c, task)
+}
+
+// ---------------- 异步任务执行器 ----------------
+
+var (
+	asyncTaskRelayEngine     *gin.Engine
+	asyncTaskRelayEngineOnce sync.Once
+
+	runningAsyncTasks     = make(map[int64]bool)
+	runningAsyncTasksLock sync.Mutex
+
+	// serveAsyncTaskRequest 执行任务中的中继请求，测试中替换
+	serveAsyncTaskRequest = func(w http.ResponseWriter, req *http.Request) {
+		getAsyncTaskRelayEngine().ServeHTTP(w, req)
+	}
+)
+
+// getAsyncTaskRelayEngine 与批处理相同，使用独立的 gin 引擎执行请求，复用令牌鉴权、限流、渠道选择、重试与计费逻辑
+func getAsyncTaskRelayEngine() *gin.Engine {
+	asyncTaskRelayEngineOnce.Do(func() {
+		engine := gin.New()
+		engine.Use(middleware.RequestId(), middleware.TokenAuth(), middleware.ModelRequestRateLimit(), middleware.TokenRateLimit(), middleware.Distribute())
+		for endpoint, relayFormat := range asyncTaskEndpoints {
+			format := relayFormat
+			engine.POST(endpoint, func(c *gin.Context) {
+				Relay(c, format)
+			})
+		}
+		asyncTaskRelayEngine = engine
+	})
+	return asyncTaskRelayEngine
+}
+
+// failInterruptedAsyncTasks 任务只在主节点执行，启动时仍处于执行中的任务已随上次进程退出而中断。
+// 中断时请求可能已发往上游并预扣费，重新执行可能重复计费，因此标记为失败由用户重新提交
+func failInterruptedAsyncTasks() {
+	var lastId int64
+	for {
+		tasks, err := model.GetInProgressAsyncTasks(100)
+		if err != nil {
+			common.SysError("failed to get interrupted async tasks: " + err.Error())
+			return
+		}
+		// 更新失败的任务会被再次查出，避免死循环
+		if len(tasks) == 0 || tasks[0].ID == lastId {
+			return
+		}
+		lastId = tasks[0].ID
+		for _, task := range tasks {
+			finishAsyncTask(task, model.TaskStatusFailure, "task interrupted by server restart, please resubmit", nil)
+		}
+	}
+}
+
+// RunAsyncTasks 轮询并执行等待中的 /v1/tasks 任务
+func RunAsyncTasks() {
+	failInterruptedAsyncTasks()
+	for {
+		time.Sleep(time.Duration(2) * time.Second)
+		asyncTaskSetting := operation_setting.GetAsyncTaskSetting()
+		if !asyncTaskSetting.Enabled {
+			continue
+		}
+		tasks, err := model.GetPendingAsyncTasks(100)
+		if err != nil {
+			common.SysError("failed to get pending async tasks: " + err.Error())
+			continue
+		}
+		for _, task := range tasks {
+			if !acquireAsyncTask(task.ID, asyncTaskSetting.MaxConcurrentTasks) {
+				continue
+			}
+			t := task
+			gopool.Go(func() {
+				defer releaseAsyncTask(t.ID)
+				processAsyncTask(t)
+			})
+		}
+	}
+}
+
+func acquireAsyncTask(id int64, maxConcurrent int) bool {
+	runningAsyncTasksLock.Lock()
+	defer runningAsyncTasksLock.Unlock()
+	if runningAsyncTasks[id] {
+		return false
+	}
+	if maxConcurrent > 0 && len(runningAsyncTasks) >= maxConcurrent {
+		return false
+	}
+	runningAsyncTasks[id] = true
+	return true
+}
+
+func releaseAsyncTask(id int64) {
+	runningAsyncTasksLock.Lock()
+	defer runningAsyncTasksLock.Unlock()
+	delete(runningAsyncTasks, id)
+}
+
+// watchAsyncTaskCancel 任务被取消（标记为失败）时中断正在执行的请求
+func watchAsyncTaskCancel(ctx context.Context, taskId int64, cancel context.CancelFunc) {
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if task, err := model.GetTaskById(taskId); err == nil && task.Status == model.TaskStatusFailure {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func processAsyncTask(task *model.Task) {
+	now := common.GetTimestamp()
+	ok, err := model.TaskUpdateUnlessStatus(task.ID, []string{model.TaskStatusInProgress, model.TaskStatusSuccess, model.TaskStatusFailure}, map[string]any{
+		"status":     model.TaskStatusInProgress,
+		"progress":   "30%",
+		"start_time": now,
+	})
+	if err != nil || !ok {
+		return
+	}
+	task.Status = model.TaskStatusInProgress
+	task.Progress = "30%"
+	task.StartTime = now
+	service.EnqueueTaskWebhook(task)
+
+	token, err := model.GetTokenById(task.TokenId)
+	if err != nil {
+		finishAsyncTask(task, model.TaskStatusFailure, "token not found", nil)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gopool.Go(func() {
+		watchAsyncTaskCancel(ctx, task.ID, cancel)
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.Action, strings.NewReader(task.Properties.Input))
+	if err != nil {
+		finishAsyncTask(task, model.TaskStatusFailure, err.Error(), nil)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+
+	recorder := httptest.NewRecorder()
+	serveAsyncTaskRequest(recorder, req)
+
+	responseBody := recorder.Body.Bytes()
+	isJson := json.Valid(responseBody)
+	if recorder.Code != http.StatusOK {
+		reason := fmt.Sprintf("status code %d", recorder.Code)
+		if message := gjson.GetBytes(responseBody, "error.message").String(); isJson && message != "" {
+			reason = message
+		} else if message = gjson.GetBytes(responseBody, "message").String(); isJson && message != "" {
+			reason = message
+		}
+		if !isJson {
+			responseBody = nil
+		}
+		finishAsyncTask(task, model.TaskStatusFailure, reason, responseBody)
+		return
+	}
+	if isJson {
+		finishAsyncTask(task, model.TaskStatusSuccess, "", responseBody)
+		return
+	}
+	// 音频等二进制输出保存为资源，通过签名链接获取
+	asset, err := service.SaveTaskOutputAsset(task, recorder.Header().Get("Content-Type"), responseBody)
+	if err != nil {
+		finishAsyncTask(task, model.TaskStatusFailure, "failed to save task output: "+err.Error(), nil)
+		return
+	}
+	data, _ := common.Marshal(asyncTaskResult{ContentType: asset.ContentType, Size: asset.Size})
+	finishAsyncTask(task, model.TaskStatusSuccess, "", data)
+}
+
+// finishAsyncTask 保存任务结果。任务已结束时不覆盖，但执行期间被取消、请求仍成功完成时已经计费，
+// 此时保存结果并转为成功，避免用户付费却拿不到结果
+func finishAsyncTask(task *model.Task, status string, reason string, data []byte) {
+	now := common.GetTimestamp()
+	params := map[string]any{
+		"status":      status,
+		"progress":    "100%",
+		"fail_reason": reason,
+		"finish_time": now,
+	}
+	if data != nil {
+		params["data"] = json.RawMessage(data)
+	}
+	ok, err := model.TaskUpdateUnlessStatus(task.ID, []string{model.TaskStatusSuccess, model.TaskStatusFailure}, params)
+	if err == nil && !ok && status == model.TaskStatusSuccess {
+		ok, err = model.TaskUpdateIfFailed(task.ID, asyncTaskCancelReason, params)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to update async task %s: %s", task.TaskID, err.Error()))
+		return
+	}
+	if !ok {
+		return
+	}
+	task.Status = model.TaskStatus(status)
+	task.Progress = "100%"
+	task.FailReason = reason
+	task.FinishTime = now
+	if data != nil {
+		task.Data = data
+	}
+	service.EnqueueTaskWebhook(task)
+}
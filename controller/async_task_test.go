@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestAsyncTask 创建令牌与指定状态的后台任务
+func newTestAsyncTask(t *testing.T, userId int, status model.TaskStatus) *model.Task {
+	t.Helper()
+	token := &model.Token{UserId: userId, Key: common.GetRandomString(48), Name: t.Name()}
+	if err := model.DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Task{
+		TaskID:     "task_" + common.GetRandomString(24),
+		Platform:   constant.TaskPlatformAsync,
+		UserId:     userId,
+		TokenId:    token.Id,
+		Action:     "/v1/chat/completions",
+		Status:     status,
+		Progress:   "0%",
+		SubmitTime: common.GetTimestamp(),
+		Properties: model.Properties{Input: `{"model":"gpt-4o"}`},
+	}
+	if err := task.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// stubAsyncTaskRequest 替换中继执行，before 在返回响应前调用
+func stubAsyncTaskRequest(t *testing.T, status int, body string, before func(req *http.Request)) {
+	t.Helper()
+	original := serveAsyncTaskRequest
+	t.Cleanup(func() { serveAsyncTaskRequest = original })
+	serveAsyncTaskRequest = func(w http.ResponseWriter, req *http.Request) {
+		if before != nil {
+			before(req)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+func cancelTestAsyncTask(t *testing.T, task *model.Task) int {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("id", task.UserId)
+	})
+	router.DELETE("/v1/tasks/:id", CancelAsyncTask)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/v1/tasks/"+task.TaskID, nil))
+	return w.Code
+}
+
+func TestProcessAsyncTask(t *testing.T) {
+	const userId = 4001
+	tests := []struct {
+		name           string
+		initialStatus  model.TaskStatus
+		status         int
+		body           string
+		cancelInFlight bool
+		wantRequest    bool
+		wantStatus     model.TaskStatus
+		wantReason     string
+		wantData       string
+	}{
+		{name: "success", initialStatus: model.TaskStatusSubmitted, status: http.StatusOK, body: `{"id":"chatcmpl-1"}`, wantRequest: true, wantStatus: model.TaskStatusSuccess, wantData: `{"id":"chatcmpl-1"}`},
+		{name: "upstream error", initialStatus: model.TaskStatusSubmitted, status: http.StatusBadRequest, body: `{"error":{"message":"bad model"}}`, wantRequest: true, wantStatus: model.TaskStatusFailure, wantReason: "bad model", wantData: `{"error":{"message":"bad model"}}`},
+		{name: "non-json error", initialStatus: model.TaskStatusSubmitted, status: http.StatusBadGateway, body: "bad gateway", wantRequest: true, wantStatus: model.TaskStatusFailure, wantReason: "status code 502"},
+		// 执行前已取消的任务不会发起请求
+		{name: "cancelled before dispatch", initialStatus: model.TaskStatusFailure, status: http.StatusOK, body: `{}`, wantStatus: model.TaskStatusFailure},
+		// 执行期间取消，请求仍成功完成时已计费，保存结果
+		{name: "cancelled after billing", initialStatus: model.TaskStatusSubmitted, status: http.StatusOK, body: `{"id":"chatcmpl-2"}`, cancelInFlight: true, wantRequest: true, wantStatus: model.TaskStatusSuccess, wantData: `{"id":"chatcmpl-2"}`},
+		{name: "cancelled and interrupted", initialStatus: model.TaskStatusSubmitted, status: http.StatusInternalServerError, body: `{"error":{"message":"context canceled"}}`, cancelInFlight: true, wantRequest: true, wantStatus: model.TaskStatusFailure, wantReason: asyncTaskCancelReason},
+	}
+	for _, tt := range tests {
+		task := newTestAsyncTask(t, userId, tt.initialStatus)
+		requested := false
+		stubAsyncTaskRequest(t, tt.status, tt.body, func(req *http.Request) {
+			requested = true
+			if req.URL.Path != task.Action || req.Header.Get("Authorization") == "" {
+				t.Errorf("%s: unexpected request %s %v", tt.name, req.URL.Path, req.Header)
+			}
+			if tt.cancelInFlight {
+				if code := cancelTestAsyncTask(t, task); code != http.StatusOK {
+					t.Errorf("%s: cancel in flight: %d", tt.name, code)
+				}
+			}
+		})
+		processAsyncTask(task)
+
+		if requested != tt.wantRequest {
+			t.Errorf("%s: requested %v, want %v", tt.name, requested, tt.wantRequest)
+		}
+		result, err := model.GetTaskById(task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != tt.wantStatus || result.FailReason != tt.wantReason || string(result.Data) != tt.wantData {
+			t.Errorf("%s: status %s reason %q data %s", tt.name, result.Status, result.FailReason, result.Data)
+		}
+	}
+
+	// 已结束的任务不能取消
+	task := newTestAsyncTask(t, userId, model.TaskStatusSuccess)
+	if code := cancelTestAsyncTask(t, task); code != http.StatusConflict {
+		t.Errorf("cancel finished task: %d", code)
+	}
+}
+
+func TestFailInterruptedAsyncTasks(t *testing.T) {
+	const userId = 4002
+	interrupted := newTestAsyncTask(t, userId, model.TaskStatusInProgress)
+	pending := newTestAsyncTask(t, userId, model.TaskStatusSubmitted)
+
+	failInterruptedAsyncTasks()
+
+	if result, _ := model.GetTaskById(interrupted.ID); result.Status != model.TaskStatusFailure || result.FinishTime == 0 {
+		t.Errorf("interrupted task: status %s finish_time %d", result.Status, result.FinishTime)
+	}
+	if result, _ := model.GetTaskById(pending.ID); result.Status != model.TaskStatusSubmitted {
+		t.Errorf("pending task: status %s", result.Status)
+	}
+}
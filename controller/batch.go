@@ -0,0 +1,678 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const batchCompletionWindow = "24h"
+
+// batchEndpoints 支持的批处理端点及对应的中继格式
+var batchEndpoints = map[string]types.RelayFormat{
+	"/v1/chat/completions": types.RelayFormatOpenAI,
+	"/v1/completions":      types.RelayFormatOpenAI,
+	"/v1/embeddings":       types.RelayFormatEmbedding,
+}
+
+func batchError(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+func optionalTimestamp(t int64) *int64 {
+	if t == 0 {
+		return nil
+	}
+	return &t
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func toOpenAIBatchObject(batch *model.Batch) dto.OpenAIBatchObject {
+	obj := dto.OpenAIBatchObject{
+		Id:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		OutputFileId:     optionalString(batch.OutputFileId),
+		ErrorFileId:      optionalString(batch.ErrorFileId),
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optionalTimestamp(batch.InProgressAt),
+		ExpiresAt:        optionalTimestamp(batch.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(batch.FinalizingAt),
+		CompletedAt:      optionalTimestamp(batch.CompletedAt),
+		FailedAt:         optionalTimestamp(batch.FailedAt),
+		ExpiredAt:        optionalTimestamp(batch.ExpiredAt),
+		CancellingAt:     optionalTimestamp(batch.CancellingAt),
+		CancelledAt:      optionalTimestamp(batch.CancelledAt),
+		RequestCounts: dto.BatchRequestCounts{
+			Total:     batch.TotalCount,
+			Completed: batch.CompletedCount,
+			Failed:    batch.FailedCount,
+		},
+		Metadata: batch.Metadata,
+	}
+	if batch.FailReason != "" {
+		obj.Errors = &dto.BatchErrors{
+			Object: "list",
+			Data: []dto.BatchError{
+				{Code: "batch_failed", Message: batch.FailReason},
+			},
+		}
+	}
+	return obj
+}
+
+// getUserBatch 获取当前用户的批处理任务，不存在时直接返回 404
+func getUserBatch(c *gin.Context) *model.Batch {
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatchByBatchId(c.GetInt("id"), batchId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			batchError(c, http.StatusNotFound, "batch_not_found", fmt.Sprintf("No such Batch object: %s", batchId))
+		} else {
+			batchError(c, http.StatusInternalServerError, "get_batch_failed", err.Error())
+		}
+		return nil
+	}
+	return batch
+}
+
+// CreateBatch 创建批处理任务 POST /v1/batches
+func CreateBatch(c *gin.Context) {
+	if !operation_setting.GetBatchSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	var req dto.BatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		batchError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if _, ok := batchEndpoints[req.Endpoint]; !ok {
+		batchError(c, http.StatusBadRequest, "invalid_endpoint", fmt.Sprintf("Unsupported endpoint: %s", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		batchError(c, http.StatusBadRequest, "invalid_completion_window", "completion_window must be 24h")
+		return
+	}
+	userId := c.GetInt("id")
+	inputFile, err := model.GetUserFileByFileId(userId, req.InputFileId)
+	if err != nil {
+		batchError(c, http.StatusBadRequest, "invalid_input_file", fmt.Sprintf("No such File object: %s", req.InputFileId))
+		return
+	}
+	if inputFile.Purpose != "batch" {
+		batchError(c, http.StatusBadRequest, "invalid_input_file", "input file must be uploaded with purpose 'batch'")
+		return
+	}
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          "batch_" + common.GetRandomString(24),
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		Endpoint:         req.Endpoint,
+		InputFileId:      req.InputFileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           model.BatchStatusValidating,
+		Metadata:         req.Metadata,
+		CreatedAt:        now,
+		ExpiresAt:        now + 24*60*60,
+	}
+	if err = batch.Insert(); err != nil {
+		batchError(c, http.StatusInternalServerError, "create_batch_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIBatchObject(batch))
+}
+
+// ListBatches 列出当前用户的批处理任务 GET /v1/batches
+func ListBatches(c *gin.Context) {
+	userId := c.GetInt("id")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	afterId := 0
+	if after := c.Query("after"); after != "" {
+		batch, err := model.GetUserBatchByBatchId(userId, after)
+		if err != nil {
+			batchError(c, http.StatusNotFound, "batch_not_found", fmt.Sprintf("No such Batch object: %s", after))
+			return
+		}
+		afterId = batch.Id
+	}
+	batches, err := model.GetUserBatches(userId, afterId, limit+1)
+	if err != nil {
+		batchError(c, http.StatusInternalServerError, "list_batches_failed", err.Error())
+		return
+	}
+	resp := dto.OpenAIBatchListResponse{
+		Object: "list",
+		Data:   make([]dto.OpenAIBatchObject, 0, len(batches)),
+	}
+	if len(batches) > limit {
+		batches = batches[:limit]
+		resp.HasMore = true
+	}
+	for _, batch := range batches {
+		resp.Data = append(resp.Data, toOpenAIBatchObject(batch))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetrieveBatch 获取批处理任务 GET /v1/batches/:id
+func RetrieveBatch(c *gin.Context) {
+	batch := getUserBatch(c)
+	if batch == nil {
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIBatchObject(batch))
+}
+
+// CancelBatch 取消批处理任务 POST /v1/batches/:id/cancel
+func CancelBatch(c *gin.Context) {
+	batch := getUserBatch(c)
+	if batch == nil {
+		return
+	}
+	ok, err := model.CancelBatch(batch.Id)
+	if err != nil {
+		batchError(c, http.StatusInternalServerError, "cancel_batch_failed", err.Error())
+		return
+	}
+	if !ok {
+		batchError(c, http.StatusConflict, "batch_not_cancellable", fmt.Sprintf("Cannot cancel a batch with status '%s'", batch.Status))
+		return
+	}
+	batch, err = model.GetUserBatchByBatchId(batch.UserId, batch.BatchId)
+	if err != nil {
+		batchError(c, http.StatusInternalServerError, "get_batch_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIBatchObject(batch))
+}
+
+// ---------------- 批处理执行器 ----------------
+
+type batchContextKey struct{}
+
+var (
+	batchRelayEngine     *gin.Engine
+	batchRelayEngineOnce sync.Once
+
+	runningBatches     = make(map[int]bool)
+	runningBatchesLock sync.Mutex
+)
+
+// getBatchRelayEngine 批处理使用独立的 gin 引擎执行请求，复用令牌鉴权、限流、渠道选择、重试与计费逻辑
+func getBatchRelayEngine() *gin.Engine {
+	batchRelayEngineOnce.Do(func() {
+		engine := gin.New()
+		engine.Use(middleware.RequestId(), middleware.TokenAuth(), middleware.ModelRequestRateLimit(), middleware.TokenRateLimit(), setupBatchContext, middleware.Distribute())
+		for endpoint, relayFormat := range batchEndpoints {
+			format := relayFormat
+			engine.POST(endpoint, func(c *gin.Context) {
+				Relay(c, format)
+			})
+		}
+		batchRelayEngine = engine
+	})
+	return batchRelayEngine
+}
+
+func setupBatchContext(c *gin.Context) {
+	if batch, ok := c.Request.Context().Value(batchContextKey{}).(*model.Batch); ok {
+		common.SetContextKey(c, constant.ContextKeyBatchId, batch.BatchId)
+		common.SetContextKey(c, constant.ContextKeyBatchDiscountRatio, operation_setting.GetBatchSetting().DiscountRatio)
+	}
+	c.Next()
+}
+
+// RunBatchJobs 轮询并执行未完成的批处理任务，任务进度保存在数据库中，重启后继续执行
+func RunBatchJobs() {
+	for {
+		time.Sleep(time.Duration(5) * time.Second)
+		batchSetting := operation_setting.GetBatchSetting()
+		if !batchSetting.Enabled {
+			continue
+		}
+		batches, err := model.GetUnfinishedBatches(100)
+		if err != nil {
+			common.SysError("failed to get unfinished batches: " + err.Error())
+			continue
+		}
+		for _, batch := range batches {
+			if !acquireBatch(batch.Id, batchSetting.MaxConcurrentBatches) {
+				continue
+			}
+			b := batch
+			gopool.Go(func() {
+				defer releaseBatch(b.Id)
+				processBatch(b)
+			})
+		}
+	}
+}
+
+func acquireBatch(id int, maxConcurrent int) bool {
+	runningBatchesLock.Lock()
+	defer runningBatchesLock.Unlock()
+	if runningBatches[id] {
+		return false
+	}
+	if maxConcurrent > 0 && len(runningBatches) >= maxConcurrent {
+		return false
+	}
+	runningBatches[id] = true
+	return true
+}
+
+func releaseBatch(id int) {
+	runningBatchesLock.Lock()
+	defer runningBatchesLock.Unlock()
+	delete(runningBatches, id)
+}
+
+func batchOutputStorageName(batch *model.Batch) string {
+	return batch.BatchId + "_output.jsonl"
+}
+
+func batchErrorStorageName(batch *model.Batch) string {
+	return batch.BatchId + "_error.jsonl"
+}
+
+// batchLineStorageName 单行执行结果，在合并进输出文件前保存，重启后不会重复执行该行
+func batchLineStorageName(batch *model.Batch, index int) string {
+	return fmt.Sprintf("%s_line_%d.json", batch.BatchId, index)
+}
+
+type batchLineResult struct {
+	Success bool            `json:"success"`
+	Output  json.RawMessage `json:"output"`
+}
+
+func saveBatchLineResult(storage service.FileStorage, batch *model.Batch, index int, result *batchLineResult) error {
+	data, err := common.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Save(batchLineStorageName(batch, index), bytes.NewReader(data))
+	return err
+}
+
+func loadBatchLineResult(storage service.FileStorage, batch *model.Batch, index int) (*batchLineResult, bool) {
+	var buf bytes.Buffer
+	if err := readStorageInto(storage, batchLineStorageName(batch, index), &buf); err != nil {
+		return nil, false
+	}
+	var result batchLineResult
+	if err := common.Unmarshal(buf.Bytes(), &result); err != nil || len(result.Output) == 0 {
+		return nil, false
+	}
+	return &result, true
+}
+
+func splitBatchLines(content []byte) [][]byte {
+	lines := make([][]byte, 0)
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// batchActiveStatuses 执行器可以从这些状态转入终态
+var batchActiveStatuses = []string{model.BatchStatusValidating, model.BatchStatusInProgress, model.BatchStatusCancelling}
+
+func failBatch(batch *model.Batch, reason string) {
+	batch.Status = model.BatchStatusFailed
+	batch.FailReason = reason
+	batch.FailedAt = common.GetTimestamp()
+	_, err := model.BatchUpdateIfStatus(batch.Id, batchActiveStatuses, map[string]any{
+		"status":      batch.Status,
+		"fail_reason": batch.FailReason,
+		"failed_at":   batch.FailedAt,
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to update batch %s: %s", batch.BatchId, err.Error()))
+	}
+}
+
+// truncateBatchLines 只保留前 n 行
+func truncateBatchLines(buf *bytes.Buffer, n int) {
+	data := buf.Bytes()
+	offset := 0
+	for i := 0; i < n; i++ {
+		idx := bytes.IndexByte(data[offset:], '\n')
+		if idx < 0 {
+			return
+		}
+		offset += idx + 1
+	}
+	buf.Truncate(offset)
+}
+
+// validateBatchLines 校验输入文件，返回错误原因
+func validateBatchLines(batch *model.Batch, lines [][]byte) string {
+	if len(lines) == 0 {
+		return "input file is empty"
+	}
+	maxRequests := operation_setting.GetBatchSetting().MaxRequestsPerBatch
+	if maxRequests > 0 && len(lines) > maxRequests {
+		return fmt.Sprintf("input file contains %d requests, exceeds the limit of %d", len(lines), maxRequests)
+	}
+	customIds := make(map[string]bool, len(lines))
+	for i, line := range lines {
+		var input dto.BatchRequestInput
+		if err := common.Unmarshal(line, &input); err != nil {
+			return fmt.Sprintf("line %d: invalid json: %s", i+1, err.Error())
+		}
+		if input.CustomId == "" {
+			return fmt.Sprintf("line %d: custom_id is required", i+1)
+		}
+		if customIds[input.CustomId] {
+			return fmt.Sprintf("line %d: duplicate custom_id %s", i+1, input.CustomId)
+		}
+		customIds[input.CustomId] = true
+		if input.Method != http.MethodPost {
+			return fmt.Sprintf("line %d: method must be POST", i+1)
+		}
+		if input.Url != batch.Endpoint {
+			return fmt.Sprintf("line %d: url %s does not match batch endpoint %s", i+1, input.Url, batch.Endpoint)
+		}
+	}
+	return ""
+}
+
+func processBatch(batch *model.Batch) {
+	inputFile, err := model.GetUserFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		failBatch(batch, "input file not found")
+		return
+	}
+	content, err := service.ReadFileContent(inputFile)
+	if err != nil {
+		failBatch(batch, "failed to read input file: "+err.Error())
+		return
+	}
+	lines := splitBatchLines(content)
+
+	if batch.Status == model.BatchStatusValidating {
+		if reason := validateBatchLines(batch, lines); reason != "" {
+			failBatch(batch, reason)
+			return
+		}
+		batch.TotalCount = len(lines)
+		batch.InProgressAt = common.GetTimestamp()
+		ok, err := model.BatchUpdateIfStatus(batch.Id, []string{model.BatchStatusValidating}, map[string]any{
+			"status":         model.BatchStatusInProgress,
+			"total_count":    batch.TotalCount,
+			"in_progress_at": batch.InProgressAt,
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to update batch %s: %s", batch.BatchId, err.Error()))
+			return
+		}
+		if ok {
+			batch.Status = model.BatchStatusInProgress
+		} else {
+			// 校验期间任务被取消，下面的循环会直接结束并标记为已取消
+			if batch.Status, err = model.GetBatchStatus(batch.Id); err != nil || batch.Status != model.BatchStatusCancelling {
+				return
+			}
+		}
+	}
+
+	storage := service.GetFileStorage()
+	var outputBuf, errorBuf bytes.Buffer
+	// 从上次保存的进度恢复已有输出
+	if batch.Progress > 0 {
+		if err = readStorageInto(storage, batchOutputStorageName(batch), &outputBuf); err != nil {
+			failBatch(batch, "failed to restore batch output: "+err.Error())
+			return
+		}
+		if err = readStorageInto(storage, batchErrorStorageName(batch), &errorBuf); err != nil {
+			failBatch(batch, "failed to restore batch errors: "+err.Error())
+			return
+		}
+		// 输出文件先于进度保存，两次写入之间中断时文件中会多出进度之后的行，
+		// 按数据库中的计数截断，多出的行随后从单行结果重新合并
+		truncateBatchLines(&outputBuf, batch.CompletedCount)
+		truncateBatchLines(&errorBuf, batch.FailedCount)
+	}
+
+	// 每行执行后立即保存单行结果，每 20 行合并进输出文件并记录进度，避免每行重写整个输出文件
+	savedProgress := batch.Progress
+	saveProgress := func() error {
+		if _, err := storage.Save(batchOutputStorageName(batch), bytes.NewReader(outputBuf.Bytes())); err != nil {
+			return err
+		}
+		if _, err := storage.Save(batchErrorStorageName(batch), bytes.NewReader(errorBuf.Bytes())); err != nil {
+			return err
+		}
+		err := model.DB.Model(&model.Batch{}).Where("id = ?", batch.Id).Updates(map[string]any{
+			"progress":        batch.Progress,
+			"completed_count": batch.CompletedCount,
+			"failed_count":    batch.FailedCount,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for i := savedProgress; i < batch.Progress; i++ {
+			_ = storage.Delete(batchLineStorageName(batch, i))
+		}
+		savedProgress = batch.Progress
+		return nil
+	}
+
+	finalStatus := model.BatchStatusCompleted
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		failBatch(batch, "token not found")
+		return
+	}
+	for batch.Progress < len(lines) {
+		status, err := model.GetBatchStatus(batch.Id)
+		if err == nil && status == model.BatchStatusCancelling {
+			finalStatus = model.BatchStatusCancelled
+			break
+		}
+		if batch.ExpiresAt > 0 && common.GetTimestamp() > batch.ExpiresAt {
+			finalStatus = model.BatchStatusExpired
+			break
+		}
+		var outputLine []byte
+		var success bool
+		if saved, ok := loadBatchLineResult(storage, batch, batch.Progress); ok {
+			// 重启前已执行但尚未合并的行直接使用保存的结果
+			outputLine, success = saved.Output, saved.Success
+		} else {
+			var output *dto.BatchRequestOutput
+			output, success = executeBatchLine(batch, token.Key, lines[batch.Progress])
+			outputLine, _ = common.Marshal(output)
+			if err = saveBatchLineResult(storage, batch, batch.Progress, &batchLineResult{Success: success, Output: outputLine}); err != nil {
+				common.SysError(fmt.Sprintf("failed to save batch %s line %d result: %s", batch.BatchId, batch.Progress, err.Error()))
+			}
+		}
+		if success {
+			outputBuf.Write(outputLine)
+			outputBuf.WriteByte('\n')
+			batch.CompletedCount++
+		} else {
+			errorBuf.Write(outputLine)
+			errorBuf.WriteByte('\n')
+			batch.FailedCount++
+		}
+		batch.Progress++
+		if batch.Progress%20 == 0 {
+			if err = saveProgress(); err != nil {
+				common.SysError(fmt.Sprintf("failed to save batch %s progress: %s", batch.BatchId, err.Error()))
+			}
+		}
+	}
+	if batch.Status == model.BatchStatusCancelling {
+		finalStatus = model.BatchStatusCancelled
+	}
+	if err = saveProgress(); err != nil {
+		common.SysError(fmt.Sprintf("failed to save batch %s progress: %s", batch.BatchId, err.Error()))
+	}
+	finalizeBatch(batch, finalStatus, outputBuf.Len(), errorBuf.Len())
+}
+
+func readStorageInto(storage service.FileStorage, name string, buf *bytes.Buffer) error {
+	reader, err := storage.Open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = buf.ReadFrom(reader)
+	return err
+}
+
+// finalizeBatch 将输出内容登记为文件，并把任务更新为最终状态
+func finalizeBatch(batch *model.Batch, finalStatus string, outputSize int, errorSize int) {
+	now := common.GetTimestamp()
+	batch.Status = model.BatchStatusFinalizing
+	batch.FinalizingAt = now
+	createFile := func(storageName string, size int, suffix string) string {
+		if size == 0 {
+			_ = service.GetFileStorage().Delete(storageName)
+			return ""
+		}
+		file := &model.File{
+			FileId:      service.LocalFileIdPrefix + common.GetRandomString(24),
+			UserId:      batch.UserId,
+			TokenId:     batch.TokenId,
+			Filename:    batch.BatchId + suffix,
+			Purpose:     "batch_output",
+			MimeType:    "application/jsonl",
+			Bytes:       int64(size),
+			StoragePath: storageName,
+			Status:      model.FileStatusProcessed,
+		}
+		if err := file.Insert(); err != nil {
+			common.SysError(fmt.Sprintf("failed to create batch %s output file: %s", batch.BatchId, err.Error()))
+			return ""
+		}
+		return file.FileId
+	}
+	batch.OutputFileId = createFile(batchOutputStorageName(batch), outputSize, "_output.jsonl")
+	batch.ErrorFileId = createFile(batchErrorStorageName(batch), errorSize, "_error.jsonl")
+
+	batch.Status = finalStatus
+	params := map[string]any{
+		"status":          batch.Status,
+		"output_file_id":  batch.OutputFileId,
+		"error_file_id":   batch.ErrorFileId,
+		"total_count":     batch.TotalCount,
+		"completed_count": batch.CompletedCount,
+		"failed_count":    batch.FailedCount,
+		"progress":        batch.Progress,
+		"finalizing_at":   batch.FinalizingAt,
+	}
+	switch finalStatus {
+	case model.BatchStatusCompleted:
+		batch.CompletedAt = now
+		params["completed_at"] = now
+	case model.BatchStatusCancelled:
+		batch.CancelledAt = now
+		params["cancelled_at"] = now
+	case model.BatchStatusExpired:
+		batch.ExpiredAt = now
+		params["expired_at"] = now
+	}
+	if _, err := model.BatchUpdateIfStatus(batch.Id, batchActiveStatuses, params); err != nil {
+		common.SysError(fmt.Sprintf("failed to update batch %s: %s", batch.BatchId, err.Error()))
+	}
+	common.SysLog(fmt.Sprintf("batch %s finished with status %s, completed %d, failed %d", batch.BatchId, batch.Status, batch.CompletedCount, batch.FailedCount))
+}
+
+// executeBatchLine 通过中继流程执行一行请求，返回输出行及是否成功
+func executeBatchLine(batch *model.Batch, tokenKey string, line []byte) (*dto.BatchRequestOutput, bool) {
+	output := &dto.BatchRequestOutput{
+		Id: "batch_req_" + common.GetRandomString(24),
+	}
+	var input dto.BatchRequestInput
+	if err := common.Unmarshal(line, &input); err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_request", Message: err.Error()}
+		return output, false
+	}
+	output.CustomId = input.CustomId
+
+	// 批处理不支持流式输出
+	var body map[string]any
+	if err := common.Unmarshal(input.Body, &body); err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_request", Message: "body must be a json object"}
+		return output, false
+	}
+	delete(body, "stream")
+	delete(body, "stream_options")
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_request", Message: err.Error()}
+		return output, false
+	}
+
+	ctx := context.WithValue(context.Background(), batchContextKey{}, batch)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, input.Url, bytes.NewReader(requestBody))
+	if err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_request", Message: err.Error()}
+		return output, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+tokenKey)
+
+	recorder := httptest.NewRecorder()
+	getBatchRelayEngine().ServeHTTP(recorder, req)
+
+	output.Response = &dto.BatchResponse{
+		StatusCode: recorder.Code,
+		RequestId:  recorder.Header().Get(common.RequestIdKey),
+		Body:       json.RawMessage(recorder.Body.Bytes()),
+	}
+	if !json.Valid(output.Response.Body) {
+		output.Response.Body, _ = json.Marshal(recorder.Body.String())
+	}
+	return output, recorder.Code == http.StatusOK
+}
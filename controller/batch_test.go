@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strings"
+	"testing"
+)
+
+func TestValidateBatchLines(t *testing.T) {
+	batch := &model.Batch{Endpoint: "/v1/chat/completions"}
+	line := func(customId string, method string, url string) string {
+		return fmt.Sprintf(`{"custom_id":%q,"method":%q,"url":%q,"body":{"model":"gpt-4o"}}`, customId, method, url)
+	}
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{name: "valid", lines: []string{line("a", "POST", "/v1/chat/completions"), line("b", "POST", "/v1/chat/completions")}},
+		{name: "empty", want: "input file is empty"},
+		{name: "invalid json", lines: []string{line("a", "POST", "/v1/chat/completions"), "{"}, want: "line 2: invalid json"},
+		{name: "missing custom_id", lines: []string{line("", "POST", "/v1/chat/completions")}, want: "line 1: custom_id is required"},
+		{name: "duplicate custom_id", lines: []string{line("a", "POST", "/v1/chat/completions"), line("a", "POST", "/v1/chat/completions")}, want: "line 2: duplicate custom_id a"},
+		{name: "method", lines: []string{line("a", "GET", "/v1/chat/completions")}, want: "line 1: method must be POST"},
+		{name: "url", lines: []string{line("a", "POST", "/v1/embeddings")}, want: "line 1: url /v1/embeddings does not match batch endpoint /v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		got := validateBatchLines(batch, splitBatchLines([]byte(strings.Join(tt.lines, "\n"))))
+		if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateBatchLines(t *testing.T) {
+	tests := []struct {
+		content string
+		n       int
+		want    string
+	}{
+		{content: "a\nb\nc\n", n: 2, want: "a\nb\n"},
+		{content: "a\nb\n", n: 0, want: ""},
+		{content: "a\nb\n", n: 2, want: "a\nb\n"},
+		{content: "a\nb\n", n: 5, want: "a\nb\n"},
+	}
+	for _, tt := range tests {
+		buf := bytes.NewBufferString(tt.content)
+		truncateBatchLines(buf, tt.n)
+		if buf.String() != tt.want {
+			t.Errorf("truncate %q to %d lines: got %q, want %q", tt.content, tt.n, buf.String(), tt.want)
+		}
+	}
+}
+
+// newTestBatch 创建输入文件与批处理任务，每行结果预先保存为单行结果，执行器不会真正发起请求
+func newTestBatch(t *testing.T, userId int, status string, results []bool) (*model.Batch, service.FileStorage) {
+	t.Helper()
+	storage := service.NewLocalFileStorage(t.TempDir())
+	service.SetFileStorage(storage)
+
+	token := &model.Token{UserId: userId, Key: common.GetRandomString(48), Name: t.Name()}
+	if err := model.DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	var input strings.Builder
+	for i := range results {
+		fmt.Fprintf(&input, `{"custom_id":"req-%d","method":"POST","url":"/v1/chat/completions","body":{}}`+"\n", i)
+	}
+	inputFile := &model.File{
+		FileId:      service.LocalFileIdPrefix + common.GetRandomString(24),
+		UserId:      userId,
+		Purpose:     "batch",
+		Bytes:       int64(input.Len()),
+		StoragePath: "input_" + common.GetRandomString(8),
+		Status:      model.FileStatusProcessed,
+	}
+	if _, err := storage.Save(inputFile.StoragePath, strings.NewReader(input.String())); err != nil {
+		t.Fatal(err)
+	}
+	if err := inputFile.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	batch := &model.Batch{
+		BatchId:          "batch_" + common.GetRandomString(24),
+		UserId:           userId,
+		TokenId:          token.Id,
+		Endpoint:         "/v1/chat/completions",
+		InputFileId:      inputFile.FileId,
+		CompletionWindow: batchCompletionWindow,
+		Status:           status,
+		ExpiresAt:        common.GetTimestamp() + 3600,
+	}
+	if err := batch.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	for i, success := range results {
+		output := []byte(fmt.Sprintf(`{"custom_id":"req-%d"}`, i))
+		if err := saveBatchLineResult(storage, batch, i, &batchLineResult{Success: success, Output: output}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return batch, storage
+}
+
+func readBatchOutput(t *testing.T, batch *model.Batch, fileId string) string {
+	t.Helper()
+	if fileId == "" {
+		return ""
+	}
+	file, err := model.GetUserFileByFileId(batch.UserId, fileId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := service.ReadFileContent(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// TestProcessBatchResume 模拟输出文件已合并、进度尚未写入数据库时中断，恢复后不能重复输出
+func TestProcessBatchResume(t *testing.T) {
+	batch, storage := newTestBatch(t, 1001, model.BatchStatusInProgress, []bool{true, true, false, true})
+	batch.TotalCount = 4
+	batch.Progress = 1
+	batch.CompletedCount = 1
+	if err := model.DB.Save(batch).Error; err != nil {
+		t.Fatal(err)
+	}
+	_ = storage.Delete(batchLineStorageName(batch, 0))
+	merged := "{\"custom_id\":\"req-0\"}\n{\"custom_id\":\"req-1\"}\n"
+	if _, err := storage.Save(batchOutputStorageName(batch), strings.NewReader(merged)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Save(batchErrorStorageName(batch), strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+
+	processBatch(batch)
+
+	result, err := model.GetUserBatchByBatchId(batch.UserId, batch.BatchId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != model.BatchStatusCompleted || result.Progress != 4 || result.CompletedCount != 3 || result.FailedCount != 1 {
+		t.Fatalf("unexpected batch: status %s progress %d completed %d failed %d", result.Status, result.Progress, result.CompletedCount, result.FailedCount)
+	}
+	wantOutput := "{\"custom_id\":\"req-0\"}\n{\"custom_id\":\"req-1\"}\n{\"custom_id\":\"req-3\"}\n"
+	if got := readBatchOutput(t, result, result.OutputFileId); got != wantOutput {
+		t.Errorf("output file:\n%s\nwant:\n%s", got, wantOutput)
+	}
+	if got := readBatchOutput(t, result, result.ErrorFileId); got != "{\"custom_id\":\"req-2\"}\n" {
+		t.Errorf("error file: %q", got)
+	}
+	for i := 0; i < 4; i++ {
+		if _, ok := loadBatchLineResult(storage, batch, i); ok {
+			t.Errorf("line %d result not cleaned up", i)
+		}
+	}
+}
+
+func TestProcessBatchCancel(t *testing.T) {
+	// 校验期间被取消：执行器不能把取消中的任务覆盖为执行中
+	batch, _ := newTestBatch(t, 1002, model.BatchStatusValidating, []bool{true, true})
+	if ok, err := model.CancelBatch(batch.Id); !ok || err != nil {
+		t.Fatalf("cancel batch: %v %v", ok, err)
+	}
+	processBatch(batch)
+	result, err := model.GetUserBatchByBatchId(batch.UserId, batch.BatchId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != model.BatchStatusCancelled || result.Progress != 0 || result.CancelledAt == 0 || result.CancellingAt == 0 {
+		t.Errorf("unexpected batch: status %s progress %d cancelled_at %d", result.Status, result.Progress, result.CancelledAt)
+	}
+
+	// 已结束的任务不会被执行器再次修改
+	failBatch(batch, "input file not found")
+	if status, _ := model.GetBatchStatus(batch.Id); status != model.BatchStatusCancelled {
+		t.Errorf("finished batch overwritten with status %s", status)
+	}
+	if ok, _ := model.CancelBatch(batch.Id); ok {
+		t.Errorf("finished batch cancelled again")
+	}
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getBillingStatementPage(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	tokenId := -1
+	if c.Query("token_id") != "" {
+		tokenId, _ = strconv.Atoi(c.Query("token_id"))
+	}
+	statements, total, err := model.GetUserBillingStatements(userId, tokenId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetUserBillingStatements 获取自己的月度账单，可按 token_id 过滤（0 表示用户账单）
+func GetUserBillingStatements(c *gin.Context) {
+	getBillingStatementPage(c, c.GetInt("id"))
+}
+
+// GetAllBillingStatements 管理员查询月度账单，可按 user_id、token_id 过滤
+func GetAllBillingStatements(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getBillingStatementPage(c, userId)
+}
+
+func downloadBillingStatement(c *gin.Context, statement *model.BillingStatement) {
+	var (
+		data        []byte
+		err         error
+		contentType string
+		ext         string
+	)
+	switch c.DefaultQuery("format", "csv") {
+	case "csv":
+		data, err = service.RenderBillingStatementCSV(statement)
+		contentType, ext = "text/csv; charset=utf-8", "csv"
+	case "html":
+		data, err = service.RenderBillingStatementHTML(statement)
+		contentType, ext = "text/html; charset=utf-8", "html"
+	default:
+		common.ApiErrorMsg(c, "不支持的账单格式，可选 csv 或 html")
+		return
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("statement-%s-%d", statement.Period, statement.UserId)
+	if statement.TokenId != 0 {
+		filename += fmt.Sprintf("-token-%d", statement.TokenId)
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", filename, ext))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// DownloadSelfBillingStatement 下载自己的账单 GET /api/statement/self/:id/download?format=csv|html
+func DownloadSelfBillingStatement(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	statement, err := model.GetBillingStatementById(id)
+	if err != nil || statement.UserId != c.GetInt("id") {
+		common.ApiErrorMsg(c, "账单不存在")
+		return
+	}
+	downloadBillingStatement(c, statement)
+}
+
+// DownloadBillingStatement 管理员下载任意账单
+func DownloadBillingStatement(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	statement, err := model.GetBillingStatementById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "账单不存在")
+		return
+	}
+	downloadBillingStatement(c, statement)
+}
+
+type regenerateBillingStatementRequest struct {
+	UserId  int    `json:"user_id"`
+	TokenId int    `json:"token_id"`
+	Period  string `json:"period"`
+}
+
+// RegenerateBillingStatement 管理员生成或重新生成账单。
+// 未指定 user_id 时为账期内所有有额度变动的用户补生成缺失的账单
+func RegenerateBillingStatement(c *gin.Context) {
+	var req regenerateBillingStatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Period == "" {
+		common.ApiError(c, errors.New("period is required"))
+		return
+	}
+	if req.UserId == 0 {
+		generated, err := service.GenerateBillingStatements(req.Period)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		common.ApiSuccess(c, gin.H{"generated": generated})
+		return
+	}
+	statement, err := model.GenerateBillingStatement(req.UserId, req.TokenId, req.Period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
@@ -8,6 +8,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel/task/custom"
 	"strconv"
 	"strings"
 
@@ -136,6 +137,7 @@ func GetAllChannels(c *gin.Context) {
 
 	for _, datum := range channelData {
 		clearChannelInfo(datum)
+		datum.Breaker = model.GetChannelBreakerStatus(datum.Id)
 	}
 
 	countQuery := model.DB.Model(&model.Channel{})
@@ -345,6 +347,7 @@ func SearchChannels(c *gin.Context) {
 
 	for _, datum := range pagedData {
 		clearChannelInfo(datum)
+		datum.Breaker = model.GetChannelBreakerStatus(datum.Id)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -372,6 +375,7 @@ func GetChannel(c *gin.Context) {
 	}
 	if channel != nil {
 		clearChannelInfo(channel)
+		channel.Breaker = model.GetChannelBreakerStatus(channel.Id)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -481,6 +485,19 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		}
 	}
 
+	// 自定义异步任务渠道的状态映射只能映射到网关的任务状态
+	if channel.Type == constant.ChannelTypeCustomTask && channel.OtherSettings != "" {
+		var otherSettings dto.ChannelOtherSettings
+		if err := common.UnmarshalJsonStr(channel.OtherSettings, &otherSettings); err != nil {
+			return fmt.Errorf("渠道其他设置[other settings] 格式错误：%s", err.Error())
+		}
+		if otherSettings.TaskConfig != nil {
+			if err := custom.ValidateStatusMapping(otherSettings.TaskConfig.StatusMapping); err != nil {
+				return err
+			}
+		}
+	}
+
 	// VertexAI 特殊校验
 	if channel.Type == constant.ChannelTypeVertexAi {
 		if channel.Other == "" {
@@ -1499,3 +1516,19 @@ func ManageMultiKeys(c *gin.Context) {
 		return
 	}
 }
+
+// GetChannelBreakers 获取所有渠道的熔断状态
+func GetChannelBreakers(c *gin.Context) {
+	common.ApiSuccess(c, model.GetAllChannelBreakerStatus())
+}
+
+// ResetChannelBreaker 清除指定渠道的熔断状态
+func ResetChannelBreaker(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.ResetChannelBreaker(id)
+	common.ApiSuccess(c, nil)
+}
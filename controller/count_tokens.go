@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/dto"
+	"one-api/relay"
+	"one-api/relay/helper"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountClaudeTokens 计算 Claude 请求的输入 token 数 POST /v1/messages/count_tokens，不扣除额度
+func CountClaudeTokens(c *gin.Context) {
+	request, err := helper.GetAndValidateClaudeRequest(c)
+	if err != nil {
+		claudeCountTokensError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	inputTokens, err := relay.ClaudeCountTokensHelper(c, request)
+	if err != nil {
+		claudeCountTokensError(c, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dto.ClaudeCountTokensResponse{
+		InputTokens: inputTokens,
+	})
+}
+
+func claudeCountTokensError(c *gin.Context, statusCode int, errType string, message string) {
+	c.JSON(statusCode, gin.H{
+		"type": "error",
+		"error": types.ClaudeError{
+			Type:    errType,
+			Message: message,
+		},
+	})
+}
+
+// CountGeminiTokens 计算 Gemini 请求的 token 数 POST /v1beta/models/{model}:countTokens，不扣除额度
+func CountGeminiTokens(c *gin.Context) {
+	response, err := relay.GeminiCountTokensHelper(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    http.StatusBadRequest,
+				"message": err.Error(),
+				"status":  "INVALID_ARGUMENT",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCountTokensContext 模拟 Distribute 选中渠道后的请求上下文
+func newCountTokensContext(path string, body string, channelType int, baseUrl string, modelName string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	common.SetContextKey(c, constant.ContextKeyChannelType, channelType)
+	common.SetContextKey(c, constant.ContextKeyChannelBaseUrl, baseUrl)
+	common.SetContextKey(c, constant.ContextKeyChannelKey, "sk-test")
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, modelName)
+	return c, w
+}
+
+func TestCountClaudeTokens(t *testing.T) {
+	setting := operation_setting.GetCountTokensSetting()
+	defer func(enabled bool) {
+		setting.UpstreamEnabled = enabled
+	}(setting.UpstreamEnabled)
+
+	var requests atomic.Int32
+	upstreamStatus := http.StatusOK
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("unexpected upstream path %s", r.URL.Path)
+		}
+		w.WriteHeader(upstreamStatus)
+		_, _ = io.WriteString(w, `{"input_tokens":42}`)
+	}))
+	defer upstream.Close()
+
+	const modelName = "claude-3-5-sonnet-20241022"
+	body := `{"model":"` + modelName + `","messages":[{"role":"user","content":"How many tokens are in this sentence?"}]}`
+	var request dto.ClaudeRequest
+	if err := common.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatal(err)
+	}
+	local, err := service.CountTokenClaudeRequest(request, modelName)
+	if err != nil || local == 0 || local == 42 {
+		t.Fatalf("local count %d: %v", local, err)
+	}
+
+	tests := []struct {
+		name            string
+		channelType     int
+		upstreamEnabled bool
+		upstreamStatus  int
+		wantRequests    int32
+		want            int
+	}{
+		{name: "upstream", channelType: constant.ChannelTypeAnthropic, upstreamEnabled: true, upstreamStatus: http.StatusOK, wantRequests: 1, want: 42},
+		// 上游失败、关闭上游计数或渠道不支持原生计数时在本地估算
+		{name: "upstream error", channelType: constant.ChannelTypeAnthropic, upstreamEnabled: true, upstreamStatus: http.StatusInternalServerError, wantRequests: 1, want: local},
+		{name: "upstream disabled", channelType: constant.ChannelTypeAnthropic, upstreamStatus: http.StatusOK, want: local},
+		{name: "no native count", channelType: constant.ChannelTypeOpenAI, upstreamEnabled: true, upstreamStatus: http.StatusOK, want: local},
+	}
+	for _, tt := range tests {
+		setting.UpstreamEnabled = tt.upstreamEnabled
+		upstreamStatus = tt.upstreamStatus
+		requests.Store(0)
+		c, w := newCountTokensContext("/v1/messages/count_tokens", body, tt.channelType, upstream.URL, modelName)
+		CountClaudeTokens(c)
+
+		var response dto.ClaudeCountTokensResponse
+		if err := common.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+			t.Errorf("%s: %d %s", tt.name, w.Code, w.Body.String())
+			continue
+		}
+		if response.InputTokens != tt.want || requests.Load() != tt.wantRequests {
+			t.Errorf("%s: input_tokens %d, upstream requests %d, want %d, %d", tt.name, response.InputTokens, requests.Load(), tt.want, tt.wantRequests)
+		}
+	}
+}
+
+func TestCountGeminiTokensLocalFallback(t *testing.T) {
+	setting := operation_setting.GetCountTokensSetting()
+	defer func(enabled bool) {
+		setting.UpstreamEnabled = enabled
+	}(setting.UpstreamEnabled)
+	setting.UpstreamEnabled = true
+
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	const modelName = "gemini-2.0-flash"
+	const text = "How many tokens are in this sentence?"
+	body := `{"contents":[{"role":"user","parts":[{"text":"` + text + `"},{"inlineData":{"mimeType":"image/png","data":"iVBORw0KGgo="}}]}]}`
+	c, w := newCountTokensContext("/v1beta/models/"+modelName+":countTokens", body, constant.ChannelTypeGemini, upstream.URL, modelName)
+	CountGeminiTokens(c)
+
+	var response dto.GeminiCountTokensResponse
+	if err := common.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body.String())
+	}
+	// 文本按分词器计数，图片固定 258 token
+	if want := service.CountTextToken(text, modelName) + 258; response.TotalTokens != want || requests.Load() != 1 {
+		t.Errorf("total_tokens %d, upstream requests %d, want %d after upstream failure", response.TotalTokens, requests.Load(), want)
+	}
+}
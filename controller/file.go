@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var supportedFilePurposes = map[string]bool{
+	"assistants": true,
+	"batch":      true,
+	"fine-tune":  true,
+	"vision":     true,
+	"user_data":  true,
+	"evals":      true,
+}
+
+func fileError(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+func fileNotFound(c *gin.Context, fileId string) {
+	fileError(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", fileId))
+}
+
+func toOpenAIFileObject(file *model.File) dto.OpenAIFileObject {
+	return dto.OpenAIFileObject{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+// getUserFile 获取当前用户的文件，不存在时直接返回 404
+func getUserFile(c *gin.Context) *model.File {
+	fileId := c.Param("id")
+	file, err := model.GetUserFileByFileId(c.GetInt("id"), fileId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fileNotFound(c, fileId)
+		} else {
+			fileError(c, http.StatusInternalServerError, "get_file_failed", err.Error())
+		}
+		return nil
+	}
+	return file
+}
+
+// UploadFile 上传文件 POST /v1/files
+func UploadFile(c *gin.Context) {
+	userId := c.GetInt("id")
+	fileSetting := operation_setting.GetFileSetting()
+
+	purpose := c.PostForm("purpose")
+	if !supportedFilePurposes[purpose] {
+		fileError(c, http.StatusBadRequest, "invalid_purpose", fmt.Sprintf("Invalid purpose: %s", purpose))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		fileError(c, http.StatusBadRequest, "missing_file", "'file' is a required property")
+		return
+	}
+	maxBytes := int64(fileSetting.MaxFileSizeMB) << 20
+	if fileSetting.MaxFileSizeMB > 0 && fileHeader.Size > maxBytes {
+		fileError(c, http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("File exceeds the maximum allowed size of %d MB", fileSetting.MaxFileSizeMB))
+		return
+	}
+	if fileSetting.UserStorageLimitMB > 0 {
+		usedBytes, err := model.GetUserFileBytes(userId)
+		if err != nil {
+			fileError(c, http.StatusInternalServerError, "get_storage_usage_failed", err.Error())
+			return
+		}
+		if usedBytes+fileHeader.Size > int64(fileSetting.UserStorageLimitMB)<<20 {
+			fileError(c, http.StatusForbidden, "storage_quota_exceeded", fmt.Sprintf("File storage quota exceeded: used %s of %d MB", common.Bytes2Size(usedBytes), fileSetting.UserStorageLimitMB))
+			return
+		}
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		fileError(c, http.StatusBadRequest, "read_file_failed", err.Error())
+		return
+	}
+	defer src.Close()
+
+	fileId := service.LocalFileIdPrefix + common.GetRandomString(24)
+	size, err := service.GetFileStorage().Save(fileId, src)
+	if err != nil {
+		common.SysError("failed to save file: " + err.Error())
+		fileError(c, http.StatusInternalServerError, "save_file_failed", "failed to save file")
+		return
+	}
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		if t := mime.TypeByExtension(filepath.Ext(fileHeader.Filename)); t != "" {
+			mimeType = t
+		}
+	}
+	file := &model.File{
+		FileId:      fileId,
+		UserId:      userId,
+		TokenId:     c.GetInt("token_id"),
+		Filename:    fileHeader.Filename,
+		Purpose:     purpose,
+		MimeType:    mimeType,
+		Bytes:       size,
+		StoragePath: fileId,
+		Status:      model.FileStatusProcessed,
+	}
+	if err = file.Insert(); err != nil {
+		_ = service.GetFileStorage().Delete(fileId)
+		fileError(c, http.StatusInternalServerError, "save_file_failed", err.Error())
+		return
+	}
+	// 保存前只能检查已用空间，保存后重新统计（同时计入并发上传的文件），超出配额时删除
+	if fileSetting.UserStorageLimitMB > 0 {
+		usedBytes, err := model.GetUserFileBytes(userId)
+		if err == nil && usedBytes > int64(fileSetting.UserStorageLimitMB)<<20 {
+			if err = file.Delete(); err != nil {
+				common.SysError(fmt.Sprintf("failed to delete file %s: %s", file.FileId, err.Error()))
+			} else if err = service.GetFileStorage().Delete(fileId); err != nil {
+				common.SysError(fmt.Sprintf("failed to delete file %s from storage: %s", file.FileId, err.Error()))
+			}
+			fileError(c, http.StatusForbidden, "storage_quota_exceeded", fmt.Sprintf("File storage quota exceeded: %d MB", fileSetting.UserStorageLimitMB))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, toOpenAIFileObject(file))
+}
+
+// ListFiles 列出当前用户的文件 GET /v1/files
+func ListFiles(c *gin.Context) {
+	userId := c.GetInt("id")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	afterId := 0
+	if after := c.Query("after"); after != "" {
+		file, err := model.GetUserFileByFileId(userId, after)
+		if err != nil {
+			fileNotFound(c, after)
+			return
+		}
+		afterId = file.Id
+	}
+	// 多查询一条用于判断是否还有更多数据
+	files, err := model.GetUserFiles(userId, c.Query("purpose"), afterId, limit+1)
+	if err != nil {
+		fileError(c, http.StatusInternalServerError, "list_files_failed", err.Error())
+		return
+	}
+	resp := dto.OpenAIFileListResponse{
+		Object: "list",
+		Data:   make([]dto.OpenAIFileObject, 0, len(files)),
+	}
+	if len(files) > limit {
+		files = files[:limit]
+		resp.HasMore = true
+	}
+	for _, file := range files {
+		resp.Data = append(resp.Data, toOpenAIFileObject(file))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetrieveFile 获取文件信息 GET /v1/files/:id
+func RetrieveFile(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIFileObject(file))
+}
+
+// DeleteFile 删除文件 DELETE /v1/files/:id
+func DeleteFile(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+	if err := file.Delete(); err != nil {
+		fileError(c, http.StatusInternalServerError, "delete_file_failed", err.Error())
+		return
+	}
+	if err := service.GetFileStorage().Delete(file.StoragePath); err != nil {
+		common.SysError(fmt.Sprintf("failed to delete file %s from storage: %s", file.FileId, err.Error()))
+	}
+	if len(file.Upstream) > 0 {
+		gopool.Go(func() {
+			service.DeleteUpstreamFiles(file)
+		})
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleteResponse{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+// RetrieveFileContent 下载文件内容 GET /v1/files/:id/content
+func RetrieveFileContent(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+	reader, err := service.GetFileStorage().Open(file.StoragePath)
+	if err != nil {
+		fileError(c, http.StatusInternalServerError, "read_file_failed", err.Error())
+		return
+	}
+	defer reader.Close()
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, file.Bytes, contentType, reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", file.Filename),
+	})
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newFileRouter 注册 Files API，以 userId 作为当前用户
+func newFileRouter(t *testing.T, userId int) *gin.Engine {
+	t.Helper()
+	service.SetFileStorage(service.NewLocalFileStorage(t.TempDir()))
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("id", userId)
+	})
+	router.POST("/v1/files", UploadFile)
+	router.GET("/v1/files", ListFiles)
+	router.GET("/v1/files/:id", RetrieveFile)
+	router.DELETE("/v1/files/:id", DeleteFile)
+	router.GET("/v1/files/:id/content", RetrieveFileContent)
+	return router
+}
+
+func uploadTestFile(router *gin.Engine, purpose string, filename string, content string) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("purpose", purpose)
+	part, _ := writer.CreateFormFile("file", filename)
+	_, _ = part.Write([]byte(content))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func serveFileRequest(router *gin.Engine, method string, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func decodeFileResponse[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := common.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+func TestFilesApi(t *testing.T) {
+	router := newFileRouter(t, 2001)
+	other := newFileRouter(t, 2002)
+
+	w := uploadTestFile(router, "invalid", "a.txt", "hello")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_purpose") {
+		t.Errorf("invalid purpose: %d %s", w.Code, w.Body.String())
+	}
+
+	var ids []string
+	for i, purpose := range []string{"batch", "assistants", "batch"} {
+		w = uploadTestFile(router, purpose, fmt.Sprintf("f%d.jsonl", i), fmt.Sprintf("content %d", i))
+		if w.Code != http.StatusOK {
+			t.Fatalf("upload: %d %s", w.Code, w.Body.String())
+		}
+		file := decodeFileResponse[dto.OpenAIFileObject](t, w)
+		if !service.IsLocalFileId(file.Id) || file.Bytes != 9 || file.Purpose != purpose || file.Status != model.FileStatusProcessed {
+			t.Errorf("unexpected file object: %+v", file)
+		}
+		ids = append(ids, file.Id)
+	}
+
+	// 按创建时间倒序分页
+	list := decodeFileResponse[dto.OpenAIFileListResponse](t, serveFileRequest(router, http.MethodGet, "/v1/files?limit=2"))
+	if len(list.Data) != 2 || !list.HasMore || list.FirstId != ids[2] || list.LastId != ids[1] {
+		t.Errorf("first page: %+v", list)
+	}
+	list = decodeFileResponse[dto.OpenAIFileListResponse](t, serveFileRequest(router, http.MethodGet, "/v1/files?limit=2&after="+list.LastId))
+	if len(list.Data) != 1 || list.HasMore || list.FirstId != ids[0] {
+		t.Errorf("second page: %+v", list)
+	}
+	list = decodeFileResponse[dto.OpenAIFileListResponse](t, serveFileRequest(router, http.MethodGet, "/v1/files?purpose=batch"))
+	if len(list.Data) != 2 {
+		t.Errorf("purpose filter: %+v", list)
+	}
+
+	w = serveFileRequest(router, http.MethodGet, "/v1/files/"+ids[1]+"/content")
+	if w.Code != http.StatusOK || w.Body.String() != "content 1" || !strings.Contains(w.Header().Get("Content-Disposition"), `filename="f1.jsonl"`) {
+		t.Errorf("content: %d %s %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	// 其他用户无法访问
+	for _, path := range []string{"/v1/files/" + ids[0], "/v1/files/" + ids[0] + "/content"} {
+		if w = serveFileRequest(other, http.MethodGet, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s from other user: %d", path, w.Code)
+		}
+	}
+	if w = serveFileRequest(other, http.MethodDelete, "/v1/files/"+ids[0]); w.Code != http.StatusNotFound {
+		t.Errorf("delete from other user: %d", w.Code)
+	}
+
+	w = serveFileRequest(router, http.MethodDelete, "/v1/files/"+ids[0])
+	if deleted := decodeFileResponse[dto.OpenAIFileDeleteResponse](t, w); w.Code != http.StatusOK || !deleted.Deleted || deleted.Id != ids[0] {
+		t.Errorf("delete: %d %s", w.Code, w.Body.String())
+	}
+	if w = serveFileRequest(router, http.MethodGet, "/v1/files/"+ids[0]); w.Code != http.StatusNotFound {
+		t.Errorf("deleted file still retrievable: %d", w.Code)
+	}
+	if used, _ := model.GetUserFileBytes(2001); used != 18 {
+		t.Errorf("storage usage after delete %d, want 18", used)
+	}
+}
+
+func TestUploadFileStorageLimit(t *testing.T) {
+	fileSetting := operation_setting.GetFileSetting()
+	defer func(maxFileSizeMB int, limitMB int) {
+		fileSetting.MaxFileSizeMB, fileSetting.UserStorageLimitMB = maxFileSizeMB, limitMB
+	}(fileSetting.MaxFileSizeMB, fileSetting.UserStorageLimitMB)
+	fileSetting.MaxFileSizeMB, fileSetting.UserStorageLimitMB = 1, 1
+
+	const userId = 2003
+	router := newFileRouter(t, userId)
+	half := strings.Repeat("a", 512<<10)
+	if w := uploadTestFile(router, "batch", "a.jsonl", strings.Repeat("a", 1<<20+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized file: %d %s", w.Code, w.Body.String())
+	}
+	for i := 0; i < 2; i++ {
+		if w := uploadTestFile(router, "batch", "a.jsonl", half); w.Code != http.StatusOK {
+			t.Fatalf("upload %d: %d %s", i, w.Code, w.Body.String())
+		}
+	}
+	if w := uploadTestFile(router, "batch", "a.jsonl", "a"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "storage_quota_exceeded") {
+		t.Errorf("upload over quota: %d %s", w.Code, w.Body.String())
+	}
+
+	// 并发上传时保存前的检查可能同时通过，保存后重新检查并删除超出配额的文件
+	if err := model.DB.Where("user_id = ?", userId).Delete(&model.File{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	codes := make([]int, 4)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = uploadTestFile(router, "batch", "a.jsonl", strings.Repeat("a", 400<<10)).Code
+		}(i)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK && code != http.StatusForbidden {
+			t.Errorf("concurrent upload %d: %d", i, code)
+		}
+	}
+	if used, _ := model.GetUserFileBytes(userId); used > 1<<20 {
+		t.Errorf("storage usage %d exceeds quota", used)
+	}
+}
+
+func TestDeleteFileUpstreamCopies(t *testing.T) {
+	deleted := make(chan string, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("unexpected upstream request %s %s", r.Method, r.URL.Path)
+		}
+		deleted <- r.Header.Get("Authorization") + " " + r.URL.Path
+		if strings.HasSuffix(r.URL.Path, "gone") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+	baseUrl := upstream.URL
+	multiKeyChannel := &model.Channel{Type: constant.ChannelTypeOpenAI, Key: "sk-0\nsk-1", Name: t.Name(), BaseURL: &baseUrl}
+	channel := &model.Channel{Type: constant.ChannelTypeOpenAI, Key: "sk-single", Name: t.Name(), BaseURL: &baseUrl}
+	for _, c := range []*model.Channel{multiKeyChannel, channel} {
+		if err := model.DB.Create(c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const userId = 2004
+	router := newFileRouter(t, userId)
+	w := uploadTestFile(router, "user_data", "a.pdf", "pdf")
+	fileId := decodeFileResponse[dto.OpenAIFileObject](t, w).Id
+	file, err := model.GetUserFileByFileId(userId, fileId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.SetUpstreamFileId(fmt.Sprintf("%d:1", multiKeyChannel.Id), "file-up")
+	_ = file.SetUpstreamFileId(fmt.Sprintf("%d", channel.Id+1000), "file-orphan")
+	_ = file.SetUpstreamFileId(fmt.Sprintf("%d", channel.Id), "file-gone")
+
+	if w = serveFileRequest(router, http.MethodDelete, "/v1/files/"+fileId); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	// 多密钥渠道使用上传时的密钥删除，已删除的渠道跳过
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-deleted:
+			got[request] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("upstream copies not deleted, got %v", got)
+		}
+	}
+	if !got["Bearer sk-1 /v1/files/file-up"] || !got["Bearer sk-single /v1/files/file-gone"] {
+		t.Errorf("unexpected upstream deletes: %v", got)
+	}
+}
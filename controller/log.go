@@ -114,6 +114,7 @@ func GetLogsStat(c *gin.Context) {
 		"message": "",
 		"data": gin.H{
 			"quota": stat.Quota,
+			"cost":  stat.Cost,
 			"rpm":   stat.Rpm,
 			"tpm":   stat.Tpm,
 		},
@@ -166,3 +167,27 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+// GetCostReport 按渠道、模型、分组或天统计收入、上游成本与毛利
+func GetCostReport(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", model.CostReportByChannel)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	items, total, err := model.GetCostReport(groupBy, model.CostReportFilter{
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		ChannelId:      channel,
+		ModelName:      c.Query("model_name"),
+		Group:          c.Query("group"),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"group_by": groupBy,
+		"items":    items,
+		"total":    total,
+	})
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain 测试使用内存 SQLite 数据库，不启用 Redis
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	common.RedisEnabled = false
+	common.IsMasterNode = true
+	common.SQLitePath = "file:controller_test?mode=memory&cache=shared"
+	if err := model.InitDB(); err != nil {
+		panic(err)
+	}
+	if err := model.InitLogDB(); err != nil {
+		panic(err)
+	}
+	service.InitHttpClient()
+	service.InitTokenEncoders()
+	os.Exit(m.Run())
+}
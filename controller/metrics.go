@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/constant"
+	"one-api/middleware"
+	"one-api/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const taskQueueMetricsTTL = 30 * time.Second
+
+func init() {
+	metrics.NewGaugeFunc("newapi_active_connections", "Active HTTP connections on this node.", nil, func() []metrics.GaugeSample {
+		return []metrics.GaugeSample{{Value: float64(middleware.GetStats().ActiveConnections)}}
+	})
+	// 任务队列需要查询数据库，缓存一段时间，避免每次抓取都执行统计查询
+	metrics.NewGaugeFunc("newapi_task_queue_size", "Unfinished async tasks by platform and status.", []string{"platform", "status"}, metrics.CachedCollect(taskQueueMetricsTTL, func() []metrics.GaugeSample {
+		sizes, err := model.GetTaskQueueSizes()
+		if err != nil {
+			common.SysLog("failed to collect task queue sizes: " + err.Error())
+			return nil
+		}
+		samples := make([]metrics.GaugeSample, 0, len(sizes))
+		for _, size := range sizes {
+			samples = append(samples, metrics.GaugeSample{
+				LabelValues: []string{size.Platform, size.Status},
+				Value:       float64(size.Count),
+			})
+		}
+		return samples
+	}))
+}
+
+// Metrics 以 Prometheus text format 输出监控指标
+func Metrics(c *gin.Context) {
+	if constant.MetricsToken != "" {
+		expected := "Bearer " + constant.MetricsToken
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+	}
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	metrics.WriteAll(c.Writer)
+}
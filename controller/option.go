@@ -8,6 +8,7 @@ import (
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/console_setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/setting/system_setting"
 	"strings"
@@ -173,6 +174,24 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "routing_setting.default_strategy":
+		err = operation_setting.CheckRoutingDefaultStrategy(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "routing_setting.strategies":
+		err = operation_setting.CheckRoutingStrategies(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
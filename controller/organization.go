@@ -0,0 +1,451 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+type organizationRequest struct {
+	Name   string `json:"name"`
+	Status int    `json:"status"`
+}
+
+type organizationMemberRequest struct {
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	QuotaLimit *int   `json:"quota_limit"`
+	// 重置成员已用额度
+	ResetUsed bool `json:"reset_used"`
+}
+
+// canRechargeOrganization 所有者、管理员与财务可以为组织充值
+func canRechargeOrganization(organizationId int, userId int) bool {
+	member, err := model.GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return false
+	}
+	return member.HasRole(model.OrganizationRoleOwner, model.OrganizationRoleAdmin, model.OrganizationRoleBilling)
+}
+
+// getOrganizationForMember 获取路径中的组织，并校验当前用户具有指定角色之一，未指定角色时只要求是成员
+func getOrganizationForMember(c *gin.Context, roles ...string) (*model.Organization, *model.OrganizationMember, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的组织 ID")
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "组织不存在")
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(id, c.GetInt("id"))
+	if err != nil || (len(roles) > 0 && !member.HasRole(roles...)) {
+		common.ApiErrorMsg(c, "无权进行此操作")
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "组织名称不能为空且不能超过 64 个字符")
+		return
+	}
+	org, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// GetSelfOrganizations 获取当前用户所在的组织
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func GetOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationForMember(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, &model.UserOrganization{
+		Organization: *org,
+		Role:         member.Role,
+		QuotaLimit:   member.QuotaLimit,
+		MemberUsed:   member.UsedQuota,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	org, _, ok := getOrganizationForMember(c, model.OrganizationRoleOwner, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if len(name) > 64 {
+			common.ApiErrorMsg(c, "组织名称不能超过 64 个字符")
+			return
+		}
+		org.Name = name
+	}
+	if req.Status == model.OrganizationStatusEnabled || req.Status == model.OrganizationStatusDisabled {
+		org.Status = req.Status
+	}
+	if err := org.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func DeleteOrganization(c *gin.Context) {
+	org, _, ok := getOrganizationForMember(c, model.OrganizationRoleOwner)
+	if !ok {
+		return
+	}
+	if org.Quota > 0 {
+		common.ApiErrorMsg(c, "组织仍有剩余额度，无法删除")
+		return
+	}
+	if err := org.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := getOrganizationForMember(c)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+// GetSelfOrganizationInvitations 获取当前用户收到的组织邀请
+func GetSelfOrganizationInvitations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizationInvitations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+// AddOrganizationMember 邀请用户加入组织，用户接受邀请后才成为成员
+func AddOrganizationMember(c *gin.Context) {
+	org, operator, ok := getOrganizationForMember(c, model.OrganizationRoleOwner, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if !model.IsValidOrganizationRole(req.Role) || req.Role == model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "无效的成员角色")
+		return
+	}
+	// 只有所有者可以添加管理员
+	if req.Role == model.OrganizationRoleAdmin && !operator.HasRole(model.OrganizationRoleOwner) {
+		common.ApiErrorMsg(c, "无权进行此操作")
+		return
+	}
+	if req.QuotaLimit != nil && *req.QuotaLimit < 0 {
+		common.ApiErrorMsg(c, "额度上限不能为负数")
+		return
+	}
+	userId, err := model.GetUserIdByUsername(strings.TrimSpace(req.Username))
+	if err != nil || userId == 0 {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+	if model.IsOrganizationMemberOrInvited(org.Id, userId) {
+		common.ApiErrorMsg(c, "该用户已是组织成员或已被邀请")
+		return
+	}
+	member := &model.OrganizationMember{
+		OrganizationId: org.Id,
+		UserId:         userId,
+		Role:           req.Role,
+		QuotaLimit:     lo.FromPtr(req.QuotaLimit),
+		Status:         model.OrganizationMemberStatusInvited,
+		InvitedBy:      operator.UserId,
+	}
+	if err := member.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordOrganizationLog(org.Id, operator.UserId, model.LogTypeManage, "邀请组织成员 "+req.Username+"，角色 "+req.Role)
+	common.ApiSuccess(c, member)
+}
+
+// getSelfOrganizationInvitation 获取当前用户收到的路径中组织的邀请
+func getSelfOrganizationInvitation(c *gin.Context) (*model.OrganizationMember, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的组织 ID")
+		return nil, false
+	}
+	member, err := model.GetOrganizationInvitation(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "邀请不存在")
+		return nil, false
+	}
+	return member, true
+}
+
+// AcceptOrganizationInvitation 接受组织邀请
+func AcceptOrganizationInvitation(c *gin.Context) {
+	member, ok := getSelfOrganizationInvitation(c)
+	if !ok {
+		return
+	}
+	accepted, err := member.Accept()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !accepted {
+		common.ApiErrorMsg(c, "邀请不存在")
+		return
+	}
+	model.RecordOrganizationLog(member.OrganizationId, member.UserId, model.LogTypeManage, "用户 #"+strconv.Itoa(member.UserId)+" 加入组织")
+	common.ApiSuccess(c, member)
+}
+
+// DeclineOrganizationInvitation 拒绝组织邀请
+func DeclineOrganizationInvitation(c *gin.Context) {
+	member, ok := getSelfOrganizationInvitation(c)
+	if !ok {
+		return
+	}
+	if err := member.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	org, operator, ok := getOrganizationForMember(c, model.OrganizationRoleOwner, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	member, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 所有者角色不可变更，管理员只能由所有者调整
+	if req.Role != "" && req.Role != member.Role {
+		if !model.IsValidOrganizationRole(req.Role) || req.Role == model.OrganizationRoleOwner || member.HasRole(model.OrganizationRoleOwner) {
+			common.ApiErrorMsg(c, "无效的成员角色")
+			return
+		}
+		if (req.Role == model.OrganizationRoleAdmin || member.HasRole(model.OrganizationRoleAdmin)) && !operator.HasRole(model.OrganizationRoleOwner) {
+			common.ApiErrorMsg(c, "无权进行此操作")
+			return
+		}
+		member.Role = req.Role
+	}
+	// 所有者与管理员的额度上限和已用额度只能由所有者调整，避免管理员为自己或其他管理员解除限制
+	if (req.QuotaLimit != nil || req.ResetUsed) &&
+		(member.HasRole(model.OrganizationRoleOwner) || member.HasRole(model.OrganizationRoleAdmin)) &&
+		!operator.HasRole(model.OrganizationRoleOwner) {
+		common.ApiErrorMsg(c, "无权进行此操作")
+		return
+	}
+	if req.QuotaLimit != nil {
+		if *req.QuotaLimit < 0 {
+			common.ApiErrorMsg(c, "额度上限不能为负数")
+			return
+		}
+		member.QuotaLimit = *req.QuotaLimit
+	}
+	if req.ResetUsed {
+		member.UsedQuota = 0
+	}
+	if err := member.Update(req.ResetUsed); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// RemoveOrganizationMember 移除成员或撤回邀请，成员也可以主动退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	roles := []string{model.OrganizationRoleOwner, model.OrganizationRoleAdmin}
+	if userId == c.GetInt("id") {
+		roles = nil
+	}
+	org, operator, ok := getOrganizationForMember(c, roles...)
+	if !ok {
+		return
+	}
+	member, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		member, err = model.GetOrganizationInvitation(org.Id, userId)
+	}
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if member.HasRole(model.OrganizationRoleOwner) {
+		common.ApiErrorMsg(c, "无法移除组织所有者")
+		return
+	}
+	if member.HasRole(model.OrganizationRoleAdmin) && member.UserId != operator.UserId && !operator.HasRole(model.OrganizationRoleOwner) {
+		common.ApiErrorMsg(c, "无权进行此操作")
+		return
+	}
+	if err := member.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordOrganizationLog(org.Id, operator.UserId, model.LogTypeManage, "移除组织成员 #"+strconv.Itoa(member.UserId))
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationTokens(c *gin.Context) {
+	org, _, ok := getOrganizationForMember(c, model.OrganizationRoleOwner, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	tokens, total, err := model.GetOrganizationTokens(org.Id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 其他成员的令牌不返回完整 key
+	userId := c.GetInt("id")
+	for _, token := range tokens {
+		if token.UserId != userId && len(token.Key) > 8 {
+			token.Key = token.Key[:4] + "****" + token.Key[len(token.Key)-4:]
+		}
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tokens)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetOrganizationLogs(c *gin.Context) {
+	org, _, ok := getOrganizationForMember(c, model.OrganizationRoleOwner, model.OrganizationRoleAdmin, model.OrganizationRoleBilling)
+	if !ok {
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(org.Id, logType, startTimestamp, endTimestamp, c.Query("model_name"), c.Query("username"), c.Query("token_name"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetOrganizationQuotaData(c *gin.Context) {
+	org, _, ok := getOrganizationForMember(c, model.OrganizationRoleOwner, model.OrganizationRoleAdmin, model.OrganizationRoleBilling)
+	if !ok {
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	// 判断时间跨度是否超过 1 个月
+	if endTimestamp-startTimestamp > 2592000 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "时间跨度不能超过 1 个月",
+		})
+		return
+	}
+	data, err := model.GetOrganizationQuotaData(org.Id, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, data)
+}
+
+// GetAllOrganizations 管理员查看所有组织
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AdminRechargeOrganization 管理员直接调整组织额度
+func AdminRechargeOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req struct {
+		Quota int `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Quota <= 0 {
+		common.ApiErrorMsg(c, "额度必须大于 0")
+		return
+	}
+	if _, err := model.GetOrganizationById(id); err != nil {
+		common.ApiErrorMsg(c, "组织不存在")
+		return
+	}
+	if err := model.RechargeOrganization(id, c.GetInt("id"), req.Quota, "管理员充值"); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
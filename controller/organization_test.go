@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newOrganizationRouter 注册组织与令牌接口，请求头 X-User-Id 作为当前用户
+func newOrganizationRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		userId, _ := strconv.Atoi(c.GetHeader("X-User-Id"))
+		c.Set("id", userId)
+	})
+	router.POST("/api/organization/:id/members", AddOrganizationMember)
+	router.DELETE("/api/organization/:id/members/:user_id", RemoveOrganizationMember)
+	router.GET("/api/organization/:id/members", GetOrganizationMembers)
+	router.GET("/api/organization/invitations", GetSelfOrganizationInvitations)
+	router.POST("/api/organization/:id/accept", AcceptOrganizationInvitation)
+	router.POST("/api/organization/:id/decline", DeclineOrganizationInvitation)
+	router.POST("/api/token/", AddToken)
+	return router
+}
+
+// serveOrganizationRequest 以 userId 发起请求，返回接口是否成功与响应内容
+func serveOrganizationRequest(router *gin.Engine, userId int, method string, path string, body string) (bool, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", strconv.Itoa(userId))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code == http.StatusOK && strings.Contains(w.Body.String(), `"success":true`), w.Body.String()
+}
+
+func newOrganizationTestUser(t *testing.T) *model.User {
+	t.Helper()
+	user := &model.User{Username: common.GetRandomString(10), AffCode: common.GetRandomString(8), Status: common.UserStatusEnabled}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestOrganizationInvitation(t *testing.T) {
+	router := newOrganizationRouter()
+	owner, invitee, other := newOrganizationTestUser(t), newOrganizationTestUser(t), newOrganizationTestUser(t)
+	org, err := model.CreateOrganization(t.Name(), owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgPath := "/api/organization/" + strconv.Itoa(org.Id)
+
+	if ok, body := serveOrganizationRequest(router, owner.Id, http.MethodPost, orgPath+"/members", `{"username":"`+invitee.Username+`","role":"billing"}`); !ok {
+		t.Fatalf("invite: %s", body)
+	}
+	if ok, _ := serveOrganizationRequest(router, owner.Id, http.MethodPost, orgPath+"/members", `{"username":"`+invitee.Username+`"}`); ok {
+		t.Errorf("invited user invited again")
+	}
+	// 接受邀请前不具有成员权限
+	if canRechargeOrganization(org.Id, invitee.Id) {
+		t.Errorf("invited user can recharge before accepting")
+	}
+	if ok, _ := serveOrganizationRequest(router, invitee.Id, http.MethodGet, orgPath+"/members", ""); ok {
+		t.Errorf("invited user can list members before accepting")
+	}
+	if ok, body := serveOrganizationRequest(router, invitee.Id, http.MethodGet, "/api/organization/invitations", ""); !ok || !strings.Contains(body, `"role":"billing"`) {
+		t.Errorf("invitations: %s", body)
+	}
+	// 只有被邀请的用户可以接受
+	if ok, _ := serveOrganizationRequest(router, other.Id, http.MethodPost, orgPath+"/accept", ""); ok {
+		t.Errorf("uninvited user accepted invitation")
+	}
+	if ok, body := serveOrganizationRequest(router, invitee.Id, http.MethodPost, orgPath+"/accept", ""); !ok {
+		t.Fatalf("accept: %s", body)
+	}
+	if !canRechargeOrganization(org.Id, invitee.Id) {
+		t.Errorf("billing member cannot recharge after accepting")
+	}
+	if ok, _ := serveOrganizationRequest(router, invitee.Id, http.MethodPost, orgPath+"/accept", ""); ok {
+		t.Errorf("invitation accepted twice")
+	}
+
+	// 拒绝邀请与撤回邀请后不再是成员，可以重新邀请
+	if ok, body := serveOrganizationRequest(router, owner.Id, http.MethodPost, orgPath+"/members", `{"username":"`+other.Username+`"}`); !ok {
+		t.Fatalf("invite other: %s", body)
+	}
+	if ok, body := serveOrganizationRequest(router, other.Id, http.MethodPost, orgPath+"/decline", ""); !ok {
+		t.Fatalf("decline: %s", body)
+	}
+	if ok, body := serveOrganizationRequest(router, owner.Id, http.MethodPost, orgPath+"/members", `{"username":"`+other.Username+`"}`); !ok {
+		t.Fatalf("invite again: %s", body)
+	}
+	if ok, body := serveOrganizationRequest(router, owner.Id, http.MethodDelete, orgPath+"/members/"+strconv.Itoa(other.Id), ""); !ok {
+		t.Fatalf("revoke: %s", body)
+	}
+	if model.IsOrganizationMemberOrInvited(org.Id, other.Id) {
+		t.Errorf("revoked invitation still exists")
+	}
+}
+
+func TestCanRechargeOrganization(t *testing.T) {
+	owner := newOrganizationTestUser(t)
+	org, err := model.CreateOrganization(t.Name(), owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		role   string
+		status int
+		want   bool
+	}{
+		{role: model.OrganizationRoleAdmin, status: model.OrganizationMemberStatusJoined, want: true},
+		{role: model.OrganizationRoleBilling, status: model.OrganizationMemberStatusJoined, want: true},
+		{role: model.OrganizationRoleMember, status: model.OrganizationMemberStatusJoined},
+		{role: model.OrganizationRoleAdmin, status: model.OrganizationMemberStatusInvited},
+	}
+	if !canRechargeOrganization(org.Id, owner.Id) {
+		t.Errorf("owner cannot recharge")
+	}
+	for _, tt := range tests {
+		user := newOrganizationTestUser(t)
+		member := &model.OrganizationMember{OrganizationId: org.Id, UserId: user.Id, Role: tt.role, Status: tt.status}
+		if err := member.Insert(); err != nil {
+			t.Fatal(err)
+		}
+		if got := canRechargeOrganization(org.Id, user.Id); got != tt.want {
+			t.Errorf("%s (status %d): got %v, want %v", tt.role, tt.status, got, tt.want)
+		}
+	}
+	if canRechargeOrganization(org.Id+1000, owner.Id) {
+		t.Errorf("recharge allowed for unknown organization")
+	}
+}
+
+func TestAddOrganizationToken(t *testing.T) {
+	router := newOrganizationRouter()
+	owner := newOrganizationTestUser(t)
+	org, err := model.CreateOrganization(t.Name(), owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		role   string
+		status int
+		want   bool
+	}{
+		{name: "member", role: model.OrganizationRoleMember, status: model.OrganizationMemberStatusJoined, want: true},
+		// 财务成员只负责充值，不能创建使用组织额度的令牌
+		{name: "billing member", role: model.OrganizationRoleBilling, status: model.OrganizationMemberStatusJoined},
+		{name: "invited member", role: model.OrganizationRoleMember, status: model.OrganizationMemberStatusInvited},
+		{name: "not a member"},
+	}
+	for _, tt := range tests {
+		user := newOrganizationTestUser(t)
+		if tt.role != "" {
+			member := &model.OrganizationMember{OrganizationId: org.Id, UserId: user.Id, Role: tt.role, Status: tt.status}
+			if err := member.Insert(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ok, body := serveOrganizationRequest(router, user.Id, http.MethodPost, "/api/token/", `{"name":"org","organization_id":`+strconv.Itoa(org.Id)+`}`)
+		if ok != tt.want {
+			t.Errorf("%s: created %v, want %v: %s", tt.name, ok, tt.want, body)
+		}
+	}
+}
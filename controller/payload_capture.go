@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func getPayloadCapture(c *gin.Context, userId int) {
+	requestId := c.Param("request_id")
+	if requestId == "" {
+		common.ApiErrorMsg(c, "请求 ID 不能为空")
+		return
+	}
+	capture, err := model.GetPayloadCaptureByRequestId(requestId, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "未找到该请求的记录")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, capture)
+}
+
+// GetPayloadCapture 管理员查看任意请求的请求与响应内容
+func GetPayloadCapture(c *gin.Context) {
+	getPayloadCapture(c, 0)
+}
+
+// GetSelfPayloadCapture 用户查看自己请求的请求与响应内容
+func GetSelfPayloadCapture(c *gin.Context) {
+	if !operation_setting.GetPayloadCaptureSetting().UserViewEnabled {
+		common.ApiErrorMsg(c, "管理员未开放查看请求记录")
+		return
+	}
+	getPayloadCapture(c, c.GetInt("id"))
+}
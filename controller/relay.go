@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/common/tracing"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
@@ -20,6 +22,7 @@ import (
 	"one-api/setting"
 	"one-api/types"
 	"strings"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 
@@ -68,6 +71,7 @@ func geminiRelayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewA
 func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	// 获取请求ID用于日志追踪和错误消息标识 [1](@ref)
 	requestId := c.GetString(common.RequestIdKey)
+	startTime := time.Now()
 	// 获取当前使用的组和原始模型信息
 	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
 	originalModel := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
@@ -110,6 +114,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 				})
 			}
 		}
+		retries := len(c.GetStringSlice("use_channel")) - 1
+		metrics.ObserveRelayRequest(c.GetInt("channel_id"), originalModel, group, c.Writer.Status(), time.Since(startTime), retries)
 	}()
 
 	// 获取并验证请求参数 [6](@ref)
@@ -141,7 +147,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 
 	// 计算请求的token数量
+	span := tracing.StartSpan(c, "count_request_token")
 	tokens, err := service.CountRequestToken(c, meta, relayInfo)
+	span.SetAttribute("prompt_tokens", tokens)
+	span.End()
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
 		return
@@ -151,18 +160,28 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	relayInfo.SetPromptTokens(tokens)
 
 	// 计算模型价格数据
+	span = tracing.StartSpan(c, "model_price_helper")
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
+	span.End()
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeModelPriceError)
 		return
 	}
 
 	// 预消耗配额（在实际转发前先检查并扣除配额）
+	span = tracing.StartSpan(c, "pre_consume_quota")
 	newAPIError = service.PreConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
+	span.SetAttribute("pre_consumed_quota", relayInfo.FinalPreConsumedQuota)
+	span.End()
 	if newAPIError != nil {
 		return
 	}
 
+	// 命中响应缓存时直接返回，不再请求上游
+	if relay.ResponseCacheHelper(c, relayInfo) {
+		return
+	}
+
 	// defer函数用于在返回前处理配额归还
 	// 只有当下游处理失败且配额确实被预消耗时，才归还配额
 	defer func() {
@@ -186,18 +205,38 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		// 重置请求体（因为Gin的上下文只能读取一次）
 		requestBody, _ := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		attemptStartTime := time.Now()
+		attemptSpan := tracing.StartSpan(c, "relay_attempt")
+		attemptSpan.SetAttribute("channel.id", channel.Id)
+		attemptSpan.SetAttribute("channel.type", c.GetInt("channel_type"))
+		attemptSpan.SetAttribute("model", originalModel)
+		attemptSpan.SetAttribute("group", group)
+		attemptSpan.SetAttribute("retry.index", i)
+
+		// 在途请求计数在本次尝试结束时释放，处理函数 panic 时也不会泄漏
+		newAPIError = func() *types.NewAPIError {
+			model.IncreaseChannelInFlight(channel.Id)
+			defer model.DecreaseChannelInFlight(channel.Id)
+			// 根据不同的中继格式选择相应的处理函数 [5](@ref)
+			switch relayFormat {
+			case types.RelayFormatOpenAIRealtime:
+				return relay.WssHelper(c, relayInfo) // WebSocket实时通信处理
+			case types.RelayFormatClaude:
+				return relay.ClaudeHelper(c, relayInfo) // Claude格式处理
+			case types.RelayFormatGemini:
+				return geminiRelayHandler(c, relayInfo) // Gemini格式处理
+			default:
+				return relayHandler(c, relayInfo) // 默认处理函数
+			}
+		}()
 
-		// 根据不同的中继格式选择相应的处理函数 [5](@ref)
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo) // WebSocket实时通信处理
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo) // Claude格式处理
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo) // Gemini格式处理
-		default:
-			newAPIError = relayHandler(c, relayInfo) // 默认处理函数
+		if newAPIError != nil {
+			attemptSpan.SetAttribute("http.status_code", newAPIError.StatusCode)
+			attemptSpan.SetError(newAPIError.MaskSensitiveError())
 		}
+		attemptSpan.End()
+		// 记录渠道请求结果，用于熔断、健康评分与路由策略
+		recordChannelResult(c, relayInfo, channel.Id, attemptStartTime, newAPIError)
 
 		// 如果没有错误，说明处理成功，直接返回
 		if newAPIError == nil {
@@ -304,6 +343,59 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return true
 }
 
+// isChannelFailure 是否为渠道侧的失败：渠道配置错误、请求上游失败、上游返回非 200 状态码，
+// 以及上游错误响应中的鉴权失败、限流与 5xx。本地产生的错误（参数转换、额度不足等）不计入
+func isChannelFailure(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	if types.IsChannelError(err) {
+		return true
+	}
+	switch err.GetErrorCode() {
+	case types.ErrorCodeBadResponseStatusCode, types.ErrorCodeDoRequestFailed:
+		return true
+	}
+	if !types.IsUpstreamError(err) {
+		return false
+	}
+	switch {
+	case err.StatusCode == http.StatusUnauthorized,
+		err.StatusCode == http.StatusForbidden,
+		err.StatusCode == http.StatusTooManyRequests,
+		err.StatusCode/100 == 5:
+		return true
+	}
+	return false
+}
+
+// recordChannelResult 记录渠道请求结果，只有渠道侧的错误计为失败
+func recordChannelResult(c *gin.Context, relayInfo *relaycommon.RelayInfo, channelId int, startTime time.Time, err *types.NewAPIError) {
+	keyIndex := -1
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	success := true
+	errMsg := ""
+	if isChannelFailure(err) {
+		success = false
+		errMsg = err.MaskSensitiveError()
+	}
+	model.RecordChannelResultWithContext(c, channelId, keyIndex, success, time.Since(startTime), errMsg)
+	code := http.StatusOK
+	var ttft time.Duration
+	if err == nil {
+		ttft = time.Since(startTime)
+		if relayInfo.HasSendResponse() && relayInfo.FirstResponseTime.After(startTime) {
+			ttft = relayInfo.FirstResponseTime.Sub(startTime)
+		}
+		model.RecordChannelTTFT(channelId, ttft)
+	} else {
+		code = err.StatusCode
+	}
+	metrics.ObserveRelayAttempt(channelId, relayInfo.OriginModelName, relayInfo.UsingGroup, code, ttft)
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
@@ -329,8 +421,16 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 		other["channel_id"] = channelId
 		other["channel_name"] = c.GetString("channel_name")
 		other["channel_type"] = c.GetInt("channel_type")
+		if common.GetContextKeyBool(c, constant.ContextKeyPayloadCaptured) {
+			other["request_id"] = c.GetString(common.RequestIdKey)
+			other["payload_captured"] = true
+		}
 		adminInfo := make(map[string]interface{})
 		adminInfo["use_channel"] = c.GetStringSlice("use_channel")
+		if strategy := common.GetContextKeyString(c, constant.ContextKeyRoutingStrategy); strategy != "" {
+			adminInfo["routing_strategy"] = strategy
+			adminInfo["routing_reason"] = common.GetContextKeyString(c, constant.ContextKeyRoutingReason)
+		}
 		isMultiKey := common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey)
 		if isMultiKey {
 			adminInfo["is_multi_key"] = true
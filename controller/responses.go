@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// getTokenStoredResponse 获取当前令牌保存的响应，不存在时直接返回 404
+func getTokenStoredResponse(c *gin.Context) *model.StoredResponse {
+	responseId := c.Param("id")
+	response, err := model.GetStoredResponse(c.GetInt("token_id"), responseId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fileError(c, http.StatusNotFound, "response_not_found", fmt.Sprintf("Response with id '%s' not found.", responseId))
+		} else {
+			fileError(c, http.StatusInternalServerError, "get_response_failed", err.Error())
+		}
+		return nil
+	}
+	return response
+}
+
+// RetrieveResponse 获取保存的响应 GET /v1/responses/:id
+func RetrieveResponse(c *gin.Context) {
+	response := getTokenStoredResponse(c)
+	if response == nil {
+		return
+	}
+	body, err := service.LoadStoredResponseBody(response)
+	if err != nil {
+		fileError(c, http.StatusInternalServerError, "get_response_failed", err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/json", body.Response)
+}
+
+// DeleteResponse 删除保存的响应 DELETE /v1/responses/:id
+func DeleteResponse(c *gin.Context) {
+	response := getTokenStoredResponse(c)
+	if response == nil {
+		return
+	}
+	if err := service.DeleteStoredResponse(response); err != nil {
+		fileError(c, http.StatusInternalServerError, "delete_response_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dto.ResponsesDeleteResponse{
+		Id:      response.ResponseId,
+		Object:  "response",
+		Deleted: true,
+	})
+}
+
+// ListResponseInputItems 获取响应的输入项 GET /v1/responses/:id/input_items
+func ListResponseInputItems(c *gin.Context) {
+	response := getTokenStoredResponse(c)
+	if response == nil {
+		return
+	}
+	body, err := service.LoadStoredResponseBody(response)
+	if err != nil {
+		fileError(c, http.StatusInternalServerError, "get_response_failed", err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	items := body.Input
+	if c.DefaultQuery("order", "desc") != "asc" {
+		items = make([]json.RawMessage, 0, len(body.Input))
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			items = append(items, body.Input[i])
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		for i, item := range items {
+			if responseItemId(item) == after {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	list := dto.ResponsesInputItemList{
+		Object: "list",
+		Data:   items,
+	}
+	if len(items) > limit {
+		list.Data = items[:limit]
+		list.HasMore = true
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = responseItemId(list.Data[0])
+		list.LastId = responseItemId(list.Data[len(list.Data)-1])
+	} else {
+		list.Data = []json.RawMessage{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func responseItemId(item json.RawMessage) string {
+	var meta struct {
+		Id string `json:"id"`
+	}
+	_ = common.Unmarshal(item, &meta)
+	return meta.Id
+}
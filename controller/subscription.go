@@ -0,0 +1,339 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/ratio_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/stripe/stripe-go/v81/invoice"
+	"github.com/stripe/stripe-go/v81/refund"
+	"github.com/stripe/stripe-go/v81/subscription"
+	"github.com/thanhpk/randstr"
+)
+
+type StripeSubscriptionRequest struct {
+	PlanId        int    `json:"plan_id"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+// RequestSubscription 创建 Stripe 按月循环扣款的订阅支付链接
+func (*StripeAdaptor) RequestSubscription(c *gin.Context, req *StripeSubscriptionRequest) {
+	if req.PaymentMethod != PaymentMethodStripe {
+		c.JSON(200, gin.H{"message": "error", "data": "不支持的支付渠道"})
+		return
+	}
+	plan, err := model.GetPlanById(req.PlanId)
+	if err != nil || plan.Status != model.PlanStatusEnabled {
+		c.JSON(200, gin.H{"message": "error", "data": "套餐不存在"})
+		return
+	}
+	if plan.StripePriceId == "" {
+		c.JSON(200, gin.H{"message": "error", "data": "套餐未配置 Stripe 价格"})
+		return
+	}
+	id := c.GetInt("id")
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "获取用户信息失败"})
+		return
+	}
+
+	reference := fmt.Sprintf("new-api-sub-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
+	referenceId := "sub_" + common.Sha1([]byte(reference))
+	if _, err = model.CreatePendingSubscription(user.Id, plan.Id, referenceId); err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+
+	payLink, err := genStripeSubscriptionLink(referenceId, user.StripeCustomer, user.Email, plan.StripePriceId)
+	if err != nil {
+		log.Println("获取Stripe订阅支付链接失败", err)
+		_ = model.CancelPendingSubscription(referenceId)
+		c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "success",
+		"data": gin.H{
+			"pay_link": payLink,
+		},
+	})
+}
+
+func genStripeSubscriptionLink(referenceId string, customerId string, email string, priceId string) (string, error) {
+	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
+		return "", fmt.Errorf("无效的Stripe API密钥")
+	}
+
+	stripe.Key = setting.StripeApiSecret
+
+	params := &stripe.CheckoutSessionParams{
+		ClientReferenceID: stripe.String(referenceId),
+		SuccessURL:        stripe.String(setting.ServerAddress + "/log"),
+		CancelURL:         stripe.String(setting.ServerAddress + "/topup"),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				Price:    stripe.String(priceId),
+				Quantity: stripe.Int64(1),
+			},
+		},
+		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: map[string]string{"reference_id": referenceId},
+		},
+	}
+
+	// 订阅模式下 Stripe 会自动创建客户
+	if "" == customerId {
+		if "" != email {
+			params.CustomerEmail = stripe.String(email)
+		}
+	} else {
+		params.Customer = stripe.String(customerId)
+	}
+
+	result, err := session.New(params)
+	if err != nil {
+		return "", err
+	}
+
+	return result.URL, nil
+}
+
+func RequestStripeSubscription(c *gin.Context) {
+	var req StripeSubscriptionRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "参数错误"})
+		return
+	}
+	stripeAdaptor.RequestSubscription(c, &req)
+}
+
+func subscriptionSessionCompleted(event stripe.Event) {
+	customerId := event.GetObjectValue("customer")
+	referenceId := event.GetObjectValue("client_reference_id")
+	subscriptionId := event.GetObjectValue("subscription")
+	if err := model.ActivateStripeSubscription(referenceId, customerId, subscriptionId); err != nil {
+		log.Println("订阅生效失败", err.Error(), referenceId)
+		if errors.Is(err, model.ErrDuplicateSubscription) {
+			refundDuplicateSubscription(subscriptionId, event.GetObjectValue("invoice"))
+		}
+		return
+	}
+	log.Printf("订阅已生效：%s, %s", referenceId, subscriptionId)
+}
+
+// refundDuplicateSubscription 用户已有生效中的订阅时，取消重复支付的 Stripe 订阅并退还首期账单
+func refundDuplicateSubscription(subscriptionId string, invoiceId string) {
+	stripe.Key = setting.StripeApiSecret
+	if _, err := subscription.Cancel(subscriptionId, nil); err != nil {
+		log.Println("取消重复订阅失败", err.Error(), subscriptionId)
+	}
+	if invoiceId == "" {
+		log.Println("重复订阅未关联账单，需手动退款", subscriptionId)
+		return
+	}
+	inv, err := invoice.Get(invoiceId, nil)
+	if err != nil {
+		log.Println("获取重复订阅账单失败", err.Error(), invoiceId)
+		return
+	}
+	params := &stripe.RefundParams{Reason: stripe.String(string(stripe.RefundReasonDuplicate))}
+	if inv.PaymentIntent != nil {
+		params.PaymentIntent = stripe.String(inv.PaymentIntent.ID)
+	} else if inv.Charge != nil {
+		params.Charge = stripe.String(inv.Charge.ID)
+	} else {
+		log.Println("重复订阅账单没有支付记录，需手动退款", invoiceId)
+		return
+	}
+	if _, err = refund.New(params); err != nil {
+		log.Println("重复订阅退款失败", err.Error(), invoiceId)
+		return
+	}
+	log.Printf("重复订阅已取消并退款：%s, %s", subscriptionId, invoiceId)
+}
+
+func invoicePaid(event stripe.Event) {
+	// 首期账单由 checkout.session.completed 处理
+	if event.GetObjectValue("billing_reason") != "subscription_cycle" {
+		return
+	}
+	subscriptionId := event.GetObjectValue("subscription")
+	if subscriptionId == "" {
+		subscriptionId = event.GetObjectValue("parent", "subscription_details", "subscription")
+	}
+	if subscriptionId == "" {
+		log.Println("续费账单未关联订阅", event.GetObjectValue("id"))
+		return
+	}
+	invoiceId := event.GetObjectValue("id")
+	if err := model.RenewStripeSubscription(subscriptionId, invoiceId); err != nil {
+		if errors.Is(err, model.ErrSubscriptionInvoiceApplied) {
+			log.Printf("续费账单已处理，忽略重复通知：%s, %s", subscriptionId, invoiceId)
+			return
+		}
+		log.Println("订阅续费失败", err.Error(), subscriptionId)
+		return
+	}
+	log.Printf("订阅已续费：%s", subscriptionId)
+}
+
+func subscriptionDeleted(event stripe.Event) {
+	subscriptionId := event.GetObjectValue("id")
+	if err := model.StopStripeSubscriptionRenewal(subscriptionId); err != nil {
+		log.Println("取消订阅续费失败", err.Error(), subscriptionId)
+		return
+	}
+	log.Printf("订阅已取消续费：%s", subscriptionId)
+}
+
+func validatePlan(plan *model.Plan) error {
+	if plan.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if plan.Quota < 0 || plan.Price < 0 {
+		return errors.New("套餐额度与价格不能为负数")
+	}
+	if plan.Group != "" && !ratio_setting.ContainsGroupRatio(plan.Group) {
+		return fmt.Errorf("分组 %s 不存在", plan.Group)
+	}
+	return nil
+}
+
+// GetPlans 获取启用的订阅套餐
+func GetPlans(c *gin.Context) {
+	plans, err := model.GetPlans(true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+// GetAllPlans 管理员获取全部套餐
+func GetAllPlans(c *gin.Context) {
+	plans, err := model.GetPlans(false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+func AddPlan(c *gin.Context) {
+	plan := model.Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plan.Id = 0
+	if plan.Status == 0 {
+		plan.Status = model.PlanStatusEnabled
+	}
+	if err := validatePlan(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plan.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func UpdatePlan(c *gin.Context) {
+	plan := model.Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetPlanById(plan.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validatePlan(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plan.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func DeletePlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeletePlan(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func getSubscriptionPage(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	subscriptions, total, err := model.GetSubscriptions(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subscriptions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetSelfSubscriptions 获取自己的订阅记录
+func GetSelfSubscriptions(c *gin.Context) {
+	getSubscriptionPage(c, c.GetInt("id"))
+}
+
+// GetAllSubscriptions 管理员查询订阅记录，可按 user_id 过滤
+func GetAllSubscriptions(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getSubscriptionPage(c, userId)
+}
+
+type grantSubscriptionRequest struct {
+	UserId int `json:"user_id"`
+	PlanId int `json:"plan_id"`
+	Months int `json:"months"`
+}
+
+// AdminGrantSubscription 管理员为用户发放订阅，不经过支付
+func AdminGrantSubscription(c *gin.Context) {
+	var req grantSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	subscription, err := model.GrantSubscription(req.UserId, req.PlanId, req.Months)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(req.UserId, model.LogTypeManage, fmt.Sprintf("管理员发放订阅套餐 #%d，有效期 %d 个月", req.PlanId, req.Months))
+	common.ApiSuccess(c, subscription)
+}
+
+// AdminExpireSubscription 管理员立即终止订阅，按到期处理（回收额度、恢复分组）
+func AdminExpireSubscription(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.ExpireSubscription(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
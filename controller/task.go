@@ -13,6 +13,7 @@ import (
 	"one-api/logger"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/service"
 	"sort"
 	"strconv"
 	"time"
@@ -29,6 +30,8 @@ func UpdateTaskBulk() {
 		common.SysLog("任务进度轮询开始")
 		ctx := context.TODO()
 		allTasks := model.GetAllUnFinishSyncTasks(500)
+		// 超时未完成的任务直接标记失败并退款，不再轮询
+		allTasks = service.ReapTimeoutTasks(allTasks)
 		platformTask := make(map[constant.TaskPlatform][]*model.Task)
 		for _, t := range allTasks {
 			platformTask[t.Platform] = append(platformTask[t.Platform], t)
@@ -76,6 +79,8 @@ func UpdateTaskByPlatform(platform constant.TaskPlatform, taskChannelM map[int][
 		//_ = UpdateMidjourneyTaskAll(context.Background(), tasks)
 	case constant.TaskPlatformSuno:
 		_ = UpdateSunoTaskAll(context.Background(), taskChannelM, taskM)
+	case constant.TaskPlatformAsync:
+		// 由 RunAsyncTasks 在后台执行，无需轮询上游
 	default:
 		if err := UpdateVideoTaskAll(context.Background(), platform, taskChannelM, taskM); err != nil {
 			common.SysLog(fmt.Sprintf("UpdateVideoTaskAll fail: %s", err))
@@ -87,7 +92,7 @@ func UpdateSunoTaskAll(ctx context.Context, taskChannelM map[int][]string, taskM
 	for channelId, taskIds := range taskChannelM {
 		err := updateSunoTaskAll(ctx, channelId, taskIds, taskM)
 		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新异步任务失败: %d", channelId, err.Error()))
+			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新异步任务失败: %s", channelId, err.Error()))
 		}
 	}
 	return nil
@@ -108,6 +113,8 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		})
 		if err != nil {
 			common.SysLog(fmt.Sprintf("UpdateMidjourneyTask error2: %v", err))
+		} else {
+			notifyBulkFailedTasks(taskIds, taskM, fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId))
 		}
 		return err
 	}
@@ -139,7 +146,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		return err
 	}
 	if !responseItems.IsSuccess() {
-		common.SysLog(fmt.Sprintf("渠道 #%d 未完成的任务有: %d, 成功获取到任务数: %d", channelId, len(taskIds), string(responseBody)))
+		common.SysLog(fmt.Sprintf("渠道 #%d 未完成的任务有: %d, 上游响应: %s", channelId, len(taskIds), string(responseBody)))
 		return err
 	}
 
@@ -148,6 +155,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		if !checkTaskNeedUpdate(task, responseItem) {
 			continue
 		}
+		oldStatus := task.Status
 
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
@@ -156,35 +164,36 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
 		if responseItem.FailReason != "" || task.Status == model.TaskStatusFailure {
 			logger.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
+			task.Status = model.TaskStatusFailure
 			task.Progress = "100%"
-			//err = model.CacheUpdateUserQuota(task.UserId) ?
-			if err != nil {
-				logger.LogError(ctx, "error update user quota cache: "+err.Error())
-			} else {
-				quota := task.Quota
-				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
-					if err != nil {
-						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
-					}
-					logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, logger.LogQuota(quota))
-					model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-				}
-			}
 		}
 		if responseItem.Status == model.TaskStatusSuccess {
 			task.Progress = "100%"
 		}
 		task.Data = responseItem.Data
 
-		err = task.Update()
-		if err != nil {
+		// 失败时的退款在状态更新成功后进行
+		if _, err = service.ApplyTaskPollResult(task, oldStatus); err != nil {
 			common.SysLog("UpdateMidjourneyTask task error: " + err.Error())
 		}
 	}
 	return nil
 }
 
+// notifyBulkFailedTasks 批量标记失败的任务同样需要触发回调
+func notifyBulkFailedTasks(taskIds []string, taskM map[string]*model.Task, reason string) {
+	for _, taskId := range taskIds {
+		task := taskM[taskId]
+		if task == nil || task.Status == model.TaskStatusFailure {
+			continue
+		}
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+		task.FailReason = reason
+		service.EnqueueTaskWebhook(task)
+	}
+}
+
 func checkTaskNeedUpdate(oldTask *model.Task, newTask dto.SunoDataResponse) bool {
 
 	if oldTask.SubmitTime != newTask.SubmitTime {
@@ -272,3 +281,43 @@ func GetUserTask(c *gin.Context) {
 	pageInfo.SetItems(items)
 	common.ApiSuccess(c, pageInfo)
 }
+
+type forceRefundTaskRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ForceRefundTask 管理员将任务标记为失败并退还额度
+func ForceRefundTask(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	var req forceRefundTaskRequest
+	_ = c.ShouldBindJSON(&req)
+	if req.Reason == "" {
+		req.Reason = "管理员手动退款"
+	}
+	task, err := model.GetTaskById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = service.FailTaskWithRefund(task, req.Reason); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("管理员手动退款异步任务 #%d（%s），退还 %s", task.ID, task.TaskID, logger.LogQuota(task.Quota)))
+	common.ApiSuccess(c, task)
+}
+
+// ForceCompleteTask 管理员将任务标记为成功，不退还额度
+func ForceCompleteTask(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	task, err := model.GetTaskById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = service.CompleteTask(task); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, task)
+}
@@ -13,6 +13,7 @@ import (
 	"one-api/relay"
 	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
+	"one-api/service"
 	"time"
 )
 
@@ -39,6 +40,8 @@ func updateVideoTaskAll(ctx context.Context, platform constant.TaskPlatform, cha
 		})
 		if errUpdate != nil {
 			common.SysLog(fmt.Sprintf("UpdateVideoTask error: %v", errUpdate))
+		} else {
+			notifyBulkFailedTasks(taskIds, taskM, fmt.Sprintf("Failed to get channel info, channel ID: %d", channelId))
 		}
 		return fmt.Errorf("CacheGetChannel failed: %w", err)
 	}
@@ -46,6 +49,9 @@ func updateVideoTaskAll(ctx context.Context, platform constant.TaskPlatform, cha
 	if adaptor == nil {
 		return fmt.Errorf("video adaptor not found")
 	}
+	if setter, ok := adaptor.(channel.TaskOtherSettingsSetter); ok {
+		setter.SetOtherSettings(cacheGetChannel.GetOtherSettings())
+	}
 	for _, taskId := range taskIds {
 		if err := updateVideoSingleTask(ctx, adaptor, cacheGetChannel, taskId, taskM); err != nil {
 			logger.LogError(ctx, fmt.Sprintf("Failed to update video task %s: %s", taskId, err.Error()))
@@ -98,6 +104,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	}
 
 	now := time.Now().Unix()
+	oldStatus := task.Status
 	if taskResult.Status == "" {
 		return fmt.Errorf("task %s status is empty", taskId)
 	}
@@ -126,22 +133,17 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		}
 		task.FailReason = taskResult.Reason
 		logger.LogInfo(ctx, fmt.Sprintf("Task %s failed: %s", task.TaskID, task.FailReason))
-		quota := task.Quota
-		if quota != 0 {
-			if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
-				logger.LogError(ctx, "Failed to increase user quota: "+err.Error())
-			}
-			logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, logger.LogQuota(quota))
-			model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-		}
 	default:
 		return fmt.Errorf("unknown task status %s for task %s", taskResult.Status, taskId)
 	}
 	if taskResult.Progress != "" {
 		task.Progress = taskResult.Progress
 	}
-	if err := task.Update(); err != nil {
+	// 失败时的退款在状态更新成功后进行
+	if changed, err := service.ApplyTaskPollResult(task, oldStatus); err != nil {
 		common.SysLog("UpdateVideoTask task error: " + err.Error())
+	} else if changed {
+		service.MirrorTaskAsset(task)
 	}
 
 	return nil
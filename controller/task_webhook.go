@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTaskWebhookDeliveries 查询异步任务回调投递记录
+func GetTaskWebhookDeliveries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	taskId, _ := strconv.ParseInt(c.Query("task_id"), 10, 64)
+	deliveries, total, err := model.GetTaskWebhookDeliveries(taskId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RedeliverTaskWebhook 立即重新投递一次回调，返回投递后的记录
+func RedeliverTaskWebhook(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	delivery, err := model.GetTaskWebhookDeliveryById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = service.DeliverTaskWebhook(delivery); err != nil {
+		common.ApiErrorMsg(c, "重新投递失败："+err.Error())
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"strconv"
 	"strings"
 
@@ -148,6 +149,14 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.DailyQuotaLimit < 0 || token.WeeklyQuotaLimit < 0 || token.MonthlyQuotaLimit < 0 || token.RpmLimit < 0 || token.TpmLimit < 0 {
+		common.ApiErrorMsg(c, "令牌限额不能为负数")
+		return
+	}
+	if err = service.ValidateTaskCallbackUrl(token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -157,6 +166,14 @@ func AddToken(c *gin.Context) {
 		common.SysLog("failed to generate token key: " + err.Error())
 		return
 	}
+	// 组织令牌使用组织额度，仅组织成员可以创建
+	if token.OrganizationId != 0 {
+		member, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt("id"))
+		if err != nil || member.HasRole(model.OrganizationRoleBilling) {
+			common.ApiErrorMsg(c, "无权创建该组织的令牌")
+			return
+		}
+	}
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
 		Name:               token.Name,
@@ -170,6 +187,16 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+
+		ResponseCacheEnabled:  token.ResponseCacheEnabled,
+		PayloadCaptureEnabled: token.PayloadCaptureEnabled,
+		OrganizationId:        token.OrganizationId,
+		DailyQuotaLimit:       token.DailyQuotaLimit,
+		WeeklyQuotaLimit:      token.WeeklyQuotaLimit,
+		MonthlyQuotaLimit:     token.MonthlyQuotaLimit,
+		RpmLimit:              token.RpmLimit,
+		TpmLimit:              token.TpmLimit,
+		CallbackUrl:           token.CallbackUrl,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -214,6 +241,14 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.DailyQuotaLimit < 0 || token.WeeklyQuotaLimit < 0 || token.MonthlyQuotaLimit < 0 || token.RpmLimit < 0 || token.TpmLimit < 0 {
+		common.ApiErrorMsg(c, "令牌限额不能为负数")
+		return
+	}
+	if err = service.ValidateTaskCallbackUrl(token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -247,6 +282,14 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.ResponseCacheEnabled = token.ResponseCacheEnabled
+		cleanToken.PayloadCaptureEnabled = token.PayloadCaptureEnabled
+		cleanToken.DailyQuotaLimit = token.DailyQuotaLimit
+		cleanToken.WeeklyQuotaLimit = token.WeeklyQuotaLimit
+		cleanToken.MonthlyQuotaLimit = token.MonthlyQuotaLimit
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.CallbackUrl = token.CallbackUrl
 	}
 	err = cleanToken.Update()
 	if err != nil {
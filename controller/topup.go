@@ -59,9 +59,10 @@ func GetTopUpInfo(c *gin.Context) {
 }
 
 type EpayRequest struct {
-	Amount        int64  `json:"amount"`
-	PaymentMethod string `json:"payment_method"`
-	TopUpCode     string `json:"top_up_code"`
+	Amount         int64  `json:"amount"`
+	PaymentMethod  string `json:"payment_method"`
+	TopUpCode      string `json:"top_up_code"`
+	OrganizationId int    `json:"organization_id"`
 }
 
 type AmountRequest struct {
@@ -135,6 +136,10 @@ func RequestEpay(c *gin.Context) {
 	}
 
 	id := c.GetInt("id")
+	if req.OrganizationId != 0 && !canRechargeOrganization(req.OrganizationId, id) {
+		c.JSON(200, gin.H{"message": "error", "data": "无权为该组织充值"})
+		return
+	}
 	group, err := model.GetUserGroup(id, true)
 	if err != nil {
 		c.JSON(200, gin.H{"message": "error", "data": "获取用户分组失败"})
@@ -187,6 +192,8 @@ func RequestEpay(c *gin.Context) {
 		TradeNo:    tradeNo,
 		CreateTime: time.Now().Unix(),
 		Status:     "pending",
+
+		OrganizationId: req.OrganizationId,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -262,17 +269,26 @@ func EpayNotify(c *gin.Context) {
 			return
 		}
 		if topUp.Status == "pending" {
+			//user, _ := model.GetUserById(topUp.UserId, false)
+			//user.Quota += topUp.Amount * 500000
+			dAmount := decimal.NewFromInt(int64(topUp.Amount))
+			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+			quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
 			topUp.Status = "success"
+			topUp.CompleteTime = common.GetTimestamp()
+			topUp.Quota = int64(quotaToAdd)
 			err := topUp.Update()
 			if err != nil {
 				log.Printf("易支付回调更新订单失败: %v", topUp)
 				return
 			}
-			//user, _ := model.GetUserById(topUp.UserId, false)
-			//user.Quota += topUp.Amount * 500000
-			dAmount := decimal.NewFromInt(int64(topUp.Amount))
-			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
+			if topUp.OrganizationId != 0 {
+				err = model.RechargeOrganization(topUp.OrganizationId, topUp.UserId, quotaToAdd, fmt.Sprintf("使用在线充值成功，支付金额：%f", topUp.Money))
+				if err != nil {
+					log.Printf("易支付回调更新组织失败: %v", topUp)
+				}
+				return
+			}
 			err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true)
 			if err != nil {
 				log.Printf("易支付回调更新用户失败: %v", topUp)
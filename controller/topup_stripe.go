@@ -27,8 +27,9 @@ const (
 var stripeAdaptor = &StripeAdaptor{}
 
 type StripePayRequest struct {
-	Amount        int64  `json:"amount"`
-	PaymentMethod string `json:"payment_method"`
+	Amount         int64  `json:"amount"`
+	PaymentMethod  string `json:"payment_method"`
+	OrganizationId int    `json:"organization_id"`
 }
 
 type StripeAdaptor struct {
@@ -68,6 +69,10 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 	}
 
 	id := c.GetInt("id")
+	if req.OrganizationId != 0 && !canRechargeOrganization(req.OrganizationId, id) {
+		c.JSON(200, gin.H{"message": "error", "data": "无权为该组织充值"})
+		return
+	}
 	user, _ := model.GetUserById(id, false)
 	chargedMoney := GetChargedAmount(float64(req.Amount), *user)
 
@@ -88,6 +93,8 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		TradeNo:    referenceId,
 		CreateTime: time.Now().Unix(),
 		Status:     common.TopUpStatusPending,
+
+		OrganizationId: req.OrganizationId,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -147,6 +154,10 @@ func StripeWebhook(c *gin.Context) {
 		sessionCompleted(event)
 	case stripe.EventTypeCheckoutSessionExpired:
 		sessionExpired(event)
+	case stripe.EventTypeInvoicePaid:
+		invoicePaid(event)
+	case stripe.EventTypeCustomerSubscriptionDeleted:
+		subscriptionDeleted(event)
 	default:
 		log.Printf("不支持的Stripe Webhook事件类型: %s\n", event.Type)
 	}
@@ -163,6 +174,11 @@ func sessionCompleted(event stripe.Event) {
 		return
 	}
 
+	if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+		subscriptionSessionCompleted(event)
+		return
+	}
+
 	err := model.Recharge(referenceId, customerId)
 	if err != nil {
 		log.Println(err.Error(), referenceId)
@@ -187,6 +203,13 @@ func sessionExpired(event stripe.Event) {
 		return
 	}
 
+	if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+		if err := model.CancelPendingSubscription(referenceId); err != nil {
+			log.Println("取消待支付订阅失败", referenceId, ", err:", err.Error())
+		}
+		return
+	}
+
 	topUp := model.GetTopUpByTradeNo(referenceId)
 	if topUp == nil {
 		log.Println("充值订单不存在", referenceId)
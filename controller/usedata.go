@@ -43,6 +43,9 @@ func GetUserQuotaDates(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	for _, date := range dates {
+		date.Cost = 0
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
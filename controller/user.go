@@ -462,6 +462,7 @@ func GetSelf(c *gin.Context) {
 		"stripe_customer":   user.StripeCustomer,
 		"sidebar_modules":   userSetting.SidebarModules, // 正确提取sidebar_modules字段
 		"permissions":       permissions,                // 新增权限字段
+		"subscription":      model.GetUserSubscriptionView(user.Id),
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -638,7 +639,7 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 	if originUser.Quota != updatedUser.Quota {
-		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
+		model.RecordQuotaAdjustmentLog(originUser.Id, updatedUser.Quota-originUser.Quota, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -1016,7 +1017,8 @@ func EmailBind(c *gin.Context) {
 }
 
 type topUpRequest struct {
-	Key string `json:"key"`
+	Key            string `json:"key"`
+	OrganizationId int    `json:"organization_id"`
 }
 
 var topUpLocks sync.Map
@@ -1077,7 +1079,16 @@ func TopUp(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	quota, err := model.Redeem(req.Key, id)
+	var quota int
+	if req.OrganizationId != 0 {
+		if !canRechargeOrganization(req.OrganizationId, id) {
+			common.ApiErrorMsg(c, "无权为该组织充值")
+			return
+		}
+		quota, err = model.RedeemForOrganization(req.Key, id, req.OrganizationId)
+	} else {
+		quota, err = model.Redeem(req.Key, id)
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -7,6 +7,10 @@ type ChannelSettings struct {
 	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
 	SystemPrompt           string `json:"system_prompt,omitempty"`
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	// 向上游传递 W3C traceparent 请求头
+	PassTraceparent bool `json:"pass_traceparent,omitempty"`
+	// 将 /v1/responses 请求转换为 Chat Completions 格式发送，适用于仅支持 Chat Completions 的 OpenAI 兼容渠道
+	ResponsesToChatCompletions bool `json:"responses_to_chat_completions,omitempty"`
 }
 
 type VertexKeyType string
@@ -19,4 +23,74 @@ const (
 type ChannelOtherSettings struct {
 	AzureResponsesVersion string        `json:"azure_responses_version,omitempty"`
 	VertexKeyType         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
+	// 自定义异步任务渠道的提交、查询与结果解析配置
+	TaskConfig *CustomTaskConfig `json:"task_config,omitempty"`
+	// 实时语音（/v1/realtime）上游模式，留空时 Gemini 渠道使用 gemini_live，其余渠道直连 OpenAI Realtime 协议
+	RealtimeMode string `json:"realtime_mode,omitempty"`
+	// realtime_mode 为 pipeline 时使用的语音识别、语音合成配置
+	RealtimePipeline *RealtimePipelineConfig `json:"realtime_pipeline,omitempty"`
+	// 上游成本配置，用于在消费日志中记录上游成本并统计毛利
+	CostConfig *ChannelCostConfig `json:"cost_config,omitempty"`
+}
+
+// ChannelCostConfig 渠道上游成本配置，model_costs 中的模型配置优先于渠道级 cost_ratio
+type ChannelCostConfig struct {
+	// 上游成本占实际扣费额度的比例，例如 0.6 表示成本为扣费额度的 60%
+	CostRatio  float64                     `json:"cost_ratio,omitempty"`
+	ModelCosts map[string]ChannelModelCost `json:"model_costs,omitempty"`
+}
+
+// ChannelModelCost 单个模型的上游成本，价格单位为美元
+type ChannelModelCost struct {
+	// 每百万输入、输出 token 的价格
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+	// 按次价格，设置后忽略 token 价格
+	CallPrice float64 `json:"call_price,omitempty"`
+	// 成本占扣费额度的比例，未设置价格时生效
+	CostRatio float64 `json:"cost_ratio,omitempty"`
+}
+
+const (
+	RealtimeModeGeminiLive = "gemini_live"
+	RealtimeModePipeline   = "pipeline"
+)
+
+// RealtimePipelineConfig 通过渠道的 OpenAI 兼容接口组合 语音识别 -> 对话 -> 语音合成，对话使用请求的模型
+type RealtimePipelineConfig struct {
+	// 语音识别模型，默认 whisper-1
+	SttModel string `json:"stt_model,omitempty"`
+	// 语音合成模型，默认 tts-1
+	TtsModel string `json:"tts_model,omitempty"`
+	// 语音合成音色，默认使用会话设置的 voice
+	TtsVoice string `json:"tts_voice,omitempty"`
+}
+
+// CustomTaskConfig 自定义异步任务（视频/音乐等）渠道配置，JSON 路径使用 gjson 语法
+type CustomTaskConfig struct {
+	// 提交任务的路径，拼接在渠道 BaseURL 之后，支持 {model} 与 {action} 占位符
+	SubmitPath string `json:"submit_path"`
+	// 提交请求体模板，支持 {{prompt}}、{{model}}、{{image}}、{{images}}、{{size}}、{{duration}}、{{mode}}、{{metadata}}、{{metadata.xxx}}
+	// 占位符会被替换为 JSON 编码后的值；为空时透传原始请求体
+	BodyTemplate string `json:"body_template,omitempty"`
+	// 提交响应中任务 ID 的路径
+	TaskIdPath string `json:"task_id_path"`
+	// 查询任务的路径，支持 {task_id} 占位符
+	FetchPath string `json:"fetch_path"`
+	// 查询任务的请求方法，默认 GET
+	FetchMethod string `json:"fetch_method,omitempty"`
+	// 查询请求体模板（POST 时使用），支持 {{task_id}} 占位符
+	FetchBodyTemplate string `json:"fetch_body_template,omitempty"`
+	// 查询响应中任务状态、进度、结果地址与失败原因的路径
+	StatusPath     string `json:"status_path"`
+	ProgressPath   string `json:"progress_path,omitempty"`
+	ResultUrlPath  string `json:"result_url_path"`
+	FailReasonPath string `json:"fail_reason_path,omitempty"`
+	// 进度的满值：1 表示 0-1 小数，100 表示 0-100；为空时自动判断，整数 1 无法区分时按 1% 处理
+	ProgressScale float64 `json:"progress_scale,omitempty"`
+	// 上游状态值到 SUBMITTED/QUEUED/IN_PROGRESS/SUCCESS/FAILURE 的映射，未配置的值按常见状态名匹配
+	StatusMapping map[string]string `json:"status_mapping,omitempty"`
+	// 鉴权请求头，默认 Authorization，前缀默认 "Bearer "
+	AuthHeader string  `json:"auth_header,omitempty"`
+	AuthPrefix *string `json:"auth_prefix,omitempty"`
 }
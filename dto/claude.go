@@ -33,6 +33,7 @@ type ClaudeMediaMessage struct {
 	Input     any    `json:"input,omitempty"`
 	Content   any    `json:"content,omitempty"`
 	ToolUseId string `json:"tool_use_id,omitempty"`
+	IsError   *bool  `json:"is_error,omitempty"`
 }
 
 func (c *ClaudeMediaMessage) SetText(s string) {
@@ -511,3 +512,8 @@ type ClaudeUsage struct {
 type ClaudeServerToolUse struct {
 	WebSearchRequests int `json:"web_search_requests"`
 }
+
+// ClaudeCountTokensResponse POST /v1/messages/count_tokens 的响应
+type ClaudeCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+// OpenAIFileObject https://platform.openai.com/docs/api-reference/files/object
+type OpenAIFileObject struct {
+	Id        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status,omitempty"`
+}
+
+type OpenAIFileListResponse struct {
+	Object  string             `json:"object"`
+	Data    []OpenAIFileObject `json:"data"`
+	FirstId string             `json:"first_id,omitempty"`
+	LastId  string             `json:"last_id,omitempty"`
+	HasMore bool               `json:"has_more"`
+}
+
+type OpenAIFileDeleteResponse struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
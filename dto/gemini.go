@@ -14,6 +14,7 @@ type GeminiChatRequest struct {
 	SafetySettings     []GeminiChatSafetySettings `json:"safetySettings,omitempty"`
 	GenerationConfig   GeminiChatGenerationConfig `json:"generationConfig,omitempty"`
 	Tools              json.RawMessage            `json:"tools,omitempty"`
+	ToolConfig         *GeminiToolConfig          `json:"toolConfig,omitempty"`
 	SystemInstructions *GeminiChatContent         `json:"systemInstruction,omitempty"`
 }
 
@@ -151,11 +152,13 @@ func (g *GeminiInlineData) UnmarshalJSON(data []byte) error {
 }
 
 type FunctionCall struct {
+	Id           string `json:"id,omitempty"`
 	FunctionName string `json:"name"`
 	Arguments    any    `json:"args"`
 }
 
 type GeminiFunctionResponse struct {
+	Id       string                 `json:"id,omitempty"`
 	Name     string                 `json:"name"`
 	Response map[string]interface{} `json:"response"`
 }
@@ -184,6 +187,7 @@ type GeminiPart struct {
 	FileData            *GeminiFileData                `json:"fileData,omitempty"`
 	ExecutableCode      *GeminiPartExecutableCode      `json:"executableCode,omitempty"`
 	CodeExecutionResult *GeminiPartCodeExecutionResult `json:"codeExecutionResult,omitempty"`
+	ThoughtSignature    string                         `json:"thoughtSignature,omitempty"`
 }
 
 // UnmarshalJSON custom unmarshaler for GeminiPart to support snake_case and camelCase for InlineData
@@ -223,6 +227,15 @@ type GeminiChatSafetySettings struct {
 	Threshold string `json:"threshold"`
 }
 
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
 type GeminiChatTool struct {
 	GoogleSearch          any `json:"googleSearch,omitempty"`
 	GoogleSearchRetrieval any `json:"googleSearchRetrieval,omitempty"`
@@ -273,6 +286,8 @@ type GeminiUsageMetadata struct {
 	TotalTokenCount      int                         `json:"totalTokenCount"`
 	ThoughtsTokenCount   int                         `json:"thoughtsTokenCount"`
 	PromptTokensDetails  []GeminiPromptTokensDetails `json:"promptTokensDetails"`
+
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
 }
 
 type GeminiPromptTokensDetails struct {
@@ -382,3 +397,15 @@ type GeminiBatchEmbeddingResponse struct {
 type ContentEmbedding struct {
 	Values []float64 `json:"values"`
 }
+
+// GeminiCountTokensRequest models/{model}:countTokens 的请求，contents 与 generateContentRequest 二选一
+type GeminiCountTokensRequest struct {
+	Contents               []GeminiChatContent `json:"contents,omitempty"`
+	GenerateContentRequest *GeminiChatRequest  `json:"generateContentRequest,omitempty"`
+}
+
+type GeminiCountTokensResponse struct {
+	TotalTokens             int                         `json:"totalTokens"`
+	CachedContentTokenCount int                         `json:"cachedContentTokenCount,omitempty"`
+	PromptTokensDetails     []GeminiPromptTokensDetails `json:"promptTokensDetails,omitempty"`
+}
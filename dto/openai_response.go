@@ -281,8 +281,14 @@ type ResponsesOutput struct {
 	Type    string                   `json:"type"`
 	ID      string                   `json:"id"`
 	Status  string                   `json:"status"`
-	Role    string                   `json:"role"`
+	Role    string                   `json:"role,omitempty"`
 	Content []ResponsesOutputContent `json:"content"`
+	// function_call
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// reasoning
+	Summary []ResponsesOutputContent `json:"summary,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -305,12 +311,27 @@ const (
 	ResponsesOutputTypeItemDone  = "response.output_item.done"
 )
 
+const (
+	ResponsesOutputItemMessage      = "message"
+	ResponsesOutputItemFunctionCall = "function_call"
+	ResponsesOutputItemReasoning    = "reasoning"
+)
+
 // ResponsesStreamResponse 用于处理 /v1/responses 流式响应
 type ResponsesStreamResponse struct {
 	Type     string                   `json:"type"`
 	Response *OpenAIResponsesResponse `json:"response,omitempty"`
 	Delta    string                   `json:"delta,omitempty"`
 	Item     *ResponsesOutput         `json:"item,omitempty"`
+
+	SequenceNumber int                     `json:"sequence_number"`
+	OutputIndex    *int                    `json:"output_index,omitempty"`
+	ContentIndex   *int                    `json:"content_index,omitempty"`
+	SummaryIndex   *int                    `json:"summary_index,omitempty"`
+	ItemId         string                  `json:"item_id,omitempty"`
+	Text           *string                 `json:"text,omitempty"`
+	Arguments      *string                 `json:"arguments,omitempty"`
+	Part           *ResponsesOutputContent `json:"part,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -354,3 +375,18 @@ func GetOpenAIError(errorField any) *types.OpenAIError {
 		}
 	}
 }
+
+// ResponsesInputItemList GET /v1/responses/{id}/input_items 的响应
+type ResponsesInputItemList struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	FirstId string            `json:"first_id,omitempty"`
+	LastId  string            `json:"last_id,omitempty"`
+	HasMore bool              `json:"has_more"`
+}
+
+type ResponsesDeleteResponse struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
@@ -7,7 +7,10 @@ const (
 	RealtimeEventTypeSessionUpdate      = "session.update"
 	RealtimeEventTypeConversationCreate = "conversation.item.create"
 	RealtimeEventTypeResponseCreate     = "response.create"
+	RealtimeEventTypeResponseCancel     = "response.cancel"
 	RealtimeEventInputAudioBufferAppend = "input_audio_buffer.append"
+	RealtimeEventInputAudioBufferCommit = "input_audio_buffer.commit"
+	RealtimeEventInputAudioBufferClear  = "input_audio_buffer.clear"
 )
 
 const (
@@ -19,6 +22,14 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+	RealtimeEventInputAudioBufferCommitted          = "input_audio_buffer.committed"
+	RealtimeEventInputAudioBufferCleared            = "input_audio_buffer.cleared"
+	RealtimeEventInputAudioTranscriptionCompleted   = "conversation.item.input_audio_transcription.completed"
+	RealtimeEventResponseCreated                    = "response.created"
+	RealtimeEventResponseTextDelta                  = "response.text.delta"
+	RealtimeEventResponseTextDone                   = "response.text.done"
+	RealtimeEventResponseAudioDone                  = "response.audio.done"
+	RealtimeEventResponseAudioTranscriptionDone     = "response.audio_transcript.done"
 )
 
 type RealtimeEvent struct {
@@ -31,10 +42,18 @@ type RealtimeEvent struct {
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+	// 以下字段仅在网关转换非 OpenAI 后端时生成
+	ItemId     string `json:"item_id,omitempty"`
+	ResponseId string `json:"response_id,omitempty"`
+	Text       string `json:"text,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
 }
 
 type RealtimeResponse struct {
-	Usage *RealtimeUsage `json:"usage"`
+	Id     string         `json:"id,omitempty"`
+	Object string         `json:"object,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Usage  *RealtimeUsage `json:"usage"`
 }
 
 type RealtimeUsage struct {
@@ -79,6 +98,7 @@ type RealtimeItem struct {
 	Name      *string           `json:"name,omitempty"`
 	ToolCalls any               `json:"tool_calls,omitempty"`
 	CallId    string            `json:"call_id,omitempty"`
+	Output    string            `json:"output,omitempty"`
 }
 type RealtimeContent struct {
 	Type       string `json:"type"`
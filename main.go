@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"one-api/common"
+	"one-api/common/tracing"
 	"one-api/constant"
 	"one-api/controller"
 	"one-api/logger"
@@ -26,8 +27,6 @@ import (
 )
 
 func main() {
-	http.DefaultTransport = model.NewLoggingTransport()
-
 	err := InitResources()
 	if err != nil {
 		common.FatalLog("failed to initialize resources: " + err.Error())
@@ -99,6 +98,32 @@ func main() {
 			controller.UpdateTaskBulk()
 		})
 	}
+	if common.IsMasterNode {
+		gopool.Go(func() {
+			controller.RunBatchJobs()
+		})
+		gopool.Go(func() {
+			controller.RunAsyncTasks()
+		})
+		gopool.Go(func() {
+			model.CleanupPayloadCaptures()
+		})
+		gopool.Go(func() {
+			service.CleanupStoredResponses()
+		})
+		gopool.Go(func() {
+			service.RunTaskWebhookDeliveries()
+		})
+		gopool.Go(func() {
+			service.CleanupExpiredAssets()
+		})
+		gopool.Go(func() {
+			service.RunBillingStatements()
+		})
+		gopool.Go(func() {
+			service.RunSubscriptionJobs()
+		})
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -169,6 +194,9 @@ func InitResources() error {
 
 	service.InitHttpClient()
 
+	// 链路追踪
+	tracing.Init()
+
 	service.InitTokenEncoders()
 
 	// Initialize SQL Database
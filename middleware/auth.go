@@ -307,6 +307,11 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 		c.Set("token_model_limit_enabled", false)
 	}
 	c.Set("token_group", token.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCacheEnabled)
+	common.SetContextKey(c, constant.ContextKeyTokenPayloadCapture, token.PayloadCaptureEnabled)
+	common.SetContextKey(c, constant.ContextKeyTokenOrganizationId, token.OrganizationId)
+	common.SetContextKey(c, constant.ContextKeyTokenLimits, token.GetLimits())
+	common.SetContextKey(c, constant.ContextKeyTokenCallbackUrl, token.CallbackUrl)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/tracing"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
@@ -27,6 +28,15 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span := tracing.StartSpan(c, "distribute")
+		// 中途返回时结束 span，正常情况下在 c.Next() 之前结束
+		defer span.End()
+		// 请求结束时释放没有记录结果的熔断探测名额
+		defer model.ReleaseChannelBreakerProbes(c)
+		// token 计数只借用渠道的计数接口，失败时在本地估算，不作为半开渠道的探测请求
+		if IsCountTokensRequest(c) {
+			common.SetContextKey(c, constant.ContextKeyChannelBreakerSkipProbe, true)
+		}
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -118,6 +128,13 @@ func Distribute() func(c *gin.Context) {
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
 		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if channel != nil {
+			span.SetAttribute("channel.id", channel.Id)
+			span.SetAttribute("channel.type", channel.Type)
+		}
+		span.SetAttribute("model", modelRequest.Model)
+		span.SetAttribute("group", common.GetContextKeyString(c, constant.ContextKeyUsingGroup))
+		span.End()
 		c.Next()
 	}
 }
@@ -264,6 +281,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 		return newAPIError
 	}
 	if channel.ChannelInfo.IsMultiKey {
+		model.ChannelBreakerAcquire(c, channel.Id, index)
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
 	} else {
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/service"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// PayloadCapture 按令牌或分组开启时记录请求与响应内容，需放在 TokenAuth 之后
+func PayloadCapture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !service.ShouldCapturePayload(c) {
+			c.Next()
+			return
+		}
+		startTime := time.Now()
+		var requestBody []byte
+		if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+			requestBody, _ = common.GetRequestBody(c)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		}
+		common.SetContextKey(c, constant.ContextKeyPayloadCaptured, true)
+		writer := service.NewPayloadCaptureWriter(c.Writer)
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		record := service.NewPayloadCaptureRecord(c, requestBody, writer, startTime)
+		gopool.Go(func() {
+			service.SavePayloadCapture(record)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"one-api/common"
+	"strings"
 	"time"
 )
 
@@ -111,3 +112,30 @@ func DownloadRateLimit() func(c *gin.Context) {
 func UploadRateLimit() func(c *gin.Context) {
 	return rateLimitFactory(common.UploadRateLimitNum, common.UploadRateLimitDuration, "UP")
 }
+
+// IsCountTokensRequest 是否为 token 计数请求
+func IsCountTokensRequest(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return strings.HasSuffix(path, "/messages/count_tokens") || strings.HasSuffix(path, ":countTokens")
+}
+
+// CountTokensRateLimit token 计数接口按令牌限流，其他请求不受影响
+func CountTokensRateLimit() func(c *gin.Context) {
+	if !common.CountTokensRateLimitEnable {
+		return defNext
+	}
+	if !common.RedisEnabled {
+		inMemoryRateLimiter.Init(common.RateLimitKeyExpirationDuration)
+	}
+	return func(c *gin.Context) {
+		if !IsCountTokensRequest(c) {
+			return
+		}
+		mark := fmt.Sprintf("CNT%d:", c.GetInt("token_id"))
+		if common.RedisEnabled {
+			redisRateLimiter(c, common.CountTokensRateLimitNum, common.CountTokensRateLimitDuration, mark)
+		} else {
+			memoryRateLimiter(c, common.CountTokensRateLimitNum, common.CountTokensRateLimitDuration, mark)
+		}
+	}
+}
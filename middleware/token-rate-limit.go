@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/logger"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tokenLimitPeriodNames = map[string]string{
+	model.TokenLimitPeriodDay:   "每日",
+	model.TokenLimitPeriodWeek:  "每周",
+	model.TokenLimitPeriodMonth: "每月",
+}
+
+func secondsUntil(t time.Time) int64 {
+	seconds := int64(time.Until(t).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// setTokenRateLimitHeaders 设置 x-ratelimit-* 响应头，kind 为 requests 或 tokens
+func setTokenRateLimitHeaders(c *gin.Context, kind string, limit int, used int64, resetAt time.Time) {
+	remaining := int64(limit) - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header("x-ratelimit-limit-"+kind, strconv.Itoa(limit))
+	c.Header("x-ratelimit-remaining-"+kind, strconv.FormatInt(remaining, 10))
+	c.Header("x-ratelimit-reset-"+kind, fmt.Sprintf("%ds", secondsUntil(resetAt)))
+}
+
+func abortWithTokenRateLimit(c *gin.Context, resetAt time.Time, message string, code string) {
+	c.Header("Retry-After", strconv.FormatInt(secondsUntil(resetAt), 10))
+	abortWithOpenAiMessage(c, http.StatusTooManyRequests, message, code)
+}
+
+// TokenRateLimit 令牌限流中间件：每分钟请求数、每分钟 token 数以及按日/周/月的消费额度
+func TokenRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// token 计数请求不扣除额度，只受 CountTokensRateLimit 限制
+		if IsCountTokensRequest(c) {
+			c.Next()
+			return
+		}
+		limits, ok := common.GetContextKeyType[model.TokenLimits](c, constant.ContextKeyTokenLimits)
+		if !ok || limits.IsEmpty() {
+			c.Next()
+			return
+		}
+		tokenId := c.GetInt("token_id")
+
+		if limits.Rpm > 0 {
+			count, resetAt := model.IncrTokenRequestCount(tokenId)
+			setTokenRateLimitHeaders(c, "requests", limits.Rpm, count, resetAt)
+			if count > int64(limits.Rpm) {
+				abortWithTokenRateLimit(c, resetAt, fmt.Sprintf("令牌已达到请求数限制：每分钟最多请求%d次，请在 %d 秒后重试", limits.Rpm, secondsUntil(resetAt)), "token_rpm_limit_exceeded")
+				return
+			}
+		}
+
+		// token 数在请求结束后才能确定，这里只是预检查：本分钟已结算的 token 数未达上限即放行，
+		// 不预估本次请求的 token 数，所以最后一个放行的请求可能使用量超过上限
+		if limits.Tpm > 0 {
+			used, resetAt := model.GetTokenTokenUsage(tokenId)
+			setTokenRateLimitHeaders(c, "tokens", limits.Tpm, used, resetAt)
+			if used >= int64(limits.Tpm) {
+				abortWithTokenRateLimit(c, resetAt, fmt.Sprintf("令牌已达到 token 数限制：每分钟最多使用%d个 token，请在 %d 秒后重试", limits.Tpm, secondsUntil(resetAt)), "token_tpm_limit_exceeded")
+				return
+			}
+		}
+
+		for _, period := range []string{model.TokenLimitPeriodDay, model.TokenLimitPeriodWeek, model.TokenLimitPeriodMonth} {
+			quotaLimit := limits.PeriodQuota(period)
+			if quotaLimit <= 0 {
+				continue
+			}
+			spent, resetAt := model.GetTokenSpend(tokenId, period)
+			if spent >= int64(quotaLimit) {
+				abortWithTokenRateLimit(c, resetAt, fmt.Sprintf("令牌已达到%s消费额度限制：%s，将于 %s 重置", tokenLimitPeriodNames[period], logger.FormatQuota(quotaLimit), resetAt.Format("2006-01-02 15:04:05")), "token_"+period+"_quota_exceeded")
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"one-api/common/tracing"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tracing 为中继请求创建根 span
+func Tracing() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+		span := tracing.StartRootSpan(c, c.Request.Method+" "+c.FullPath())
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", c.FullPath())
+		c.Header("X-Trace-Id", span.TraceId())
+		defer span.End()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", status)
+		span.SetAttribute("user.id", c.GetInt("id"))
+		span.SetAttribute("token.id", c.GetInt("token_id"))
+		if status >= 400 {
+			span.SetError("http status " + strconv.Itoa(status))
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -102,7 +103,8 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 	return channelQuery, nil
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// GetRandomSatisfiedChannel 未启用内存缓存时从数据库中选择渠道，选择规则与内存缓存路径一致
+func GetRandomSatisfiedChannel(c *gin.Context, group string, model string, retry int) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
@@ -118,28 +120,21 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	if err != nil {
 		return nil, err
 	}
-	channel := Channel{}
-	if len(abilities) > 0 {
-		// Randomly choose one
-		weightSum := uint(0)
-		for _, ability_ := range abilities {
-			weightSum += ability_.Weight + 10
-		}
-		// Randomly choose one
-		weight := common.GetRandomInt(int(weightSum))
-		for _, ability_ := range abilities {
-			weight -= int(ability_.Weight) + 10
-			//log.Printf("weight: %d, ability weight: %d", weight, *ability_.Weight)
-			if weight <= 0 {
-				channel.Id = ability_.ChannelId
-				break
-			}
-		}
-	} else {
+	if len(abilities) == 0 {
+		return nil, nil
+	}
+	channelIds := make([]int, 0, len(abilities))
+	for _, ability_ := range abilities {
+		channelIds = append(channelIds, ability_.ChannelId)
+	}
+	var channels []*Channel
+	if err = DB.Where("id in (?)", channelIds).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
 		return nil, nil
 	}
-	err = DB.First(&channel, "id = ?", channel.Id).Error
-	return &channel, err
+	return selectSatisfiedChannel(c, group, model, channels)
 }
 
 func (channel *Channel) AddAbilities(tx *gorm.DB) error {
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+const (
+	AssetSourceTask       = "task"
+	AssetSourceMidjourney = "midjourney"
+)
+
+// Asset 转存到网关存储的任务生成媒体，通过签名链接访问，过期后删除
+type Asset struct {
+	Id          int    `json:"id"`
+	AssetId     string `json:"asset_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	SourceType  string `json:"source_type" gorm:"type:varchar(20);index:idx_asset_source"`
+	SourceId    string `json:"source_id" gorm:"type:varchar(64);index:idx_asset_source"` // Task.ID 或 Midjourney.Id
+	SourceUrl   string `json:"source_url" gorm:"type:text"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (asset *Asset) Insert() error {
+	if asset.CreatedAt == 0 {
+		asset.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(asset).Error
+}
+
+func (asset *Asset) Delete() error {
+	return DB.Delete(asset).Error
+}
+
+// GetAssetByAssetId 获取未过期的资源
+func GetAssetByAssetId(assetId string) (*Asset, error) {
+	var asset Asset
+	err := DB.Where("asset_id = ? and expires_at > ?", assetId, common.GetTimestamp()).First(&asset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetAssetBySource 获取任务转存的未过期资源
+func GetAssetBySource(sourceType string, sourceId string) (*Asset, error) {
+	var asset Asset
+	err := DB.Where("source_type = ? and source_id = ? and expires_at > ?", sourceType, sourceId, common.GetTimestamp()).
+		First(&asset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetAssets 分页查询资源，userId 为 0 时查询全部
+func GetAssets(userId int, startIdx int, num int) (assets []*Asset, total int64, err error) {
+	query := DB.Model(&Asset{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&assets).Error
+	return assets, total, err
+}
+
+// SumAssetSize 统计资源占用的存储大小，userId 为 0 时统计全部
+func SumAssetSize(userId int) (int64, error) {
+	var size int64
+	query := DB.Model(&Asset{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.Select("COALESCE(SUM(size), 0)").Scan(&size).Error
+	return size, err
+}
+
+// GetExpiredAssets 获取已过期的资源
+func GetExpiredAssets(now int64, limit int) (assets []*Asset, err error) {
+	err = DB.Where("expires_at <= ?", now).Order("id asc").Limit(limit).Find(&assets).Error
+	return assets, err
+}
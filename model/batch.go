@@ -0,0 +1,151 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"one-api/common"
+)
+
+// Batch 状态与 OpenAI Batch API 保持一致
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// BatchMetadata 用户提交的 metadata
+type BatchMetadata map[string]string
+
+func (m *BatchMetadata) Scan(val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		if len(v) == 0 {
+			*m = nil
+			return nil
+		}
+		return json.Unmarshal(v, m)
+	case string:
+		if v == "" {
+			*m = nil
+			return nil
+		}
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("unsupported type for BatchMetadata")
+	}
+}
+
+func (m BatchMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// Batch 离线批处理任务，输入/输出文件保存在 File 中。
+// Progress 记录已处理的输入行数，服务重启后从该行继续执行
+type Batch struct {
+	Id               int           `json:"id"`
+	BatchId          string        `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int           `json:"user_id" gorm:"index"`
+	TokenId          int           `json:"token_id" gorm:"index"`
+	Endpoint         string        `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string        `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string        `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string        `json:"error_file_id" gorm:"type:varchar(64)"`
+	CompletionWindow string        `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string        `json:"status" gorm:"type:varchar(20);index"`
+	FailReason       string        `json:"fail_reason"`
+	Metadata         BatchMetadata `json:"metadata" gorm:"type:json"`
+	TotalCount       int           `json:"total_count"`
+	CompletedCount   int           `json:"completed_count"`
+	FailedCount      int           `json:"failed_count"`
+	Progress         int           `json:"progress"`
+	CreatedAt        int64         `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64         `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt        int64         `json:"expires_at" gorm:"bigint"`
+	FinalizingAt     int64         `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64         `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64         `json:"failed_at" gorm:"bigint"`
+	ExpiredAt        int64         `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64         `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64         `json:"cancelled_at" gorm:"bigint"`
+}
+
+func (batch *Batch) Insert() error {
+	if batch.CreatedAt == 0 {
+		batch.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(batch).Error
+}
+
+// BatchUpdateIfStatus 仅在任务处于指定状态之一时更新，避免覆盖并发的取消请求，返回是否更新成功
+func BatchUpdateIfStatus(id int, statuses []string, params map[string]any) (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? and status in ?", id, statuses).Updates(params)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// IsFinished 是否处于终态
+func (batch *Batch) IsFinished() bool {
+	switch batch.Status {
+	case BatchStatusFailed, BatchStatusCompleted, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func GetUserBatchByBatchId(userId int, batchId string) (*Batch, error) {
+	if batchId == "" {
+		return nil, errors.New("batch id 为空！")
+	}
+	var batch Batch
+	err := DB.Where("user_id = ? and batch_id = ?", userId, batchId).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetBatchStatus 只查询状态，用于执行过程中检查是否被取消
+func GetBatchStatus(id int) (string, error) {
+	var status string
+	err := DB.Model(&Batch{}).Where("id = ?", id).Select("status").Scan(&status).Error
+	return status, err
+}
+
+// GetUserBatches 分页获取用户批处理任务，afterId 大于 0 时只返回 id 小于 afterId 的记录
+func GetUserBatches(userId int, afterId int, num int) (batches []*Batch, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if afterId > 0 {
+		tx = tx.Where("id < ?", afterId)
+	}
+	err = tx.Order("id desc").Limit(num).Find(&batches).Error
+	return batches, err
+}
+
+// GetUnfinishedBatches 获取未完成的批处理任务，按创建顺序排列
+func GetUnfinishedBatches(limit int) (batches []*Batch, err error) {
+	err = DB.Where("status in ?", []string{BatchStatusValidating, BatchStatusInProgress, BatchStatusFinalizing, BatchStatusCancelling}).
+		Order("id asc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// CancelBatch 将未完成的批处理任务标记为取消中，由执行器完成后续处理
+func CancelBatch(id int) (bool, error) {
+	result := DB.Model(&Batch{}).
+		Where("id = ? and status in ?", id, []string{BatchStatusValidating, BatchStatusInProgress}).
+		Updates(map[string]any{"status": BatchStatusCancelling, "cancelling_at": common.GetTimestamp()})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,311 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BillingStatement 月度账单，TokenId 为 0 时为用户账单，否则为单个令牌的账单。
+// 用户账单不包含组织支付的消费与组织充值
+type BillingStatement struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"uniqueIndex:idx_statement_period,priority:1"`
+	TokenId   int    `json:"token_id" gorm:"default:0;uniqueIndex:idx_statement_period,priority:2"`
+	TokenName string `json:"token_name" gorm:"default:''"`
+	// 账期，格式 2006-01，按服务器时区划分
+	Period    string `json:"period" gorm:"type:varchar(7);uniqueIndex:idx_statement_period,priority:3"`
+	StartTime int64  `json:"start_time" gorm:"bigint"`
+	EndTime   int64  `json:"end_time" gorm:"bigint"`
+
+	OpeningBalance  int64   `json:"opening_balance"`
+	TopUpQuota      int64   `json:"topup_quota"`
+	TopUpMoney      float64 `json:"topup_money"`
+	RedemptionQuota int64   `json:"redemption_quota"`
+	ConsumedQuota   int64   `json:"consumed_quota"`
+	RefundQuota     int64   `json:"refund_quota"`
+	// 管理员调整的额度
+	AdjustmentQuota int64 `json:"adjustment_quota"`
+	ClosingBalance  int64 `json:"closing_balance"`
+	RequestCount    int64 `json:"request_count"`
+
+	Items     []StatementModelItem `json:"items" gorm:"serializer:json;type:text"`
+	CreatedAt int64                `json:"created_at" gorm:"bigint"`
+}
+
+// StatementModelItem 账单中按模型汇总的消费
+type StatementModelItem struct {
+	ModelName        string `json:"model_name"`
+	Count            int64  `json:"count"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+// statementFlow 一段时间内的额度流水
+type statementFlow struct {
+	TopUpQuota      int64
+	TopUpMoney      float64
+	RedemptionQuota int64
+	ConsumedQuota   int64
+	RefundQuota     int64
+	AdjustmentQuota int64
+	RequestCount    int64
+}
+
+func (f statementFlow) net() int64 {
+	return f.TopUpQuota + f.RedemptionQuota + f.RefundQuota + f.AdjustmentQuota - f.ConsumedQuota
+}
+
+// ParseStatementPeriod 解析账期，返回账期起止时间
+func ParseStatementPeriod(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, expected YYYY-MM", period)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// consumeLogQuery 用户或令牌的消费、退款日志，用户账单排除组织支付的记录
+func consumeLogQuery(userId int, tokenId int, logType int, start int64, end int64) *gorm.DB {
+	tx := LOG_DB.Table("logs").Where("user_id = ? and type = ? and created_at >= ?", userId, logType, start)
+	if end != 0 {
+		tx = tx.Where("created_at < ?", end)
+	}
+	if tokenId != 0 {
+		return tx.Where("token_id = ?", tokenId)
+	}
+	return tx.Where("organization_id = 0")
+}
+
+// getStatementFlow 统计 [start, end) 内的额度流水，end 为 0 表示至今
+func getStatementFlow(userId int, tokenId int, start int64, end int64) (flow statementFlow, err error) {
+	var consume struct {
+		Count int64
+		Quota int64
+	}
+	if err = consumeLogQuery(userId, tokenId, LogTypeConsume, start, end).
+		Select("count(*) as count, coalesce(sum(quota), 0) as quota").Scan(&consume).Error; err != nil {
+		return
+	}
+	flow.RequestCount = consume.Count
+	flow.ConsumedQuota = consume.Quota
+	if err = consumeLogQuery(userId, tokenId, LogTypeRefund, start, end).
+		Select("coalesce(sum(quota), 0)").Scan(&flow.RefundQuota).Error; err != nil {
+		return
+	}
+	// 充值、兑换与管理员调整只计入用户账单
+	if tokenId != 0 {
+		return
+	}
+	if err = consumeLogQuery(userId, 0, LogTypeManage, start, end).
+		Select("coalesce(sum(quota), 0)").Scan(&flow.AdjustmentQuota).Error; err != nil {
+		return
+	}
+	var topUp struct {
+		Quota int64
+		Money float64
+	}
+	tx := DB.Model(&TopUp{}).Where("user_id = ? and organization_id = 0 and status = ? and complete_time >= ?", userId, common.TopUpStatusSuccess, start)
+	if end != 0 {
+		tx = tx.Where("complete_time < ?", end)
+	}
+	var topUps []TopUp
+	if err = tx.Find(&topUps).Error; err != nil {
+		return
+	}
+	for _, t := range topUps {
+		quota := t.Quota
+		if quota == 0 {
+			// 旧订单未记录到账额度
+			quota = int64(float64(t.Amount) * common.QuotaPerUnit)
+		}
+		topUp.Quota += quota
+		topUp.Money += t.Money
+	}
+	flow.TopUpQuota = topUp.Quota
+	flow.TopUpMoney = topUp.Money
+	tx = DB.Model(&Redemption{}).Where("used_user_id = ? and organization_id = 0 and status = ? and redeemed_time >= ?", userId, common.RedemptionCodeStatusUsed, start)
+	if end != 0 {
+		tx = tx.Where("redeemed_time < ?", end)
+	}
+	err = tx.Select("coalesce(sum(quota), 0)").Scan(&flow.RedemptionQuota).Error
+	return
+}
+
+func getStatementItems(userId int, tokenId int, start int64, end int64) (items []StatementModelItem, err error) {
+	err = consumeLogQuery(userId, tokenId, LogTypeConsume, start, end).
+		Select("model_name, count(*) as count, coalesce(sum(prompt_tokens), 0) as prompt_tokens, " +
+			"coalesce(sum(completion_tokens), 0) as completion_tokens, coalesce(sum(quota), 0) as quota").
+		Group("model_name").Order("quota desc").Scan(&items).Error
+	return items, err
+}
+
+// GenerateBillingStatement 生成（或重新生成）用户或令牌的月度账单。
+// 期初余额使用上一期账单的期末余额，期末余额为期初余额加上账期内记录的流水；
+// 没有上一期账单时（首期账单）由当前余额倒推账期开始后的流水得到期初余额
+func GenerateBillingStatement(userId int, tokenId int, period string) (*BillingStatement, error) {
+	startTime, endTime, err := ParseStatementPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if endTime.After(time.Now()) {
+		return nil, errors.New("账期尚未结束")
+	}
+	start, end := startTime.Unix(), endTime.Unix()
+
+	statement := &BillingStatement{
+		UserId:    userId,
+		TokenId:   tokenId,
+		Period:    period,
+		StartTime: start,
+		EndTime:   end,
+		CreatedAt: common.GetTimestamp(),
+	}
+	var token *Token
+	if tokenId != 0 {
+		if token, err = GetTokenByIds(tokenId, userId); err != nil {
+			return nil, err
+		}
+		statement.TokenName = token.Name
+	}
+
+	flow, err := getStatementFlow(userId, tokenId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	statement.TopUpQuota = flow.TopUpQuota
+	statement.TopUpMoney = flow.TopUpMoney
+	statement.RedemptionQuota = flow.RedemptionQuota
+	statement.ConsumedQuota = flow.ConsumedQuota
+	statement.RefundQuota = flow.RefundQuota
+	statement.AdjustmentQuota = flow.AdjustmentQuota
+	statement.RequestCount = flow.RequestCount
+
+	previousPeriod := startTime.AddDate(0, -1, 0).Format("2006-01")
+	var previous BillingStatement
+	err = DB.Where("user_id = ? and token_id = ? and period = ?", userId, tokenId, previousPeriod).First(&previous).Error
+	switch {
+	case err == nil:
+		statement.OpeningBalance = previous.ClosingBalance
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		var currentBalance int64
+		if token != nil {
+			if !token.UnlimitedQuota {
+				currentBalance = int64(token.RemainQuota)
+			}
+		} else {
+			quota, err := GetUserQuota(userId, true)
+			if err != nil {
+				return nil, err
+			}
+			currentBalance = int64(quota)
+		}
+		since, err := getStatementFlow(userId, tokenId, start, 0)
+		if err != nil {
+			return nil, err
+		}
+		statement.OpeningBalance = currentBalance - since.net()
+	default:
+		return nil, err
+	}
+	statement.ClosingBalance = statement.OpeningBalance + flow.net()
+	if statement.Items, err = getStatementItems(userId, tokenId, start, end); err != nil {
+		return nil, err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? and token_id = ? and period = ?", userId, tokenId, period).Delete(&BillingStatement{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(statement).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// BillingStatementExists 账单是否已生成
+func BillingStatementExists(userId int, tokenId int, period string) bool {
+	var count int64
+	DB.Model(&BillingStatement{}).Where("user_id = ? and token_id = ? and period = ?", userId, tokenId, period).Count(&count)
+	return count > 0
+}
+
+// GetStatementTargets 返回账期内有额度变动的用户，以及有消费的令牌（用户 ID -> 令牌 ID 列表）
+func GetStatementTargets(start int64, end int64) (userIds []int, tokens map[int][]int, err error) {
+	userSet := make(map[int]bool)
+	var ids []int
+	if err = LOG_DB.Table("logs").Where("type in ? and created_at >= ? and created_at < ?", []int{LogTypeConsume, LogTypeRefund}, start, end).
+		Distinct("user_id").Pluck("user_id", &ids).Error; err != nil {
+		return
+	}
+	for _, id := range ids {
+		userSet[id] = true
+	}
+	ids = nil
+	if err = DB.Model(&TopUp{}).Where("status = ? and complete_time >= ? and complete_time < ?", common.TopUpStatusSuccess, start, end).
+		Distinct("user_id").Pluck("user_id", &ids).Error; err != nil {
+		return
+	}
+	for _, id := range ids {
+		userSet[id] = true
+	}
+	ids = nil
+	if err = DB.Model(&Redemption{}).Where("status = ? and redeemed_time >= ? and redeemed_time < ?", common.RedemptionCodeStatusUsed, start, end).
+		Distinct("used_user_id").Pluck("used_user_id", &ids).Error; err != nil {
+		return
+	}
+	for _, id := range ids {
+		userSet[id] = true
+	}
+	for id := range userSet {
+		if id != 0 {
+			userIds = append(userIds, id)
+		}
+	}
+
+	var pairs []struct {
+		UserId  int
+		TokenId int
+	}
+	if err = LOG_DB.Table("logs").Select("user_id, token_id").
+		Where("type = ? and token_id <> 0 and created_at >= ? and created_at < ?", LogTypeConsume, start, end).
+		Group("user_id, token_id").Scan(&pairs).Error; err != nil {
+		return
+	}
+	tokens = make(map[int][]int)
+	for _, pair := range pairs {
+		tokens[pair.UserId] = append(tokens[pair.UserId], pair.TokenId)
+	}
+	return
+}
+
+// GetUserBillingStatements 获取用户的账单列表，tokenId 为 -1 时返回用户及其令牌的全部账单
+func GetUserBillingStatements(userId int, tokenId int, startIdx int, num int) (statements []*BillingStatement, total int64, err error) {
+	tx := DB.Model(&BillingStatement{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if tokenId >= 0 {
+		tx = tx.Where("token_id = ?", tokenId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("period desc, token_id asc").Limit(num).Offset(startIdx).Find(&statements).Error
+	return statements, total, err
+}
+
+func GetBillingStatementById(id int) (*BillingStatement, error) {
+	var statement BillingStatement
+	err := DB.First(&statement, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatementPeriod(t *testing.T) {
+	start, end, err := ParseStatementPeriod("2025-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.Local)) || !end.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected period range %v - %v", start, end)
+	}
+	for _, period := range []string{"", "2025-13", "2025/01", "202501"} {
+		if _, _, err := ParseStatementPeriod(period); err == nil {
+			t.Errorf("expected error for %q", period)
+		}
+	}
+}
+
+func TestStatementFlowNet(t *testing.T) {
+	flow := statementFlow{TopUpQuota: 1000, RedemptionQuota: 200, RefundQuota: 50, ConsumedQuota: 700}
+	if got := flow.net(); got != 550 {
+		t.Errorf("net = %d, want 550", got)
+	}
+}
+
+func TestGenerateBillingStatementBalances(t *testing.T) {
+	user := &User{Username: "statement_user", AffCode: "stmt", Quota: 1000, Status: 1}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day int) int64 {
+		return time.Date(2025, month, day, 12, 0, 0, 0, time.Local).Unix()
+	}
+	logs := []*Log{
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 100, ModelName: "gpt-4o", CreatedAt: at(1, 10)},
+		{UserId: user.Id, Type: LogTypeManage, Quota: 50, CreatedAt: at(2, 10)},
+		// 组织支付的消费不计入用户账单
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 70, OrganizationId: 1, CreatedAt: at(2, 11)},
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 30, CreatedAt: at(3, 10)},
+	}
+	for _, log := range logs {
+		if err := LOG_DB.Create(log).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 首期账单由当前余额倒推期初余额：1000 - (-100 + 50 - 30) = 1080
+	january, err := GenerateBillingStatement(user.Id, 0, "2025-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if january.OpeningBalance != 1080 || january.ClosingBalance != 980 || january.ConsumedQuota != 100 || len(january.Items) != 1 {
+		t.Errorf("january: %+v", january)
+	}
+
+	// 之后的账单从上一期期末余额累加流水，不受当前余额中未记录的变动影响
+	if err = DB.Model(user).Update("quota", 5000).Error; err != nil {
+		t.Fatal(err)
+	}
+	february, err := GenerateBillingStatement(user.Id, 0, "2025-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if february.OpeningBalance != 980 || february.AdjustmentQuota != 50 || february.ConsumedQuota != 0 || february.ClosingBalance != 1030 {
+		t.Errorf("february: %+v", february)
+	}
+
+	// 重新生成时替换原账单
+	if _, err = GenerateBillingStatement(user.Id, 0, "2025-02"); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	DB.Model(&BillingStatement{}).Where("user_id = ? and period = ?", user.Id, "2025-02").Count(&count)
+	if count != 1 {
+		t.Errorf("regenerated statements: %d", count)
+	}
+}
@@ -54,6 +54,9 @@ type Channel struct {
 
 	// cache info
 	Keys []string `json:"-" gorm:"-"`
+
+	// 熔断状态，仅用于管理接口展示
+	Breaker *ChannelBreakerStatus `json:"breaker,omitempty" gorm:"-"`
 }
 
 type ChannelInfo struct {
@@ -141,6 +144,20 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	if len(enabledIdx) == 0 {
 		return keys[0], 0, nil
 	}
+	// 跳过熔断中的 Key，全部熔断时仍从启用的 Key 中选择
+	availableIdx := make([]int, 0, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		if ChannelBreakerAvailable(channel.Id, idx) {
+			availableIdx = append(availableIdx, idx)
+		}
+	}
+	if len(availableIdx) > 0 {
+		enabledIdx = availableIdx
+	}
+	selectable := make(map[int]bool, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		selectable[idx] = true
+	}
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
@@ -172,7 +189,7 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		for i := 0; i < len(keys); i++ {
 			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+			if selectable[idx] {
 				// update polling index for next call (point to the next position)
 				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
 				return keys[idx], idx, nil
@@ -0,0 +1,410 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道熔断器：按渠道（以及多 Key 渠道的每个 Key）统计滚动窗口内的错误率与延迟，
+// 错误率过高时熔断，熔断到期后进入半开状态放行少量探测请求，探测成功即恢复。
+// 状态仅保存在当前节点内存中。
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+const (
+	breakerBucketCount = 10
+	// 半开探测请求超过该时间未返回结果时释放探测名额
+	breakerProbeTimeout = 2 * time.Minute
+)
+
+type breakerBucket struct {
+	slot      int64
+	requests  int
+	failures  int
+	latencyMs int64
+}
+
+type channelBreaker struct {
+	mu           sync.Mutex
+	buckets      [breakerBucketCount]breakerBucket
+	state        string
+	openUntil    time.Time
+	tripCount    int
+	probing      int
+	probeStarted time.Time
+	lastError    string
+}
+
+// ChannelBreakerStatus 渠道熔断状态，用于管理接口展示
+type ChannelBreakerStatus struct {
+	State        string                        `json:"state"`
+	Requests     int                           `json:"requests"`
+	Failures     int                           `json:"failures"`
+	ErrorRate    float64                       `json:"error_rate"`
+	AvgLatencyMs int64                         `json:"avg_latency_ms"`
+	HealthScore  float64                       `json:"health_score"`
+	OpenUntil    int64                         `json:"open_until,omitempty"`
+	TripCount    int                           `json:"trip_count"`
+	LastError    string                        `json:"last_error,omitempty"`
+	Keys         map[int]*ChannelBreakerStatus `json:"keys,omitempty"`
+}
+
+var (
+	channelBreakers     = make(map[string]*channelBreaker)
+	channelBreakersLock sync.RWMutex
+)
+
+func channelBreakerKey(channelId int, keyIndex int) string {
+	if keyIndex < 0 {
+		return fmt.Sprintf("%d", channelId)
+	}
+	return fmt.Sprintf("%d:%d", channelId, keyIndex)
+}
+
+func getChannelBreaker(key string, create bool) *channelBreaker {
+	channelBreakersLock.RLock()
+	breaker, ok := channelBreakers[key]
+	channelBreakersLock.RUnlock()
+	if ok || !create {
+		return breaker
+	}
+	channelBreakersLock.Lock()
+	defer channelBreakersLock.Unlock()
+	if breaker, ok = channelBreakers[key]; ok {
+		return breaker
+	}
+	breaker = &channelBreaker{state: BreakerStateClosed}
+	channelBreakers[key] = breaker
+	return breaker
+}
+
+func breakerBucketSeconds(setting *operation_setting.ChannelBreakerSetting) int64 {
+	seconds := int64(setting.WindowSeconds) / breakerBucketCount
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// stats 统计窗口内的请求数、失败数、平均延迟，调用方需持有锁
+func (b *channelBreaker) stats(now time.Time, setting *operation_setting.ChannelBreakerSetting) (requests int, failures int, avgLatencyMs int64) {
+	bucketSeconds := breakerBucketSeconds(setting)
+	currentSlot := now.Unix() / bucketSeconds
+	var latencyMs int64
+	for _, bucket := range b.buckets {
+		if currentSlot-bucket.slot >= breakerBucketCount {
+			continue
+		}
+		requests += bucket.requests
+		failures += bucket.failures
+		latencyMs += bucket.latencyMs
+	}
+	if requests > 0 {
+		avgLatencyMs = latencyMs / int64(requests)
+	}
+	return
+}
+
+func (b *channelBreaker) resetWindow() {
+	b.buckets = [breakerBucketCount]breakerBucket{}
+}
+
+// refresh 熔断到期后转为半开，调用方需持有锁
+func (b *channelBreaker) refresh(now time.Time) {
+	if b.state == BreakerStateOpen && !now.Before(b.openUntil) {
+		b.state = BreakerStateHalfOpen
+		b.probing = 0
+	}
+	if b.state == BreakerStateHalfOpen && b.probing > 0 && now.Sub(b.probeStarted) > breakerProbeTimeout {
+		b.probing = 0
+	}
+}
+
+func (b *channelBreaker) available(now time.Time, setting *operation_setting.ChannelBreakerSetting) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(now)
+	switch b.state {
+	case BreakerStateOpen:
+		return false
+	case BreakerStateHalfOpen:
+		return b.probing < setting.HalfOpenProbes
+	}
+	return true
+}
+
+// acquire 半开状态下占用一个探测名额，返回是否占用
+func (b *channelBreaker) acquire(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(now)
+	if b.state != BreakerStateHalfOpen {
+		return false
+	}
+	b.probing++
+	b.probeStarted = now
+	return true
+}
+
+// release 释放没有记录结果的探测名额
+func (b *channelBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerStateHalfOpen && b.probing > 0 {
+		b.probing--
+	}
+}
+
+func (b *channelBreaker) trip(now time.Time, setting *operation_setting.ChannelBreakerSetting) {
+	openSeconds := float64(setting.OpenSeconds) * math.Pow(2, float64(b.tripCount))
+	if setting.MaxOpenSeconds > 0 && openSeconds > float64(setting.MaxOpenSeconds) {
+		openSeconds = float64(setting.MaxOpenSeconds)
+	}
+	b.state = BreakerStateOpen
+	b.openUntil = now.Add(time.Duration(openSeconds) * time.Second)
+	b.tripCount++
+	b.probing = 0
+}
+
+func (b *channelBreaker) record(now time.Time, success bool, latency time.Duration, errMsg string, setting *operation_setting.ChannelBreakerSetting) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(now)
+
+	slot := now.Unix() / breakerBucketSeconds(setting)
+	bucket := &b.buckets[slot%breakerBucketCount]
+	if bucket.slot != slot {
+		*bucket = breakerBucket{slot: slot}
+	}
+	bucket.requests++
+	bucket.latencyMs += latency.Milliseconds()
+	if !success {
+		bucket.failures++
+		b.lastError = errMsg
+	}
+
+	switch b.state {
+	case BreakerStateHalfOpen:
+		if b.probing > 0 {
+			b.probing--
+		}
+		if success {
+			// 探测成功，自动恢复
+			b.state = BreakerStateClosed
+			b.tripCount = 0
+			b.resetWindow()
+		} else {
+			b.trip(now, setting)
+		}
+	case BreakerStateClosed:
+		if success {
+			return
+		}
+		requests, failures, _ := b.stats(now, setting)
+		if requests >= setting.MinRequests && float64(failures)/float64(requests) >= setting.ErrorRateThreshold {
+			b.trip(now, setting)
+		}
+	}
+}
+
+func (b *channelBreaker) healthScore(now time.Time, setting *operation_setting.ChannelBreakerSetting) float64 {
+	requests, failures, avgLatencyMs := b.stats(now, setting)
+	if requests == 0 {
+		return 1
+	}
+	score := 1 - float64(failures)/float64(requests)
+	if setting.LatencyThresholdMs > 0 && avgLatencyMs > int64(setting.LatencyThresholdMs) {
+		score = score * float64(setting.LatencyThresholdMs) / float64(avgLatencyMs)
+	}
+	return math.Max(score, 0.1)
+}
+
+func (b *channelBreaker) status(now time.Time, setting *operation_setting.ChannelBreakerSetting) *ChannelBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(now)
+	requests, failures, avgLatencyMs := b.stats(now, setting)
+	status := &ChannelBreakerStatus{
+		State:        b.state,
+		Requests:     requests,
+		Failures:     failures,
+		AvgLatencyMs: avgLatencyMs,
+		HealthScore:  b.healthScore(now, setting),
+		TripCount:    b.tripCount,
+		LastError:    b.lastError,
+	}
+	if requests > 0 {
+		status.ErrorRate = float64(failures) / float64(requests)
+	}
+	if b.state == BreakerStateOpen {
+		status.OpenUntil = b.openUntil.Unix()
+	}
+	return status
+}
+
+// ChannelBreakerAvailable 渠道（或渠道的某个 Key，keyIndex < 0 表示整个渠道）当前是否允许请求
+func ChannelBreakerAvailable(channelId int, keyIndex int) bool {
+	setting := operation_setting.GetChannelBreakerSetting()
+	if !setting.Enabled {
+		return true
+	}
+	breaker := getChannelBreaker(channelBreakerKey(channelId, keyIndex), false)
+	if breaker == nil {
+		return true
+	}
+	return breaker.available(time.Now(), setting)
+}
+
+// channelBreakerProbe 请求占用的探测名额
+type channelBreakerProbe struct {
+	channelId int
+	keyIndex  int
+}
+
+// ChannelBreakerAcquire 选中渠道后调用，半开状态下占用一个探测名额。
+// 占用的名额记录在请求上下文中，请求结束时仍未记录结果的由 ReleaseChannelBreakerProbes 释放
+func ChannelBreakerAcquire(c *gin.Context, channelId int, keyIndex int) {
+	if !operation_setting.GetChannelBreakerSetting().Enabled {
+		return
+	}
+	if c != nil && common.GetContextKeyBool(c, constant.ContextKeyChannelBreakerSkipProbe) {
+		return
+	}
+	breaker := getChannelBreaker(channelBreakerKey(channelId, keyIndex), false)
+	if breaker == nil || !breaker.acquire(time.Now()) || c == nil {
+		return
+	}
+	probes, _ := common.GetContextKeyType[[]channelBreakerProbe](c, constant.ContextKeyChannelBreakerProbes)
+	common.SetContextKey(c, constant.ContextKeyChannelBreakerProbes, append(probes, channelBreakerProbe{channelId: channelId, keyIndex: keyIndex}))
+}
+
+// ReleaseChannelBreakerProbes 请求结束时释放仍未记录结果的探测名额，
+// 避免不记录结果的路由（计数、任务查询等）长期占用半开渠道的探测名额
+func ReleaseChannelBreakerProbes(c *gin.Context) {
+	probes, _ := common.GetContextKeyType[[]channelBreakerProbe](c, constant.ContextKeyChannelBreakerProbes)
+	for _, probe := range probes {
+		if breaker := getChannelBreaker(channelBreakerKey(probe.channelId, probe.keyIndex), false); breaker != nil {
+			breaker.release()
+		}
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelBreakerProbes, []channelBreakerProbe(nil))
+}
+
+// RecordChannelResultWithContext 记录请求结果，并移除该渠道在请求上下文中占用的探测名额（由记录结果归还）
+func RecordChannelResultWithContext(c *gin.Context, channelId int, keyIndex int, success bool, latency time.Duration, errMsg string) {
+	probes, _ := common.GetContextKeyType[[]channelBreakerProbe](c, constant.ContextKeyChannelBreakerProbes)
+	remaining := make([]channelBreakerProbe, 0, len(probes))
+	for _, probe := range probes {
+		if probe.channelId != channelId {
+			remaining = append(remaining, probe)
+		}
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelBreakerProbes, remaining)
+	RecordChannelResult(channelId, keyIndex, success, latency, errMsg)
+}
+
+// ChannelHealthScore 渠道健康分（0.1 ~ 1），用于调整渠道权重
+func ChannelHealthScore(channelId int) float64 {
+	setting := operation_setting.GetChannelBreakerSetting()
+	if !setting.Enabled {
+		return 1
+	}
+	breaker := getChannelBreaker(channelBreakerKey(channelId, -1), false)
+	if breaker == nil {
+		return 1
+	}
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return breaker.healthScore(time.Now(), setting)
+}
+
+// RecordChannelResult 记录一次渠道请求结果，keyIndex < 0 表示非多 Key 渠道
+func RecordChannelResult(channelId int, keyIndex int, success bool, latency time.Duration, errMsg string) {
+	setting := operation_setting.GetChannelBreakerSetting()
+	if !setting.Enabled || channelId == 0 {
+		return
+	}
+	now := time.Now()
+	getChannelBreaker(channelBreakerKey(channelId, -1), true).record(now, success, latency, errMsg, setting)
+	if keyIndex >= 0 {
+		getChannelBreaker(channelBreakerKey(channelId, keyIndex), true).record(now, success, latency, errMsg, setting)
+	}
+}
+
+// GetChannelBreakerStatus 获取渠道熔断状态，没有统计数据时返回 nil
+func GetChannelBreakerStatus(channelId int) *ChannelBreakerStatus {
+	setting := operation_setting.GetChannelBreakerSetting()
+	now := time.Now()
+	breaker := getChannelBreaker(channelBreakerKey(channelId, -1), false)
+	if breaker == nil {
+		return nil
+	}
+	status := breaker.status(now, setting)
+
+	prefix := fmt.Sprintf("%d:", channelId)
+	channelBreakersLock.RLock()
+	defer channelBreakersLock.RUnlock()
+	for key, keyBreaker := range channelBreakers {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		keyIndex, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err != nil {
+			continue
+		}
+		if status.Keys == nil {
+			status.Keys = make(map[int]*ChannelBreakerStatus)
+		}
+		status.Keys[keyIndex] = keyBreaker.status(now, setting)
+	}
+	return status
+}
+
+// GetAllChannelBreakerStatus 获取所有有统计数据的渠道熔断状态
+func GetAllChannelBreakerStatus() map[int]*ChannelBreakerStatus {
+	channelBreakersLock.RLock()
+	channelIds := make([]int, 0, len(channelBreakers))
+	for key := range channelBreakers {
+		if channelId, err := strconv.Atoi(strings.Split(key, ":")[0]); err == nil {
+			channelIds = append(channelIds, channelId)
+		}
+	}
+	channelBreakersLock.RUnlock()
+
+	result := make(map[int]*ChannelBreakerStatus)
+	for _, channelId := range channelIds {
+		if _, ok := result[channelId]; ok {
+			continue
+		}
+		if status := GetChannelBreakerStatus(channelId); status != nil {
+			result[channelId] = status
+		}
+	}
+	return result
+}
+
+// ResetChannelBreaker 清除渠道（包括所有 Key）的熔断状态
+func ResetChannelBreaker(channelId int) {
+	prefix := fmt.Sprintf("%d:", channelId)
+	channelBreakersLock.Lock()
+	defer channelBreakersLock.Unlock()
+	delete(channelBreakers, channelBreakerKey(channelId, -1))
+	for key := range channelBreakers {
+		if strings.HasPrefix(key, prefix) {
+			delete(channelBreakers, key)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChannelBreakerTransitions(t *testing.T) {
+	setting := &operation_setting.ChannelBreakerSetting{
+		Enabled:            true,
+		WindowSeconds:      60,
+		MinRequests:        4,
+		ErrorRateThreshold: 0.5,
+		OpenSeconds:        30,
+		MaxOpenSeconds:     60,
+		HalfOpenProbes:     1,
+	}
+	type step struct {
+		at        int64
+		op        string
+		wantState string
+		wantAvail bool
+	}
+	trip := []step{
+		{0, "fail", BreakerStateClosed, true},
+		{0, "fail", BreakerStateClosed, true},
+		{0, "fail", BreakerStateClosed, true},
+		{0, "fail", BreakerStateOpen, false},
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "below min requests", steps: trip[:3]},
+		{name: "below error rate", steps: []step{
+			{0, "ok", BreakerStateClosed, true},
+			{0, "ok", BreakerStateClosed, true},
+			{0, "ok", BreakerStateClosed, true},
+			{0, "fail", BreakerStateClosed, true},
+		}},
+		{name: "trip at error rate", steps: []step{
+			{0, "ok", BreakerStateClosed, true},
+			{0, "ok", BreakerStateClosed, true},
+			{0, "fail", BreakerStateClosed, true},
+			{0, "fail", BreakerStateOpen, false},
+		}},
+		// 窗口外的失败不再计入
+		{name: "failures leave the window", steps: append(append([]step{}, trip[:3]...),
+			step{60, "fail", BreakerStateClosed, true},
+		)},
+		{name: "probe success closes", steps: append(append([]step{}, trip...),
+			step{29, "check", BreakerStateOpen, false},
+			step{30, "check", BreakerStateHalfOpen, true},
+			step{30, "acquire", BreakerStateHalfOpen, false},
+			step{31, "ok", BreakerStateClosed, true},
+			// 恢复后统计窗口清空
+			step{31, "fail", BreakerStateClosed, true},
+		)},
+		// 连续熔断时长翻倍，但不超过 MaxOpenSeconds
+		{name: "probe failure reopens with backoff", steps: append(append([]step{}, trip...),
+			step{30, "acquire", BreakerStateHalfOpen, false},
+			step{31, "fail", BreakerStateOpen, false},
+			step{90, "check", BreakerStateOpen, false},
+			step{91, "acquire", BreakerStateHalfOpen, false},
+			step{92, "fail", BreakerStateOpen, false},
+			step{151, "check", BreakerStateOpen, false},
+			step{152, "check", BreakerStateHalfOpen, true},
+		)},
+		{name: "stale probe released", steps: append(append([]step{}, trip...),
+			step{30, "acquire", BreakerStateHalfOpen, false},
+			step{30 + int64(breakerProbeTimeout.Seconds()), "check", BreakerStateHalfOpen, false},
+			step{31 + int64(breakerProbeTimeout.Seconds()), "check", BreakerStateHalfOpen, true},
+		)},
+	}
+	base := time.Unix(1_800_000_000, 0)
+	for _, tt := range tests {
+		breaker := &channelBreaker{state: BreakerStateClosed}
+		for i, s := range tt.steps {
+			now := base.Add(time.Duration(s.at) * time.Second)
+			switch s.op {
+			case "ok", "fail":
+				breaker.record(now, s.op == "ok", 100*time.Millisecond, "upstream error", setting)
+			case "acquire":
+				breaker.acquire(now)
+			}
+			if state := breaker.status(now, setting).State; state != s.wantState {
+				t.Errorf("%s: step %d (%s at %ds) state %s, want %s", tt.name, i, s.op, s.at, state, s.wantState)
+			}
+			if available := breaker.available(now, setting); available != s.wantAvail {
+				t.Errorf("%s: step %d (%s at %ds) available %v, want %v", tt.name, i, s.op, s.at, available, s.wantAvail)
+			}
+		}
+	}
+}
+
+func TestChannelBreakerHealthScore(t *testing.T) {
+	setting := &operation_setting.ChannelBreakerSetting{WindowSeconds: 60, LatencyThresholdMs: 1000}
+	now := time.Unix(1_800_000_000, 0)
+	tests := []struct {
+		name     string
+		requests int
+		failures int
+		latency  time.Duration
+		want     float64
+	}{
+		{name: "no requests", want: 1},
+		{name: "healthy", requests: 4, latency: 500 * time.Millisecond, want: 1},
+		{name: "half failed", requests: 4, failures: 2, latency: 500 * time.Millisecond, want: 0.5},
+		{name: "slow", requests: 4, latency: 2 * time.Second, want: 0.5},
+		{name: "floor", requests: 4, failures: 4, want: 0.1},
+	}
+	for _, tt := range tests {
+		breaker := &channelBreaker{state: BreakerStateClosed}
+		breaker.buckets[0] = breakerBucket{
+			slot:      now.Unix() / breakerBucketSeconds(setting),
+			requests:  tt.requests,
+			failures:  tt.failures,
+			latencyMs: int64(tt.requests) * tt.latency.Milliseconds(),
+		}
+		if got := breaker.healthScore(now, setting); got != tt.want {
+			t.Errorf("%s: health score %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordChannelResult(t *testing.T) {
+	const channelId = 1 << 30
+	defer ResetChannelBreaker(channelId)
+	setting := operation_setting.GetChannelBreakerSetting()
+	for i := 0; i < setting.MinRequests; i++ {
+		RecordChannelResult(channelId, 1, false, time.Second, "upstream error")
+	}
+	if ChannelBreakerAvailable(channelId, -1) || ChannelBreakerAvailable(channelId, 1) {
+		t.Errorf("channel and key should be open")
+	}
+	if !ChannelBreakerAvailable(channelId, 0) {
+		t.Errorf("key without records should be available")
+	}
+	status := GetChannelBreakerStatus(channelId)
+	if status == nil || status.State != BreakerStateOpen || status.Failures != setting.MinRequests || status.Keys[1] == nil || status.LastError != "upstream error" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	ResetChannelBreaker(channelId)
+	if GetChannelBreakerStatus(channelId) != nil || !ChannelBreakerAvailable(channelId, 1) {
+		t.Errorf("reset did not clear channel and key breakers")
+	}
+}
+
+func TestChannelBreakerProbeRelease(t *testing.T) {
+	const channelId = 1<<30 + 1
+	defer ResetChannelBreaker(channelId)
+	halfOpen := func() {
+		breaker := getChannelBreaker(channelBreakerKey(channelId, -1), true)
+		breaker.mu.Lock()
+		breaker.state, breaker.probing = BreakerStateHalfOpen, 0
+		breaker.mu.Unlock()
+	}
+	newContext := func() *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		return c
+	}
+
+	// 没有记录结果的请求结束时归还探测名额
+	halfOpen()
+	c := newContext()
+	ChannelBreakerAcquire(c, channelId, -1)
+	if ChannelBreakerAvailable(channelId, -1) {
+		t.Fatalf("probe not acquired")
+	}
+	ReleaseChannelBreakerProbes(c)
+	if !ChannelBreakerAvailable(channelId, -1) {
+		t.Errorf("probe not released at request end")
+	}
+
+	// 记录结果后不再重复释放，其他请求占用的名额保持不变
+	halfOpen()
+	c = newContext()
+	ChannelBreakerAcquire(c, channelId, -1)
+	RecordChannelResultWithContext(c, channelId, -1, false, time.Second, "upstream error")
+	halfOpen()
+	ChannelBreakerAcquire(newContext(), channelId, -1)
+	ReleaseChannelBreakerProbes(c)
+	if ChannelBreakerAvailable(channelId, -1) {
+		t.Errorf("recorded probe released twice")
+	}
+
+	// token 计数等不记录结果的请求不占用探测名额
+	halfOpen()
+	c = newContext()
+	common.SetContextKey(c, constant.ContextKeyChannelBreakerSkipProbe, true)
+	ChannelBreakerAcquire(c, channelId, -1)
+	if !ChannelBreakerAvailable(channelId, -1) {
+		t.Errorf("probe acquired by request skipping probes")
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 	"one-api/common"
+	"one-api/common/metrics"
 	"one-api/constant"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"sort"
 	"strings"
@@ -24,6 +26,7 @@ func InitChannelCache() {
 	if !common.MemoryCacheEnabled {
 		return
 	}
+	defer metrics.ObserveCacheSync("channels", time.Now())
 	newChannelId2channel := make(map[int]*Channel)
 	var channels []*Channel
 	DB.Find(&channels)
@@ -107,7 +110,7 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			if common.DebugEnabled {
 				println("autoGroup:", autoGroup)
 			}
-			channel, _ = getRandomSatisfiedChannel(autoGroup, model, retry)
+			channel, _ = getRandomSatisfiedChannel(c, autoGroup, model, retry)
 			if channel == nil {
 				continue
 			} else {
@@ -120,7 +123,7 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			}
 		}
 	} else {
-		channel, err = getRandomSatisfiedChannel(group, model, retry)
+		channel, err = getRandomSatisfiedChannel(c, group, model, retry)
 		if err != nil {
 			return nil, group, err
 		}
@@ -128,10 +131,10 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 	return channel, selectGroup, nil
 }
 
-func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+func getRandomSatisfiedChannel(c *gin.Context, group string, model string, retry int) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model, retry)
+		return GetRandomSatisfiedChannel(c, group, model, retry)
 	}
 
 	channelSyncLock.RLock()
@@ -152,6 +155,7 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	if len(channels) == 1 {
 		if channel, ok := channelsIDM[channels[0]]; ok {
+			ChannelBreakerAcquire(c, channel.Id, -1)
 			return channel, nil
 		}
 		return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channels[0])
@@ -188,20 +192,57 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		}
 	}
 
+	return selectSatisfiedChannel(c, group, model, targetChannels)
+}
+
+// selectSatisfiedChannel 从同一优先级的候选渠道中选择：跳过熔断中的渠道，按路由策略选择，
+// 未配置策略时按权重与健康评分加权随机。内存缓存与数据库两种路径共用
+func selectSatisfiedChannel(c *gin.Context, group string, model string, targetChannels []*Channel) (*Channel, error) {
+	// 跳过熔断中的渠道，全部熔断时仍从原有渠道中选择
+	availableChannels := make([]*Channel, 0, len(targetChannels))
+	for _, channel := range targetChannels {
+		if ChannelBreakerAvailable(channel.Id, -1) {
+			availableChannels = append(availableChannels, channel)
+		}
+	}
+	if len(availableChannels) > 0 {
+		targetChannels = availableChannels
+	}
+
+	// 按分组/模型配置的路由策略选择渠道
+	if strategy := operation_setting.GetRoutingStrategy(group, model); strategy != operation_setting.RoutingStrategyWeightedRandom {
+		candidates := excludeUsedChannels(c, targetChannels)
+		if channel, reason := selectChannelByStrategy(c, strategy, candidates, model); channel != nil {
+			if c != nil {
+				common.SetContextKey(c, constant.ContextKeyRoutingStrategy, strategy)
+				common.SetContextKey(c, constant.ContextKeyRoutingReason, reason)
+			}
+			ChannelBreakerAcquire(c, channel.Id, -1)
+			return channel, nil
+		}
+	}
+	if c != nil {
+		common.SetContextKey(c, constant.ContextKeyRoutingStrategy, "")
+		common.SetContextKey(c, constant.ContextKeyRoutingReason, "")
+	}
+
 	// 平滑系数
 	smoothingFactor := 10
-	// Calculate the total weight of all channels up to endIdx
-	totalWeight := 0
-	for _, channel := range targetChannels {
-		totalWeight += channel.GetWeight() + smoothingFactor
+	// Calculate the total weight of all channels up to endIdx, scaled by channel health score
+	weights := make([]float64, len(targetChannels))
+	totalWeight := 0.0
+	for i, channel := range targetChannels {
+		weights[i] = float64(channel.GetWeight()+smoothingFactor) * ChannelHealthScore(channel.Id)
+		totalWeight += weights[i]
 	}
 	// Generate a random value in the range [0, totalWeight)
-	randomWeight := rand.Intn(totalWeight)
+	randomWeight := rand.Float64() * totalWeight
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight() + smoothingFactor
+	for i, channel := range targetChannels {
+		randomWeight -= weights[i]
 		if randomWeight < 0 {
+			ChannelBreakerAcquire(c, channel.Id, -1)
 			return channel, nil
 		}
 	}
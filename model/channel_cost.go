@@ -0,0 +1,90 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// getChannelCostConfig 优先使用请求上下文中的渠道配置，异步任务等场景回退到渠道缓存
+func getChannelCostConfig(c *gin.Context, channelId int) *dto.ChannelCostConfig {
+	if channelId == 0 {
+		return nil
+	}
+	if c != nil && common.GetContextKeyInt(c, constant.ContextKeyChannelId) == channelId {
+		if settings, ok := common.GetContextKeyType[dto.ChannelOtherSettings](c, constant.ContextKeyChannelOtherSetting); ok {
+			return settings.CostConfig
+		}
+	}
+	channel, err := CacheGetChannel(channelId)
+	if err != nil {
+		return nil
+	}
+	return channel.GetOtherSettings().CostConfig
+}
+
+// CalcChannelCost 根据渠道成本配置计算一次调用的上游成本（额度单位），未配置成本时返回 0。
+// quota 为未乘分组倍率与定时价格规则倍率的基础额度
+func CalcChannelCost(config *dto.ChannelCostConfig, modelNames []string, promptTokens int, completionTokens int, quota int) int {
+	if config == nil {
+		return 0
+	}
+	costRatio := config.CostRatio
+	for _, modelName := range modelNames {
+		modelCost, ok := config.ModelCosts[modelName]
+		if !ok {
+			continue
+		}
+		dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+		if modelCost.CallPrice > 0 {
+			return int(decimal.NewFromFloat(modelCost.CallPrice).Mul(dQuotaPerUnit).Round(0).IntPart())
+		}
+		if modelCost.InputPrice > 0 || modelCost.OutputPrice > 0 {
+			dCost := decimal.NewFromFloat(modelCost.InputPrice).Mul(decimal.NewFromInt(int64(promptTokens))).
+				Add(decimal.NewFromFloat(modelCost.OutputPrice).Mul(decimal.NewFromInt(int64(completionTokens)))).
+				Div(decimal.NewFromInt(1000000)).Mul(dQuotaPerUnit)
+			return int(dCost.Round(0).IntPart())
+		}
+		if modelCost.CostRatio > 0 {
+			costRatio = modelCost.CostRatio
+		}
+		break
+	}
+	return int(decimal.NewFromFloat(costRatio).Mul(decimal.NewFromInt(int64(quota))).Round(0).IntPart())
+}
+
+func calcConsumeLogCost(c *gin.Context, params RecordConsumeLogParams) int {
+	config := getChannelCostConfig(c, params.ChannelId)
+	if config == nil {
+		return 0
+	}
+	// 命中响应缓存时没有请求上游
+	if cacheHit, _ := params.Other["cache_hit"].(bool); cacheHit {
+		return 0
+	}
+	modelNames := []string{params.ModelName}
+	if upstreamModelName, ok := params.Other["upstream_model_name"].(string); ok && upstreamModelName != "" {
+		modelNames = append(modelNames, upstreamModelName)
+	}
+	return CalcChannelCost(config, modelNames, params.PromptTokens, params.CompletionTokens, baseCostQuota(params.Quota, params.Other))
+}
+
+// baseCostQuota 从计费额度中除去分组倍率（含批量折扣）与定时价格规则倍率，
+// 这些倍率只影响售价，不影响上游成本。倍率为 0 时计费额度也为 0，无法还原，按 0 计算
+func baseCostQuota(quota int, other map[string]interface{}) int {
+	dQuota := decimal.NewFromInt(int64(quota))
+	for _, key := range []string{"group_ratio", "pricing_rule_multiplier"} {
+		ratio, ok := other[key].(float64)
+		if !ok {
+			continue
+		}
+		if ratio <= 0 {
+			return 0
+		}
+		dQuota = dQuota.Div(decimal.NewFromFloat(ratio))
+	}
+	return int(dQuota.Round(0).IntPart())
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCalcChannelCost(t *testing.T) {
+	common.QuotaPerUnit = 500 * 1000.0
+	config := &dto.ChannelCostConfig{
+		CostRatio: 0.5,
+		ModelCosts: map[string]dto.ChannelModelCost{
+			"token-model": {InputPrice: 2, OutputPrice: 8},
+			"call-model":  {CallPrice: 0.04},
+			"ratio-model": {CostRatio: 0.8},
+		},
+	}
+	tests := []struct {
+		name       string
+		config     *dto.ChannelCostConfig
+		modelNames []string
+		prompt     int
+		completion int
+		quota      int
+		want       int
+	}{
+		{name: "no config", config: nil, modelNames: []string{"token-model"}, quota: 1000, want: 0},
+		{name: "channel ratio", config: config, modelNames: []string{"other"}, quota: 1000, want: 500},
+		// (1000*2 + 500*8) / 1e6 * 500000 = 3000
+		{name: "token price", config: config, modelNames: []string{"token-model"}, prompt: 1000, completion: 500, quota: 1000, want: 3000},
+		{name: "call price", config: config, modelNames: []string{"call-model"}, quota: 1000, want: 20000},
+		{name: "model ratio", config: config, modelNames: []string{"ratio-model"}, quota: 1000, want: 800},
+		{name: "upstream model name", config: config, modelNames: []string{"alias", "call-model"}, quota: 1000, want: 20000},
+	}
+	for _, tt := range tests {
+		if got := CalcChannelCost(tt.config, tt.modelNames, tt.prompt, tt.completion, tt.quota); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBaseCostQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota int
+		other map[string]interface{}
+		want  int
+	}{
+		{name: "no ratios", quota: 1000, want: 1000},
+		{name: "group ratio", quota: 1500, other: map[string]interface{}{"group_ratio": 1.5}, want: 1000},
+		{name: "group ratio and pricing rule", quota: 600, other: map[string]interface{}{"group_ratio": 2.0, "pricing_rule_multiplier": 0.3}, want: 1000},
+		{name: "free group", quota: 0, other: map[string]interface{}{"group_ratio": 0.0}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := baseCostQuota(tt.quota, tt.other); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCalcConsumeLogCost(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyChannelId, 7)
+	common.SetContextKey(c, constant.ContextKeyChannelOtherSetting, dto.ChannelOtherSettings{CostConfig: &dto.ChannelCostConfig{CostRatio: 0.5}})
+	tests := []struct {
+		name  string
+		other map[string]interface{}
+		want  int
+	}{
+		// 分组倍率只影响售价：1500 / 1.5 * 0.5
+		{name: "group ratio", other: map[string]interface{}{"group_ratio": 1.5}, want: 500},
+		{name: "cache hit", other: map[string]interface{}{"group_ratio": 1.5, "cache_hit": true}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := calcConsumeLogCost(c, RecordConsumeLogParams{ChannelId: 7, ModelName: "gpt-4o", Quota: 1500, Other: tt.other}); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package model
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道路由统计：首字时间（TTFT）的指数移动平均值与当前进行中的请求数，仅保存在当前节点内存中
+
+const (
+	ttftSmoothingFactor = 0.2
+	// 超过该时间没有新样本的首字时间视为过期，渠道重新作为没有数据的渠道被探测
+	ttftSampleTTL = 10 * time.Minute
+)
+
+// 最低首字时间策略以该概率随机选择渠道，避免偶发慢请求后渠道长期得不到流量、统计无法恢复
+var ttftExploreRate = 0.05
+
+type channelTTFTStat struct {
+	ms        float64
+	updatedAt time.Time
+}
+
+var (
+	channelTTFT     = make(map[int]channelTTFTStat)
+	channelTTFTLock sync.RWMutex
+
+	channelInFlight     = make(map[int]*int64)
+	channelInFlightLock sync.RWMutex
+)
+
+// RecordChannelTTFT 记录渠道首字时间
+func RecordChannelTTFT(channelId int, ttft time.Duration) {
+	if channelId == 0 || ttft <= 0 {
+		return
+	}
+	ms := float64(ttft.Milliseconds())
+	now := time.Now()
+	channelTTFTLock.Lock()
+	defer channelTTFTLock.Unlock()
+	if old, ok := channelTTFT[channelId]; ok && now.Sub(old.updatedAt) <= ttftSampleTTL {
+		ms = old.ms*(1-ttftSmoothingFactor) + ms*ttftSmoothingFactor
+	}
+	channelTTFT[channelId] = channelTTFTStat{ms: ms, updatedAt: now}
+}
+
+// GetChannelTTFT 获取渠道首字时间（毫秒），没有统计数据或数据已过期时返回 false
+func GetChannelTTFT(channelId int) (float64, bool) {
+	channelTTFTLock.RLock()
+	defer channelTTFTLock.RUnlock()
+	stat, ok := channelTTFT[channelId]
+	if !ok || time.Since(stat.updatedAt) > ttftSampleTTL {
+		return 0, false
+	}
+	return stat.ms, true
+}
+
+func getChannelInFlightCounter(channelId int) *int64 {
+	channelInFlightLock.RLock()
+	counter, ok := channelInFlight[channelId]
+	channelInFlightLock.RUnlock()
+	if ok {
+		return counter
+	}
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	if counter, ok = channelInFlight[channelId]; !ok {
+		counter = new(int64)
+		channelInFlight[channelId] = counter
+	}
+	return counter
+}
+
+func IncreaseChannelInFlight(channelId int) {
+	atomic.AddInt64(getChannelInFlightCounter(channelId), 1)
+}
+
+func DecreaseChannelInFlight(channelId int) {
+	atomic.AddInt64(getChannelInFlightCounter(channelId), -1)
+}
+
+func GetChannelInFlight(channelId int) int64 {
+	return atomic.LoadInt64(getChannelInFlightCounter(channelId))
+}
+
+// getChannelEffectivePrice 计算渠道实际使用的上游模型的价格，统一换算为额度：
+// 按次计费的模型为每次请求的额度（perCall 为 true），按量计费的模型为每 1K token 的平均额度（输入与输出各占一半），
+// 未配置价格的模型 ok 为 false
+func getChannelEffectivePrice(channel *Channel, modelName string) (price float64, perCall bool, upstreamModel string, ok bool) {
+	upstreamModel = modelName
+	if mapping := channel.GetModelMapping(); mapping != "" && mapping != "{}" {
+		modelMap := make(map[string]string)
+		if err := common.UnmarshalJsonStr(mapping, &modelMap); err == nil {
+			if mapped, ok := modelMap[modelName]; ok && mapped != "" {
+				upstreamModel = mapped
+			}
+		}
+	}
+	if modelPrice, ok := ratio_setting.GetModelPrice(upstreamModel, false); ok {
+		return modelPrice * common.QuotaPerUnit, true, upstreamModel, true
+	}
+	modelRatio, ok, _ := ratio_setting.GetModelRatio(upstreamModel)
+	if !ok {
+		return 0, false, upstreamModel, false
+	}
+	completionRatio := ratio_setting.GetCompletionRatio(upstreamModel)
+	return modelRatio * 1000 * (1 + completionRatio) / 2, false, upstreamModel, true
+}
+
+// selectLowestPriceChannel 选择价格最低的渠道。按次计费与按量计费无法直接比较，
+// 候选渠道的计费方式不一致时返回 nil，由默认的加权随机选择
+func selectLowestPriceChannel(channels []*Channel, modelName string) (*Channel, string) {
+	var selected *Channel
+	best := math.MaxFloat64
+	bestModel := ""
+	bestPerCall := false
+	hasPerCall, hasPerToken := false, false
+	for _, channel := range channels {
+		price, perCall, upstreamModel, ok := getChannelEffectivePrice(channel, modelName)
+		if !ok {
+			continue
+		}
+		if perCall {
+			hasPerCall = true
+		} else {
+			hasPerToken = true
+		}
+		if price < best {
+			best = price
+			bestModel = upstreamModel
+			bestPerCall = perCall
+			selected = channel
+		}
+	}
+	if selected == nil || (hasPerCall && hasPerToken) {
+		return nil, ""
+	}
+	if bestPerCall {
+		return selected, fmt.Sprintf("lowest price %g quota per call (upstream model %s)", best, bestModel)
+	}
+	return selected, fmt.Sprintf("lowest price %g quota per 1K tokens (upstream model %s)", best, bestModel)
+}
+
+// stickyScore 基于用户和渠道计算的一致性哈希分值（rendezvous hashing）
+func stickyScore(userId int, channelId int) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.Itoa(userId) + ":" + strconv.Itoa(channelId)))
+	return h.Sum64()
+}
+
+// selectChannelByStrategy 按路由策略从候选渠道中选择，返回 nil 表示使用默认的加权随机
+func selectChannelByStrategy(c *gin.Context, strategy string, channels []*Channel, modelName string) (*Channel, string) {
+	if len(channels) == 0 {
+		return nil, ""
+	}
+	var selected *Channel
+	var reason string
+	switch strategy {
+	case operation_setting.RoutingStrategyLowestTTFT:
+		if len(channels) > 1 && rand.Float64() < ttftExploreRate {
+			channel := channels[rand.Intn(len(channels))]
+			return channel, fmt.Sprintf("exploring channel #%d for ttft data", channel.Id)
+		}
+		best := math.MaxFloat64
+		for _, channel := range channels {
+			ttft, ok := GetChannelTTFT(channel.Id)
+			if !ok {
+				// 没有统计数据的渠道优先选择，以便收集数据
+				return channel, fmt.Sprintf("channel #%d has no ttft data yet", channel.Id)
+			}
+			if ttft < best {
+				best = ttft
+				selected = channel
+			}
+		}
+		reason = fmt.Sprintf("lowest ttft %.0fms", best)
+	case operation_setting.RoutingStrategyLowestPrice:
+		return selectLowestPriceChannel(channels, modelName)
+	case operation_setting.RoutingStrategyLeastInFlight:
+		var least int64 = math.MaxInt64
+		for _, channel := range channels {
+			inFlight := GetChannelInFlight(channel.Id)
+			if inFlight < least {
+				least = inFlight
+				selected = channel
+			}
+		}
+		reason = fmt.Sprintf("least in-flight requests %d", least)
+	case operation_setting.RoutingStrategyStickyUser:
+		userId := 0
+		if c != nil {
+			userId = c.GetInt("id")
+		}
+		var best uint64
+		for _, channel := range channels {
+			if score := stickyScore(userId, channel.Id); selected == nil || score > best {
+				best = score
+				selected = channel
+			}
+		}
+		reason = fmt.Sprintf("sticky for user %d", userId)
+	}
+	return selected, reason
+}
+
+// excludeUsedChannels 重试时排除已使用过的渠道，没有其他渠道时仍返回原列表
+func excludeUsedChannels(c *gin.Context, channels []*Channel) []*Channel {
+	if c == nil {
+		return channels
+	}
+	useChannel := c.GetStringSlice("use_channel")
+	if len(useChannel) == 0 {
+		return channels
+	}
+	used := make(map[string]bool, len(useChannel))
+	for _, id := range useChannel {
+		used[id] = true
+	}
+	result := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !used[strconv.Itoa(channel.Id)] {
+			result = append(result, channel)
+		}
+	}
+	if len(result) == 0 {
+		return channels
+	}
+	return result
+}
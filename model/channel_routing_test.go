@@ -0,0 +1,112 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+	"testing"
+	"time"
+)
+
+func TestSelectLowestPriceChannel(t *testing.T) {
+	common.QuotaPerUnit = 500 * 1000.0
+	if err := ratio_setting.UpdateModelRatioByJSONString(`{"route-cheap":1,"route-pricey":2}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ratio_setting.UpdateCompletionRatioByJSONString(`{"route-cheap":4,"route-pricey":1}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ratio_setting.UpdateModelPriceByJSONString(`{"route-call-a":0.02,"route-call-b":0.01}`); err != nil {
+		t.Fatal(err)
+	}
+	defer ratio_setting.InitRatioSettings()
+
+	mapped := func(id int, upstream string) *Channel {
+		mapping := `{"route":"` + upstream + `"}`
+		return &Channel{Id: id, ModelMapping: &mapping}
+	}
+	tests := []struct {
+		name     string
+		channels []*Channel
+		want     int
+	}{
+		// 每 1K token 平均额度：cheap 1000*1*(1+4)/2=2500，pricey 1000*2*(1+1)/2=2000
+		{name: "per token", channels: []*Channel{mapped(1, "route-cheap"), mapped(2, "route-pricey")}, want: 2},
+		{name: "per call", channels: []*Channel{mapped(1, "route-call-a"), mapped(2, "route-call-b")}, want: 2},
+		{name: "mixed pricing", channels: []*Channel{mapped(1, "route-cheap"), mapped(2, "route-call-b")}, want: 0},
+		{name: "unpriced skipped", channels: []*Channel{mapped(1, "route-unknown"), mapped(2, "route-pricey")}, want: 2},
+		{name: "all unpriced", channels: []*Channel{mapped(1, "route-unknown")}, want: 0},
+	}
+	for _, tt := range tests {
+		got, _ := selectLowestPriceChannel(tt.channels, "route")
+		gotId := 0
+		if got != nil {
+			gotId = got.Id
+		}
+		if gotId != tt.want {
+			t.Errorf("%s: got channel %d, want %d", tt.name, gotId, tt.want)
+		}
+	}
+}
+
+func TestSelectLowestTTFTChannel(t *testing.T) {
+	defer func(rate float64) {
+		ttftExploreRate = rate
+	}(ttftExploreRate)
+	ttftExploreRate = 0
+
+	const base = 1 << 29
+	now := time.Now()
+	channels := []*Channel{{Id: base + 1}, {Id: base + 2}, {Id: base + 3}}
+	tests := []struct {
+		name  string
+		stats map[int]channelTTFTStat
+		want  int
+	}{
+		{name: "lowest", stats: map[int]channelTTFTStat{
+			base + 1: {ms: 900, updatedAt: now}, base + 2: {ms: 300, updatedAt: now}, base + 3: {ms: 600, updatedAt: now},
+		}, want: base + 2},
+		// 没有数据的渠道优先，以便收集数据
+		{name: "missing data", stats: map[int]channelTTFTStat{
+			base + 1: {ms: 900, updatedAt: now}, base + 2: {ms: 300, updatedAt: now},
+		}, want: base + 3},
+		// 长时间没有新样本的渠道重新探测
+		{name: "stale data", stats: map[int]channelTTFTStat{
+			base + 1: {ms: 100, updatedAt: now.Add(-2 * ttftSampleTTL)}, base + 2: {ms: 300, updatedAt: now}, base + 3: {ms: 600, updatedAt: now},
+		}, want: base + 1},
+	}
+	for _, tt := range tests {
+		channelTTFTLock.Lock()
+		for _, channel := range channels {
+			delete(channelTTFT, channel.Id)
+		}
+		for id, stat := range tt.stats {
+			channelTTFT[id] = stat
+		}
+		channelTTFTLock.Unlock()
+		if got, reason := selectChannelByStrategy(nil, operation_setting.RoutingStrategyLowestTTFT, channels, "m"); got == nil || got.Id != tt.want {
+			t.Errorf("%s: got %v (%s), want #%d", tt.name, got, reason, tt.want)
+		}
+	}
+
+	// 过期数据被新样本替换而不是继续平滑
+	RecordChannelTTFT(base+1, 500*time.Millisecond)
+	if ttft, ok := GetChannelTTFT(base + 1); !ok || ttft != 500 {
+		t.Errorf("ttft after stale sample: %v %v", ttft, ok)
+	}
+	RecordChannelTTFT(base+1, 1000*time.Millisecond)
+	if ttft, _ := GetChannelTTFT(base + 1); ttft != 600 {
+		t.Errorf("smoothed ttft %v, want 600", ttft)
+	}
+
+	// 探测时随机选择，所有渠道都有机会被选中
+	ttftExploreRate = 1
+	picked := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		got, _ := selectChannelByStrategy(nil, operation_setting.RoutingStrategyLowestTTFT, channels, "m")
+		picked[got.Id] = true
+	}
+	if len(picked) != len(channels) {
+		t.Errorf("exploration picked %v", picked)
+	}
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	CostReportByChannel = "channel"
+	CostReportByModel   = "model"
+	CostReportByGroup   = "group"
+	CostReportByDay     = "day"
+)
+
+// CostReportItem 收入（扣费额度）、上游成本与毛利统计，额度单位
+type CostReportItem struct {
+	Key         string  `json:"key"`
+	ChannelName string  `json:"channel_name,omitempty"`
+	Count       int64   `json:"count"`
+	Revenue     int64   `json:"revenue"`
+	Cost        int64   `json:"cost"`
+	Margin      int64   `json:"margin"`
+	MarginRate  float64 `json:"margin_rate"`
+	// 已记录上游成本的调用次数，未配置成本的调用不计入成本
+	CostedCount int64 `json:"costed_count"`
+}
+
+func (item *CostReportItem) fill() {
+	item.Margin = item.Revenue - item.Cost
+	if item.Revenue != 0 {
+		item.MarginRate = float64(item.Margin) / float64(item.Revenue)
+	}
+}
+
+type CostReportFilter struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	ChannelId      int
+	ModelName      string
+	Group          string
+}
+
+// GetCostReport 按渠道、模型、分组或天（UTC）汇总消费日志中的收入与上游成本
+func GetCostReport(groupBy string, filter CostReportFilter) (items []*CostReportItem, total CostReportItem, err error) {
+	var keyExpr string
+	switch groupBy {
+	case CostReportByChannel:
+		keyExpr = "channel_id"
+	case CostReportByModel:
+		keyExpr = "model_name"
+	case CostReportByGroup:
+		keyExpr = logGroupCol
+	case CostReportByDay:
+		keyExpr = "created_at - created_at % 86400"
+	default:
+		return nil, total, fmt.Errorf("invalid group_by: %s", groupBy)
+	}
+
+	tx := LOG_DB.Table("logs").Where("type = ?", LogTypeConsume)
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	if filter.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", filter.ChannelId)
+	}
+	if filter.ModelName != "" {
+		tx = tx.Where("model_name = ?", filter.ModelName)
+	}
+	if filter.Group != "" {
+		tx = tx.Where(logGroupCol+" = ?", filter.Group)
+	}
+
+	var rows []struct {
+		ReportKey   string
+		Count       int64
+		Revenue     int64
+		Cost        int64
+		CostedCount int64
+	}
+	err = tx.Select(keyExpr + " as report_key, count(*) as count, sum(quota) as revenue, sum(cost) as cost, " +
+		"sum(case when cost > 0 then 1 else 0 end) as costed_count").
+		Group(keyExpr).
+		Order("revenue desc").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, total, err
+	}
+
+	channelIds := make([]int, 0)
+	items = make([]*CostReportItem, 0, len(rows))
+	for _, row := range rows {
+		item := &CostReportItem{
+			Key:         row.ReportKey,
+			Count:       row.Count,
+			Revenue:     row.Revenue,
+			Cost:        row.Cost,
+			CostedCount: row.CostedCount,
+		}
+		item.fill()
+		items = append(items, item)
+		total.Count += item.Count
+		total.Revenue += item.Revenue
+		total.Cost += item.Cost
+		total.CostedCount += item.CostedCount
+		if groupBy == CostReportByChannel {
+			if id, convErr := strconv.Atoi(item.Key); convErr == nil {
+				channelIds = append(channelIds, id)
+			}
+		}
+	}
+	total.Key = "total"
+	total.fill()
+
+	if len(channelIds) > 0 {
+		var channels []Channel
+		if err = DB.Select("id, name").Where("id in ?", channelIds).Find(&channels).Error; err == nil {
+			names := make(map[string]string, len(channels))
+			for _, channel := range channels {
+				names[strconv.Itoa(channel.Id)] = channel.Name
+			}
+			for _, item := range items {
+				item.ChannelName = names[item.Key]
+			}
+		}
+		err = nil
+	}
+	return items, total, nil
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FileStatusUploaded  = "uploaded"
+	FileStatusProcessed = "processed"
+	FileStatusDeleted   = "deleted"
+)
+
+// UpstreamFileIds 记录文件在各渠道上传后的上游文件 ID，key 为渠道 ID（多密钥渠道为 渠道ID:密钥索引）
+type UpstreamFileIds map[string]string
+
+func (m *UpstreamFileIds) Scan(val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		if len(v) == 0 {
+			*m = nil
+			return nil
+		}
+		return json.Unmarshal(v, m)
+	case string:
+		if v == "" {
+			*m = nil
+			return nil
+		}
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("unsupported type for UpstreamFileIds")
+	}
+}
+
+func (m UpstreamFileIds) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// File 用户通过 /v1/files 上传的文件，文件内容保存在 FileStorage 中
+type File struct {
+	Id          int             `json:"id"`
+	FileId      string          `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId      int             `json:"user_id" gorm:"index"`
+	TokenId     int             `json:"token_id" gorm:"index"`
+	Filename    string          `json:"filename" gorm:"type:varchar(255)"`
+	Purpose     string          `json:"purpose" gorm:"type:varchar(32);index"`
+	MimeType    string          `json:"mime_type" gorm:"type:varchar(128)"`
+	Bytes       int64           `json:"bytes"`
+	StoragePath string          `json:"-" gorm:"type:varchar(255)"`
+	Status      string          `json:"status" gorm:"type:varchar(20);index"`
+	Upstream    UpstreamFileIds `json:"upstream,omitempty" gorm:"type:json"`
+	CreatedAt   int64           `json:"created_at" gorm:"bigint;index"`
+	DeletedAt   gorm.DeletedAt  `json:"-" gorm:"index"`
+}
+
+func (file *File) Insert() error {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+// Delete 标记文件为已删除并软删除记录，上传到渠道的副本由调用方清理
+func (file *File) Delete() error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(file).Update("status", FileStatusDeleted).Error; err != nil {
+			return err
+		}
+		return tx.Delete(file).Error
+	})
+	if err != nil {
+		return err
+	}
+	file.Status = FileStatusDeleted
+	return nil
+}
+
+// GetUpstreamFileId 获取文件在指定渠道上的上游文件 ID
+func (file *File) GetUpstreamFileId(key string) string {
+	if file.Upstream == nil {
+		return ""
+	}
+	return file.Upstream[key]
+}
+
+// SetUpstreamFileId 保存文件在指定渠道上的上游文件 ID
+func (file *File) SetUpstreamFileId(key string, upstreamId string) error {
+	if file.Upstream == nil {
+		file.Upstream = make(UpstreamFileIds)
+	}
+	file.Upstream[key] = upstreamId
+	return DB.Model(&File{}).Where("id = ?", file.Id).Update("upstream", file.Upstream).Error
+}
+
+func GetUserFileByFileId(userId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	var file File
+	err := DB.Where("user_id = ? and file_id = ?", userId, fileId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFiles 分页获取用户文件，afterId 大于 0 时只返回 id 小于 afterId 的记录
+func GetUserFiles(userId int, purpose string, afterId int, num int) (files []*File, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		tx = tx.Where("purpose = ?", purpose)
+	}
+	if afterId > 0 {
+		tx = tx.Where("id < ?", afterId)
+	}
+	err = tx.Order("id desc").Limit(num).Find(&files).Error
+	return files, err
+}
+
+// GetUserFileBytes 获取用户当前占用的文件存储空间（字节）
+func GetUserFileBytes(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&File{}).Where("user_id = ?", userId).Select("COALESCE(SUM(bytes), 0)").Scan(&total).Error
+	return total, err
+}
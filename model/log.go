@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/common/tracing"
+	"one-api/constant"
 	"one-api/logger"
 	"one-api/types"
 	"os"
@@ -26,6 +29,7 @@ type Log struct {
 	TokenName        string `json:"token_name" gorm:"index;default:''"`
 	ModelName        string `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
 	Quota            int    `json:"quota" gorm:"default:0"`
+	Cost             int    `json:"cost,omitempty" gorm:"default:0"` // 上游成本（额度单位），仅管理员可见
 	PromptTokens     int    `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens int    `json:"completion_tokens" gorm:"default:0"`
 	UseTime          int    `json:"use_time" gorm:"default:0"`
@@ -36,6 +40,7 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	OrganizationId   int    `json:"organization_id" gorm:"default:0;index"`
 }
 
 const (
@@ -45,11 +50,13 @@ const (
 	LogTypeManage
 	LogTypeSystem
 	LogTypeError
+	LogTypeRefund
 )
 
 func formatUserLogs(logs []*Log) {
 	for i := range logs {
 		logs[i].ChannelName = ""
+		logs[i].Cost = 0
 		var otherMap map[string]interface{}
 		otherMap, _ = common.StrToMap(logs[i].Other)
 		if otherMap != nil {
@@ -93,10 +100,69 @@ func RecordLog(userId int, logType int, content string) {
 	}
 }
 
+// RecordRefundLog 记录退款日志，额度记录在 quota 字段中，用于生成账单
+func RecordRefundLog(userId int, organizationId int, tokenId int, quota int, content string) {
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:         userId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           LogTypeRefund,
+		Content:        content,
+		Quota:          quota,
+		TokenId:        tokenId,
+		OrganizationId: organizationId,
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		common.SysLog("failed to record log: " + err.Error())
+	}
+}
+
+// RecordQuotaAdjustmentLog 记录管理员调整额度的日志，调整量（可为负）记录在 quota 字段中，用于生成账单
+func RecordQuotaAdjustmentLog(userId int, quota int, content string) {
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:    userId,
+		Username:  username,
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeManage,
+		Content:   content,
+		Quota:     quota,
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		common.SysLog("failed to record log: " + err.Error())
+	}
+}
+
+// RecordOrganizationLog 记录与组织相关的日志，组织成员可在组织日志中查看
+func RecordOrganizationLog(organizationId int, userId int, logType int, content string) {
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:         userId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           logType,
+		Content:        content,
+		OrganizationId: organizationId,
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		common.SysLog("failed to record log: " + err.Error())
+	}
+}
+
 func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string, tokenName string, content string, tokenId int, useTimeSeconds int,
 	isStream bool, group string, other map[string]interface{}) {
 	logger.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
 	username := c.GetString("username")
+	if traceId := tracing.GetTraceId(c); traceId != "" {
+		if other == nil {
+			other = make(map[string]interface{})
+		}
+		other["trace_id"] = traceId
+	}
 	otherStr := common.MapToJsonStr(other)
 	// 判断是否需要记录 IP
 	needRecordIp := false
@@ -110,6 +176,7 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		Username:         username,
 		CreatedAt:        common.GetTimestamp(),
 		Type:             LogTypeError,
+		OrganizationId:   common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 		Content:          content,
 		PromptTokens:     0,
 		CompletionTokens: 0,
@@ -151,12 +218,20 @@ type RecordConsumeLogParams struct {
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	metrics.ObserveConsume(params.ChannelId, params.ModelName, params.Group, params.PromptTokens, params.CompletionTokens, params.Quota)
 	if !common.LogConsumeEnabled {
 		return
 	}
 	logger.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
 	username := c.GetString("username")
+	if traceId := tracing.GetTraceId(c); traceId != "" {
+		if params.Other == nil {
+			params.Other = make(map[string]interface{})
+		}
+		params.Other["trace_id"] = traceId
+	}
 	otherStr := common.MapToJsonStr(params.Other)
+	cost := calcConsumeLogCost(c, params)
 	// 判断是否需要记录 IP
 	needRecordIp := false
 	if settingMap, err := GetUserSetting(userId, false); err == nil {
@@ -169,12 +244,14 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		Username:         username,
 		CreatedAt:        common.GetTimestamp(),
 		Type:             LogTypeConsume,
+		OrganizationId:   common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 		Content:          params.Content,
 		PromptTokens:     params.PromptTokens,
 		CompletionTokens: params.CompletionTokens,
 		TokenName:        params.TokenName,
 		ModelName:        params.ModelName,
 		Quota:            params.Quota,
+		Cost:             cost,
 		ChannelId:        params.ChannelId,
 		TokenId:          params.TokenId,
 		UseTime:          params.UseTimeSeconds,
@@ -194,7 +271,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 	if common.DataExportEnabled {
 		gopool.Go(func() {
-			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
+			LogQuotaData(userId, username, params.ModelName, params.Quota, cost, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
 		})
 	}
 }
@@ -313,12 +390,13 @@ func SearchUserLogs(userId int, keyword string) (logs []*Log, err error) {
 
 type Stat struct {
 	Quota int `json:"quota"`
+	Cost  int `json:"cost"`
 	Rpm   int `json:"rpm"`
 	Tpm   int `json:"tpm"`
 }
 
 func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
-	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
+	tx := LOG_DB.Table("logs").Select("sum(quota) quota, sum(cost) cost")
 
 	// 为rpm和tpm创建单独的查询
 	rpmTpmQuery := LOG_DB.Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
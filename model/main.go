@@ -265,6 +265,16 @@ func migrateDB() error {
 		&Setup{},
 		&TwoFA{},
 		&TwoFABackupCode{},
+		&File{},
+		&Batch{},
+		&Organization{},
+		&OrganizationMember{},
+		&StoredResponse{},
+		&TaskWebhookDelivery{},
+		&Asset{},
+		&BillingStatement{},
+		&Plan{},
+		&Subscription{},
 	)
 	if err != nil {
 		return err
@@ -297,6 +307,16 @@ func migrateDBFast() error {
 		{&Setup{}, "Setup"},
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&File{}, "File"},
+		{&Batch{}, "Batch"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&StoredResponse{}, "StoredResponse"},
+		{&TaskWebhookDelivery{}, "TaskWebhookDelivery"},
+		{&Asset{}, "Asset"},
+		{&BillingStatement{}, "BillingStatement"},
+		{&Plan{}, "Plan"},
+		{&Subscription{}, "Subscription"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -327,7 +347,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &PayloadCapture{}); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"one-api/common"
+	"os"
+	"testing"
+)
+
+// TestMain 测试使用内存 SQLite 数据库，不启用 Redis
+func TestMain(m *testing.M) {
+	common.RedisEnabled = false
+	common.IsMasterNode = true
+	common.SQLitePath = "file:model_test?mode=memory&cache=shared"
+	if err := InitDB(); err != nil {
+		panic(err)
+	}
+	if err := InitLogDB(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"one-api/common"
+	"one-api/common/metrics"
 	"one-api/setting"
 	"one-api/setting/config"
 	"one-api/setting/operation_setting"
@@ -107,6 +108,8 @@ func InitOptionMap() {
 	common.OptionMap["ModelRatio"] = ratio_setting.ModelRatio2JSONString()
 	common.OptionMap["ModelPrice"] = ratio_setting.ModelPrice2JSONString()
 	common.OptionMap["CacheRatio"] = ratio_setting.CacheRatio2JSONString()
+	common.OptionMap["ModelRatioTiers"] = ratio_setting.ModelRatioTiers2JSONString()
+	common.OptionMap["PricingRules"] = ratio_setting.PricingRules2JSONString()
 	common.OptionMap["GroupRatio"] = ratio_setting.GroupRatio2JSONString()
 	common.OptionMap["GroupGroupRatio"] = ratio_setting.GroupGroupRatio2JSONString()
 	common.OptionMap["UserUsableGroups"] = setting.UserUsableGroups2JSONString()
@@ -146,6 +149,7 @@ func InitOptionMap() {
 }
 
 func loadOptionsFromDatabase() {
+	defer metrics.ObserveCacheSync("options", time.Now())
 	options, _ := AllOption()
 	for _, option := range options {
 		err := updateOptionMap(option.Key, option.Value)
@@ -396,6 +400,10 @@ func updateOptionMap(key string, value string) (err error) {
 		err = ratio_setting.UpdateModelPriceByJSONString(value)
 	case "CacheRatio":
 		err = ratio_setting.UpdateCacheRatioByJSONString(value)
+	case "ModelRatioTiers":
+		err = ratio_setting.UpdateModelRatioTiersByJSONString(value)
+	case "PricingRules":
+		err = ratio_setting.UpdatePricingRulesByJSONString(value)
 	case "TopUpLink":
 		common.TopUpLink = value
 	//case "ChatLink":
@@ -0,0 +1,397 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationRoleOwner   = "owner"
+	OrganizationRoleAdmin   = "admin"
+	OrganizationRoleMember  = "member"
+	OrganizationRoleBilling = "billing"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+// 成员被添加时处于邀请状态，用户接受后才能使用组织额度与权限
+const (
+	OrganizationMemberStatusJoined  = 1
+	OrganizationMemberStatusInvited = 2
+)
+
+// Organization 组织，成员共享组织额度池
+type Organization struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId     int            `json:"owner_id" gorm:"index"`
+	Status      int            `json:"status" gorm:"default:1"`
+	Quota       int            `json:"quota" gorm:"default:0"`
+	UsedQuota   int            `json:"used_quota" gorm:"default:0"`
+	CreatedTime int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员可使用的组织额度上限，0 表示不限制
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member,priority:1"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_org_member,priority:2;index"`
+	Username       string `json:"username" gorm:"-"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"default:0"`
+	Status         int    `json:"status" gorm:"default:1"`
+	InvitedBy      int    `json:"invited_by" gorm:"default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// UserOrganization 用户所在的组织及其角色
+type UserOrganization struct {
+	Organization
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	MemberUsed int    `json:"member_used_quota"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember, OrganizationRoleBilling:
+		return true
+	}
+	return false
+}
+
+// HasRole 判断成员是否具有任意一个给定角色
+func (member *OrganizationMember) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if member.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	org := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: common.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			Status:         OrganizationMemberStatusJoined,
+			CreatedTime:    common.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	err := DB.First(&org, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func GetAllOrganizations(startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	err = DB.Model(&Organization{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// GetUserOrganizations 获取用户已加入的组织
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	return getUserOrganizations(userId, OrganizationMemberStatusJoined)
+}
+
+// GetUserOrganizationInvitations 获取用户收到的组织邀请
+func GetUserOrganizationInvitations(userId int) ([]*UserOrganization, error) {
+	return getUserOrganizations(userId, OrganizationMemberStatusInvited)
+}
+
+func getUserOrganizations(userId int, status int) ([]*UserOrganization, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ? and status = ?", userId, status).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*UserOrganization, 0, len(members))
+	for _, member := range members {
+		org, err := GetOrganizationById(member.OrganizationId)
+		if err != nil {
+			continue
+		}
+		result = append(result, &UserOrganization{
+			Organization: *org,
+			Role:         member.Role,
+			QuotaLimit:   member.QuotaLimit,
+			MemberUsed:   member.UsedQuota,
+		})
+	}
+	return result, nil
+}
+
+func (org *Organization) Update() error {
+	return DB.Model(org).Select("name", "status").Updates(org).Error
+}
+
+// Delete 删除组织，同时删除成员关系并禁用组织令牌
+func (org *Organization) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", org.Id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ?", org.Id).Update("status", common.TokenStatusDisabled).Error; err != nil {
+			return err
+		}
+		return tx.Delete(org).Error
+	})
+}
+
+// GetOrganizationMember 获取已加入组织的成员，未接受邀请的用户不视为成员
+func GetOrganizationMember(organizationId int, userId int) (*OrganizationMember, error) {
+	return getOrganizationMember(organizationId, userId, OrganizationMemberStatusJoined)
+}
+
+// GetOrganizationInvitation 获取用户尚未接受的组织邀请
+func GetOrganizationInvitation(organizationId int, userId int) (*OrganizationMember, error) {
+	return getOrganizationMember(organizationId, userId, OrganizationMemberStatusInvited)
+}
+
+func getOrganizationMember(organizationId int, userId int, status int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.Where("organization_id = ? and user_id = ? and status = ?", organizationId, userId, status).First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// IsOrganizationMemberOrInvited 判断用户已加入组织或已被邀请
+func IsOrganizationMemberOrInvited(organizationId int, userId int) bool {
+	var count int64
+	DB.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", organizationId, userId).Count(&count)
+	return count > 0
+}
+
+func GetOrganizationMembers(organizationId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	err := DB.Where("organization_id = ?", organizationId).Order("id asc").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		member.Username, _ = GetUsernameById(member.UserId, false)
+	}
+	return members, nil
+}
+
+func (member *OrganizationMember) Insert() error {
+	member.CreatedTime = common.GetTimestamp()
+	return DB.Create(member).Error
+}
+
+// Accept 接受组织邀请，邀请已被撤回或已接受时返回 false
+func (member *OrganizationMember) Accept() (bool, error) {
+	result := DB.Model(&OrganizationMember{}).Where("id = ? and status = ?", member.Id, OrganizationMemberStatusInvited).
+		Update("status", OrganizationMemberStatusJoined)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	member.Status = OrganizationMemberStatusJoined
+	return true, nil
+}
+
+// Update 更新成员角色与额度上限，resetUsed 时同时清零已用额度，
+// 否则不写回已用额度，避免覆盖并发的消费记录
+func (member *OrganizationMember) Update(resetUsed bool) error {
+	columns := []interface{}{"role", "quota_limit"}
+	if resetUsed {
+		columns = append(columns, "used_quota")
+	}
+	return DB.Model(member).Select(columns[0], columns[1:]...).Updates(member).Error
+}
+
+// Delete 移除成员，并禁用该成员创建的组织令牌
+func (member *OrganizationMember) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Token{}).Where("organization_id = ? and user_id = ?", member.OrganizationId, member.UserId).Update("status", common.TokenStatusDisabled).Error; err != nil {
+			return err
+		}
+		return tx.Delete(member).Error
+	})
+}
+
+func GetOrganizationTokens(organizationId int, startIdx int, num int) (tokens []*Token, total int64, err error) {
+	tx := DB.Model(&Token{}).Where("organization_id = ?", organizationId)
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&tokens).Error
+	return tokens, total, err
+}
+
+// GetOrganizationAvailableQuota 获取成员可使用的组织额度（组织剩余额度与成员剩余上限的较小值）
+func GetOrganizationAvailableQuota(organizationId int, userId int) (int, error) {
+	org, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return 0, errors.New("组织不存在")
+	}
+	if org.Status != OrganizationStatusEnabled {
+		return 0, errors.New("组织已被禁用")
+	}
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return 0, errors.New("用户不是该组织成员")
+	}
+	// 财务成员只能充值与查看账单，成员改为财务角色前创建的令牌也不能使用组织额度
+	if member.HasRole(OrganizationRoleBilling) {
+		return 0, errors.New("财务成员不能使用组织额度")
+	}
+	quota := org.Quota
+	if member.QuotaLimit > 0 && member.QuotaLimit-member.UsedQuota < quota {
+		quota = member.QuotaLimit - member.UsedQuota
+	}
+	return quota, nil
+}
+
+// DecreaseOrganizationQuota 扣除组织额度，并累计组织与成员的已用额度
+func DecreaseOrganizationQuota(organizationId int, userId int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", quota),
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", organizationId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	})
+}
+
+// IncreaseOrganizationQuota 返还组织额度，userId 为 0 时表示充值，不影响已用额度
+func IncreaseOrganizationQuota(organizationId int, userId int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	if userId == 0 {
+		return DB.Model(&Organization{}).Where("id = ?", organizationId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", quota),
+			"used_quota": gorm.Expr("used_quota - ?", quota),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", organizationId, userId).
+			Update("used_quota", gorm.Expr("used_quota - ?", quota)).Error
+	})
+}
+
+// GetPayerQuota 获取请求付费方的剩余额度：组织令牌使用组织额度，否则使用用户额度
+func GetPayerQuota(userId int, organizationId int) (int, error) {
+	if organizationId != 0 {
+		return GetOrganizationAvailableQuota(organizationId, userId)
+	}
+	return GetUserQuota(userId, false)
+}
+
+func DecreasePayerQuota(userId int, organizationId int, quota int) error {
+	if organizationId != 0 {
+		return DecreaseOrganizationQuota(organizationId, userId, quota)
+	}
+	return DecreaseUserQuota(userId, quota)
+}
+
+func IncreasePayerQuota(userId int, organizationId int, quota int) error {
+	if organizationId != 0 {
+		return IncreaseOrganizationQuota(organizationId, userId, quota)
+	}
+	return IncreaseUserQuota(userId, quota, false)
+}
+
+// RechargeOrganization 为组织充值并记录日志
+func RechargeOrganization(organizationId int, operatorId int, quota int, content string) error {
+	err := IncreaseOrganizationQuota(organizationId, 0, quota)
+	if err != nil {
+		return err
+	}
+	RecordOrganizationLog(organizationId, operatorId, LogTypeTopup, fmt.Sprintf("%s，充值组织 #%d 额度: %s", content, organizationId, logger.LogQuota(quota)))
+	return nil
+}
+
+// GetOrganizationLogs 获取组织日志，包括组织令牌产生的消费记录与组织充值记录
+func GetOrganizationLogs(organizationId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.organization_id = ?", organizationId)
+	if logType != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", logType)
+	}
+	if modelName != "" {
+		tx = tx.Where("logs.model_name like ?", modelName)
+	}
+	if username != "" {
+		tx = tx.Where("logs.username = ?", username)
+	}
+	if tokenName != "" {
+		tx = tx.Where("logs.token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	err = tx.Model(&Log{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("logs.id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	formatUserLogs(logs)
+	return logs, total, err
+}
+
+// GetOrganizationQuotaData 按小时、成员与模型汇总组织的消费数据
+func GetOrganizationQuotaData(organizationId int, startTime int64, endTime int64) (quotaData []*QuotaData, err error) {
+	err = LOG_DB.Table("logs").
+		Select("user_id, username, model_name, count(*) as count, sum(quota) as quota, sum(prompt_tokens + completion_tokens) as token_used, created_at - created_at % 3600 as created_at").
+		Where("organization_id = ? and type = ? and created_at >= ? and created_at <= ?", organizationId, LogTypeConsume, startTime, endTime).
+		Group("user_id, username, model_name, created_at - created_at % 3600").
+		Find(&quotaData).Error
+	return quotaData, err
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"testing"
+)
+
+// newTestOrganization 创建组织并按角色添加已加入的成员，返回组织与成员的用户 ID
+func newTestOrganization(t *testing.T, quota int, roles ...string) (*Organization, []int) {
+	t.Helper()
+	org, err := CreateOrganization(t.Name(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = DB.Model(org).Update("quota", quota).Error; err != nil {
+		t.Fatal(err)
+	}
+	var userIds []int
+	for i, role := range roles {
+		userId := 5000 + org.Id*10 + i
+		member := &OrganizationMember{OrganizationId: org.Id, UserId: userId, Role: role, Status: OrganizationMemberStatusJoined}
+		if err = member.Insert(); err != nil {
+			t.Fatal(err)
+		}
+		userIds = append(userIds, userId)
+	}
+	return org, userIds
+}
+
+func TestPayerQuota(t *testing.T) {
+	org, userIds := newTestOrganization(t, 1000, OrganizationRoleMember)
+	memberId := userIds[0]
+
+	if err := DecreasePayerQuota(memberId, org.Id, 300); err != nil {
+		t.Fatal(err)
+	}
+	// 返还时恢复组织额度并扣回组织与成员的已用额度
+	if err := IncreasePayerQuota(memberId, org.Id, 100); err != nil {
+		t.Fatal(err)
+	}
+	result, _ := GetOrganizationById(org.Id)
+	member, _ := GetOrganizationMember(org.Id, memberId)
+	if result.Quota != 800 || result.UsedQuota != 200 || member.UsedQuota != 200 {
+		t.Errorf("organization quota %d used %d, member used %d", result.Quota, result.UsedQuota, member.UsedQuota)
+	}
+	if err := IncreasePayerQuota(memberId, org.Id, -1); err == nil {
+		t.Errorf("negative quota accepted")
+	}
+
+	// 非组织令牌返还到用户额度
+	user := &User{Username: "payer_user", AffCode: "payer", Quota: 100, Status: 1}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := IncreasePayerQuota(user.Id, 0, 50); err != nil {
+		t.Fatal(err)
+	}
+	if quota, _ := GetPayerQuota(user.Id, 0); quota != 150 {
+		t.Errorf("user quota %d, want 150", quota)
+	}
+	if result, _ = GetOrganizationById(org.Id); result.Quota != 800 {
+		t.Errorf("organization quota changed to %d", result.Quota)
+	}
+}
+
+func TestGetOrganizationAvailableQuota(t *testing.T) {
+	org, userIds := newTestOrganization(t, 1000, OrganizationRoleMember, OrganizationRoleBilling, OrganizationRoleMember)
+	limited, billing, invited := userIds[0], userIds[1], userIds[2]
+	if err := DB.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", org.Id, limited).
+		Updates(map[string]any{"quota_limit": 500, "used_quota": 200}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", org.Id, invited).
+		Update("status", OrganizationMemberStatusInvited).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userId  int
+		want    int
+		wantErr bool
+	}{
+		{name: "member quota limit", userId: limited, want: 300},
+		// 财务成员与未接受邀请的用户不能使用组织额度
+		{name: "billing member", userId: billing, wantErr: true},
+		{name: "invited user", userId: invited, wantErr: true},
+		{name: "not a member", userId: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := GetOrganizationAvailableQuota(org.Id, tt.userId)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: got %d, %v", tt.name, got, err)
+		}
+	}
+}
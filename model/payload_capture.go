@@ -0,0 +1,91 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"time"
+)
+
+// PayloadCapture 请求与响应内容的审计记录，通过 request_id 与消费日志关联
+type PayloadCapture struct {
+	Id                int    `json:"id"`
+	RequestId         string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId            int    `json:"user_id" gorm:"index"`
+	TokenId           int    `json:"token_id" gorm:"default:0;index"`
+	TokenName         string `json:"token_name" gorm:"default:''"`
+	ChannelId         int    `json:"channel_id" gorm:"default:0"`
+	ModelName         string `json:"model_name" gorm:"default:''"`
+	Group             string `json:"group" gorm:"default:''"`
+	Method            string `json:"method" gorm:"type:varchar(16)"`
+	Path              string `json:"path" gorm:"type:varchar(255)"`
+	StatusCode        int    `json:"status_code"`
+	IsStream          bool   `json:"is_stream"`
+	RequestBody       string `json:"request_body" gorm:"type:text"`
+	ResponseBody      string `json:"response_body" gorm:"type:text"`
+	RequestTruncated  bool   `json:"request_truncated"`
+	ResponseTruncated bool   `json:"response_truncated"`
+	DurationMs        int64  `json:"duration_ms"`
+	CreatedAt         int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func (capture *PayloadCapture) Insert() error {
+	if capture.CreatedAt == 0 {
+		capture.CreatedAt = common.GetTimestamp()
+	}
+	return LOG_DB.Create(capture).Error
+}
+
+// GetPayloadCaptureByRequestId 根据请求 ID 获取审计记录，userId 为 0 时不限制用户
+func GetPayloadCaptureByRequestId(requestId string, userId int) (*PayloadCapture, error) {
+	var capture PayloadCapture
+	tx := LOG_DB.Where("request_id = ?", requestId)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err := tx.First(&capture).Error
+	if err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+func DeleteOldPayloadCaptures(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+
+		result := LOG_DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&PayloadCapture{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// CleanupPayloadCaptures 定期清理超过保留天数的审计记录
+func CleanupPayloadCaptures() {
+	for {
+		if days := operation_setting.GetPayloadCaptureSetting().RetentionDays; days > 0 {
+			target := time.Now().AddDate(0, 0, -days).Unix()
+			count, err := DeleteOldPayloadCaptures(context.Background(), target, 1000)
+			if err != nil {
+				common.SysLog("failed to delete old payload captures: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d old payload captures", count))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -14,20 +14,35 @@ import (
 )
 
 type Pricing struct {
-	ModelName              string                  `json:"model_name"`
-	Description            string                  `json:"description,omitempty"`
-	Icon                   string                  `json:"icon,omitempty"`
-	Tags                   string                  `json:"tags,omitempty"`
-	VendorID               int                     `json:"vendor_id,omitempty"`
-	QuotaType              int                     `json:"quota_type"`
-	ModelRatio             float64                 `json:"model_ratio"`
-	ModelPrice             float64                 `json:"model_price"`
-	OwnerBy                string                  `json:"owner_by"`
-	CompletionRatio        float64                 `json:"completion_ratio"`
+	ModelName       string  `json:"model_name"`
+	Description     string  `json:"description,omitempty"`
+	Icon            string  `json:"icon,omitempty"`
+	Tags            string  `json:"tags,omitempty"`
+	VendorID        int     `json:"vendor_id,omitempty"`
+	QuotaType       int     `json:"quota_type"`
+	ModelRatio      float64 `json:"model_ratio"`
+	ModelPrice      float64 `json:"model_price"`
+	OwnerBy         string  `json:"owner_by"`
+	CompletionRatio float64 `json:"completion_ratio"`
+	// 上下文长度分段倍率，提示 token 数超过分段起点时使用分段倍率
+	ContextTiers []ratio_setting.ModelRatioTier `json:"context_tiers,omitempty"`
+	// 当前生效及即将生效的定时价格规则
+	PricingRules           []PricingRuleView       `json:"pricing_rules,omitempty"`
 	EnableGroup            []string                `json:"enable_groups"`
 	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
 }
 
+// PricingRuleView 定时价格规则在定价接口中的展示，Start/End 为当前或下一个生效时段
+type PricingRuleView struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Groups     []string `json:"groups,omitempty"`
+	Multiplier float64  `json:"multiplier"`
+	Active     bool     `json:"active"`
+	Start      int64    `json:"start"`
+	End        int64    `json:"end"`
+}
+
 type PricingVendor struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
@@ -262,6 +277,7 @@ func updatePricing() {
 	}
 
 	pricingMap = make([]Pricing, 0)
+	now := time.Now()
 	for model, groups := range modelGroupsMap {
 		pricing := Pricing{
 			ModelName:              model,
@@ -288,8 +304,10 @@ func updatePricing() {
 			modelRatio, _, _ := ratio_setting.GetModelRatio(model)
 			pricing.ModelRatio = modelRatio
 			pricing.CompletionRatio = ratio_setting.GetCompletionRatio(model)
+			pricing.ContextTiers = ratio_setting.GetModelRatioTiers(model)
 			pricing.QuotaType = 0
 		}
+		pricing.PricingRules = getPricingRuleViews(model, now)
 		pricingMap = append(pricingMap, pricing)
 	}
 
@@ -310,3 +328,23 @@ func updatePricing() {
 func GetSupportedEndpointMap() map[string]common.EndpointInfo {
 	return supportedEndpointMap
 }
+
+func getPricingRuleViews(modelName string, now time.Time) []PricingRuleView {
+	var views []PricingRuleView
+	for _, rule := range ratio_setting.GetModelPricingRules(modelName) {
+		window, ok := rule.NextWindow(now)
+		if !ok {
+			continue
+		}
+		views = append(views, PricingRuleView{
+			Id:         rule.Id,
+			Name:       rule.Name,
+			Groups:     rule.Groups,
+			Multiplier: rule.Multiplier,
+			Active:     window.Start <= now.Unix(),
+			Start:      window.Start,
+			End:        window.End,
+		})
+	}
+	return views
+}
@@ -23,6 +23,8 @@ type Redemption struct {
 	UsedUserId   int            `json:"used_user_id"`
 	DeletedAt    gorm.DeletedAt `gorm:"index"`
 	ExpiredTime  int64          `json:"expired_time" gorm:"bigint"` // 过期时间，0 表示不过期
+	// 为组织兑换时的组织 ID
+	OrganizationId int `json:"organization_id" gorm:"default:0"`
 }
 
 func GetAllRedemptions(startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
@@ -112,6 +114,15 @@ func GetRedemptionById(id int) (*Redemption, error) {
 }
 
 func Redeem(key string, userId int) (quota int, err error) {
+	return redeem(key, userId, 0)
+}
+
+// RedeemForOrganization 使用兑换码为组织充值
+func RedeemForOrganization(key string, userId int, organizationId int) (quota int, err error) {
+	return redeem(key, userId, organizationId)
+}
+
+func redeem(key string, userId int, organizationId int) (quota int, err error) {
 	if key == "" {
 		return 0, errors.New("未提供兑换码")
 	}
@@ -136,19 +147,28 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if redemption.ExpiredTime != 0 && redemption.ExpiredTime < common.GetTimestamp() {
 			return errors.New("该兑换码已过期")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		if organizationId != 0 {
+			err = tx.Model(&Organization{}).Where("id = ?", organizationId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		} else {
+			err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		}
 		if err != nil {
 			return err
 		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
 		redemption.UsedUserId = userId
+		redemption.OrganizationId = organizationId
 		err = tx.Save(redemption).Error
 		return err
 	})
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
+	if organizationId != 0 {
+		RecordOrganizationLog(organizationId, userId, LogTypeTopup, fmt.Sprintf("通过兑换码为组织 #%d 充值 %s，兑换码ID %d", organizationId, logger.LogQuota(redemption.Quota), redemption.Id))
+		return redemption.Quota, nil
+	}
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s，兑换码ID %d", logger.LogQuota(redemption.Quota), redemption.Id))
 	return redemption.Quota, nil
 }
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+)
+
+// StoredResponse 网关保存的 /v1/responses 响应，输入与完整响应保存在 FileStorage 中
+type StoredResponse struct {
+	Id                 int    `json:"id"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(128);uniqueIndex"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(128);default:''"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	TokenId            int    `json:"token_id" gorm:"index"`
+	OrganizationId     int    `json:"organization_id" gorm:"default:0"`
+	ModelName          string `json:"model_name" gorm:"default:''"`
+	Status             string `json:"status" gorm:"type:varchar(20)"`
+	StoragePath        string `json:"-" gorm:"type:varchar(255)"`
+	Bytes              int64  `json:"bytes"`
+	Quota              int    `json:"quota" gorm:"default:0"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt          int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (response *StoredResponse) Insert() error {
+	if response.CreatedAt == 0 {
+		response.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(response).Error
+}
+
+func (response *StoredResponse) Delete() error {
+	return DB.Delete(response).Error
+}
+
+// GetStoredResponse 获取令牌保存的响应，已过期的视为不存在
+func GetStoredResponse(tokenId int, responseId string) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, errors.New("response id 为空！")
+	}
+	var response StoredResponse
+	err := DB.Where("token_id = ? and response_id = ? and expires_at > ?", tokenId, responseId, common.GetTimestamp()).First(&response).Error
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetExcessStoredResponses 获取令牌保存的响应中超出 keep 条的最早记录，每次最多 limit 条
+func GetExcessStoredResponses(tokenId int, keep int, limit int) (responses []*StoredResponse, err error) {
+	err = DB.Where("token_id = ?", tokenId).Order("id desc").Offset(keep).Limit(limit).Find(&responses).Error
+	return responses, err
+}
+
+// GetExpiredStoredResponses 获取已过期的响应
+func GetExpiredStoredResponses(now int64, limit int) (responses []*StoredResponse, err error) {
+	err = DB.Where("expires_at <= ?", now).Order("id asc").Limit(limit).Find(&responses).Error
+	return responses, err
+}
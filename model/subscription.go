@@ -0,0 +1,527 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PlanStatusEnabled  = 1
+	PlanStatusDisabled = 2
+)
+
+const (
+	SubscriptionStatusPending  = "pending"
+	SubscriptionStatusActive   = "active"
+	SubscriptionStatusExpired  = "expired"
+	SubscriptionStatusCanceled = "canceled"
+)
+
+var (
+	// ErrDuplicateSubscription 用户已有生效中的订阅，重复支付的订阅被取消，需要退款
+	ErrDuplicateSubscription = errors.New("用户已有生效中的订阅")
+	// ErrSubscriptionInvoiceApplied 续费账单已处理过，Stripe 重复推送时返回
+	ErrSubscriptionInvoiceApplied = errors.New("续费账单已处理")
+)
+
+// Plan 订阅套餐，按月计费，每月发放 Quota 额度
+type Plan struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	Description string `json:"description" gorm:"type:text"`
+	// 展示价格（每月），实际扣款金额以 Stripe 价格为准
+	Price float64 `json:"price"`
+	Quota int     `json:"quota"`
+	// 未用完的额度是否结转到下个月，不结转时每次发放前回收上月剩余的套餐额度
+	Rollover bool `json:"rollover"`
+	// 订阅期间用户升级到的分组，留空表示不调整分组
+	Group string `json:"group" gorm:"type:varchar(64);default:''"`
+	// Stripe 按月循环扣款的价格 ID
+	StripePriceId string `json:"stripe_price_id" gorm:"type:varchar(255)"`
+	Status        int    `json:"status" gorm:"default:1"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+}
+
+// Subscription 用户订阅，每个用户同时只有一个生效中的订阅
+type Subscription struct {
+	Id      int    `json:"id"`
+	UserId  int    `json:"user_id" gorm:"index"`
+	PlanId  int    `json:"plan_id" gorm:"index"`
+	Status  string `json:"status" gorm:"type:varchar(16);index"`
+	TradeNo string `json:"trade_no" gorm:"type:varchar(255);index"`
+	// Stripe 订阅 ID，管理员发放的订阅为空
+	StripeSubscriptionId string `json:"stripe_subscription_id" gorm:"type:varchar(255);index"`
+	// 订阅生效前的用户分组，到期后恢复
+	PreviousGroup    string `json:"previous_group" gorm:"type:varchar(64);default:''"`
+	StartTime        int64  `json:"start_time" gorm:"bigint"`
+	CurrentPeriodEnd int64  `json:"current_period_end" gorm:"bigint;index"`
+	// 是否自动续费，Stripe 订阅取消后为 false，到期后不再续期
+	AutoRenew bool `json:"auto_renew"`
+	// 最近一次发放的额度、发放时间及发放时用户的已用额度，用于不结转时回收剩余额度
+	GrantedQuota   int   `json:"granted_quota"`
+	GrantUsedQuota int   `json:"-"`
+	LastGrantTime  int64 `json:"last_grant_time" gorm:"bigint"`
+	// 最近一次处理的 Stripe 续费账单 ID，用于忽略重复推送的 invoice.paid
+	LastInvoiceId string `json:"-" gorm:"type:varchar(255);default:''"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// SubscriptionView 用户当前订阅状态，用于 GetSelf
+type SubscriptionView struct {
+	Id               int    `json:"id"`
+	PlanId           int    `json:"plan_id"`
+	PlanName         string `json:"plan_name"`
+	Status           string `json:"status"`
+	Group            string `json:"group"`
+	Rollover         bool   `json:"rollover"`
+	MonthlyQuota     int    `json:"monthly_quota"`
+	GrantedQuota     int    `json:"granted_quota"`
+	AutoRenew        bool   `json:"auto_renew"`
+	StartTime        int64  `json:"start_time"`
+	CurrentPeriodEnd int64  `json:"current_period_end"`
+}
+
+func addMonths(timestamp int64, months int) int64 {
+	return time.Unix(timestamp, 0).AddDate(0, months, 0).Unix()
+}
+
+// unusedGrantQuota 上次发放的套餐额度中尚未使用的部分，消费优先计入套餐额度，且不超过用户当前余额
+func unusedGrantQuota(granted int, usedAtGrant int, usedNow int, userQuota int) int {
+	unused := granted - (usedNow - usedAtGrant)
+	if unused > userQuota {
+		unused = userQuota
+	}
+	if unused < 0 {
+		return 0
+	}
+	return unused
+}
+
+func (plan *Plan) Insert() error {
+	plan.CreatedTime = common.GetTimestamp()
+	return DB.Create(plan).Error
+}
+
+func (plan *Plan) Update() error {
+	return DB.Model(plan).Select("name", "description", "price", "quota", "rollover", "group", "stripe_price_id", "status").Updates(plan).Error
+}
+
+func GetPlanById(id int) (*Plan, error) {
+	var plan Plan
+	if err := DB.First(&plan, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetPlans 获取套餐列表，enabledOnly 时只返回启用的套餐
+func GetPlans(enabledOnly bool) (plans []*Plan, err error) {
+	tx := DB.Model(&Plan{})
+	if enabledOnly {
+		tx = tx.Where("status = ?", PlanStatusEnabled)
+	}
+	err = tx.Order("price asc, id asc").Find(&plans).Error
+	return plans, err
+}
+
+// DeletePlan 删除没有生效订阅的套餐
+func DeletePlan(id int) error {
+	var count int64
+	if err := DB.Model(&Subscription{}).Where("plan_id = ? and status = ?", id, SubscriptionStatusActive).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("套餐仍有生效中的订阅，请先禁用套餐")
+	}
+	return DB.Delete(&Plan{}, id).Error
+}
+
+func GetActiveSubscription(userId int) (*Subscription, error) {
+	var subscription Subscription
+	err := DB.Where("user_id = ? and status = ?", userId, SubscriptionStatusActive).Order("id desc").First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetUserSubscriptionView 获取用户生效中的订阅，没有订阅时返回 nil
+func GetUserSubscriptionView(userId int) *SubscriptionView {
+	subscription, err := GetActiveSubscription(userId)
+	if err != nil {
+		return nil
+	}
+	view := &SubscriptionView{
+		Id:               subscription.Id,
+		PlanId:           subscription.PlanId,
+		Status:           subscription.Status,
+		GrantedQuota:     subscription.GrantedQuota,
+		AutoRenew:        subscription.AutoRenew,
+		StartTime:        subscription.StartTime,
+		CurrentPeriodEnd: subscription.CurrentPeriodEnd,
+	}
+	if plan, err := GetPlanById(subscription.PlanId); err == nil {
+		view.PlanName = plan.Name
+		view.Group = plan.Group
+		view.Rollover = plan.Rollover
+		view.MonthlyQuota = plan.Quota
+	}
+	return view
+}
+
+// GetSubscriptions 获取订阅记录，userId 为 0 时返回全部
+func GetSubscriptions(userId int, startIdx int, num int) (subscriptions []*Subscription, total int64, err error) {
+	tx := DB.Model(&Subscription{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&subscriptions).Error
+	return subscriptions, total, err
+}
+
+// CreatePendingSubscription 创建待支付的订阅，用户已有生效中的订阅时返回错误。
+// 这里只是提前拦截，并发支付的多个订阅在生效时再次检查
+func CreatePendingSubscription(userId int, planId int, tradeNo string) (*Subscription, error) {
+	if _, err := GetActiveSubscription(userId); err == nil {
+		return nil, errors.New("已有生效中的订阅")
+	}
+	now := common.GetTimestamp()
+	subscription := &Subscription{
+		UserId:      userId,
+		PlanId:      planId,
+		Status:      SubscriptionStatusPending,
+		TradeNo:     tradeNo,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	return subscription, DB.Create(subscription).Error
+}
+
+// CancelPendingSubscription 支付会话过期时取消待支付的订阅
+func CancelPendingSubscription(tradeNo string) error {
+	return DB.Model(&Subscription{}).Where("trade_no = ? and status = ?", tradeNo, SubscriptionStatusPending).
+		Updates(map[string]interface{}{"status": SubscriptionStatusCanceled, "updated_time": common.GetTimestamp()}).Error
+}
+
+// grantSubscriptionQuota 发放一个月的套餐额度，不结转时先回收上次发放的剩余额度
+func grantSubscriptionQuota(tx *gorm.DB, subscription *Subscription, plan *Plan, user *User) (revoked int, err error) {
+	if !plan.Rollover && subscription.GrantedQuota > 0 {
+		revoked = unusedGrantQuota(subscription.GrantedQuota, subscription.GrantUsedQuota, user.UsedQuota, user.Quota)
+	}
+	delta := plan.Quota - revoked
+	if delta != 0 {
+		if err = tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", gorm.Expr("quota + ?", delta)).Error; err != nil {
+			return 0, err
+		}
+	}
+	user.Quota += delta
+	subscription.GrantedQuota = plan.Quota
+	subscription.GrantUsedQuota = user.UsedQuota
+	subscription.LastGrantTime = common.GetTimestamp()
+	return revoked, nil
+}
+
+func recordSubscriptionGrantLog(userId int, plan *Plan, revoked int) {
+	content := fmt.Sprintf("订阅套餐 %s 发放额度 %s", plan.Name, logger.LogQuota(plan.Quota))
+	if revoked > 0 {
+		content += fmt.Sprintf("，回收上月剩余额度 %s", logger.LogQuota(revoked))
+	}
+	RecordLog(userId, LogTypeTopup, content)
+}
+
+// lockSubscription 在事务中加锁读取订阅、套餐与用户
+func lockSubscription(tx *gorm.DB, query string, args ...interface{}) (*Subscription, *Plan, *User, error) {
+	subscription := &Subscription{}
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where(query, args...).Order("id desc").First(subscription).Error; err != nil {
+		return nil, nil, nil, errors.New("订阅不存在")
+	}
+	plan := &Plan{}
+	if err := tx.First(plan, "id = ?", subscription.PlanId).Error; err != nil {
+		return nil, nil, nil, errors.New("套餐不存在")
+	}
+	user := &User{}
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(user, "id = ?", subscription.UserId).Error; err != nil {
+		return nil, nil, nil, errors.New("用户不存在")
+	}
+	return subscription, plan, user, nil
+}
+
+// hasOtherActiveSubscription 在已锁定用户的事务中检查用户是否有其他生效中的订阅
+func hasOtherActiveSubscription(tx *gorm.DB, userId int, subscriptionId int) (bool, error) {
+	var count int64
+	err := tx.Model(&Subscription{}).Where("user_id = ? and status = ? and id <> ?", userId, SubscriptionStatusActive, subscriptionId).Count(&count).Error
+	return count > 0, err
+}
+
+// activateSubscription 生效订阅：升级分组并发放首月额度
+func activateSubscription(tx *gorm.DB, subscription *Subscription, plan *Plan, user *User, months int) (int, error) {
+	now := common.GetTimestamp()
+	subscription.Status = SubscriptionStatusActive
+	subscription.StartTime = now
+	subscription.CurrentPeriodEnd = addMonths(now, months)
+	subscription.PreviousGroup = user.Group
+	subscription.GrantedQuota = 0
+	if plan.Group != "" && plan.Group != user.Group {
+		if err := tx.Model(&User{}).Where("id = ?", user.Id).Update("group", plan.Group).Error; err != nil {
+			return 0, err
+		}
+	}
+	revoked, err := grantSubscriptionQuota(tx, subscription, plan, user)
+	if err != nil {
+		return 0, err
+	}
+	subscription.UpdatedTime = now
+	return revoked, tx.Save(subscription).Error
+}
+
+// ActivateStripeSubscription Stripe 订阅支付完成后生效订阅，
+// 用户已有其他生效中的订阅时取消本订阅并返回 ErrDuplicateSubscription，由调用方退款
+func ActivateStripeSubscription(tradeNo string, customerId string, stripeSubscriptionId string) error {
+	var plan *Plan
+	var userId int
+	duplicate := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		subscription, p, user, err := lockSubscription(tx, "trade_no = ?", tradeNo)
+		if err != nil {
+			return err
+		}
+		if subscription.Status != SubscriptionStatusPending {
+			return errors.New("订阅状态错误")
+		}
+		plan, userId = p, user.Id
+		subscription.StripeSubscriptionId = stripeSubscriptionId
+		if duplicate, err = hasOtherActiveSubscription(tx, user.Id, subscription.Id); err != nil {
+			return err
+		}
+		if duplicate {
+			subscription.Status = SubscriptionStatusCanceled
+			subscription.UpdatedTime = common.GetTimestamp()
+			return tx.Save(subscription).Error
+		}
+		subscription.AutoRenew = true
+		if _, err = activateSubscription(tx, subscription, plan, user, 1); err != nil {
+			return err
+		}
+		if customerId != "" {
+			return tx.Model(&User{}).Where("id = ?", user.Id).Update("stripe_customer", customerId).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		RecordLog(userId, LogTypeSystem, fmt.Sprintf("订阅套餐 %s 重复支付，已取消该订阅", plan.Name))
+		return ErrDuplicateSubscription
+	}
+	_ = invalidateUserCache(userId)
+	recordSubscriptionGrantLog(userId, plan, 0)
+	return nil
+}
+
+// GrantSubscription 管理员为用户发放订阅，有效期 months 个月，每月由续期任务发放额度
+func GrantSubscription(userId int, planId int, months int) (*Subscription, error) {
+	if months <= 0 {
+		return nil, errors.New("订阅月数必须大于 0")
+	}
+	now := common.GetTimestamp()
+	subscription := &Subscription{
+		UserId:      userId,
+		PlanId:      planId,
+		Status:      SubscriptionStatusPending,
+		TradeNo:     fmt.Sprintf("admin-%d-%d", userId, time.Now().UnixNano()),
+		CreatedTime: now,
+	}
+	var plan *Plan
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		p := &Plan{}
+		if err := tx.First(p, "id = ?", planId).Error; err != nil {
+			return errors.New("套餐不存在")
+		}
+		user := &User{}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(user, "id = ?", userId).Error; err != nil {
+			return errors.New("用户不存在")
+		}
+		if duplicate, err := hasOtherActiveSubscription(tx, userId, 0); err != nil {
+			return err
+		} else if duplicate {
+			return ErrDuplicateSubscription
+		}
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+		plan = p
+		_, err := activateSubscription(tx, subscription, plan, user, months)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = invalidateUserCache(userId)
+	recordSubscriptionGrantLog(userId, plan, 0)
+	return subscription, nil
+}
+
+// RenewStripeSubscription Stripe 续费成功后延长订阅一个月并发放额度，已到期的订阅会重新生效，
+// 同一账单重复推送时返回 ErrSubscriptionInvoiceApplied
+func RenewStripeSubscription(stripeSubscriptionId string, invoiceId string) error {
+	var plan *Plan
+	var userId, revoked int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		subscription, p, user, err := lockSubscription(tx, "stripe_subscription_id = ?", stripeSubscriptionId)
+		if err != nil {
+			return err
+		}
+		if invoiceId != "" && subscription.LastInvoiceId == invoiceId {
+			return ErrSubscriptionInvoiceApplied
+		}
+		subscription.LastInvoiceId = invoiceId
+		plan, userId = p, user.Id
+		switch subscription.Status {
+		case SubscriptionStatusActive:
+			now := common.GetTimestamp()
+			// 续费通知可能早于或晚于当前周期结束
+			base := subscription.CurrentPeriodEnd
+			if base < now {
+				base = now
+			}
+			subscription.CurrentPeriodEnd = addMonths(base, 1)
+			if revoked, err = grantSubscriptionQuota(tx, subscription, plan, user); err != nil {
+				return err
+			}
+			subscription.UpdatedTime = now
+			return tx.Save(subscription).Error
+		case SubscriptionStatusExpired:
+			if duplicate, err := hasOtherActiveSubscription(tx, user.Id, subscription.Id); err != nil {
+				return err
+			} else if duplicate {
+				return errors.New("用户已有其他生效中的订阅")
+			}
+			revoked, err = activateSubscription(tx, subscription, plan, user, 1)
+			return err
+		default:
+			return fmt.Errorf("订阅状态错误：%s", subscription.Status)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	_ = invalidateUserCache(userId)
+	recordSubscriptionGrantLog(userId, plan, revoked)
+	return nil
+}
+
+// StopStripeSubscriptionRenewal Stripe 订阅取消后不再续费，订阅在当前周期结束后由到期任务处理
+func StopStripeSubscriptionRenewal(stripeSubscriptionId string) error {
+	return DB.Model(&Subscription{}).Where("stripe_subscription_id = ?", stripeSubscriptionId).
+		Updates(map[string]interface{}{"auto_renew": false, "updated_time": common.GetTimestamp()}).Error
+}
+
+// GetSubscriptionsToGrant 获取需要按月发放额度的非 Stripe 订阅（距上次发放满一个月且未到期）
+func GetSubscriptionsToGrant(now int64, limit int) (subscriptions []*Subscription, err error) {
+	err = DB.Where("status = ? and stripe_subscription_id = '' and current_period_end > ?", SubscriptionStatusActive, now).
+		Order("last_grant_time asc").Limit(limit).Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	due := subscriptions[:0]
+	for _, subscription := range subscriptions {
+		next := addMonths(subscription.LastGrantTime, 1)
+		if next <= now && next < subscription.CurrentPeriodEnd {
+			due = append(due, subscription)
+		}
+	}
+	return due, nil
+}
+
+// GrantMonthlySubscriptionQuota 为多月订阅发放当月额度
+func GrantMonthlySubscriptionQuota(id int) error {
+	var plan *Plan
+	var userId, revoked int
+	granted := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		subscription, p, user, err := lockSubscription(tx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		now := common.GetTimestamp()
+		next := addMonths(subscription.LastGrantTime, 1)
+		if subscription.Status != SubscriptionStatusActive || next > now || next >= subscription.CurrentPeriodEnd {
+			return nil
+		}
+		plan, userId = p, user.Id
+		if revoked, err = grantSubscriptionQuota(tx, subscription, plan, user); err != nil {
+			return err
+		}
+		granted = true
+		subscription.UpdatedTime = now
+		return tx.Save(subscription).Error
+	})
+	if err != nil || !granted {
+		return err
+	}
+	_ = invalidateUserCache(userId)
+	recordSubscriptionGrantLog(userId, plan, revoked)
+	return nil
+}
+
+// GetExpiredSubscriptions 获取当前周期已于 before 之前结束的生效订阅
+func GetExpiredSubscriptions(before int64, limit int) (subscriptions []*Subscription, err error) {
+	err = DB.Where("status = ? and current_period_end <= ?", SubscriptionStatusActive, before).
+		Order("current_period_end asc").Limit(limit).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// ExpireSubscription 订阅到期：不结转时回收剩余套餐额度，用户仍在套餐分组时恢复原分组
+func ExpireSubscription(id int) error {
+	var plan *Plan
+	var userId, revoked int
+	expired := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		subscription, p, user, err := lockSubscription(tx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		if subscription.Status != SubscriptionStatusActive {
+			return nil
+		}
+		plan, userId = p, user.Id
+		if !plan.Rollover && subscription.GrantedQuota > 0 {
+			revoked = unusedGrantQuota(subscription.GrantedQuota, subscription.GrantUsedQuota, user.UsedQuota, user.Quota)
+			if revoked > 0 {
+				if err = tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", gorm.Expr("quota - ?", revoked)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if plan.Group != "" && user.Group == plan.Group && subscription.PreviousGroup != "" {
+			if err = tx.Model(&User{}).Where("id = ?", user.Id).Update("group", subscription.PreviousGroup).Error; err != nil {
+				return err
+			}
+		}
+		expired = true
+		subscription.Status = SubscriptionStatusExpired
+		subscription.AutoRenew = false
+		subscription.UpdatedTime = common.GetTimestamp()
+		return tx.Save(subscription).Error
+	})
+	if err != nil || !expired {
+		return err
+	}
+	_ = invalidateUserCache(userId)
+	content := fmt.Sprintf("订阅套餐 %s 已到期", plan.Name)
+	if revoked > 0 {
+		content += fmt.Sprintf("，回收剩余额度 %s", logger.LogQuota(revoked))
+	}
+	RecordLog(userId, LogTypeSystem, content)
+	return nil
+}
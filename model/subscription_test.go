@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"testing"
+	"time"
+)
+
+func TestUnusedGrantQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		granted     int
+		usedAtGrant int
+		usedNow     int
+		userQuota   int
+		want        int
+	}{
+		{name: "untouched", granted: 1000, usedAtGrant: 500, usedNow: 500, userQuota: 3000, want: 1000},
+		{name: "partially used", granted: 1000, usedAtGrant: 500, usedNow: 800, userQuota: 3000, want: 700},
+		{name: "fully used", granted: 1000, usedAtGrant: 500, usedNow: 1600, userQuota: 3000, want: 0},
+		{name: "capped by balance", granted: 1000, usedAtGrant: 0, usedNow: 100, userQuota: 400, want: 400},
+		{name: "negative balance", granted: 1000, usedAtGrant: 0, usedNow: 0, userQuota: -10, want: 0},
+	}
+	for _, tt := range tests {
+		if got := unusedGrantQuota(tt.granted, tt.usedAtGrant, tt.usedNow, tt.userQuota); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	start := time.Date(2026, 1, 15, 8, 0, 0, 0, time.Local)
+	if got := addMonths(start.Unix(), 1); got != time.Date(2026, 2, 15, 8, 0, 0, 0, time.Local).Unix() {
+		t.Errorf("unexpected next month %v", time.Unix(got, 0))
+	}
+	if got := addMonths(start.Unix(), 12); got != time.Date(2027, 1, 15, 8, 0, 0, 0, time.Local).Unix() {
+		t.Errorf("unexpected next year %v", time.Unix(got, 0))
+	}
+}
+
+func newTestSubscriptionUser(t *testing.T, quota int) (*User, *Plan) {
+	t.Helper()
+	user := &User{Username: common.GetRandomString(10), AffCode: common.GetRandomString(8), Group: "default", Quota: quota, Status: common.UserStatusEnabled}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	plan := &Plan{Name: t.Name(), Quota: 1000, Group: "vip", Status: PlanStatusEnabled}
+	if err := DB.Create(plan).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, plan
+}
+
+func getTestUserQuota(t *testing.T, userId int) int {
+	t.Helper()
+	user := &User{}
+	if err := DB.First(user, "id = ?", userId).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user.Quota
+}
+
+// TestRenewStripeSubscriptionInvoice Stripe 重复推送同一续费账单时只续费一次
+func TestRenewStripeSubscriptionInvoice(t *testing.T) {
+	user, plan := newTestSubscriptionUser(t, 0)
+	if _, err := CreatePendingSubscription(user.Id, plan.Id, "trade-renew"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ActivateStripeSubscription("trade-renew", "cus_1", "sub_renew"); err != nil {
+		t.Fatal(err)
+	}
+	subscription, err := GetActiveSubscription(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodEnd := subscription.CurrentPeriodEnd
+
+	if err := RenewStripeSubscription("sub_renew", "in_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenewStripeSubscription("sub_renew", "in_1"); !errors.Is(err, ErrSubscriptionInvoiceApplied) {
+		t.Fatalf("redelivered invoice: got %v, want ErrSubscriptionInvoiceApplied", err)
+	}
+	subscription, _ = GetActiveSubscription(user.Id)
+	if want := addMonths(periodEnd, 1); subscription.CurrentPeriodEnd != want {
+		t.Errorf("period end %d, want %d", subscription.CurrentPeriodEnd, want)
+	}
+	// 不结转时续费回收上月剩余额度，再发放一个月
+	if quota := getTestUserQuota(t, user.Id); quota != plan.Quota {
+		t.Errorf("quota %d, want %d", quota, plan.Quota)
+	}
+
+	if err := RenewStripeSubscription("sub_renew", "in_2"); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ = GetActiveSubscription(user.Id)
+	if want := addMonths(periodEnd, 2); subscription.CurrentPeriodEnd != want {
+		t.Errorf("period end %d after next invoice, want %d", subscription.CurrentPeriodEnd, want)
+	}
+}
+
+// TestActivateDuplicateSubscription 并发支付的第二个订阅生效时被取消，只发放一次额度
+func TestActivateDuplicateSubscription(t *testing.T) {
+	user, plan := newTestSubscriptionUser(t, 0)
+	for _, tradeNo := range []string{"trade-dup-1", "trade-dup-2"} {
+		if _, err := CreatePendingSubscription(user.Id, plan.Id, tradeNo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ActivateStripeSubscription("trade-dup-1", "cus_2", "sub_dup_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ActivateStripeSubscription("trade-dup-2", "cus_2", "sub_dup_2"); !errors.Is(err, ErrDuplicateSubscription) {
+		t.Fatalf("second activation: got %v, want ErrDuplicateSubscription", err)
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != plan.Quota {
+		t.Errorf("quota %d, want %d", quota, plan.Quota)
+	}
+	duplicate := &Subscription{}
+	if err := DB.First(duplicate, "trade_no = ?", "trade-dup-2").Error; err != nil {
+		t.Fatal(err)
+	}
+	if duplicate.Status != SubscriptionStatusCanceled || duplicate.StripeSubscriptionId != "sub_dup_2" {
+		t.Errorf("duplicate subscription: status %s stripe id %q", duplicate.Status, duplicate.StripeSubscriptionId)
+	}
+	// 已取消的订阅不能再次生效
+	if err := ActivateStripeSubscription("trade-dup-2", "cus_2", "sub_dup_2"); err == nil || errors.Is(err, ErrDuplicateSubscription) {
+		t.Errorf("redelivered duplicate activation: got %v", err)
+	}
+}
+
+func TestGrantSubscriptionWithActiveSubscription(t *testing.T) {
+	user, plan := newTestSubscriptionUser(t, 0)
+	if _, err := GrantSubscription(user.Id, plan.Id, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GrantSubscription(user.Id, plan.Id, 1); !errors.Is(err, ErrDuplicateSubscription) {
+		t.Fatalf("second grant: got %v, want ErrDuplicateSubscription", err)
+	}
+	var count int64
+	DB.Model(&Subscription{}).Where("user_id = ?", user.Id).Count(&count)
+	if count != 1 {
+		t.Errorf("created %d subscriptions, want 1", count)
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != plan.Quota {
+		t.Errorf("quota %d, want %d", quota, plan.Quota)
+	}
+}
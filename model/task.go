@@ -37,6 +37,12 @@ type Task struct {
 	FinishTime int64                 `json:"finish_time" gorm:"index"`
 	Progress   string                `json:"progress" gorm:"type:varchar(20);index"`
 	Properties Properties            `json:"properties" gorm:"type:json"`
+	// 组织令牌提交的任务，失败时返还到组织额度
+	OrganizationId int `json:"organization_id" gorm:"default:0"`
+	// 任务状态变更时的回调地址，为空不回调
+	CallbackUrl string `json:"callback_url" gorm:"type:varchar(512);default:''"`
+	// 提交任务的令牌，任务失败退款时一并返还令牌额度
+	TokenId int `json:"token_id" gorm:"default:0"`
 
 	Data json.RawMessage `json:"data" gorm:"type:json"`
 }
@@ -86,6 +92,8 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.RelayInfo)
 		ChannelId:  relayInfo.ChannelId,
 		Platform:   platform,
 	}
+	t.OrganizationId = relayInfo.OrganizationId
+	t.TokenId = relayInfo.TokenId
 	return t
 }
 
@@ -228,6 +236,49 @@ func TaskUpdateProgress(id int64, progress string) error {
 	return DB.Model(&Task{}).Where("id = ?", id).Update("progress", progress).Error
 }
 
+func GetTaskById(id int64) (*Task, error) {
+	var task Task
+	err := DB.First(&task, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetPendingAsyncTasks 获取等待执行的 /v1/tasks 异步任务
+func GetPendingAsyncTasks(limit int) (tasks []*Task, err error) {
+	err = DB.Where("platform = ? and status = ?", constant.TaskPlatformAsync, TaskStatusSubmitted).
+		Order("id").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// GetInProgressAsyncTasks 获取执行中的 /v1/tasks 任务，用于服务重启后处理被中断的任务
+func GetInProgressAsyncTasks(limit int) (tasks []*Task, err error) {
+	err = DB.Where("platform = ? and status = ?", constant.TaskPlatformAsync, TaskStatusInProgress).
+		Order("id").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// TaskUpdateUnlessStatus 仅当任务不处于给定状态时更新，返回是否更新成功，
+// 用于超时回收、管理员操作与轮询并发时避免重复退款
+func TaskUpdateUnlessStatus(id int64, statuses []string, params map[string]any) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? and status not in (?)", id, statuses).Updates(params)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// TaskUpdateIfFailed 仅当任务以给定原因失败时更新，返回是否更新成功，
+// 用于已计费的结果覆盖执行期间的取消
+func TaskUpdateIfFailed(id int64, reason string, params map[string]any) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? and status = ? and fail_reason = ?", id, TaskStatusFailure, reason).Updates(params)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (Task *Task) Insert() error {
 	var err error
 	err = DB.Create(Task).Error
@@ -240,6 +291,22 @@ func (Task *Task) Update() error {
 	return err
 }
 
+// UpdateUnlessFinished 保存轮询得到的任务状态，任务已被超时回收或管理员结束（成功/失败）时不覆盖，返回是否更新成功
+func (Task *Task) UpdateUnlessFinished() (bool, error) {
+	params := map[string]any{
+		"status":      Task.Status,
+		"progress":    Task.Progress,
+		"fail_reason": Task.FailReason,
+		"submit_time": Task.SubmitTime,
+		"start_time":  Task.StartTime,
+		"finish_time": Task.FinishTime,
+	}
+	if len(Task.Data) > 0 {
+		params["data"] = Task.Data
+	}
+	return TaskUpdateUnlessStatus(Task.ID, []string{TaskStatusSuccess, TaskStatusFailure}, params)
+}
+
 func TaskBulkUpdate(TaskIds []string, params map[string]any) error {
 	if len(TaskIds) == 0 {
 		return nil
@@ -363,3 +430,36 @@ func TaskCountAllUserTask(userId int, queryParams SyncTaskQueryParams) int64 {
 	_ = query.Count(&total).Error
 	return total
 }
+
+// TaskQueueSize 未完成任务数量，用于监控指标
+type TaskQueueSize struct {
+	Platform string `json:"platform"`
+	Status   string `json:"status"`
+	Count    int64  `json:"count"`
+}
+
+// GetTaskQueueSizes 按平台与状态统计未完成的异步任务，包括 Midjourney 任务与批处理任务
+func GetTaskQueueSizes() ([]*TaskQueueSize, error) {
+	var sizes []*TaskQueueSize
+	err := DB.Model(&Task{}).Select("platform, status, count(*) as count").
+		Where("status not in ?", []TaskStatus{TaskStatusSuccess, TaskStatusFailure}).
+		Group("platform, status").Find(&sizes).Error
+	if err != nil {
+		return nil, err
+	}
+	var mjSizes []*TaskQueueSize
+	err = DB.Model(&Midjourney{}).Select("'mj' as platform, status, count(*) as count").
+		Where("progress != ?", "100%").Group("status").Find(&mjSizes).Error
+	if err != nil {
+		return nil, err
+	}
+	var batchSizes []*TaskQueueSize
+	err = DB.Model(&Batch{}).Select("'batch' as platform, status, count(*) as count").
+		Where("status in ?", []string{BatchStatusValidating, BatchStatusInProgress, BatchStatusFinalizing, BatchStatusCancelling}).
+		Group("status").Find(&batchSizes).Error
+	if err != nil {
+		return nil, err
+	}
+	sizes = append(sizes, mjSizes...)
+	return append(sizes, batchSizes...), nil
+}
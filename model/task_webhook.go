@@ -0,0 +1,74 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+const (
+	TaskWebhookStatusPending = "pending"
+	TaskWebhookStatusSuccess = "success"
+	TaskWebhookStatusFailed  = "failed"
+)
+
+// TaskWebhookDelivery 异步任务状态变更回调的投递记录，失败时按退避时间重试
+type TaskWebhookDelivery struct {
+	Id            int    `json:"id"`
+	TaskId        int64  `json:"task_id" gorm:"index"` // Task.ID
+	UserId        int    `json:"user_id" gorm:"index"`
+	Url           string `json:"url" gorm:"type:varchar(512)"`
+	Event         string `json:"event" gorm:"type:varchar(20)"` // 触发回调的任务状态
+	Payload       string `json:"payload" gorm:"type:text"`
+	Status        string `json:"status" gorm:"type:varchar(20);index"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `json:"next_attempt_at" gorm:"bigint;index"`
+	ResponseCode  int    `json:"response_code"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index"`
+	DeliveredAt   int64  `json:"delivered_at" gorm:"bigint"`
+}
+
+func (delivery *TaskWebhookDelivery) Insert() error {
+	if delivery.CreatedAt == 0 {
+		delivery.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(delivery).Error
+}
+
+func (delivery *TaskWebhookDelivery) Update() error {
+	return DB.Save(delivery).Error
+}
+
+func GetTaskWebhookDeliveryById(id int) (*TaskWebhookDelivery, error) {
+	var delivery TaskWebhookDelivery
+	err := DB.First(&delivery, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetDueTaskWebhookDeliveries 获取到达重试时间的待投递记录
+func GetDueTaskWebhookDeliveries(limit int) ([]*TaskWebhookDelivery, error) {
+	var deliveries []*TaskWebhookDelivery
+	err := DB.Where("status = ? and next_attempt_at <= ?", TaskWebhookStatusPending, common.GetTimestamp()).
+		Order("id").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetTaskWebhookDeliveries 分页查询投递记录，taskId、status 为空时不过滤
+func GetTaskWebhookDeliveries(taskId int64, status string, startIdx int, num int) ([]*TaskWebhookDelivery, int64, error) {
+	var deliveries []*TaskWebhookDelivery
+	var total int64
+	query := DB.Model(&TaskWebhookDelivery{})
+	if taskId != 0 {
+		query = query.Where("task_id = ?", taskId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
@@ -27,6 +27,22 @@ type Token struct {
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
+
+	// 是否启用响应缓存
+	ResponseCacheEnabled bool `json:"response_cache_enabled"`
+	// 是否记录请求与响应内容
+	PayloadCaptureEnabled bool `json:"payload_capture_enabled"`
+	// 所属组织，非 0 时使用组织额度
+	OrganizationId int `json:"organization_id" gorm:"default:0;index"`
+	// 按自然日、周、月统计的消费额度上限，0 表示不限制
+	DailyQuotaLimit   int `json:"daily_quota_limit" gorm:"default:0"`
+	WeeklyQuotaLimit  int `json:"weekly_quota_limit" gorm:"default:0"`
+	MonthlyQuotaLimit int `json:"monthly_quota_limit" gorm:"default:0"`
+	// 每分钟请求数与 token 数上限，0 表示不限制
+	RpmLimit int `json:"rpm_limit" gorm:"default:0"`
+	TpmLimit int `json:"tpm_limit" gorm:"default:0"`
+	// 异步任务状态变更时的默认回调地址，请求中的 callback_url 优先
+	CallbackUrl string `json:"callback_url" gorm:"type:varchar(512);default:''"`
 }
 
 func (token *Token) Clean() {
@@ -184,7 +200,8 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "response_cache_enabled", "payload_capture_enabled",
+		"daily_quota_limit", "weekly_quota_limit", "monthly_quota_limit", "rpm_limit", "tpm_limit", "callback_url").Updates(token).Error
 	return err
 }
 
@@ -265,6 +282,7 @@ func IncreaseTokenQuota(id int, key string, quota int) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
+	RecordTokenSpend(id, -quota)
 	if common.RedisEnabled {
 		gopool.Go(func() {
 			err := cacheIncrTokenQuota(key, int64(quota))
@@ -295,6 +313,7 @@ func DecreaseTokenQuota(id int, key string, quota int) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
+	RecordTokenSpend(id, quota)
 	if common.RedisEnabled {
 		gopool.Go(func() {
 			err := cacheDecrTokenQuota(key, int64(quota))
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/common/limiter"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 令牌限额计数：按自然日/周/月统计的消费额度，以及每分钟的请求数与 token 数，
+// 启用 Redis 时计数保存在 Redis 中，否则保存在当前节点内存中
+
+const (
+	TokenLimitPeriodDay   = "day"
+	TokenLimitPeriodWeek  = "week"
+	TokenLimitPeriodMonth = "month"
+)
+
+const tokenLimitMemoryCleanThreshold = 10000
+
+// TokenLimits 令牌的消费额度与速率限制，0 表示不限制
+type TokenLimits struct {
+	DailyQuota   int `json:"daily_quota"`
+	WeeklyQuota  int `json:"weekly_quota"`
+	MonthlyQuota int `json:"monthly_quota"`
+	Rpm          int `json:"rpm"`
+	// Tpm 只在请求开始前检查本分钟已结算的 token 数，请求本身的 token 数在结算后才计入，
+	// 因此单个请求可能使本分钟的用量超过上限
+	Tpm int `json:"tpm"`
+}
+
+func (limits TokenLimits) IsEmpty() bool {
+	return limits.DailyQuota <= 0 && limits.WeeklyQuota <= 0 && limits.MonthlyQuota <= 0 && limits.Rpm <= 0 && limits.Tpm <= 0
+}
+
+// PeriodQuota 获取指定周期的消费额度上限
+func (limits TokenLimits) PeriodQuota(period string) int {
+	switch period {
+	case TokenLimitPeriodDay:
+		return limits.DailyQuota
+	case TokenLimitPeriodWeek:
+		return limits.WeeklyQuota
+	case TokenLimitPeriodMonth:
+		return limits.MonthlyQuota
+	}
+	return 0
+}
+
+func (token *Token) GetLimits() TokenLimits {
+	return TokenLimits{
+		DailyQuota:   token.DailyQuotaLimit,
+		WeeklyQuota:  token.WeeklyQuotaLimit,
+		MonthlyQuota: token.MonthlyQuotaLimit,
+		Rpm:          token.RpmLimit,
+		Tpm:          token.TpmLimit,
+	}
+}
+
+type tokenLimitCounter struct {
+	count   int64
+	resetAt time.Time
+}
+
+var (
+	tokenLimitCounters     = make(map[string]*tokenLimitCounter)
+	tokenLimitCountersLock sync.Mutex
+)
+
+// incrTokenLimitCounter 固定窗口计数，返回增加 n 后的累计值与窗口重置时间，n 为 0 时仅查询，
+// n 为负数时表示返还，累计值最低为 0
+func incrTokenLimitCounter(key string, n int64, resetAt time.Time) (int64, time.Time) {
+	now := time.Now()
+	if common.RedisEnabled {
+		window := int64(resetAt.Sub(now).Seconds()) + 1
+		ctx := context.Background()
+		count, ttl, err := limiter.New(ctx, common.RDB).IncrWindow(ctx, "tokenLimit:"+key, n, window)
+		if err != nil {
+			common.SysLog("failed to update token limit counter: " + err.Error())
+			return 0, resetAt
+		}
+		return count, now.Add(time.Duration(ttl) * time.Second)
+	}
+	tokenLimitCountersLock.Lock()
+	defer tokenLimitCountersLock.Unlock()
+	counter, ok := tokenLimitCounters[key]
+	if !ok || now.After(counter.resetAt) {
+		if n <= 0 {
+			return 0, resetAt
+		}
+		if len(tokenLimitCounters) >= tokenLimitMemoryCleanThreshold {
+			for k, c := range tokenLimitCounters {
+				if now.After(c.resetAt) {
+					delete(tokenLimitCounters, k)
+				}
+			}
+		}
+		counter = &tokenLimitCounter{resetAt: resetAt}
+		tokenLimitCounters[key] = counter
+	}
+	counter.count += n
+	if counter.count < 0 {
+		counter.count = 0
+	}
+	return counter.count, counter.resetAt
+}
+
+// getTokenLimitPeriod 返回周期标识与下次重置时间，周从周一开始
+func getTokenLimitPeriod(period string, now time.Time) (string, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case TokenLimitPeriodWeek:
+		start := today.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+		return "w" + start.Format("20060102"), start.AddDate(0, 0, 7)
+	case TokenLimitPeriodMonth:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return "m" + start.Format("200601"), start.AddDate(0, 1, 0)
+	default:
+		return "d" + today.Format("20060102"), today.AddDate(0, 0, 1)
+	}
+}
+
+func getTokenLimitMinute(now time.Time) (string, time.Time) {
+	minute := now.Truncate(time.Minute)
+	return fmt.Sprintf("%d", minute.Unix()/60), minute.Add(time.Minute)
+}
+
+// RecordTokenSpend 累计令牌在当前日、周、月的消费额度，quota 为负数时表示返还
+func RecordTokenSpend(tokenId int, quota int) {
+	if tokenId == 0 || quota == 0 {
+		return
+	}
+	record := func() {
+		now := time.Now()
+		for _, period := range []string{TokenLimitPeriodDay, TokenLimitPeriodWeek, TokenLimitPeriodMonth} {
+			id, resetAt := getTokenLimitPeriod(period, now)
+			incrTokenLimitCounter(fmt.Sprintf("spend:%d:%s", tokenId, id), int64(quota), resetAt)
+		}
+	}
+	if common.RedisEnabled {
+		gopool.Go(record)
+		return
+	}
+	record()
+}
+
+// GetTokenSpend 获取令牌在当前周期内的消费额度与周期重置时间
+func GetTokenSpend(tokenId int, period string) (int64, time.Time) {
+	id, resetAt := getTokenLimitPeriod(period, time.Now())
+	return incrTokenLimitCounter(fmt.Sprintf("spend:%d:%s", tokenId, id), 0, resetAt)
+}
+
+// IncrTokenRequestCount 记录一次请求，返回本分钟内的请求数与重置时间
+func IncrTokenRequestCount(tokenId int) (int64, time.Time) {
+	id, resetAt := getTokenLimitMinute(time.Now())
+	return incrTokenLimitCounter(fmt.Sprintf("rpm:%d:%s", tokenId, id), 1, resetAt)
+}
+
+// RecordTokenTokenUsage 在请求结算后累计令牌本分钟实际使用的 token 数
+func RecordTokenTokenUsage(tokenId int, tokens int) {
+	if tokenId == 0 || tokens <= 0 {
+		return
+	}
+	id, resetAt := getTokenLimitMinute(time.Now())
+	incrTokenLimitCounter(fmt.Sprintf("tpm:%d:%s", tokenId, id), int64(tokens), resetAt)
+}
+
+// GetTokenTokenUsage 获取令牌本分钟使用的 token 数与重置时间
+func GetTokenTokenUsage(tokenId int) (int64, time.Time) {
+	id, resetAt := getTokenLimitMinute(time.Now())
+	return incrTokenLimitCounter(fmt.Sprintf("tpm:%d:%s", tokenId, id), 0, resetAt)
+}
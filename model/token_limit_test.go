@@ -0,0 +1,94 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+	"time"
+)
+
+func TestGetTokenLimitPeriod(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	tests := []struct {
+		name      string
+		period    string
+		now       time.Time
+		wantId    string
+		wantReset time.Time
+	}{
+		{name: "day", period: TokenLimitPeriodDay, now: time.Date(2026, 3, 31, 23, 59, 59, 0, loc), wantId: "d20260331", wantReset: time.Date(2026, 4, 1, 0, 0, 0, 0, loc)},
+		{name: "unknown period falls back to day", period: "year", now: time.Date(2026, 3, 31, 8, 0, 0, 0, loc), wantId: "d20260331", wantReset: time.Date(2026, 4, 1, 0, 0, 0, 0, loc)},
+		// 2026-03-30 是周一
+		{name: "week from monday", period: TokenLimitPeriodWeek, now: time.Date(2026, 3, 30, 0, 0, 0, 0, loc), wantId: "w20260330", wantReset: time.Date(2026, 4, 6, 0, 0, 0, 0, loc)},
+		{name: "week from sunday", period: TokenLimitPeriodWeek, now: time.Date(2026, 4, 5, 23, 0, 0, 0, loc), wantId: "w20260330", wantReset: time.Date(2026, 4, 6, 0, 0, 0, 0, loc)},
+		{name: "month", period: TokenLimitPeriodMonth, now: time.Date(2026, 2, 28, 12, 0, 0, 0, loc), wantId: "m202602", wantReset: time.Date(2026, 3, 1, 0, 0, 0, 0, loc)},
+		{name: "december", period: TokenLimitPeriodMonth, now: time.Date(2026, 12, 31, 12, 0, 0, 0, loc), wantId: "m202612", wantReset: time.Date(2027, 1, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		id, resetAt := getTokenLimitPeriod(tt.period, tt.now)
+		if id != tt.wantId || !resetAt.Equal(tt.wantReset) {
+			t.Errorf("%s: got %s %v, want %s %v", tt.name, id, resetAt, tt.wantId, tt.wantReset)
+		}
+	}
+
+	id, resetAt := getTokenLimitMinute(time.Date(2026, 3, 31, 10, 20, 30, 0, time.UTC))
+	if want := time.Date(2026, 3, 31, 10, 21, 0, 0, time.UTC); !resetAt.Equal(want) || id != "29582540" {
+		t.Errorf("minute: got %s %v, want 29582540 %v", id, resetAt, want)
+	}
+}
+
+// TestIncrTokenLimitCounter 覆盖未启用 Redis 时的内存计数
+func TestIncrTokenLimitCounter(t *testing.T) {
+	common.RedisEnabled = false
+	resetAt := time.Now().Add(time.Minute)
+	expired := time.Now().Add(-time.Second)
+	tests := []struct {
+		name    string
+		key     string
+		steps   []int64
+		resetAt time.Time
+		want    []int64
+	}{
+		{name: "query empty window", key: "query", steps: []int64{0, 0}, resetAt: resetAt, want: []int64{0, 0}},
+		{name: "accumulate", key: "accumulate", steps: []int64{1, 2, 0, 3}, resetAt: resetAt, want: []int64{1, 3, 3, 6}},
+		{name: "refund clamps at zero", key: "refund", steps: []int64{5, -3, -10, 0, 4}, resetAt: resetAt, want: []int64{5, 2, 0, 0, 4}},
+		{name: "refund on empty window", key: "refund-empty", steps: []int64{-5, 0, 2}, resetAt: resetAt, want: []int64{0, 0, 2}},
+		// 窗口过期后重新计数
+		{name: "expired window", key: "expired", steps: []int64{5, 5, 0}, resetAt: expired, want: []int64{5, 5, 0}},
+	}
+	for _, tt := range tests {
+		for i, n := range tt.steps {
+			got, gotReset := incrTokenLimitCounter("test:"+tt.key, n, tt.resetAt)
+			if got != tt.want[i] {
+				t.Errorf("%s: step %d (%+d) got %d, want %d", tt.name, i, n, got, tt.want[i])
+			}
+			if !gotReset.Equal(tt.resetAt) {
+				t.Errorf("%s: step %d reset at %v, want %v", tt.name, i, gotReset, tt.resetAt)
+			}
+		}
+	}
+}
+
+func TestTokenRateUsage(t *testing.T) {
+	common.RedisEnabled = false
+	const tokenId = 1 << 30
+	RecordTokenTokenUsage(tokenId, 100)
+	RecordTokenTokenUsage(tokenId, -50)
+	RecordTokenTokenUsage(0, 100)
+	if used, _ := GetTokenTokenUsage(tokenId); used != 100 {
+		t.Errorf("tpm usage %d, want 100", used)
+	}
+
+	RecordTokenSpend(tokenId, 300)
+	RecordTokenSpend(tokenId, -500)
+	for _, period := range []string{TokenLimitPeriodDay, TokenLimitPeriodWeek, TokenLimitPeriodMonth} {
+		if spent, _ := GetTokenSpend(tokenId, period); spent != 0 {
+			t.Errorf("%s spend %d after refund, want 0", period, spent)
+		}
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if count, _ := IncrTokenRequestCount(tokenId); count != i {
+			t.Errorf("request count %d, want %d", count, i)
+		}
+	}
+}
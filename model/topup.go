@@ -18,6 +18,10 @@ type TopUp struct {
 	CreateTime   int64   `json:"create_time"`
 	CompleteTime int64   `json:"complete_time"`
 	Status       string  `json:"status"`
+	// 为组织充值时的组织 ID
+	OrganizationId int `json:"organization_id" gorm:"default:0;index"`
+	// 实际到账额度，用于生成账单
+	Quota int64 `json:"quota" gorm:"default:0"`
 }
 
 func (topUp *TopUp) Insert() error {
@@ -75,14 +79,22 @@ func Recharge(referenceId string, customerId string) (err error) {
 			return errors.New("充值订单状态错误")
 		}
 
+		quota = topUp.Money * common.QuotaPerUnit
 		topUp.CompleteTime = common.GetTimestamp()
 		topUp.Status = common.TopUpStatusSuccess
+		topUp.Quota = int64(quota)
 		err = tx.Save(topUp).Error
 		if err != nil {
 			return err
 		}
 
-		quota = topUp.Money * common.QuotaPerUnit
+		if topUp.OrganizationId != 0 {
+			err = tx.Model(&Organization{}).Where("id = ?", topUp.OrganizationId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+			if err != nil {
+				return err
+			}
+			return tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("stripe_customer", customerId).Error
+		}
 		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
 		if err != nil {
 			return err
@@ -95,6 +107,10 @@ func Recharge(referenceId string, customerId string) (err error) {
 		return errors.New("充值失败，" + err.Error())
 	}
 
+	if topUp.OrganizationId != 0 {
+		RecordOrganizationLog(topUp.OrganizationId, topUp.UserId, LogTypeTopup, fmt.Sprintf("使用在线充值为组织 #%d 充值成功，充值金额: %v，支付金额：%d", topUp.OrganizationId, logger.FormatQuota(int(quota)), topUp.Amount))
+		return nil
+	}
 	RecordLog(topUp.UserId, LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%d", logger.FormatQuota(int(quota)), topUp.Amount))
 
 	return nil
@@ -308,6 +308,15 @@ func GetUserIdByAffCode(affCode string) (int, error) {
 	return user.Id, err
 }
 
+func GetUserIdByUsername(username string) (int, error) {
+	if username == "" {
+		return 0, errors.New("用户名为空！")
+	}
+	var user User
+	err := DB.Select("id").First(&user, "username = ?", username).Error
+	return user.Id, err
+}
+
 func DeleteUserById(id int) (err error) {
 	if id == 0 {
 		return errors.New("id 为空！")
@@ -49,3 +49,8 @@ type TaskAdaptor interface {
 
 	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
 }
+
+// TaskOtherSettingsSetter 轮询任务时需要渠道额外配置的任务适配器
+type TaskOtherSettingsSetter interface {
+	SetOtherSettings(settings dto.ChannelOtherSettings)
+}
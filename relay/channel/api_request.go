@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	common2 "one-api/common"
+	"one-api/common/tracing"
 	"one-api/logger"
 	"one-api/relay/common"
 	"one-api/relay/constant"
@@ -263,6 +264,9 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	if info.ChannelSetting.PassTraceparent {
+		tracing.Inject(c, req.Header)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
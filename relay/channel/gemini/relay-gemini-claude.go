@@ -0,0 +1,668 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Claude Messages 与 Gemini 之间的直接转换，不经过 OpenAI 格式，保留工具调用 id、思考签名、图片/文档与缓存用量。
+// Gemini 没有按内容块指定的缓存，cache_control 被忽略，由 Gemini 的隐式缓存处理，命中的 token 以 cache_read_input_tokens 返回
+
+// geminiSignaturePrefix Gemini 的 thoughtSignature 在 Claude 格式中的前缀，用于区分 Claude 上游生成的签名
+const geminiSignaturePrefix = "gemini:"
+
+// claudeToolUseIdPrefix 网关为没有 id 的 Gemini 函数调用生成的 id 前缀，这类 id 不回传给 Gemini
+const claudeToolUseIdPrefix = "toolu_"
+
+func CovertClaude2Gemini(c *gin.Context, request dto.ClaudeRequest, info *relaycommon.RelayInfo) (*dto.GeminiChatRequest, error) {
+	geminiRequest := dto.GeminiChatRequest{
+		Contents: make([]dto.GeminiChatContent, 0, len(request.Messages)),
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			Temperature:     request.Temperature,
+			TopP:            request.TopP,
+			TopK:            float64(request.TopK),
+			MaxOutputTokens: request.MaxTokens,
+			StopSequences:   request.StopSequences,
+		},
+	}
+
+	if model_setting.IsGeminiModelSupportImagine(info.UpstreamModelName) {
+		geminiRequest.GenerationConfig.ResponseModalities = []string{
+			"TEXT",
+			"IMAGE",
+		}
+	}
+
+	if request.Thinking != nil && request.Thinking.Type == "enabled" {
+		geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+			IncludeThoughts: true,
+		}
+		if budget := request.Thinking.GetBudgetTokens(); budget > 0 {
+			geminiRequest.GenerationConfig.ThinkingConfig.SetThinkingBudget(clampThinkingBudget(info.UpstreamModelName, budget))
+		}
+	} else {
+		ThinkingAdaptor(&geminiRequest, info)
+	}
+
+	safetySettings := make([]dto.GeminiChatSafetySettings, 0, len(SafetySettingList))
+	for _, category := range SafetySettingList {
+		safetySettings = append(safetySettings, dto.GeminiChatSafetySettings{
+			Category:  category,
+			Threshold: model_setting.GetGeminiSafetySetting(category),
+		})
+	}
+	geminiRequest.SafetySettings = safetySettings
+
+	if err := convertClaudeTools(&geminiRequest, request); err != nil {
+		return nil, err
+	}
+
+	// system
+	if request.System != nil {
+		var systemParts []dto.GeminiPart
+		if request.IsStringSystem() {
+			if system := request.GetStringSystem(); system != "" {
+				systemParts = append(systemParts, dto.GeminiPart{Text: system})
+			}
+		} else {
+			for _, system := range request.ParseSystem() {
+				if system.GetText() != "" {
+					systemParts = append(systemParts, dto.GeminiPart{Text: system.GetText()})
+				}
+			}
+		}
+		if len(systemParts) > 0 {
+			geminiRequest.SystemInstructions = &dto.GeminiChatContent{
+				Parts: systemParts,
+			}
+		}
+	}
+
+	toolNames := make(map[string]string)
+	for _, message := range request.Messages {
+		content := dto.GeminiChatContent{
+			Role: "user",
+		}
+		if message.Role == "assistant" {
+			content.Role = "model"
+		}
+		if message.IsStringContent() {
+			if text := message.GetStringContent(); text != "" {
+				content.Parts = append(content.Parts, dto.GeminiPart{Text: text})
+			}
+		} else {
+			blocks, err := message.ParseContent()
+			if err != nil {
+				return nil, err
+			}
+			parts, err := claudeBlocksToGeminiParts(c, request, blocks, toolNames)
+			if err != nil {
+				return nil, err
+			}
+			content.Parts = parts
+		}
+		if len(content.Parts) == 0 {
+			continue
+		}
+		// 合并相同角色的连续消息
+		if last := len(geminiRequest.Contents) - 1; last >= 0 && geminiRequest.Contents[last].Role == content.Role {
+			geminiRequest.Contents[last].Parts = append(geminiRequest.Contents[last].Parts, content.Parts...)
+			continue
+		}
+		geminiRequest.Contents = append(geminiRequest.Contents, content)
+	}
+
+	return &geminiRequest, nil
+}
+
+func convertClaudeTools(geminiRequest *dto.GeminiChatRequest, request dto.ClaudeRequest) error {
+	tools := request.GetTools()
+	if len(tools) == 0 {
+		return nil
+	}
+	functions := make([]dto.FunctionRequest, 0, len(tools))
+	googleSearch := false
+	codeExecution := false
+	for _, tool := range tools {
+		toolMap, ok := tool.(map[string]any)
+		if !ok {
+			continue
+		}
+		toolType, _ := toolMap["type"].(string)
+		switch {
+		case strings.HasPrefix(toolType, "web_search"):
+			googleSearch = true
+			continue
+		case strings.HasPrefix(toolType, "code_execution"):
+			codeExecution = true
+			continue
+		case toolType != "" && toolType != "custom":
+			// 其他 Claude 内置工具 Gemini 不支持
+			continue
+		}
+		name, _ := toolMap["name"].(string)
+		description, _ := toolMap["description"].(string)
+		var parameters any
+		if schema, ok := toolMap["input_schema"].(map[string]any); ok {
+			if props, hasProps := schema["properties"].(map[string]any); !hasProps || len(props) > 0 {
+				parameters = cleanFunctionParameters(schema)
+			}
+		}
+		functions = append(functions, dto.FunctionRequest{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		})
+	}
+
+	var geminiTools []dto.GeminiChatTool
+	if codeExecution {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			CodeExecution: make(map[string]string),
+		})
+	}
+	if googleSearch {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			GoogleSearch: make(map[string]string),
+		})
+	}
+	if len(functions) > 0 {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			FunctionDeclarations: functions,
+		})
+	}
+	if len(geminiTools) > 0 {
+		geminiRequest.SetTools(geminiTools)
+	}
+
+	if request.ToolChoice != nil && len(functions) > 0 {
+		toolChoice, err := common.Any2Type[dto.ClaudeToolChoice](request.ToolChoice)
+		if err != nil {
+			return fmt.Errorf("invalid tool_choice: %w", err)
+		}
+		config := &dto.GeminiFunctionCallingConfig{}
+		switch toolChoice.Type {
+		case "auto":
+			config.Mode = "AUTO"
+		case "any":
+			config.Mode = "ANY"
+		case "none":
+			config.Mode = "NONE"
+		case "tool":
+			config.Mode = "ANY"
+			config.AllowedFunctionNames = []string{toolChoice.Name}
+		default:
+			return nil
+		}
+		geminiRequest.ToolConfig = &dto.GeminiToolConfig{
+			FunctionCallingConfig: config,
+		}
+	}
+	return nil
+}
+
+// claudeBlocksToGeminiParts 转换一条消息的内容块，thinking 块的签名附加到其后的第一个内容上（没有时附加到前一个内容）
+func claudeBlocksToGeminiParts(c *gin.Context, request dto.ClaudeRequest, blocks []dto.ClaudeMediaMessage, toolNames map[string]string) ([]dto.GeminiPart, error) {
+	var parts []dto.GeminiPart
+	var mediaParts []dto.GeminiPart
+	pendingSignature := ""
+	appendPart := func(part dto.GeminiPart) {
+		if pendingSignature != "" {
+			part.ThoughtSignature = pendingSignature
+			pendingSignature = ""
+		}
+		parts = append(parts, part)
+	}
+
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			if block.GetText() != "" {
+				appendPart(dto.GeminiPart{Text: block.GetText()})
+			}
+		case "image", "document":
+			part, err := claudeSourceToGeminiPart(c, block)
+			if err != nil {
+				return nil, err
+			}
+			if part != nil {
+				appendPart(*part)
+			}
+		case "thinking":
+			// 只回传由 Gemini 生成的签名，思考内容本身不需要回传
+			if signature, ok := strings.CutPrefix(block.Signature, geminiSignaturePrefix); ok && signature != "" {
+				pendingSignature = signature
+			}
+		case "tool_use":
+			args := block.Input
+			if args == nil {
+				args = map[string]any{}
+			}
+			functionCall := &dto.FunctionCall{
+				FunctionName: block.Name,
+				Arguments:    args,
+			}
+			if !strings.HasPrefix(block.Id, claudeToolUseIdPrefix) {
+				functionCall.Id = block.Id
+			}
+			toolNames[block.Id] = block.Name
+			appendPart(dto.GeminiPart{FunctionCall: functionCall})
+		case "tool_result":
+			name, ok := toolNames[block.ToolUseId]
+			if !ok {
+				name = request.SearchToolNameByToolCallId(block.ToolUseId)
+			}
+			var text string
+			if block.IsStringContent() {
+				text = block.GetStringContent()
+			} else {
+				var texts []string
+				for _, resultContent := range block.ParseMediaContent() {
+					if resultContent.Type == "text" {
+						texts = append(texts, resultContent.GetText())
+						continue
+					}
+					// 工具返回的图片、文档作为独立的内容放在函数结果之后
+					part, err := claudeSourceToGeminiPart(c, resultContent)
+					if err != nil {
+						return nil, err
+					}
+					if part != nil {
+						mediaParts = append(mediaParts, *part)
+					}
+				}
+				text = strings.Join(texts, "\n")
+			}
+			functionResponse := &dto.GeminiFunctionResponse{
+				Name:     name,
+				Response: toolResultResponse(text, block.IsError != nil && *block.IsError),
+			}
+			if !strings.HasPrefix(block.ToolUseId, claudeToolUseIdPrefix) {
+				functionResponse.Id = block.ToolUseId
+			}
+			appendPart(dto.GeminiPart{FunctionResponse: functionResponse})
+		}
+	}
+	if pendingSignature != "" && len(parts) > 0 && parts[len(parts)-1].ThoughtSignature == "" {
+		parts[len(parts)-1].ThoughtSignature = pendingSignature
+	}
+	return append(parts, mediaParts...), nil
+}
+
+// toolResultResponse 工具结果为 JSON 对象时直接作为 response，否则包装为 content 或 error
+func toolResultResponse(text string, isError bool) map[string]interface{} {
+	if isError {
+		return map[string]interface{}{"error": text}
+	}
+	var contentMap map[string]interface{}
+	if err := common.UnmarshalJsonStr(text, &contentMap); err == nil {
+		return contentMap
+	}
+	var contentSlice []interface{}
+	if err := common.UnmarshalJsonStr(text, &contentSlice); err == nil {
+		return map[string]interface{}{"result": contentSlice}
+	}
+	return map[string]interface{}{"content": text}
+}
+
+// claudeSourceToGeminiPart 将 Claude 的 image、document 内容块转换为 Gemini 的 inlineData
+func claudeSourceToGeminiPart(c *gin.Context, block dto.ClaudeMediaMessage) (*dto.GeminiPart, error) {
+	if block.Source == nil {
+		return nil, nil
+	}
+	switch block.Source.Type {
+	case "base64":
+		return &dto.GeminiPart{
+			InlineData: &dto.GeminiInlineData{
+				MimeType: block.Source.MediaType,
+				Data:     common.Interface2String(block.Source.Data),
+			},
+		}, nil
+	case "text":
+		return &dto.GeminiPart{Text: common.Interface2String(block.Source.Data)}, nil
+	case "url":
+		fileData, err := service.GetFileBase64FromUrl(c, block.Source.Url, "formatting claude content for Gemini")
+		if err != nil {
+			return nil, fmt.Errorf("get file base64 from url '%s' failed: %w", block.Source.Url, err)
+		}
+		if _, ok := geminiSupportedMimeTypes[strings.ToLower(fileData.MimeType)]; !ok {
+			return nil, fmt.Errorf("mime type is not supported by Gemini: '%s', url: '%s', supported types are: %v", fileData.MimeType, block.Source.Url, getSupportedMimeTypesList())
+		}
+		return &dto.GeminiPart{
+			InlineData: &dto.GeminiInlineData{
+				MimeType: fileData.MimeType,
+				Data:     fileData.Base64Data,
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+func geminiToolUseId(functionCall *dto.FunctionCall) string {
+	if functionCall.Id != "" {
+		return functionCall.Id
+	}
+	return claudeToolUseIdPrefix + common.GetUUID()
+}
+
+func geminiFunctionArguments(functionCall *dto.FunctionCall) any {
+	if args, ok := functionCall.Arguments.(map[string]interface{}); ok {
+		return unescapeMapOrSlice(args)
+	}
+	if functionCall.Arguments == nil {
+		return map[string]interface{}{}
+	}
+	return functionCall.Arguments
+}
+
+// geminiPartText 将 Gemini 的非文本内容转换为 Claude 的文本
+func geminiPartText(part *dto.GeminiPart) string {
+	if part.InlineData != nil {
+		if strings.HasPrefix(part.InlineData.MimeType, "image") {
+			return "![image](data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data + ")"
+		}
+		return fmt.Sprintf("[media](data:%s;base64,%s)", part.InlineData.MimeType, part.InlineData.Data)
+	}
+	if part.ExecutableCode != nil {
+		return "```" + part.ExecutableCode.Language + "\n" + part.ExecutableCode.Code + "\n```\n"
+	}
+	if part.CodeExecutionResult != nil {
+		return "```output\n" + part.CodeExecutionResult.Output + "\n```\n"
+	}
+	return part.Text
+}
+
+func stopReasonGemini2Claude(finishReason string, hasToolUse bool) string {
+	if hasToolUse {
+		return "tool_use"
+	}
+	switch finishReason {
+	case "MAX_TOKENS":
+		return "max_tokens"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// geminiUsage2Claude 转换用量，Gemini 的 promptTokenCount 包含缓存命中的 token
+func geminiUsage2Claude(metadata dto.GeminiUsageMetadata) (*dto.Usage, *dto.ClaudeUsage) {
+	cached := metadata.CachedContentTokenCount
+	usage := &dto.Usage{
+		PromptTokens:     metadata.PromptTokenCount - cached,
+		CompletionTokens: metadata.TotalTokenCount - metadata.PromptTokenCount,
+		TotalTokens:      metadata.TotalTokenCount,
+	}
+	usage.PromptTokensDetails.CachedTokens = cached
+	usage.CompletionTokenDetails.ReasoningTokens = metadata.ThoughtsTokenCount
+	for _, detail := range metadata.PromptTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.PromptTokensDetails.AudioTokens = detail.TokenCount
+		} else if detail.Modality == "TEXT" {
+			usage.PromptTokensDetails.TextTokens = detail.TokenCount
+		}
+	}
+	return usage, &dto.ClaudeUsage{
+		InputTokens:          usage.PromptTokens,
+		OutputTokens:         usage.CompletionTokens,
+		CacheReadInputTokens: cached,
+	}
+}
+
+func responseGemini2Claude(c *gin.Context, response *dto.GeminiChatResponse, info *relaycommon.RelayInfo) *dto.ClaudeResponse {
+	contents := make([]dto.ClaudeMediaMessage, 0)
+	hasToolUse := false
+	finishReason := ""
+	if len(response.Candidates) > 0 {
+		candidate := response.Candidates[0]
+		if candidate.FinishReason != nil {
+			finishReason = *candidate.FinishReason
+		}
+		for i := range candidate.Content.Parts {
+			part := &candidate.Content.Parts[i]
+			if part.Thought {
+				if last := len(contents) - 1; last >= 0 && contents[last].Type == "thinking" && contents[last].Signature == "" {
+					contents[last].Thinking += part.Text
+				} else {
+					contents = append(contents, dto.ClaudeMediaMessage{Type: "thinking", Thinking: part.Text})
+				}
+				if part.ThoughtSignature != "" {
+					contents[len(contents)-1].Signature = geminiSignaturePrefix + part.ThoughtSignature
+				}
+				continue
+			}
+			if part.ThoughtSignature != "" {
+				// 签名属于其之前的思考内容
+				if last := len(contents) - 1; last >= 0 && contents[last].Type == "thinking" && contents[last].Signature == "" {
+					contents[last].Signature = geminiSignaturePrefix + part.ThoughtSignature
+				} else {
+					contents = append(contents, dto.ClaudeMediaMessage{Type: "thinking", Signature: geminiSignaturePrefix + part.ThoughtSignature})
+				}
+			}
+			if part.FunctionCall != nil {
+				hasToolUse = true
+				contents = append(contents, dto.ClaudeMediaMessage{
+					Type:  "tool_use",
+					Id:    geminiToolUseId(part.FunctionCall),
+					Name:  part.FunctionCall.FunctionName,
+					Input: geminiFunctionArguments(part.FunctionCall),
+				})
+				continue
+			}
+			text := geminiPartText(part)
+			if text == "" || text == "\n" {
+				continue
+			}
+			if last := len(contents) - 1; last >= 0 && contents[last].Type == "text" {
+				contents[last].SetText(contents[last].GetText() + text)
+			} else {
+				claudeContent := dto.ClaudeMediaMessage{Type: "text"}
+				claudeContent.SetText(text)
+				contents = append(contents, claudeContent)
+			}
+		}
+	}
+	if len(contents) == 0 {
+		claudeContent := dto.ClaudeMediaMessage{Type: "text"}
+		claudeContent.SetText("")
+		contents = append(contents, claudeContent)
+	}
+	_, claudeUsage := geminiUsage2Claude(response.UsageMetadata)
+	return &dto.ClaudeResponse{
+		Id:         helper.GetResponseID(c),
+		Type:       "message",
+		Role:       "assistant",
+		Model:      info.UpstreamModelName,
+		Content:    contents,
+		StopReason: stopReasonGemini2Claude(finishReason, hasToolUse),
+		Usage:      claudeUsage,
+	}
+}
+
+func GeminiClaudeHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	if common.DebugEnabled {
+		println(string(responseBody))
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err = common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no candidates returned"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	claudeResponse := responseGemini2Claude(c, &geminiResponse, info)
+	usage, _ := geminiUsage2Claude(geminiResponse.UsageMetadata)
+	responseBody, err = common.Marshal(claudeResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return usage, nil
+}
+
+// geminiClaudeStream Gemini 流式响应转换为 Claude 事件的状态
+type geminiClaudeStream struct {
+	c            *gin.Context
+	index        int
+	blockType    string
+	hasToolUse   bool
+	finishReason string
+	responseText strings.Builder
+}
+
+func (s *geminiClaudeStream) send(resp *dto.ClaudeResponse) {
+	_ = helper.ClaudeData(s.c, *resp)
+}
+
+func (s *geminiClaudeStream) stopBlock() {
+	if s.blockType == "" {
+		return
+	}
+	s.send(&dto.ClaudeResponse{Type: "content_block_stop", Index: common.GetPointer(s.index)})
+	s.index++
+	s.blockType = ""
+}
+
+func (s *geminiClaudeStream) startBlock(block *dto.ClaudeMediaMessage) {
+	s.stopBlock()
+	s.blockType = block.Type
+	s.send(&dto.ClaudeResponse{Type: "content_block_start", Index: common.GetPointer(s.index), ContentBlock: block})
+}
+
+func (s *geminiClaudeStream) delta(delta *dto.ClaudeMediaMessage) {
+	s.send(&dto.ClaudeResponse{Type: "content_block_delta", Index: common.GetPointer(s.index), Delta: delta})
+}
+
+func (s *geminiClaudeStream) signature(signature string) {
+	if s.blockType != "thinking" {
+		s.startBlock(&dto.ClaudeMediaMessage{Type: "thinking", Thinking: ""})
+	}
+	s.delta(&dto.ClaudeMediaMessage{Type: "signature_delta", Signature: geminiSignaturePrefix + signature})
+}
+
+func (s *geminiClaudeStream) handlePart(part *dto.GeminiPart) {
+	if part.Thought {
+		if s.blockType != "thinking" {
+			s.startBlock(&dto.ClaudeMediaMessage{Type: "thinking", Thinking: ""})
+		}
+		if part.Text != "" {
+			s.responseText.WriteString(part.Text)
+			s.delta(&dto.ClaudeMediaMessage{Type: "thinking_delta", Thinking: part.Text})
+		}
+		if part.ThoughtSignature != "" {
+			s.signature(part.ThoughtSignature)
+		}
+		return
+	}
+	if part.ThoughtSignature != "" {
+		s.signature(part.ThoughtSignature)
+	}
+	if part.FunctionCall != nil {
+		s.hasToolUse = true
+		s.startBlock(&dto.ClaudeMediaMessage{
+			Type:  "tool_use",
+			Id:    geminiToolUseId(part.FunctionCall),
+			Name:  part.FunctionCall.FunctionName,
+			Input: map[string]interface{}{},
+		})
+		// Gemini 一次返回完整的参数
+		args, _ := common.Marshal(geminiFunctionArguments(part.FunctionCall))
+		s.responseText.WriteString(part.FunctionCall.FunctionName)
+		s.responseText.Write(args)
+		s.delta(&dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: common.GetPointer(string(args))})
+		s.stopBlock()
+		return
+	}
+	text := geminiPartText(part)
+	if text == "" || text == "\n" {
+		return
+	}
+	if s.blockType != "text" {
+		s.startBlock(&dto.ClaudeMediaMessage{Type: "text", Text: common.GetPointer("")})
+	}
+	s.responseText.WriteString(text)
+	s.delta(&dto.ClaudeMediaMessage{Type: "text_delta", Text: common.GetPointer(text)})
+}
+
+func GeminiClaudeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	stream := &geminiClaudeStream{c: c}
+	var usageMetadata dto.GeminiUsageMetadata
+	started := false
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var geminiResponse dto.GeminiChatResponse
+		if err := common.UnmarshalJsonStr(data, &geminiResponse); err != nil {
+			logger.LogError(c, "error unmarshalling stream response: "+err.Error())
+			return false
+		}
+		if geminiResponse.UsageMetadata.TotalTokenCount != 0 {
+			usageMetadata = geminiResponse.UsageMetadata
+		}
+		if !started {
+			started = true
+			message := &dto.ClaudeMediaMessage{
+				Id:    helper.GetResponseID(c),
+				Model: info.UpstreamModelName,
+				Type:  "message",
+				Role:  "assistant",
+				Usage: &dto.ClaudeUsage{
+					InputTokens: info.PromptTokens,
+				},
+			}
+			message.SetContent(make([]any, 0))
+			stream.send(&dto.ClaudeResponse{Type: "message_start", Message: message})
+		}
+		if len(geminiResponse.Candidates) == 0 {
+			return true
+		}
+		candidate := geminiResponse.Candidates[0]
+		for i := range candidate.Content.Parts {
+			stream.handlePart(&candidate.Content.Parts[i])
+		}
+		if candidate.FinishReason != nil {
+			stream.finishReason = *candidate.FinishReason
+		}
+		return true
+	})
+
+	if !started {
+		// 空补全，报错不计费
+		return nil, types.NewOpenAIError(errors.New("no response received from Gemini API"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+
+	usage, claudeUsage := geminiUsage2Claude(usageMetadata)
+	if usageMetadata.TotalTokenCount == 0 {
+		usage = service.ResponseText2Usage(stream.responseText.String(), info.UpstreamModelName, info.PromptTokens)
+		claudeUsage = service.UsageOpenAI2Claude(usage)
+	}
+
+	stream.stopBlock()
+	stream.send(&dto.ClaudeResponse{
+		Type:  "message_delta",
+		Usage: claudeUsage,
+		Delta: &dto.ClaudeMediaMessage{
+			StopReason: common.GetPointer(stopReasonGemini2Claude(stream.finishReason, stream.hasToolUse)),
+		},
+	})
+	stream.send(&dto.ClaudeResponse{Type: "message_stop"})
+	return usage, nil
+}
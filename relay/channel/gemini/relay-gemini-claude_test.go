@@ -0,0 +1,140 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 使用 go test ./relay/channel/gemini -update 重新生成 testdata 下的 golden 文件
+var update = flag.Bool("update", false, "update golden files")
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\n got:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// indentJSON 统一格式化 JSON，便于对比与阅读
+func indentJSON(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(out, '\n')
+}
+
+func newTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	return c, recorder
+}
+
+func newTestResponse(body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func newTestRelayInfo() *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		PromptTokens: 50,
+		ChannelMeta:  &relaycommon.ChannelMeta{UpstreamModelName: "gemini-2.5-flash"},
+	}
+}
+
+func TestCovertClaude2Gemini(t *testing.T) {
+	var request dto.ClaudeRequest
+	if err := json.Unmarshal(readTestdata(t, "claude_request.json"), &request); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := newTestContext()
+	geminiRequest, err := CovertClaude2Gemini(c, request, newTestRelayInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(geminiRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "claude_request.gemini.golden.json", indentJSON(t, data))
+}
+
+func TestGeminiClaudeHandler(t *testing.T) {
+	c, recorder := newTestContext()
+	usage, apiErr := GeminiClaudeHandler(c, newTestRelayInfo(), newTestResponse(readTestdata(t, "gemini_response.json")))
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	// promptTokenCount 包含缓存命中的 token
+	if usage.PromptTokens != 20 || usage.PromptTokensDetails.CachedTokens != 100 || usage.CompletionTokens != 50 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+	assertGolden(t, "gemini_response.claude.golden.json", indentJSON(t, recorder.Body.Bytes()))
+}
+
+func TestGeminiClaudeStreamHandler(t *testing.T) {
+	constant.StreamingTimeout = 30
+	c, recorder := newTestContext()
+	info := newTestRelayInfo()
+	info.DisablePing = true
+	usage, apiErr := GeminiClaudeStreamHandler(c, info, newTestResponse(readTestdata(t, "gemini_stream.txt")))
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if usage.PromptTokens != 40 || usage.PromptTokensDetails.CachedTokens != 10 || usage.CompletionTokens != 20 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+	assertGolden(t, "gemini_stream.claude.golden.txt", recorder.Body.Bytes())
+}
+
+func TestGeminiClaudeStreamHandlerEmpty(t *testing.T) {
+	constant.StreamingTimeout = 30
+	c, _ := newTestContext()
+	info := newTestRelayInfo()
+	info.DisablePing = true
+	if _, apiErr := GeminiClaudeStreamHandler(c, info, newTestResponse(nil)); apiErr == nil {
+		t.Error("expected error for empty stream")
+	}
+}
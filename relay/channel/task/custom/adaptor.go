@@ -0,0 +1,348 @@
+package custom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+)
+
+// 未配置 status_mapping 时按常见状态名匹配
+var defaultStatusMapping = map[string]string{
+	"submitted":   model.TaskStatusSubmitted,
+	"created":     model.TaskStatusSubmitted,
+	"queued":      model.TaskStatusQueued,
+	"queueing":    model.TaskStatusQueued,
+	"pending":     model.TaskStatusQueued,
+	"waiting":     model.TaskStatusQueued,
+	"in_progress": model.TaskStatusInProgress,
+	"processing":  model.TaskStatusInProgress,
+	"running":     model.TaskStatusInProgress,
+	"generating":  model.TaskStatusInProgress,
+	"success":     model.TaskStatusSuccess,
+	"succeeded":   model.TaskStatusSuccess,
+	"completed":   model.TaskStatusSuccess,
+	"done":        model.TaskStatusSuccess,
+	"failure":     model.TaskStatusFailure,
+	"failed":      model.TaskStatusFailure,
+	"error":       model.TaskStatusFailure,
+	"cancelled":   model.TaskStatusFailure,
+	"canceled":    model.TaskStatusFailure,
+}
+
+// ValidateStatusMapping 校验 status_mapping 的值只能是网关的任务状态，不区分大小写
+func ValidateStatusMapping(mapping map[string]string) error {
+	for state, status := range mapping {
+		switch strings.ToUpper(status) {
+		case model.TaskStatusSubmitted, model.TaskStatusQueued, model.TaskStatusInProgress,
+			model.TaskStatusSuccess, model.TaskStatusFailure:
+		default:
+			return fmt.Errorf("task_config.status_mapping[%q] must be one of SUBMITTED, QUEUED, IN_PROGRESS, SUCCESS, FAILURE, got %q", state, status)
+		}
+	}
+	return nil
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([\w.\-]+)\s*\}\}`)
+
+// ============================
+// Adaptor implementation
+// ============================
+
+// TaskAdaptor 通过渠道 other_settings.task_config 配置的通用异步任务适配器
+type TaskAdaptor struct {
+	ChannelType int
+	baseURL     string
+	config      *dto.CustomTaskConfig
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.ChannelType = info.ChannelType
+	a.baseURL = info.ChannelBaseUrl
+	a.config = info.ChannelOtherSettings.TaskConfig
+}
+
+// SetOtherSettings 轮询任务时设置渠道配置
+func (a *TaskAdaptor) SetOtherSettings(settings dto.ChannelOtherSettings) {
+	a.config = settings.TaskConfig
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskError {
+	if a.config == nil || a.config.SubmitPath == "" || a.config.TaskIdPath == "" {
+		return service.TaskErrorWrapperLocal(fmt.Errorf("channel task_config is not configured"), "invalid_channel_config", http.StatusInternalServerError)
+	}
+	return relaycommon.ValidateTaskRequestWithImageBinding(c, info)
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	path := strings.NewReplacer(
+		"{model}", url.PathEscape(info.UpstreamModelName),
+		"{action}", url.PathEscape(info.Action),
+	).Replace(a.config.SubmitPath)
+	return a.baseURL + path, nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	a.setAuthHeader(req, info.ApiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	v, exists := c.Get("task_request")
+	if !exists {
+		return nil, fmt.Errorf("request not found in context")
+	}
+	req := v.(relaycommon.TaskSubmitReq)
+	if info.UpstreamModelName != "" {
+		req.Model = info.UpstreamModelName
+	}
+
+	if a.config.BodyTemplate == "" {
+		return a.passThroughBody(c, req.Model)
+	}
+
+	images := req.Images
+	if len(images) == 0 && req.Image != "" {
+		images = []string{req.Image}
+	}
+	image := req.Image
+	if image == "" && len(images) > 0 {
+		image = images[0]
+	}
+	values := map[string]any{
+		"prompt":   req.Prompt,
+		"model":    req.Model,
+		"mode":     req.Mode,
+		"image":    image,
+		"images":   images,
+		"size":     req.Size,
+		"duration": req.Duration,
+		"metadata": req.Metadata,
+	}
+	data, err := renderTemplate(a.config.BodyTemplate, func(name string) (any, bool) {
+		if key, ok := strings.CutPrefix(name, "metadata."); ok {
+			value, exists := req.Metadata[key]
+			return value, exists
+		}
+		value, exists := values[name]
+		return value, exists
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, _ *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if !gjson.ValidBytes(responseBody) {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("invalid response: %s", responseBody), "unmarshal_response_failed", http.StatusInternalServerError)
+		return
+	}
+	taskID = gjson.GetBytes(responseBody, a.config.TaskIdPath).String()
+	if taskID == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("task id not found in response: %s", responseBody), "invalid_response", http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task_id": taskID})
+	return taskID, responseBody, nil
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	if a.config == nil || a.config.FetchPath == "" {
+		return nil, fmt.Errorf("channel task_config.fetch_path is not configured")
+	}
+
+	fetchUrl := baseUrl + strings.ReplaceAll(a.config.FetchPath, "{task_id}", url.PathEscape(taskID))
+	method := strings.ToUpper(a.config.FetchMethod)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if a.config.FetchBodyTemplate != "" {
+		data, err := renderTemplate(a.config.FetchBodyTemplate, func(name string) (any, bool) {
+			return taskID, name == "task_id"
+		})
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, fetchUrl, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	a.setAuthHeader(req, key)
+
+	return service.GetHttpClient().Do(req)
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	if a.config == nil || a.config.StatusPath == "" {
+		return nil, fmt.Errorf("channel task_config.status_path is not configured")
+	}
+	if !gjson.ValidBytes(respBody) {
+		return nil, errors.Errorf("invalid response body: %s", respBody)
+	}
+
+	state := gjson.GetBytes(respBody, a.config.StatusPath).String()
+	status, ok := a.config.StatusMapping[state]
+	if ok {
+		status = strings.ToUpper(status)
+	} else {
+		status, ok = defaultStatusMapping[strings.ToLower(state)]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown task state: %s", state)
+	}
+
+	taskInfo := &relaycommon.TaskInfo{Status: status}
+	switch status {
+	case model.TaskStatusSuccess:
+		taskInfo.Url = gjson.GetBytes(respBody, a.config.ResultUrlPath).String()
+	case model.TaskStatusFailure:
+		if a.config.FailReasonPath != "" {
+			taskInfo.Reason = gjson.GetBytes(respBody, a.config.FailReasonPath).String()
+		}
+		if taskInfo.Reason == "" {
+			taskInfo.Reason = "task failed: " + state
+		}
+	default:
+		if a.config.ProgressPath != "" {
+			taskInfo.Progress = formatProgress(gjson.GetBytes(respBody, a.config.ProgressPath), a.config.ProgressScale)
+		}
+	}
+	return taskInfo, nil
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return []string{}
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return "custom_task"
+}
+
+// ============================
+// helpers
+// ============================
+
+func (a *TaskAdaptor) setAuthHeader(req *http.Request, key string) {
+	header, prefix := "Authorization", "Bearer "
+	if a.config != nil {
+		if a.config.AuthHeader != "" {
+			header = a.config.AuthHeader
+		}
+		if a.config.AuthPrefix != nil {
+			prefix = *a.config.AuthPrefix
+		}
+	}
+	req.Header.Set(header, prefix+key)
+}
+
+// passThroughBody 未配置请求体模板时透传原始请求，仅替换模型名并移除网关自用字段
+func (a *TaskAdaptor) passThroughBody(c *gin.Context, modelName string) (io.Reader, error) {
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err = common.Unmarshal(requestBody, &body); err != nil {
+		return nil, errors.Wrap(err, "unmarshal request body failed")
+	}
+	body["model"] = modelName
+	delete(body, "callback_url")
+	data, err := common.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// renderTemplate 将模板中的 {{name}} 替换为 JSON 编码后的值，缺失的值替换为 null
+func renderTemplate(template string, lookup func(name string) (any, bool)) ([]byte, error) {
+	var renderErr error
+	rendered := templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := lookup(name)
+		if !ok {
+			return "null"
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			renderErr = err
+			return "null"
+		}
+		return string(data)
+	})
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	if !json.Valid([]byte(rendered)) {
+		return nil, fmt.Errorf("rendered body template is not valid json: %s", rendered)
+	}
+	return []byte(rendered), nil
+}
+
+// formatProgress 将上游进度统一为百分比字符串，支持 0-1 小数、0-100 数值与已带 % 的字符串。
+// scale 为进度满值，为 0 时自动判断：小于 1 或带小数点的 1（如 1.0）按 0-1 处理
+func formatProgress(value gjson.Result, scale float64) string {
+	if !value.Exists() {
+		return ""
+	}
+	raw := strings.TrimSpace(value.String())
+	if raw == "" || strings.HasSuffix(raw, "%") {
+		return raw
+	}
+	progress, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case scale > 0:
+		progress = progress * 100 / scale
+	case progress > 0 && progress < 1, progress == 1 && strings.Contains(value.Raw, "."):
+		progress *= 100
+	}
+	return fmt.Sprintf("%d%%", int(math.Round(min(progress, 100))))
+}
+
+var _ channel.TaskAdaptor = (*TaskAdaptor)(nil)
+var _ channel.TaskOtherSettingsSetter = (*TaskAdaptor)(nil)
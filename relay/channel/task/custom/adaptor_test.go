@@ -0,0 +1,133 @@
+package custom
+
+import (
+	"one-api/dto"
+	"one-api/model"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	values := map[string]any{
+		"prompt":   `a "cat"`,
+		"images":   []string{"a.png", "b.png"},
+		"duration": 5,
+		"metadata": map[string]any{"seed": 42},
+	}
+	lookup := func(name string) (any, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "values encoded as json", template: `{"prompt": {{prompt}}, "images": {{images}}, "seconds": {{ duration }}}`, want: `{"prompt": "a \"cat\"", "images": ["a.png","b.png"], "seconds": 5}`},
+		{name: "missing value", template: `{"seed": {{metadata.seed}}}`, want: `{"seed": null}`},
+		{name: "nested object", template: `{"metadata": {{metadata}}}`, want: `{"metadata": {"seed":42}}`},
+		{name: "no placeholders", template: `{"fixed": true}`, want: `{"fixed": true}`},
+		// 占位符外加引号会得到无效的 JSON
+		{name: "quoted placeholder", template: `{"prompt": "{{prompt}}"}`, wantErr: true},
+		{name: "invalid template", template: `{"prompt": {{prompt}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := renderTemplate(tt.template, lookup)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && string(got) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		json  string
+		scale float64
+		want  string
+	}{
+		{json: `{}`, want: ""},
+		{json: `{"p": ""}`, want: ""},
+		{json: `{"p": "abc"}`, want: ""},
+		{json: `{"p": "45%"}`, want: "45%"},
+		{json: `{"p": 0}`, want: "0%"},
+		{json: `{"p": 0.29}`, want: "29%"},
+		{json: `{"p": "0.5"}`, want: "50%"},
+		{json: `{"p": 1.0}`, want: "100%"},
+		{json: `{"p": 1}`, want: "1%"},
+		{json: `{"p": 50}`, want: "50%"},
+		{json: `{"p": 100}`, want: "100%"},
+		// 配置满值后不再自动判断
+		{json: `{"p": 1}`, scale: 1, want: "100%"},
+		{json: `{"p": 0.5}`, scale: 1, want: "50%"},
+		{json: `{"p": 0.5}`, scale: 100, want: "1%"},
+		{json: `{"p": 500}`, scale: 1000, want: "50%"},
+		{json: `{"p": 2}`, scale: 1, want: "100%"},
+	}
+	for _, tt := range tests {
+		if got := formatProgress(gjson.Get(tt.json, "p"), tt.scale); got != tt.want {
+			t.Errorf("%s (scale %v): got %q, want %q", tt.json, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestParseTaskResult(t *testing.T) {
+	config := &dto.CustomTaskConfig{
+		StatusPath:     "data.state",
+		ProgressPath:   "data.progress",
+		ResultUrlPath:  "data.outputs.0.url",
+		FailReasonPath: "error.message",
+		StatusMapping:  map[string]string{"2": "success", "3": "FAILURE", "Waiting": "in_progress"},
+	}
+	tests := []struct {
+		name         string
+		config       *dto.CustomTaskConfig
+		body         string
+		wantStatus   string
+		wantProgress string
+		wantUrl      string
+		wantReason   string
+		wantErr      bool
+	}{
+		// 配置的映射值不区分大小写
+		{name: "mapped success", config: config, body: `{"data":{"state":2,"outputs":[{"url":"https://cdn/v.mp4"}]}}`, wantStatus: model.TaskStatusSuccess, wantUrl: "https://cdn/v.mp4"},
+		{name: "mapped failure", config: config, body: `{"data":{"state":"3"},"error":{"message":"nsfw"}}`, wantStatus: model.TaskStatusFailure, wantReason: "nsfw"},
+		{name: "mapping overrides default", config: config, body: `{"data":{"state":"Waiting","progress":0.4}}`, wantStatus: model.TaskStatusInProgress, wantProgress: "40%"},
+		// 未配置的状态按常见状态名匹配，不区分大小写
+		{name: "default mapping", config: config, body: `{"data":{"state":"RUNNING","progress":"30%"}}`, wantStatus: model.TaskStatusInProgress, wantProgress: "30%"},
+		{name: "default queued", config: config, body: `{"data":{"state":"pending"}}`, wantStatus: model.TaskStatusQueued},
+		{name: "failure without reason", config: config, body: `{"data":{"state":"Failed"}}`, wantStatus: model.TaskStatusFailure, wantReason: "task failed: Failed"},
+		{name: "unknown state", config: config, body: `{"data":{"state":"paused"}}`, wantErr: true},
+		{name: "invalid json", config: config, body: `not json`, wantErr: true},
+		{name: "status path not configured", config: &dto.CustomTaskConfig{}, body: `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		a := &TaskAdaptor{}
+		a.SetOtherSettings(dto.ChannelOtherSettings{TaskConfig: tt.config})
+		info, err := a.ParseTaskResult([]byte(tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if info.Status != tt.wantStatus || info.Progress != tt.wantProgress || info.Url != tt.wantUrl || info.Reason != tt.wantReason {
+			t.Errorf("%s: got %+v", tt.name, info)
+		}
+	}
+}
+
+func TestValidateStatusMapping(t *testing.T) {
+	if err := ValidateStatusMapping(map[string]string{"0": "queued", "1": "IN_PROGRESS"}); err != nil {
+		t.Errorf("valid mapping rejected: %v", err)
+	}
+	if err := ValidateStatusMapping(map[string]string{"0": "DONE"}); err == nil {
+		t.Errorf("invalid status accepted")
+	}
+}
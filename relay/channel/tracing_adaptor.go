@@ -0,0 +1,120 @@
+package channel
+
+import (
+	"io"
+	"net/http"
+	"one-api/common/tracing"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TracingAdaptor 为适配器的请求转换、发送与响应处理记录 span
+type TracingAdaptor struct {
+	Adaptor
+}
+
+// WithTracing 启用链路追踪时包装适配器
+func WithTracing(adaptor Adaptor) Adaptor {
+	if adaptor == nil || !tracing.Enabled() {
+		return adaptor
+	}
+	return &TracingAdaptor{Adaptor: adaptor}
+}
+
+func startConvertSpan(c *gin.Context, name string) *tracing.Span {
+	return tracing.StartSpan(c, "adaptor."+name)
+}
+
+func endSpan(span *tracing.Span, err error) {
+	if err != nil {
+		span.SetError(err.Error())
+	}
+	span.End()
+}
+
+func (a *TracingAdaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertOpenAIRequest")
+	result, err := a.Adaptor.ConvertOpenAIRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertRerankRequest")
+	result, err := a.Adaptor.ConvertRerankRequest(c, relayMode, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertEmbeddingRequest")
+	result, err := a.Adaptor.ConvertEmbeddingRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	span := startConvertSpan(c, "ConvertAudioRequest")
+	result, err := a.Adaptor.ConvertAudioRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertImageRequest")
+	result, err := a.Adaptor.ConvertImageRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertOpenAIResponsesRequest")
+	result, err := a.Adaptor.ConvertOpenAIResponsesRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertClaudeRequest")
+	result, err := a.Adaptor.ConvertClaudeRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
+	span := startConvertSpan(c, "ConvertGeminiRequest")
+	result, err := a.Adaptor.ConvertGeminiRequest(c, info, request)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	span := tracing.StartSpanWithKind(c, "adaptor.DoRequest", tracing.SpanKindClient)
+	span.SetAttribute("channel.id", info.ChannelId)
+	span.SetAttribute("channel.type", info.ChannelType)
+	span.SetAttribute("upstream.model", info.UpstreamModelName)
+	result, err := a.Adaptor.DoRequest(c, info, requestBody)
+	if resp, ok := result.(*http.Response); ok && resp != nil {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *TracingAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (any, *types.NewAPIError) {
+	span := tracing.StartSpan(c, "adaptor.DoResponse")
+	span.SetAttribute("stream", info.IsStream)
+	usage, newAPIError := a.Adaptor.DoResponse(c, resp, info)
+	if u, ok := usage.(*dto.Usage); ok && u != nil {
+		span.SetAttribute("prompt_tokens", u.PromptTokens)
+		span.SetAttribute("completion_tokens", u.CompletionTokens)
+	}
+	if newAPIError != nil {
+		span.SetError(newAPIError.MaskSensitiveError())
+	}
+	span.End()
+	return usage, newAPIError
+}
@@ -34,6 +34,8 @@ type ClaudeConvertInfo struct {
 	Usage            *dto.Usage
 	FinishReason     string
 	Done             bool
+	// 当前 tool_use 内容块对应的工具调用 id
+	ToolCallId string
 }
 
 type RerankerInfo struct {
@@ -78,6 +80,7 @@ type RelayInfo struct {
 	UsingGroup        string // 使用的分组
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
+	OrganizationId    int // 组织令牌所属组织，使用组织额度
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -387,6 +390,7 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/tracing"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
@@ -15,6 +16,7 @@ import (
 	"one-api/service"
 	"one-api/setting/model_setting"
 	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
 	"one-api/types"
 	"strings"
 	"time"
@@ -46,6 +48,12 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	// 将引用的本地文件转换为当前渠道可用的形式
+	err = service.ResolveRequestFiles(info, request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+
 	includeUsage := true
 	// 判断用户是否需要返回使用情况
 	if request.StreamOptions != nil {
@@ -165,6 +173,13 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
+	// 需要写入响应缓存时捕获下游响应
+	var capture *service.ResponseCaptureWriter
+	if common.GetContextKeyString(c, constant.ContextKeyResponseCacheKey) != "" {
+		capture = service.StartResponseCapture(c)
+		defer capture.Stop(c)
+	}
+
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
 	if newApiErr != nil {
 		// reset status code 重置状态码
@@ -177,10 +192,15 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	} else {
 		postConsumeQuota(c, info, usage.(*dto.Usage), "")
 	}
+	if capture != nil {
+		saveResponseCache(c, info, capture, usage.(*dto.Usage))
+	}
 	return nil
 }
 
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	span := tracing.StartSpan(ctx, "post_consume_quota")
+	defer span.End()
 	if usage == nil {
 		usage = &dto.Usage{
 			PromptTokens:     relayInfo.PromptTokens,
@@ -191,6 +211,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	}
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
+	// 按实际提示 token 数重新选择上下文长度分段
+	ratio_setting.ApplyModelRatioTier(&relayInfo.PriceData, relayInfo.OriginModelName, promptTokens)
 	cacheTokens := usage.PromptTokensDetails.CachedTokens
 	imageTokens := usage.PromptTokensDetails.ImageTokens
 	audioTokens := usage.PromptTokensDetails.AudioTokens
@@ -429,6 +451,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		other["audio_input_token_count"] = audioTokens
 		other["audio_input_price"] = audioInputPrice
 	}
+	service.RecordTokenRateUsage(ctx, promptTokens+completionTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -0,0 +1,152 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// token 计数请求不扣除额度：渠道支持原生计数时请求上游，否则或上游失败时在本地估算
+
+// countTokensAdaptor 复用渠道适配器的请求头与代理设置，只替换请求地址
+type countTokensAdaptor struct {
+	channel.Adaptor
+	url string
+}
+
+func (a *countTokensAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return a.url, nil
+}
+
+// upstreamCountTokensURL 渠道的原生计数接口，不支持时返回空
+func upstreamCountTokensURL(info *relaycommon.RelayInfo) string {
+	switch info.ApiType {
+	case constant.APITypeAnthropic:
+		return fmt.Sprintf("%s/v1/messages/count_tokens", info.ChannelBaseUrl)
+	case constant.APITypeGemini:
+		version := model_setting.GetGeminiVersionSetting(info.UpstreamModelName)
+		return fmt.Sprintf("%s/%s/models/%s:countTokens", info.ChannelBaseUrl, version, info.UpstreamModelName)
+	}
+	return ""
+}
+
+// doUpstreamCountTokens 请求上游计数接口并解析响应，渠道不支持原生计数时返回 false
+func doUpstreamCountTokens(c *gin.Context, info *relaycommon.RelayInfo, body []byte, response any) (bool, error) {
+	if !operation_setting.GetCountTokensSetting().UpstreamEnabled {
+		return false, nil
+	}
+	url := upstreamCountTokensURL(info)
+	if url == "" {
+		return false, nil
+	}
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return false, nil
+	}
+	adaptor.Init(info)
+	resp, err := channel.DoApiRequest(&countTokensAdaptor{Adaptor: adaptor, url: url}, c, info, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("status code %d: %s", resp.StatusCode, string(data))
+	}
+	if err = common.Unmarshal(data, response); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClaudeCountTokensHelper 计算 Claude 请求的输入 token 数
+func ClaudeCountTokensHelper(c *gin.Context, request *dto.ClaudeRequest) (int, error) {
+	info := relaycommon.GenRelayInfoClaude(c, request)
+	info.InitChannelMeta(c)
+	if err := helper.ModelMappedHelper(c, info, request); err != nil {
+		return 0, err
+	}
+
+	body, err := common.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+	var response dto.ClaudeCountTokensResponse
+	ok, err := doUpstreamCountTokens(c, info, body, &response)
+	if err != nil {
+		logger.LogWarn(c, "failed to count tokens by upstream, fallback to local: "+err.Error())
+	} else if ok {
+		return response.InputTokens, nil
+	}
+	return service.CountTokenClaudeRequest(*request, info.OriginModelName)
+}
+
+// GeminiCountTokensHelper 计算 Gemini 请求的 token 数，原始请求体直接转发给上游
+func GeminiCountTokensHelper(c *gin.Context) (*dto.GeminiCountTokensResponse, error) {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	var request dto.GeminiCountTokensRequest
+	if err = common.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	chatRequest := request.GenerateContentRequest
+	if chatRequest == nil {
+		chatRequest = &dto.GeminiChatRequest{Contents: request.Contents}
+	}
+
+	info := relaycommon.GenRelayInfoGemini(c, chatRequest)
+	info.InitChannelMeta(c)
+	if err = helper.ModelMappedHelper(c, info, nil); err != nil {
+		return nil, err
+	}
+
+	var response dto.GeminiCountTokensResponse
+	ok, err := doUpstreamCountTokens(c, info, body, &response)
+	if err != nil {
+		logger.LogWarn(c, "failed to count tokens by upstream, fallback to local: "+err.Error())
+	} else if ok {
+		return &response, nil
+	}
+
+	// 本地估算：文本按分词器计数，图片按 Gemini 的固定 258 token 计数
+	meta := chatRequest.GetTokenCountMeta()
+	texts := []string{meta.CombineText}
+	if chatRequest.SystemInstructions != nil {
+		for _, part := range chatRequest.SystemInstructions.Parts {
+			texts = append(texts, part.Text)
+		}
+	}
+	for _, tool := range chatRequest.GetTools() {
+		if tool.FunctionDeclarations != nil {
+			declarations, _ := common.Marshal(tool.FunctionDeclarations)
+			texts = append(texts, string(declarations))
+		}
+	}
+	response.TotalTokens = service.CountTextToken(strings.Join(texts, "\n"), info.OriginModelName)
+	for _, file := range meta.Files {
+		if file.FileType == types.FileTypeImage {
+			response.TotalTokens += 258
+		}
+	}
+	return &response, nil
+}
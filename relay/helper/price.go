@@ -3,9 +3,11 @@ package helper
 import (
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	relaycommon "one-api/relay/common"
 	"one-api/setting/ratio_setting"
 	"one-api/types"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,6 +40,11 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) types.
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// batch request discount
+	if batchDiscountRatio, ok := common.GetContextKeyType[float64](ctx, constant.ContextKeyBatchDiscountRatio); ok {
+		groupRatioInfo.GroupRatio = groupRatioInfo.GroupRatio * batchDiscountRatio
+	}
+
 	return groupRatioInfo
 }
 
@@ -45,6 +52,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
 
 	groupRatioInfo := HandleGroupRatio(c, info)
+	pricingRule := GetPricingRuleInfo(info.OriginModelName, info.UsingGroup)
 
 	var preConsumedQuota int
 	var modelRatio float64
@@ -52,6 +60,8 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var cacheRatio float64
 	var imageRatio float64
 	var cacheCreationRatio float64
+	var contextTier int
+	var contextTierPromptTokensAbove int
 	if !usePrice {
 		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
@@ -73,12 +83,30 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		cacheRatio, _ = ratio_setting.GetCacheRatio(info.OriginModelName)
 		cacheCreationRatio, _ = ratio_setting.GetCreateCacheRatio(info.OriginModelName)
 		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
+		// 超过上下文长度分段时使用分段倍率，结算时按实际提示 token 数重新选择
+		if tier, index, ok := ratio_setting.GetModelRatioTier(info.OriginModelName, promptTokens); ok {
+			contextTier = index
+			contextTierPromptTokensAbove = tier.PromptTokensAbove
+			modelRatio = tier.ModelRatio
+			if tier.CompletionRatio > 0 {
+				completionRatio = tier.CompletionRatio
+			}
+			if tier.CacheRatio > 0 {
+				cacheRatio = tier.CacheRatio
+			}
+		}
+		if pricingRule.Id != "" {
+			modelRatio *= pricingRule.Multiplier
+		}
 		ratio := modelRatio * groupRatioInfo.GroupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
 		if meta.ImagePriceRatio != 0 {
 			modelPrice = modelPrice * meta.ImagePriceRatio
 		}
+		if pricingRule.Id != "" {
+			modelPrice *= pricingRule.Multiplier
+		}
 		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	}
 
@@ -92,6 +120,10 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		ImageRatio:             imageRatio,
 		CacheCreationRatio:     cacheCreationRatio,
 		ShouldPreConsumedQuota: preConsumedQuota,
+
+		ContextTier:                  contextTier,
+		ContextTierPromptTokensAbove: contextTierPromptTokensAbove,
+		PricingRule:                  pricingRule,
 	}
 
 	if common.DebugEnabled {
@@ -115,15 +147,29 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) types.
 			modelPrice = defaultPrice
 		}
 	}
+	pricingRule := GetPricingRuleInfo(info.OriginModelName, info.UsingGroup)
+	if pricingRule.Id != "" {
+		modelPrice *= pricingRule.Multiplier
+	}
 	quota := int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	priceData := types.PerCallPriceData{
 		ModelPrice:     modelPrice,
 		Quota:          quota,
 		GroupRatioInfo: groupRatioInfo,
+		PricingRule:    pricingRule,
 	}
 	return priceData
 }
 
+// GetPricingRuleInfo 返回模型在分组下当前生效的定时价格规则
+func GetPricingRuleInfo(modelName string, group string) types.PricingRuleInfo {
+	rule, ok := ratio_setting.GetActivePricingRule(modelName, group, time.Now())
+	if !ok {
+		return types.PricingRuleInfo{}
+	}
+	return types.PricingRuleInfo{Id: rule.Id, Name: rule.Name, Multiplier: rule.Multiplier}
+}
+
 func ContainPriceOrRatio(modelName string) bool {
 	_, ok := ratio_setting.GetModelPrice(modelName, false)
 	if ok {
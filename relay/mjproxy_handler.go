@@ -32,6 +32,14 @@ func RelayMidjourneyImage(c *gin.Context) {
 		})
 		return
 	}
+	// 已转存的图片直接从网关存储读取
+	if asset, err := model.GetAssetBySource(model.AssetSourceMidjourney, strconv.Itoa(midjourneyTask.Id)); err == nil {
+		if reader, err := service.GetAssetStorage().Open(asset.AssetId); err == nil {
+			defer reader.Close()
+			c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, reader, nil)
+			return
+		}
+	}
 	var httpClient *http.Client
 	if channel, err := model.CacheGetChannel(midjourneyTask.ChannelId); err == nil {
 		proxy := channel.GetSetting().Proxy
@@ -130,7 +138,9 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 	midjourneyTask.StartTime = originTask.StartTime
 	midjourneyTask.FinishTime = originTask.FinishTime
 	midjourneyTask.ImageUrl = ""
-	if originTask.ImageUrl != "" && setting.MjForwardUrlEnabled {
+	if assetUrl := service.GetSourceAssetUrl(model.AssetSourceMidjourney, strconv.Itoa(originTask.Id)); assetUrl != "" {
+		midjourneyTask.ImageUrl = assetUrl
+	} else if originTask.ImageUrl != "" && setting.MjForwardUrlEnabled {
 		midjourneyTask.ImageUrl = setting.ServerAddress + "/mj/image/" + originTask.MjId
 		if originTask.Status != "SUCCESS" {
 			midjourneyTask.ImageUrl += "?rand=" + strconv.FormatInt(time.Now().UnixNano(), 10)
@@ -186,7 +196,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 
 	priceData := helper.ModelPriceHelperPerCall(c, info)
 
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	userQuota, err := model.GetPayerQuota(info.UserId, info.OrganizationId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -251,6 +261,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
 	}
+	midjourneyTask.OrganizationId = info.OrganizationId
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "insert_midjourney_task_failed")
@@ -487,7 +498,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
 
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetPayerQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -558,6 +569,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
 	}
+	midjourneyTask.OrganizationId = relayInfo.OrganizationId
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
 		channel, err := model.GetChannelById(midjourneyTask.ChannelId, true)
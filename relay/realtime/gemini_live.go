@@ -0,0 +1,379 @@
+package realtime
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gorilla/websocket"
+)
+
+const geminiLivePath = "/ws/google.ai.generativelanguage.v1beta.GenerativeService.BidiGenerateContent"
+
+// Gemini Live 预置音色，OpenAI 音色名无法对应时使用上游默认音色
+var geminiLiveVoices = map[string]bool{
+	"Puck": true, "Charon": true, "Kore": true, "Fenrir": true, "Aoede": true, "Leda": true, "Orus": true, "Zephyr": true,
+}
+
+type geminiLiveSetup struct {
+	Model                    string           `json:"model"`
+	GenerationConfig         map[string]any   `json:"generationConfig,omitempty"`
+	SystemInstruction        map[string]any   `json:"systemInstruction,omitempty"`
+	Tools                    []map[string]any `json:"tools,omitempty"`
+	InputAudioTranscription  *struct{}        `json:"inputAudioTranscription,omitempty"`
+	OutputAudioTranscription *struct{}        `json:"outputAudioTranscription,omitempty"`
+}
+
+type geminiLiveServerMessage struct {
+	SetupComplete *struct{} `json:"setupComplete,omitempty"`
+	ServerContent *struct {
+		ModelTurn *struct {
+			Parts []struct {
+				Text       string `json:"text,omitempty"`
+				InlineData *struct {
+					MimeType string `json:"mimeType"`
+					Data     string `json:"data"`
+				} `json:"inlineData,omitempty"`
+			} `json:"parts"`
+		} `json:"modelTurn,omitempty"`
+		InputTranscription *struct {
+			Text string `json:"text"`
+		} `json:"inputTranscription,omitempty"`
+		OutputTranscription *struct {
+			Text string `json:"text"`
+		} `json:"outputTranscription,omitempty"`
+		TurnComplete bool `json:"turnComplete,omitempty"`
+		Interrupted  bool `json:"interrupted,omitempty"`
+	} `json:"serverContent,omitempty"`
+	ToolCall *struct {
+		FunctionCalls []struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+			Args any    `json:"args"`
+		} `json:"functionCalls"`
+	} `json:"toolCall,omitempty"`
+	GoAway *struct {
+		TimeLeft string `json:"timeLeft"`
+	} `json:"goAway,omitempty"`
+}
+
+// geminiLiveBackend 将 OpenAI Realtime 事件转换为 Gemini Live（BidiGenerateContent）协议，
+// Gemini Live 只能在连接建立时设置会话，因此在收到第一条非 session.update 事件时才连接上游
+type geminiLiveBackend struct {
+	s    *session
+	conn *websocket.Conn
+	lock sync.Mutex
+
+	// 当前正在输出的回复
+	responseId string
+	itemId     string
+	transcript strings.Builder
+	text       strings.Builder
+}
+
+func newGeminiLiveBackend(s *session) *geminiLiveBackend {
+	return &geminiLiveBackend{s: s}
+}
+
+func (b *geminiLiveBackend) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.conn != nil {
+		_ = b.conn.Close()
+	}
+}
+
+func (b *geminiLiveBackend) liveUrl() string {
+	baseUrl := strings.TrimRight(b.s.info.ChannelBaseUrl, "/")
+	if baseUrl == "" {
+		baseUrl = "https://generativelanguage.googleapis.com"
+	}
+	baseUrl = strings.Replace(baseUrl, "https://", "wss://", 1)
+	baseUrl = strings.Replace(baseUrl, "http://", "ws://", 1)
+	return fmt.Sprintf("%s%s?key=%s", baseUrl, geminiLivePath, url.QueryEscape(b.s.info.ApiKey))
+}
+
+// connect 建立上游连接并发送会话设置，等待 setupComplete
+func (b *geminiLiveBackend) connect() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.conn != nil {
+		return nil
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(b.liveUrl(), http.Header{})
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial gemini live failed with status code %d: %w", resp.StatusCode, err)
+		}
+		return fmt.Errorf("dial gemini live failed: %w", err)
+	}
+
+	config := b.s.config
+	modalities := []string{"TEXT"}
+	if b.s.audioOutput() {
+		modalities = []string{"AUDIO"}
+	}
+	generationConfig := map[string]any{
+		"responseModalities": modalities,
+	}
+	if config.Temperature != 0 {
+		generationConfig["temperature"] = config.Temperature
+	}
+	if geminiLiveVoices[config.Voice] {
+		generationConfig["speechConfig"] = map[string]any{
+			"voiceConfig": map[string]any{
+				"prebuiltVoiceConfig": map[string]any{"voiceName": config.Voice},
+			},
+		}
+	}
+	setup := geminiLiveSetup{
+		Model:                   "models/" + b.s.info.UpstreamModelName,
+		GenerationConfig:        generationConfig,
+		InputAudioTranscription: &struct{}{},
+	}
+	if b.s.audioOutput() {
+		setup.OutputAudioTranscription = &struct{}{}
+	}
+	if config.Instructions != "" {
+		setup.SystemInstruction = map[string]any{
+			"parts": []map[string]any{{"text": config.Instructions}},
+		}
+	}
+	if len(config.Tools) > 0 {
+		declarations := make([]map[string]any, 0, len(config.Tools))
+		for _, tool := range config.Tools {
+			declarations = append(declarations, map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			})
+		}
+		setup.Tools = []map[string]any{{"functionDeclarations": declarations}}
+	}
+	if err = conn.WriteJSON(map[string]any{"setup": setup}); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("send gemini live setup failed: %w", err)
+	}
+	var message geminiLiveServerMessage
+	if err = conn.ReadJSON(&message); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("gemini live setup failed: %w", err)
+	}
+	if message.SetupComplete == nil {
+		_ = conn.Close()
+		return fmt.Errorf("gemini live setup failed: unexpected message")
+	}
+	b.conn = conn
+	b.s.info.SetFirstResponseTime()
+	gopool.Go(b.readUpstream)
+	return nil
+}
+
+func (b *geminiLiveBackend) write(message any) error {
+	if err := b.connect(); err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.conn.WriteJSON(message)
+}
+
+func (b *geminiLiveBackend) handleClientEvent(event *dto.RealtimeEvent) error {
+	switch event.Type {
+	case dto.RealtimeEventInputAudioBufferAppend:
+		if event.Audio == "" {
+			return nil
+		}
+		b.s.addInputAudio(event.Audio)
+		return b.write(map[string]any{
+			"realtimeInput": map[string]any{
+				"audio": map[string]any{
+					"mimeType": "audio/pcm;rate=24000",
+					"data":     event.Audio,
+				},
+			},
+		})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		// Gemini Live 自动检测语音结束，提交时通知音频流结束
+		if err := b.write(map[string]any{"realtimeInput": map[string]any{"audioStreamEnd": true}}); err != nil {
+			return err
+		}
+		return b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: b.s.newId("item")})
+	case dto.RealtimeEventInputAudioBufferClear:
+		return b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventTypeConversationCreate:
+		if event.Item == nil {
+			return nil
+		}
+		if event.Item.Type == "function_call_output" {
+			return b.write(map[string]any{
+				"toolResponse": map[string]any{
+					"functionResponses": []map[string]any{{
+						"id":       event.Item.CallId,
+						"response": map[string]any{"output": event.Item.Output},
+					}},
+				},
+			})
+		}
+		text := itemText(event.Item)
+		if text == "" {
+			return nil
+		}
+		b.s.addInputText(text)
+		role := "user"
+		if event.Item.Role == "assistant" {
+			role = "model"
+		}
+		if err := b.write(map[string]any{
+			"clientContent": map[string]any{
+				"turns":        []map[string]any{{"role": role, "parts": []map[string]any{{"text": text}}}},
+				"turnComplete": false,
+			},
+		}); err != nil {
+			return err
+		}
+		return b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: event.Item})
+	case dto.RealtimeEventTypeResponseCreate:
+		return b.write(map[string]any{"clientContent": map[string]any{"turnComplete": true}})
+	case dto.RealtimeEventTypeResponseCancel:
+		// Gemini Live 在检测到新的用户输入时自动打断，无需额外处理
+		return nil
+	}
+	return nil
+}
+
+func (b *geminiLiveBackend) startResponse() error {
+	if b.responseId != "" {
+		return nil
+	}
+	b.responseId = b.s.newId("resp")
+	b.itemId = b.s.newId("item")
+	b.transcript.Reset()
+	b.text.Reset()
+	return b.s.send(&dto.RealtimeEvent{
+		Type:     dto.RealtimeEventResponseCreated,
+		Response: &dto.RealtimeResponse{Id: b.responseId, Object: "realtime.response", Status: "in_progress"},
+	})
+}
+
+func (b *geminiLiveBackend) finishResponse(status string) error {
+	if b.responseId == "" {
+		return nil
+	}
+	if b.s.audioOutput() {
+		_ = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDone, ResponseId: b.responseId, ItemId: b.itemId})
+		_ = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDone, ResponseId: b.responseId, ItemId: b.itemId, Transcript: b.transcript.String()})
+	} else {
+		_ = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDone, ResponseId: b.responseId, ItemId: b.itemId, Text: b.text.String()})
+	}
+	responseId := b.responseId
+	b.responseId = ""
+	return b.s.responseDone(responseId, status)
+}
+
+func (b *geminiLiveBackend) readUpstream() {
+	for {
+		_, data, err := b.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				b.s.fail(fmt.Errorf("error reading from gemini live: %v", err))
+			} else {
+				b.s.fail(fmt.Errorf("gemini live connection closed"))
+			}
+			return
+		}
+		var message geminiLiveServerMessage
+		if err = common.Unmarshal(data, &message); err != nil {
+			continue
+		}
+		if err = b.handleServerMessage(&message); err != nil {
+			b.s.fail(err)
+			return
+		}
+	}
+}
+
+func (b *geminiLiveBackend) handleServerMessage(message *geminiLiveServerMessage) error {
+	if message.GoAway != nil {
+		return fmt.Errorf("gemini live session is going away, time left %s", message.GoAway.TimeLeft)
+	}
+	// 工具调用转换为 response.function_call_arguments.done
+	if message.ToolCall != nil && len(message.ToolCall.FunctionCalls) > 0 {
+		if err := b.startResponse(); err != nil {
+			return err
+		}
+		for _, call := range message.ToolCall.FunctionCalls {
+			arguments, _ := common.Marshal(call.Args)
+			name := call.Name
+			b.s.addOutputText(string(arguments))
+			if err := b.s.send(&dto.RealtimeEvent{
+				Type:       dto.RealtimeEventResponseFunctionCallArgumentsDone,
+				ResponseId: b.responseId,
+				ItemId:     b.itemId,
+				Item:       &dto.RealtimeItem{Type: "function_call", CallId: call.Id, Name: &name},
+				Delta:      string(arguments),
+			}); err != nil {
+				return err
+			}
+		}
+		// 工具调用没有文本或音频输出，直接结束本轮回复
+		responseId := b.responseId
+		b.responseId = ""
+		return b.s.responseDone(responseId, "completed")
+	}
+	content := message.ServerContent
+	if content == nil {
+		return nil
+	}
+	if content.InputTranscription != nil && content.InputTranscription.Text != "" {
+		if err := b.s.send(&dto.RealtimeEvent{
+			Type:       dto.RealtimeEventInputAudioTranscriptionCompleted,
+			ItemId:     b.s.newId("item"),
+			Transcript: content.InputTranscription.Text,
+		}); err != nil {
+			return err
+		}
+	}
+	if content.ModelTurn != nil {
+		if err := b.startResponse(); err != nil {
+			return err
+		}
+		for _, part := range content.ModelTurn.Parts {
+			if part.InlineData != nil && part.InlineData.Data != "" {
+				b.s.addOutputAudio(part.InlineData.Data)
+				if err := b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDelta, ResponseId: b.responseId, ItemId: b.itemId, Delta: part.InlineData.Data}); err != nil {
+					return err
+				}
+			}
+			if part.Text != "" && !b.s.audioOutput() {
+				b.s.addOutputText(part.Text)
+				b.text.WriteString(part.Text)
+				if err := b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDelta, ResponseId: b.responseId, ItemId: b.itemId, Delta: part.Text}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if content.OutputTranscription != nil && content.OutputTranscription.Text != "" {
+		if err := b.startResponse(); err != nil {
+			return err
+		}
+		b.s.addOutputText(content.OutputTranscription.Text)
+		b.transcript.WriteString(content.OutputTranscription.Text)
+		if err := b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDelta, ResponseId: b.responseId, ItemId: b.itemId, Delta: content.OutputTranscription.Text}); err != nil {
+			return err
+		}
+	}
+	if content.Interrupted {
+		return b.finishResponse("cancelled")
+	}
+	if content.TurnComplete {
+		return b.finishResponse("completed")
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package realtime
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// geminiLiveUpstream 模拟 Gemini Live 上游：完成会话设置后转发收到的消息，并发送测试指定的服务端消息
+type geminiLiveUpstream struct {
+	*httptest.Server
+	setup    chan map[string]any
+	received chan map[string]any
+	send     chan string
+}
+
+func newGeminiLiveUpstream(t *testing.T) *geminiLiveUpstream {
+	upstream := &geminiLiveUpstream{
+		setup:    make(chan map[string]any, 1),
+		received: make(chan map[string]any, 16),
+		send:     make(chan string, 16),
+	}
+	upstream.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != geminiLivePath || r.URL.Query().Get("key") != "test-key" {
+			t.Errorf("unexpected upstream url %s", r.URL)
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		var setup map[string]any
+		if err = conn.ReadJSON(&setup); err != nil {
+			t.Error(err)
+			return
+		}
+		upstream.setup <- setup
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(`{"setupComplete":{}}`)); err != nil {
+			return
+		}
+		go func() {
+			for message := range upstream.send {
+				if conn.WriteMessage(websocket.TextMessage, []byte(message)) != nil {
+					return
+				}
+			}
+		}()
+		for {
+			var message map[string]any
+			if conn.ReadJSON(&message) != nil {
+				return
+			}
+			upstream.received <- message
+		}
+	}))
+	t.Cleanup(func() {
+		close(upstream.send)
+		upstream.Close()
+	})
+	return upstream
+}
+
+func (u *geminiLiveUpstream) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case message := <-u.received:
+		data, _ := common.Marshal(message)
+		return string(data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream message not received")
+		return ""
+	}
+}
+
+func TestGeminiLiveSession(t *testing.T) {
+	upstream := newGeminiLiveUpstream(t)
+	s, client := newTestSession(t, &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{ChannelBaseUrl: upstream.URL, ApiKey: "test-key", UpstreamModelName: "gemini-live-2.5-flash"}})
+	s.updateSession(&dto.RealtimeSession{
+		Instructions: "be brief",
+		Voice:        "Kore",
+		Tools:        []dto.RealTimeTool{{Type: "function", Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+	})
+	instructionTokens := s.turnUsage.InputTokenDetails.TextTokens
+	b := newGeminiLiveBackend(s)
+	defer b.close()
+
+	// 第一条事件建立上游连接，会话设置转换为 setup
+	err := b.handleClientEvent(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeConversationCreate, Item: &dto.RealtimeItem{Type: "message", Role: "user", Content: []dto.RealtimeContent{{Type: "input_text", Text: "hi"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup, _ := common.Marshal(<-upstream.setup)
+	wantSetup := `{"setup":{"generationConfig":{"responseModalities":["TEXT"],"speechConfig":{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":"Kore"}}}},"inputAudioTranscription":{},"model":"models/gemini-live-2.5-flash","systemInstruction":{"parts":[{"text":"be brief"}]},"tools":[{"functionDeclarations":[{"description":"","name":"get_weather","parameters":{"type":"object"}}]}]}}`
+	if string(setup) != wantSetup {
+		t.Errorf("setup:\n got %s\nwant %s", setup, wantSetup)
+	}
+	if got := upstream.next(t); got != `{"clientContent":{"turnComplete":false,"turns":[{"parts":[{"text":"hi"}],"role":"user"}]}}` {
+		t.Errorf("conversation item: %s", got)
+	}
+	if got := eventTypes(readEvents(t, client, 1)); got != "conversation.item.created" {
+		t.Errorf("item events: %s", got)
+	}
+	if s.turnUsage.InputTokenDetails.TextTokens <= instructionTokens {
+		t.Errorf("input text not charged: %+v", s.turnUsage)
+	}
+
+	clientEvents := []struct {
+		event *dto.RealtimeEvent
+		want  string
+	}{
+		{
+			event: &dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferAppend, Audio: "AAAA"},
+			want:  `{"realtimeInput":{"audio":{"data":"AAAA","mimeType":"audio/pcm;rate=24000"}}}`,
+		},
+		{
+			event: &dto.RealtimeEvent{Type: dto.RealtimeEventTypeConversationCreate, Item: &dto.RealtimeItem{Type: "function_call_output", CallId: "call_1", Output: "sunny"}},
+			want:  `{"toolResponse":{"functionResponses":[{"id":"call_1","response":{"output":"sunny"}}]}}`,
+		},
+		{
+			event: &dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseCreate},
+			want:  `{"clientContent":{"turnComplete":true}}`,
+		},
+	}
+	for _, tt := range clientEvents {
+		if err = b.handleClientEvent(tt.event); err != nil {
+			t.Fatal(err)
+		}
+		if got := upstream.next(t); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.event.Type, got, tt.want)
+		}
+	}
+
+	upstream.send <- `{"serverContent":{"modelTurn":{"parts":[{"text":"Hel"},{"text":"lo"}]}}}`
+	upstream.send <- `{"serverContent":{"turnComplete":true}}`
+	got := readEvents(t, client, 5)
+	if types := eventTypes(got); types != "response.created response.text.delta response.text.delta response.text.done response.done" {
+		t.Fatalf("response events: %s", types)
+	}
+	if got[3].Text != "Hello" || got[4].Response.Status != "completed" {
+		t.Errorf("text done %q, status %s", got[3].Text, got[4].Response.Status)
+	}
+	usage := got[4].Response.Usage
+	if usage.InputTokenDetails.TextTokens <= instructionTokens || usage.OutputTokenDetails.TextTokens == 0 || usage.InputTokenDetails.AudioTokens != 0 {
+		t.Errorf("usage: %+v", usage)
+	}
+}
+
+func TestGeminiLiveServerMessages(t *testing.T) {
+	pcm := base64.StdEncoding.EncodeToString(make([]byte, 4800))
+	tests := []struct {
+		name        string
+		audioOutput bool
+		messages    []string
+		want        string
+		wantStatus  string
+	}{
+		{
+			name:       "tool call",
+			messages:   []string{`{"toolCall":{"functionCalls":[{"id":"call_1","name":"get_weather","args":{"city":"Paris"}}]}}`},
+			want:       "response.created response.function_call_arguments.done response.done",
+			wantStatus: "completed",
+		},
+		{
+			name:        "audio with transcript",
+			audioOutput: true,
+			messages: []string{
+				`{"serverContent":{"inputTranscription":{"text":"hello"}}}`,
+				`{"serverContent":{"modelTurn":{"parts":[{"inlineData":{"mimeType":"audio/pcm;rate=24000","data":"` + pcm + `"}}]},"outputTranscription":{"text":"hi"}}}`,
+				`{"serverContent":{"turnComplete":true}}`,
+			},
+			want:       "conversation.item.input_audio_transcription.completed response.created response.audio.delta response.audio_transcript.delta response.audio.done response.audio_transcript.done response.done",
+			wantStatus: "completed",
+		},
+		// 音频模式下忽略模型输出的文本，只计费输出转写
+		{
+			name:        "text ignored in audio mode",
+			audioOutput: true,
+			messages: []string{
+				`{"serverContent":{"modelTurn":{"parts":[{"text":"thinking"}]}}}`,
+				`{"serverContent":{"interrupted":true}}`,
+			},
+			want:       "response.created response.audio.done response.audio_transcript.done response.done",
+			wantStatus: "cancelled",
+		},
+		{
+			name:     "turn complete without response",
+			messages: []string{`{"serverContent":{"turnComplete":true}}`, `{"setupComplete":{}}`},
+		},
+	}
+	for _, tt := range tests {
+		s, client := newTestSession(t, &relaycommon.RelayInfo{})
+		if tt.audioOutput {
+			s.config.Modalities = []string{"text", "audio"}
+		}
+		b := newGeminiLiveBackend(s)
+		for _, message := range tt.messages {
+			var serverMessage geminiLiveServerMessage
+			if err := common.Unmarshal([]byte(message), &serverMessage); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if err := b.handleServerMessage(&serverMessage); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if tt.want == "" {
+			if s.turnUsage.TotalTokens != 0 || b.responseId != "" {
+				t.Errorf("%s: unexpected response", tt.name)
+			}
+			continue
+		}
+		got := readEvents(t, client, len(strings.Fields(tt.want)))
+		if types := eventTypes(got); types != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, types, tt.want)
+			continue
+		}
+		done := got[len(got)-1].Response
+		if done.Status != tt.wantStatus || done.Usage.OutputTokens == 0 && tt.wantStatus == "completed" {
+			t.Errorf("%s: response done %+v usage %+v", tt.name, done, done.Usage)
+		}
+	}
+
+	// GoAway 结束会话
+	s, _ := newTestSession(t, &relaycommon.RelayInfo{})
+	if err := newGeminiLiveBackend(s).handleServerMessage(&geminiLiveServerMessage{GoAway: &struct {
+		TimeLeft string `json:"timeLeft"`
+	}{TimeLeft: "10s"}}); err == nil {
+		t.Errorf("go away should end the session")
+	}
+}
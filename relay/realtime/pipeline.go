@@ -0,0 +1,445 @@
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/service"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	defaultPipelineSttModel = "whisper-1"
+	defaultPipelineTtsModel = "tts-1"
+	defaultPipelineTtsVoice = "alloy"
+	// 每个 response.audio.delta 携带的音频字节数（pcm16 24kHz 约 0.5 秒）
+	pipelineAudioChunkSize = 24000
+)
+
+type pipelineChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Id       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage dto.Usage `json:"usage"`
+}
+
+// pipelineBackend 通过渠道的 OpenAI 兼容接口组合 语音识别 -> 对话 -> 语音合成，
+// 客户端需手动提交音频（input_audio_buffer.commit）并发送 response.create
+type pipelineBackend struct {
+	s      *session
+	client *http.Client
+	config dto.RealtimePipelineConfig
+
+	lock    sync.Mutex
+	audio   []byte
+	history []dto.Message
+	cancel  context.CancelFunc
+}
+
+func newPipelineBackend(s *session) *pipelineBackend {
+	b := &pipelineBackend{s: s}
+	if s.info.ChannelOtherSettings.RealtimePipeline != nil {
+		b.config = *s.info.ChannelOtherSettings.RealtimePipeline
+	}
+	if b.config.SttModel == "" {
+		b.config.SttModel = defaultPipelineSttModel
+	}
+	if b.config.TtsModel == "" {
+		b.config.TtsModel = defaultPipelineTtsModel
+	}
+	return b
+}
+
+func (b *pipelineBackend) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.cancel != nil {
+		b.cancel()
+	}
+}
+
+func (b *pipelineBackend) handleClientEvent(event *dto.RealtimeEvent) error {
+	switch event.Type {
+	case dto.RealtimeEventInputAudioBufferAppend:
+		if event.Audio == "" {
+			return nil
+		}
+		if b.s.config.InputAudioFormat != "pcm16" {
+			return fmt.Errorf("realtime pipeline only supports pcm16 input audio")
+		}
+		data, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil {
+			return fmt.Errorf("invalid input audio: %w", err)
+		}
+		b.s.addInputAudio(event.Audio)
+		b.lock.Lock()
+		b.audio = append(b.audio, data...)
+		b.lock.Unlock()
+		return nil
+	case dto.RealtimeEventInputAudioBufferCommit:
+		return b.commitAudio()
+	case dto.RealtimeEventInputAudioBufferClear:
+		b.lock.Lock()
+		b.audio = nil
+		b.lock.Unlock()
+		return b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventTypeConversationCreate:
+		if event.Item == nil {
+			return nil
+		}
+		b.addItem(event.Item)
+		return b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: event.Item})
+	case dto.RealtimeEventTypeResponseCreate:
+		b.lock.Lock()
+		if b.cancel != nil {
+			b.cancel()
+		}
+		ctx, cancel := context.WithCancel(b.s.c.Request.Context())
+		b.cancel = cancel
+		b.lock.Unlock()
+		gopool.Go(func() {
+			defer cancel()
+			if err := b.respond(ctx); err != nil && ctx.Err() == nil {
+				b.s.fail(err)
+			}
+		})
+		return nil
+	case dto.RealtimeEventTypeResponseCancel:
+		b.lock.Lock()
+		if b.cancel != nil {
+			b.cancel()
+		}
+		b.lock.Unlock()
+		return nil
+	}
+	return nil
+}
+
+func (b *pipelineBackend) addItem(item *dto.RealtimeItem) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch item.Type {
+	case "function_call_output":
+		b.history = append(b.history, dto.Message{Role: "tool", Content: item.Output, ToolCallId: item.CallId})
+	default:
+		text := itemText(item)
+		if text == "" {
+			return
+		}
+		role := item.Role
+		if role == "" {
+			role = "user"
+		}
+		b.history = append(b.history, dto.Message{Role: role, Content: text})
+	}
+}
+
+// commitAudio 识别已缓冲的音频并作为用户消息加入会话
+func (b *pipelineBackend) commitAudio() error {
+	b.lock.Lock()
+	audio := b.audio
+	b.audio = nil
+	b.lock.Unlock()
+	itemId := b.s.newId("item")
+	if err := b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: itemId}); err != nil {
+		return err
+	}
+	if len(audio) == 0 {
+		return nil
+	}
+	transcript, err := b.transcribe(b.s.c.Request.Context(), audio)
+	if err != nil {
+		return err
+	}
+	item := &dto.RealtimeItem{
+		Id:      itemId,
+		Type:    "message",
+		Status:  "completed",
+		Role:    "user",
+		Content: []dto.RealtimeContent{{Type: "input_audio", Transcript: transcript}},
+	}
+	b.lock.Lock()
+	b.history = append(b.history, dto.Message{Role: "user", Content: transcript})
+	b.lock.Unlock()
+	if err = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: item}); err != nil {
+		return err
+	}
+	return b.s.send(&dto.RealtimeEvent{
+		Type:       dto.RealtimeEventInputAudioTranscriptionCompleted,
+		ItemId:     itemId,
+		Transcript: transcript,
+	})
+}
+
+// respond 根据当前会话生成一轮回复，音频模式下再合成语音
+func (b *pipelineBackend) respond(ctx context.Context) error {
+	responseId := b.s.newId("resp")
+	itemId := b.s.newId("item")
+	if err := b.s.send(&dto.RealtimeEvent{
+		Type:     dto.RealtimeEventResponseCreated,
+		Response: &dto.RealtimeResponse{Id: responseId, Object: "realtime.response", Status: "in_progress"},
+	}); err != nil {
+		return err
+	}
+
+	chatResponse, err := b.chat(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return b.s.responseDone(responseId, "cancelled")
+		}
+		return err
+	}
+	b.s.info.SetFirstResponseTime()
+	// 会话条目与指令每轮都会作为历史发送给上游，文本用量以上游返回为准
+	b.s.addTextTokens(chatResponse.Usage.PromptTokens, chatResponse.Usage.CompletionTokens)
+	if len(chatResponse.Choices) == 0 {
+		return b.s.responseDone(responseId, "completed")
+	}
+	message := chatResponse.Choices[0].Message
+
+	if len(message.ToolCalls) > 0 {
+		toolCalls, _ := common.Marshal(message.ToolCalls)
+		b.lock.Lock()
+		b.history = append(b.history, dto.Message{Role: "assistant", Content: message.Content, ToolCalls: toolCalls})
+		b.lock.Unlock()
+		for _, call := range message.ToolCalls {
+			name := call.Function.Name
+			if err = b.s.send(&dto.RealtimeEvent{
+				Type:       dto.RealtimeEventResponseFunctionCallArgumentsDone,
+				ResponseId: responseId,
+				ItemId:     itemId,
+				Item:       &dto.RealtimeItem{Type: "function_call", CallId: call.Id, Name: &name},
+				Delta:      call.Function.Arguments,
+			}); err != nil {
+				return err
+			}
+		}
+		return b.s.responseDone(responseId, "completed")
+	}
+
+	b.lock.Lock()
+	b.history = append(b.history, dto.Message{Role: "assistant", Content: message.Content})
+	b.lock.Unlock()
+
+	if !b.s.audioOutput() || message.Content == "" {
+		if err = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDelta, ResponseId: responseId, ItemId: itemId, Delta: message.Content}); err != nil {
+			return err
+		}
+		if err = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDone, ResponseId: responseId, ItemId: itemId, Text: message.Content}); err != nil {
+			return err
+		}
+		return b.s.responseDone(responseId, "completed")
+	}
+
+	if err = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDelta, ResponseId: responseId, ItemId: itemId, Delta: message.Content}); err != nil {
+		return err
+	}
+	if err = b.speak(ctx, responseId, itemId, message.Content); err != nil {
+		if ctx.Err() != nil {
+			return b.s.responseDone(responseId, "cancelled")
+		}
+		return err
+	}
+	_ = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDone, ResponseId: responseId, ItemId: itemId})
+	_ = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDone, ResponseId: responseId, ItemId: itemId, Transcript: message.Content})
+	return b.s.responseDone(responseId, "completed")
+}
+
+func (b *pipelineBackend) transcribe(ctx context.Context, pcm []byte) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("model", b.config.SttModel)
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", err
+	}
+	if _, err = part.Write(pcm16ToWav(pcm, 24000)); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	data, err := b.post(ctx, "/v1/audio/transcriptions", writer.FormDataContentType(), body)
+	if err != nil {
+		return "", fmt.Errorf("transcribe audio failed: %w", err)
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err = common.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("transcribe audio failed: %w", err)
+	}
+	return result.Text, nil
+}
+
+func (b *pipelineBackend) chat(ctx context.Context) (*pipelineChatResponse, error) {
+	b.lock.Lock()
+	messages := make([]dto.Message, 0, len(b.history)+1)
+	if b.s.config.Instructions != "" {
+		messages = append(messages, dto.Message{Role: "system", Content: b.s.config.Instructions})
+	}
+	messages = append(messages, b.history...)
+	b.lock.Unlock()
+
+	request := map[string]any{
+		"model":    b.s.info.UpstreamModelName,
+		"messages": messages,
+		"stream":   false,
+	}
+	if b.s.config.Temperature != 0 {
+		request["temperature"] = b.s.config.Temperature
+	}
+	if len(b.s.config.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(b.s.config.Tools))
+		for _, tool := range b.s.config.Tools {
+			tools = append(tools, map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.Parameters,
+				},
+			})
+		}
+		request["tools"] = tools
+	}
+	requestBody, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.post(ctx, "/v1/chat/completions", "application/json", bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+	var chatResponse pipelineChatResponse
+	if err = common.Unmarshal(data, &chatResponse); err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+	return &chatResponse, nil
+}
+
+// speak 合成语音并按块发送 response.audio.delta，按输出音频时长计费
+func (b *pipelineBackend) speak(ctx context.Context, responseId string, itemId string, text string) error {
+	if b.s.config.OutputAudioFormat != "pcm16" {
+		return fmt.Errorf("realtime pipeline only supports pcm16 output audio")
+	}
+	voice := b.config.TtsVoice
+	if voice == "" {
+		voice = b.s.config.Voice
+	}
+	if voice == "" {
+		voice = defaultPipelineTtsVoice
+	}
+	requestBody, err := common.Marshal(map[string]any{
+		"model":           b.config.TtsModel,
+		"voice":           voice,
+		"input":           text,
+		"response_format": "pcm",
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(ctx, "/v1/audio/speech", "application/json", bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("speech synthesis failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, pipelineAudioChunkSize)
+	for {
+		n, readErr := io.ReadFull(resp.Body, buf)
+		if n > 0 {
+			audio := base64.StdEncoding.EncodeToString(buf[:n])
+			b.s.addOutputAudio(audio)
+			if err = b.s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDelta, ResponseId: responseId, ItemId: itemId, Delta: audio}); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("speech synthesis failed: %w", readErr)
+		}
+	}
+}
+
+func (b *pipelineBackend) post(ctx context.Context, path string, contentType string, body io.Reader) ([]byte, error) {
+	resp, err := b.do(ctx, path, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *pipelineBackend) do(ctx context.Context, path string, contentType string, body io.Reader) (*http.Response, error) {
+	if b.client == nil {
+		if b.s.info.ChannelSetting.Proxy != "" {
+			client, err := service.NewProxyHttpClient(b.s.info.ChannelSetting.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("new proxy http client failed: %w", err)
+			}
+			b.client = client
+		} else {
+			b.client = service.GetHttpClient()
+		}
+	}
+	baseUrl := strings.TrimRight(b.s.info.ChannelBaseUrl, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseUrl+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+b.s.info.ApiKey)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
+// pcm16ToWav 为 16 位单声道 PCM 数据添加 WAV 文件头
+func pcm16ToWav(pcm []byte, sampleRate int) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(pcm)))
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1)) // PCM
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1)) // 单声道
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate*2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
@@ -0,0 +1,175 @@
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pipelineUpstream 模拟渠道的语音识别、对话与语音合成接口
+type pipelineUpstream struct {
+	*httptest.Server
+	chatResponse string
+	speech       []byte
+
+	lock         sync.Mutex
+	chatRequests []map[string]any
+}
+
+func newPipelineUpstream(t *testing.T, chatResponse string) *pipelineUpstream {
+	upstream := &pipelineUpstream{chatResponse: chatResponse}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/audio/transcriptions", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("model") != defaultPipelineSttModel {
+			t.Errorf("unexpected stt model %q", r.FormValue("model"))
+		}
+		_, _ = io.WriteString(w, `{"text":"hello there"}`)
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		data, _ := io.ReadAll(r.Body)
+		if err := common.Unmarshal(data, &request); err != nil {
+			t.Error(err)
+		}
+		upstream.lock.Lock()
+		upstream.chatRequests = append(upstream.chatRequests, request)
+		upstream.lock.Unlock()
+		_, _ = io.WriteString(w, upstream.chatResponse)
+	})
+	mux.HandleFunc("/v1/audio/speech", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(upstream.speech)
+	})
+	upstream.Server = httptest.NewServer(mux)
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func newTestPipeline(t *testing.T, chatResponse string) (*pipelineBackend, *pipelineUpstream, *session, func(n int) []*dto.RealtimeEvent) {
+	upstream := newPipelineUpstream(t, chatResponse)
+	s, client := newTestSession(t, &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{ChannelBaseUrl: upstream.URL, UpstreamModelName: "gpt-4o"}})
+	s.upstreamTextUsage = true
+	b := newPipelineBackend(s)
+	b.client = upstream.Client()
+	return b, upstream, s, func(n int) []*dto.RealtimeEvent { return readEvents(t, client, n) }
+}
+
+func TestPipelineTextResponse(t *testing.T) {
+	b, upstream, s, read := newTestPipeline(t, `{"choices":[{"message":{"content":"hi, how can I help?"}}],"usage":{"prompt_tokens":20,"completion_tokens":5,"total_tokens":25}}`)
+	s.config.Instructions = "be brief"
+
+	events := []*dto.RealtimeEvent{
+		{Type: dto.RealtimeEventTypeConversationCreate, Item: &dto.RealtimeItem{Type: "message", Role: "user", Content: []dto.RealtimeContent{{Type: "input_text", Text: "hi"}}}},
+		{Type: dto.RealtimeEventTypeConversationCreate, Item: &dto.RealtimeItem{Type: "function_call_output", CallId: "call_1", Output: "sunny"}},
+	}
+	for _, event := range events {
+		if err := b.handleClientEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := eventTypes(read(2)); got != "conversation.item.created conversation.item.created" {
+		t.Errorf("item events: %s", got)
+	}
+	// 会话条目随每轮历史发送给上游，不在本地估算
+	if s.turnUsage.TotalTokens != 0 {
+		t.Errorf("items charged locally: %+v", s.turnUsage)
+	}
+
+	if err := b.respond(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := read(4)
+	if types := eventTypes(got); types != "response.created response.text.delta response.text.done response.done" {
+		t.Fatalf("response events: %s", types)
+	}
+	if got[2].Text != "hi, how can I help?" {
+		t.Errorf("text done: %q", got[2].Text)
+	}
+	usage := got[3].Response.Usage
+	if usage.InputTokens != 20 || usage.OutputTokens != 5 || usage.TotalTokens != 25 || usage.InputTokenDetails.TextTokens != 20 {
+		t.Errorf("usage should match upstream: %+v", usage)
+	}
+
+	if len(upstream.chatRequests) != 1 {
+		t.Fatalf("sent %d chat requests", len(upstream.chatRequests))
+	}
+	messages, _ := common.Marshal(upstream.chatRequests[0]["messages"])
+	want := `[{"content":"be brief","role":"system"},{"content":"hi","role":"user"},{"content":"sunny","role":"tool","tool_call_id":"call_1"}]`
+	if string(messages) != want {
+		t.Errorf("chat messages:\n got %s\nwant %s", messages, want)
+	}
+	if b.history[len(b.history)-1].Role != "assistant" {
+		t.Errorf("assistant reply not added to history")
+	}
+}
+
+func TestPipelineToolCallResponse(t *testing.T) {
+	b, _, _, read := newTestPipeline(t, `{"choices":[{"message":{"content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}],"usage":{"prompt_tokens":30,"completion_tokens":8}}`)
+	if err := b.respond(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := read(3)
+	if types := eventTypes(got); types != "response.created response.function_call_arguments.done response.done" {
+		t.Fatalf("response events: %s", types)
+	}
+	call := got[1]
+	if call.Item.CallId != "call_1" || *call.Item.Name != "get_weather" || call.Delta != `{"city":"Paris"}` {
+		t.Errorf("function call: %+v %s", call.Item, call.Delta)
+	}
+	if usage := got[2].Response.Usage; usage.InputTokens != 30 || usage.OutputTokens != 8 {
+		t.Errorf("usage: %+v", usage)
+	}
+}
+
+func TestPipelineAudioResponse(t *testing.T) {
+	b, upstream, s, read := newTestPipeline(t, `{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":12,"completion_tokens":2}}`)
+	s.config.Modalities = []string{"text", "audio"}
+	// 1.25 秒的合成语音，按 0.5 秒分块发送
+	upstream.speech = make([]byte, 60000)
+
+	// 0.2 秒输入音频
+	audio := base64.StdEncoding.EncodeToString(make([]byte, 9600))
+	if err := b.handleClientEvent(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferAppend, Audio: audio}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.handleClientEvent(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommit}); err != nil {
+		t.Fatal(err)
+	}
+	got := read(3)
+	if types := eventTypes(got); types != "input_audio_buffer.committed conversation.item.created conversation.item.input_audio_transcription.completed" {
+		t.Fatalf("commit events: %s", types)
+	}
+	if got[2].Transcript != "hello there" || got[1].Item.Content[0].Transcript != "hello there" || got[0].ItemId != got[2].ItemId {
+		t.Errorf("transcription events: %+v %+v", got[1].Item, got[2])
+	}
+
+	if err := b.respond(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got = read(8)
+	want := "response.created response.audio_transcript.delta response.audio.delta response.audio.delta response.audio.delta response.audio.done response.audio_transcript.done response.done"
+	if types := eventTypes(got); types != want {
+		t.Fatalf("response events:\n got %s\nwant %s", types, want)
+	}
+	usage := got[7].Response.Usage
+	if usage.InputTokenDetails.AudioTokens == 0 || usage.OutputTokenDetails.AudioTokens == 0 {
+		t.Errorf("audio not charged: %+v", usage)
+	}
+	if usage.InputTokenDetails.TextTokens != 12 || usage.OutputTokenDetails.TextTokens != 2 {
+		t.Errorf("text usage should match upstream: %+v", usage)
+	}
+	if s.inputSeconds != 0.2 || s.outputSeconds != 1.25 {
+		t.Errorf("audio seconds: input %v output %v", s.inputSeconds, s.outputSeconds)
+	}
+	messages, _ := common.Marshal(upstream.chatRequests[0]["messages"])
+	if !strings.Contains(string(messages), `"content":"hello there"`) {
+		t.Errorf("transcript not sent to chat: %s", messages)
+	}
+}
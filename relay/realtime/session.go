@@ -0,0 +1,297 @@
+package realtime
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// backend 将 OpenAI Realtime 客户端事件转换为上游协议
+type backend interface {
+	// handleClientEvent 处理一条客户端事件
+	handleClientEvent(event *dto.RealtimeEvent) error
+	close()
+}
+
+// session 面向客户端的 OpenAI Realtime 会话，负责事件发送与按音频时长计费
+type session struct {
+	c      *gin.Context
+	info   *relaycommon.RelayInfo
+	config dto.RealtimeSession
+
+	writeLock sync.Mutex
+	usageLock sync.Mutex
+	// 当前轮次尚未结算的用量与整个会话已结算的用量
+	turnUsage dto.RealtimeUsage
+	sumUsage  dto.RealtimeUsage
+	// 会话累计的输入、输出音频时长（秒）
+	inputSeconds  float64
+	outputSeconds float64
+	// 上游按轮次返回文本用量（pipeline 模式），此时不在本地估算输入文本
+	upstreamTextUsage bool
+
+	errChan chan error
+}
+
+// GetBackendMode 返回渠道需要转换的实时语音模式，为空时直连 OpenAI Realtime 协议
+func GetBackendMode(info *relaycommon.RelayInfo) string {
+	switch info.ChannelOtherSettings.RealtimeMode {
+	case dto.RealtimeModeGeminiLive, dto.RealtimeModePipeline:
+		return info.ChannelOtherSettings.RealtimeMode
+	}
+	if info.ApiType == constant.APITypeGemini {
+		return dto.RealtimeModeGeminiLive
+	}
+	return ""
+}
+
+// Handler 通过 Gemini Live 或 语音识别 -> 对话 -> 语音合成 组合实现 OpenAI Realtime 会话，返回会话用量与计费说明
+func Handler(c *gin.Context, info *relaycommon.RelayInfo, mode string) (*dto.RealtimeUsage, string, *types.NewAPIError) {
+	if info.ClientWs == nil {
+		return nil, "", types.NewError(fmt.Errorf("invalid websocket connection"), types.ErrorCodeBadResponse)
+	}
+	info.IsStream = true
+	s := &session{
+		c:    c,
+		info: info,
+		config: dto.RealtimeSession{
+			Modalities:        []string{"text", "audio"},
+			InputAudioFormat:  info.InputAudioFormat,
+			OutputAudioFormat: info.OutputAudioFormat,
+		},
+		errChan: make(chan error, 4),
+	}
+
+	var b backend
+	switch mode {
+	case dto.RealtimeModeGeminiLive:
+		b = newGeminiLiveBackend(s)
+	case dto.RealtimeModePipeline:
+		s.upstreamTextUsage = true
+		b = newPipelineBackend(s)
+	default:
+		return nil, "", types.NewError(fmt.Errorf("unsupported realtime mode: %s", mode), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+	}
+	defer b.close()
+
+	if err := s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionCreated, Session: &s.config}); err != nil {
+		return nil, "", types.NewError(err, types.ErrorCodeBadResponse)
+	}
+
+	clientClosed := make(chan struct{})
+	gopool.Go(func() {
+		defer close(clientClosed)
+		for {
+			_, message, err := info.ClientWs.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					s.fail(fmt.Errorf("error reading from client: %v", err))
+				}
+				return
+			}
+			event := &dto.RealtimeEvent{}
+			if err = common.Unmarshal(message, event); err != nil {
+				s.sendError("invalid_event", "invalid event: "+err.Error())
+				continue
+			}
+			if event.Type == dto.RealtimeEventTypeSessionUpdate {
+				if event.Session != nil {
+					s.updateSession(event.Session)
+				}
+				_ = s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdated, Session: &s.config})
+				continue
+			}
+			if err = b.handleClientEvent(event); err != nil {
+				s.fail(err)
+				return
+			}
+		}
+	})
+
+	select {
+	case <-clientClosed:
+	case err := <-s.errChan:
+		logger.LogError(c, "realtime error: "+err.Error())
+		s.sendError("upstream_error", err.Error())
+	case <-c.Done():
+	}
+
+	// 结算最后一轮未结算的用量
+	_, _ = s.settle()
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	sumUsage := s.sumUsage
+	return &sumUsage, fmt.Sprintf("输入音频 %.1f 秒，输出音频 %.1f 秒", s.inputSeconds, s.outputSeconds), nil
+}
+
+func (s *session) updateSession(update *dto.RealtimeSession) {
+	if len(update.Modalities) > 0 {
+		s.config.Modalities = update.Modalities
+	}
+	if update.Instructions != "" {
+		s.config.Instructions = update.Instructions
+		s.addInputText(update.Instructions)
+	}
+	if update.Voice != "" {
+		s.config.Voice = update.Voice
+	}
+	if update.Temperature != 0 {
+		s.config.Temperature = update.Temperature
+	}
+	if update.InputAudioTranscription.Model != "" {
+		s.config.InputAudioTranscription = update.InputAudioTranscription
+	}
+	if update.Tools != nil {
+		s.config.Tools = update.Tools
+		s.info.RealtimeTools = update.Tools
+	}
+	s.config.TurnDetection = update.TurnDetection
+}
+
+func (s *session) audioOutput() bool {
+	for _, modality := range s.config.Modalities {
+		if modality == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) newId(prefix string) string {
+	return prefix + "_" + common.GetRandomString(20)
+}
+
+func (s *session) send(event *dto.RealtimeEvent) error {
+	if event.EventId == "" {
+		event.EventId = s.newId("event")
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return helper.WssObject(s.c, s.info.ClientWs, event)
+}
+
+func (s *session) sendError(code string, message string) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	helper.WssError(s.c, s.info.ClientWs, types.OpenAIError{
+		Message: message,
+		Type:    "invalid_request_error",
+		Code:    code,
+	})
+}
+
+// fail 通知会话结束
+func (s *session) fail(err error) {
+	select {
+	case s.errChan <- err:
+	default:
+	}
+}
+
+// addInputAudio 按输入音频时长计费
+func (s *session) addInputAudio(audio string) {
+	seconds, _ := service.GetAudioDuration(audio, s.config.InputAudioFormat)
+	tokens, _ := service.CountAudioTokenInput(audio, s.config.InputAudioFormat)
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	s.inputSeconds += seconds
+	s.turnUsage.InputTokens += tokens
+	s.turnUsage.TotalTokens += tokens
+	s.turnUsage.InputTokenDetails.AudioTokens += tokens
+}
+
+// addOutputAudio 按输出音频时长计费
+func (s *session) addOutputAudio(audio string) {
+	seconds, _ := service.GetAudioDuration(audio, s.config.OutputAudioFormat)
+	tokens, _ := service.CountAudioTokenOutput(audio, s.config.OutputAudioFormat)
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	s.outputSeconds += seconds
+	s.turnUsage.OutputTokens += tokens
+	s.turnUsage.TotalTokens += tokens
+	s.turnUsage.OutputTokenDetails.AudioTokens += tokens
+}
+
+// addInputText 本地估算输入文本用量，上游返回文本用量时不重复计费
+func (s *session) addInputText(text string) {
+	if s.upstreamTextUsage {
+		return
+	}
+	s.addTextTokens(service.CountTextToken(text, s.info.UpstreamModelName), 0)
+}
+
+func (s *session) addOutputText(text string) {
+	s.addTextTokens(0, service.CountTextToken(text, s.info.UpstreamModelName))
+}
+
+func (s *session) addTextTokens(inputTokens int, outputTokens int) {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	s.turnUsage.InputTokens += inputTokens
+	s.turnUsage.OutputTokens += outputTokens
+	s.turnUsage.TotalTokens += inputTokens + outputTokens
+	s.turnUsage.InputTokenDetails.TextTokens += inputTokens
+	s.turnUsage.OutputTokenDetails.TextTokens += outputTokens
+}
+
+// settle 结算当前轮次的用量，额度不足时返回错误
+func (s *session) settle() (dto.RealtimeUsage, error) {
+	s.usageLock.Lock()
+	usage := s.turnUsage
+	s.turnUsage = dto.RealtimeUsage{}
+	s.sumUsage.TotalTokens += usage.TotalTokens
+	s.sumUsage.InputTokens += usage.InputTokens
+	s.sumUsage.OutputTokens += usage.OutputTokens
+	s.sumUsage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
+	s.sumUsage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
+	s.sumUsage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
+	s.sumUsage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	s.usageLock.Unlock()
+	if usage.TotalTokens == 0 {
+		return usage, nil
+	}
+	return usage, service.PreWssConsumeQuota(s.c, s.info, &usage)
+}
+
+// responseDone 结算本轮用量并发送 response.done
+func (s *session) responseDone(responseId string, status string) error {
+	usage, err := s.settle()
+	if err != nil {
+		return fmt.Errorf("error consume usage: %v", err)
+	}
+	return s.send(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeResponseDone,
+		Response: &dto.RealtimeResponse{
+			Id:     responseId,
+			Object: "realtime.response",
+			Status: status,
+			Usage:  &usage,
+		},
+	})
+}
+
+// itemText 拼接会话条目中的文本内容
+func itemText(item *dto.RealtimeItem) string {
+	var text strings.Builder
+	for _, content := range item.Content {
+		switch content.Type {
+		case "input_text", "text":
+			text.WriteString(content.Text)
+		case "input_audio", "audio":
+			text.WriteString(content.Transcript)
+		}
+	}
+	return text.String()
+}
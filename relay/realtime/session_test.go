@@ -0,0 +1,111 @@
+package realtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// newTestSession 创建连接到测试客户端的会话，返回的客户端连接用于读取会话发送的事件。
+// UsePrice 跳过每轮结算时的额度检查
+func newTestSession(t *testing.T, info *relaycommon.RelayInfo) (*session, *websocket.Conn) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	service.InitTokenEncoders()
+	serverConn := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		serverConn <- conn
+	}))
+	t.Cleanup(server.Close)
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	info.ClientWs = <-serverConn
+	info.UsePrice = true
+	if info.ChannelMeta == nil {
+		info.ChannelMeta = &relaycommon.ChannelMeta{}
+	}
+	if info.UpstreamModelName == "" {
+		info.UpstreamModelName = "gpt-4o"
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/realtime", nil)
+	s := &session{
+		c:    c,
+		info: info,
+		config: dto.RealtimeSession{
+			Modalities:        []string{"text"},
+			InputAudioFormat:  "pcm16",
+			OutputAudioFormat: "pcm16",
+		},
+		errChan: make(chan error, 4),
+	}
+	return s, client
+}
+
+// readEvents 读取客户端收到的 n 条事件
+func readEvents(t *testing.T, client *websocket.Conn, n int) []*dto.RealtimeEvent {
+	t.Helper()
+	events := make([]*dto.RealtimeEvent, 0, n)
+	for i := 0; i < n; i++ {
+		_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("read event %d: %v", i, err)
+		}
+		event := &dto.RealtimeEvent{}
+		if err = common.Unmarshal(data, event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func eventTypes(events []*dto.RealtimeEvent) string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return strings.Join(types, " ")
+}
+
+func TestSessionUpdateInstructionsUsage(t *testing.T) {
+	tests := []struct {
+		name              string
+		upstreamTextUsage bool
+		wantCharged       bool
+	}{
+		{name: "local estimate", wantCharged: true},
+		// pipeline 模式每轮把指令作为 system 消息发送，由上游用量计费
+		{name: "upstream usage", upstreamTextUsage: true},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t, &relaycommon.RelayInfo{})
+		s.upstreamTextUsage = tt.upstreamTextUsage
+		s.updateSession(&dto.RealtimeSession{Instructions: "you are a helpful assistant", Modalities: []string{"text", "audio"}})
+		if s.config.Instructions != "you are a helpful assistant" || !s.audioOutput() {
+			t.Errorf("%s: session not updated: %+v", tt.name, s.config)
+		}
+		if charged := s.turnUsage.InputTokenDetails.TextTokens > 0; charged != tt.wantCharged {
+			t.Errorf("%s: instructions charged %d tokens", tt.name, s.turnUsage.InputTokenDetails.TextTokens)
+		}
+	}
+}
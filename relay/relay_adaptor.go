@@ -25,6 +25,7 @@ import (
 	"one-api/relay/channel/palm"
 	"one-api/relay/channel/perplexity"
 	"one-api/relay/channel/siliconflow"
+	taskcustom "one-api/relay/channel/task/custom"
 	taskjimeng "one-api/relay/channel/task/jimeng"
 	"one-api/relay/channel/task/kling"
 	"one-api/relay/channel/task/suno"
@@ -40,6 +41,10 @@ import (
 )
 
 func GetAdaptor(apiType int) channel.Adaptor {
+	return channel.WithTracing(getAdaptor(apiType))
+}
+
+func getAdaptor(apiType int) channel.Adaptor {
 	switch apiType {
 	case constant.APITypeAli:
 		return &ali.Adaptor{}
@@ -128,6 +133,8 @@ func GetTaskAdaptor(platform constant.TaskPlatform) channel.TaskAdaptor {
 			return &taskjimeng.TaskAdaptor{}
 		case constant.ChannelTypeVidu:
 			return &taskVidu.TaskAdaptor{}
+		case constant.ChannelTypeCustomTask:
+			return &taskcustom.TaskAdaptor{}
 		}
 	}
 	return nil
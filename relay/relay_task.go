@@ -13,8 +13,10 @@ import (
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/ratio_setting"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -43,6 +45,10 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	if taskErr != nil {
 		return
 	}
+	callbackUrl, err := service.GetTaskCallbackUrl(c)
+	if err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest)
+	}
 
 	modelName := info.OriginModelName
 	if modelName == "" {
@@ -57,6 +63,10 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 			modelPrice = defaultPrice
 		}
 	}
+	pricingRule := helper.GetPricingRuleInfo(modelName, info.UsingGroup)
+	if pricingRule.Id != "" {
+		modelPrice *= pricingRule.Multiplier
+	}
 
 	// 预扣
 	groupRatio := ratio_setting.GetGroupRatio(info.UsingGroup)
@@ -67,7 +77,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	} else {
 		ratio = modelPrice * groupRatio
 	}
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	userQuota, err := model.GetPayerQuota(info.UserId, info.OrganizationId)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
@@ -146,6 +156,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 				if hasUserGroupRatio {
 					other["user_group_ratio"] = userGroupRatio
 				}
+				service.AppendPricingRuleInfo(other, pricingRule)
 				model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
 					ChannelId: info.ChannelId,
 					ModelName: modelName,
@@ -173,6 +184,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	task.Quota = quota
 	task.Data = taskData
 	task.Action = info.Action
+	task.CallbackUrl = callbackUrl
 	err = task.Insert()
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
@@ -284,7 +296,7 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 }
 
 func TaskModel2Dto(task *model.Task) *dto.TaskDto {
-	return &dto.TaskDto{
+	taskDto := &dto.TaskDto{
 		TaskID:     task.TaskID,
 		Action:     task.Action,
 		Status:     string(task.Status),
@@ -295,4 +307,17 @@ func TaskModel2Dto(task *model.Task) *dto.TaskDto {
 		Progress:   task.Progress,
 		Data:       task.Data,
 	}
+	// 视频结果已转存时返回网关签名链接
+	if task.Status == model.TaskStatusSuccess && task.Platform != constant.TaskPlatformSuno {
+		sourceId := strconv.FormatInt(task.ID, 10)
+		if task.Platform == constant.TaskPlatformAsync {
+			// 异步任务的非 JSON 输出总是保存在资源存储中
+			if asset, err := model.GetAssetBySource(model.AssetSourceTask, sourceId); err == nil {
+				taskDto.FailReason = service.GetAssetSignedUrl(asset)
+			}
+		} else if assetUrl := service.GetSourceAssetUrl(model.AssetSourceTask, sourceId); assetUrl != "" {
+			taskDto.FailReason = assetUrl
+		}
+	}
+	return taskDto
 }
@@ -0,0 +1,87 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseCacheHelper 查找响应缓存，命中时直接回放响应并按命中倍率计费，返回是否已处理请求；
+// 未命中时记录缓存 key，由 TextHelper 在请求成功后写入缓存
+func ResponseCacheHelper(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return false
+	}
+	request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return false
+	}
+	key, ok := service.GetResponseCacheKey(c, request)
+	if !ok {
+		return false
+	}
+	common.SetContextKey(c, constant.ContextKeyResponseCacheKey, key)
+	// 客户端要求不使用缓存时仍然写入新的响应
+	if c.GetHeader("Cache-Control") == "no-cache" {
+		return false
+	}
+	entry := service.GetResponseCache(key)
+	if entry == nil {
+		return false
+	}
+
+	hitRatio := operation_setting.GetResponseCacheSetting().HitBillingRatio
+	common.SetContextKey(c, constant.ContextKeyResponseCacheHit, true)
+	common.SetContextKey(c, constant.ContextKeyResponseCacheRatio, hitRatio)
+	common.SetContextKey(c, constant.ContextKeyResponseCacheKey, "")
+
+	// 命中缓存时不经过任何渠道
+	info.ChannelMeta = &relaycommon.ChannelMeta{}
+	info.IsStream = entry.IsStream
+	info.SetFirstResponseTime()
+	info.PriceData.GroupRatioInfo.GroupRatio *= hitRatio
+
+	if entry.IsStream {
+		helper.SetEventStreamHeaders(c)
+	} else if entry.ContentType != "" {
+		c.Writer.Header().Set("Content-Type", entry.ContentType)
+	}
+	c.Writer.Header().Set("X-Response-Cache", "HIT")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, err := c.Writer.Write(entry.Body)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write cached response: %s", err.Error()))
+	}
+	if entry.IsStream {
+		_ = helper.FlushWriter(c)
+	}
+
+	usage := entry.Usage
+	postConsumeQuota(c, info, &usage, "响应缓存命中")
+	return true
+}
+
+// saveResponseCache 请求成功后写入响应缓存
+func saveResponseCache(c *gin.Context, info *relaycommon.RelayInfo, capture *service.ResponseCaptureWriter, usage *dto.Usage) {
+	key := common.GetContextKeyString(c, constant.ContextKeyResponseCacheKey)
+	if key == "" {
+		return
+	}
+	entry := capture.Entry(info.IsStream, usage)
+	if entry == nil {
+		return
+	}
+	if err := service.SetResponseCache(key, entry); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to save response cache: %s", err.Error()))
+	}
+}
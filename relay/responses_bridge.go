@@ -0,0 +1,252 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 不支持 /v1/responses 的渠道：请求转换为 Chat Completions 后交给渠道适配器处理，
+// 适配器输出的 Chat Completions 响应再由 responsesBridgeWriter 转换回 Responses 格式
+
+// supportsNativeResponses 渠道是否可以直接处理 Responses 请求
+func supportsNativeResponses(info *relaycommon.RelayInfo) bool {
+	if info.ChannelSetting.ResponsesToChatCompletions {
+		return false
+	}
+	return info.ApiType == constant.APITypeOpenAI || info.ApiType == constant.APITypeCloudflare
+}
+
+// responsesBridgeWriter 替换 c.Writer，将写出的 Chat Completions 响应转换为 Responses 格式
+type responsesBridgeWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	request   *dto.OpenAIResponsesRequest
+	id        string
+	modelName string
+	createdAt int64
+	isStream  bool
+	converter *service.ResponsesStreamConverter
+	buf       bytes.Buffer
+	status    int
+}
+
+func startResponsesBridge(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) *responsesBridgeWriter {
+	w := &responsesBridgeWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		request:        request,
+		id:             fmt.Sprintf("resp_%s", c.GetString(common.RequestIdKey)),
+		modelName:      info.OriginModelName,
+		createdAt:      time.Now().Unix(),
+		isStream:       info.IsStream,
+		status:         http.StatusOK,
+	}
+	if w.isStream {
+		helper.SetEventStreamHeaders(c)
+		w.converter = service.NewResponsesStreamConverter(w.id, w.modelName, w.createdAt, request)
+		w.writeEvents(w.converter.Start())
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *responsesBridgeWriter) writeEvents(events []dto.ResponsesStreamResponse) {
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			logger.LogError(w.c, "failed to marshal responses stream event: "+err.Error())
+			continue
+		}
+		_, _ = w.ResponseWriter.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data))
+	}
+	w.ResponseWriter.Flush()
+}
+
+// processLines 处理缓冲区中已完整的 SSE 行
+func (w *responsesBridgeWriter) processLines() {
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// 行不完整，放回缓冲区等待后续数据
+			remaining := line + w.buf.String()
+			w.buf.Reset()
+			w.buf.WriteString(remaining)
+			return
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			logger.LogError(w.c, "failed to unmarshal chat completions chunk: "+err.Error())
+			continue
+		}
+		w.writeEvents(w.converter.Convert(&chunk))
+	}
+}
+
+func (w *responsesBridgeWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	if w.isStream {
+		w.processLines()
+	}
+	return len(data), nil
+}
+
+func (w *responsesBridgeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responsesBridgeWriter) WriteHeader(code int) {
+	if w.isStream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// 非流式响应在转换完成后再写出
+	w.status = code
+}
+
+func (w *responsesBridgeWriter) WriteHeaderNow() {
+	if w.isStream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *responsesBridgeWriter) Flush() {
+	if w.isStream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Stop 恢复原始的 Writer
+func (w *responsesBridgeWriter) Stop() {
+	w.c.Writer = w.ResponseWriter
+}
+
+// Finish 写出转换后的响应，流式响应以 response.completed 事件结束
+func (w *responsesBridgeWriter) Finish(usage *dto.Usage) *types.NewAPIError {
+	w.Stop()
+	if w.isStream {
+		w.buf.WriteString("\n")
+		w.processLines()
+		w.writeEvents(w.converter.Finish(usage))
+		return nil
+	}
+	var openAIResponse dto.OpenAITextResponse
+	if err := common.Unmarshal(w.buf.Bytes(), &openAIResponse); err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	response := service.ResponseOpenAI2Responses(&openAIResponse, w.request, w.id, w.modelName, w.createdAt, usage)
+	body, err := common.Marshal(response)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	w.c.Writer.Header().Del("Content-Length")
+	w.c.Writer.Header().Set("Content-Type", "application/json")
+	w.c.Data(w.status, "application/json", body)
+	return nil
+}
+
+// responsesViaChatCompletions 通过 Chat Completions 格式完成 Responses 请求
+func responsesViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) *types.NewAPIError {
+	if request.PreviousResponseID != "" {
+		return types.NewErrorWithStatusCode(fmt.Errorf("previous_response_id is not supported by this channel"), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	chatRequest, err := service.ResponsesToOpenAIRequest(*request)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	chatRequest.Model = info.UpstreamModelName
+	if info.SupportStreamOptions && chatRequest.Stream {
+		chatRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	// 将引用的本地文件转换为当前渠道可用的形式
+	if err = service.ResolveRequestFiles(info, chatRequest); err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeConvertRequestFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	// 适配器按 Chat Completions 处理，结束后恢复，避免影响重试到其他渠道
+	relayMode, relayFormat, requestURLPath := info.RelayMode, info.RelayFormat, info.RequestURLPath
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RelayFormat = types.RelayFormatOpenAI
+	info.RequestURLPath = "/v1/chat/completions"
+	defer func() {
+		info.RelayMode, info.RelayFormat, info.RequestURLPath = relayMode, relayFormat, requestURLPath
+	}()
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
+	}
+	adaptor.Init(info)
+
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, chatRequest)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeConvertRequestFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	// apply param override
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+		}
+	}
+	if common.DebugEnabled {
+		println("requestBody: ", string(jsonData))
+	}
+
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		if httpResp.StatusCode != http.StatusOK {
+			newAPIError := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+			// reset status code 重置状态码
+			service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+			return newAPIError
+		}
+	}
+
+	bridge := startResponsesBridge(c, info, request)
+	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	if newAPIError != nil {
+		bridge.Stop()
+		// reset status code 重置状态码
+		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+		return newAPIError
+	}
+	if newAPIError = bridge.Finish(usage.(*dto.Usage)); newAPIError != nil {
+		return newAPIError
+	}
+
+	postConsumeQuota(c, info, usage.(*dto.Usage), "")
+	return nil
+}
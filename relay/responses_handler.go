@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -35,13 +36,38 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	// previous_response_id 引用网关保存的响应时，在本地展开为完整历史
+	expanded, err := service.ResolvePreviousResponse(info, request)
+	if err != nil {
+		if errors.Is(err, service.ErrStoredResponseUnavailable) {
+			// 存储出错与渠道无关，不计入熔断，也不切换渠道重试
+			return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if service.ShouldStoreResponse(responsesReq) {
+		storeWriter := service.StartResponsesStore(c, info.IsStream)
+		defer func() {
+			storeWriter.Stop(c)
+			if newAPIError == nil {
+				service.SaveStoredResponse(c, info, responsesReq, storeWriter)
+			}
+		}()
+	}
+
+	if !supportsNativeResponses(info) {
+		return responsesViaChatCompletions(c, info, request)
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
+	// 展开历史后原始请求体中的 previous_response_id 上游无法识别，必须使用展开后的请求
+	passThrough := model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled
+	if passThrough && !expanded {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/relay/realtime"
 	"one-api/service"
 	"one-api/types"
 
@@ -14,6 +16,19 @@ import (
 func WssHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types.NewAPIError) {
 	info.InitChannelMeta(c)
 
+	// 非 OpenAI Realtime 上游由网关转换协议并按音频时长计费
+	if mode := realtime.GetBackendMode(info); mode != "" {
+		if err := helper.ModelMappedHelper(c, info, nil); err != nil {
+			return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+		}
+		usage, extraContent, apiErr := realtime.Handler(c, info, mode)
+		if apiErr != nil {
+			return apiErr
+		}
+		service.PostWssConsumeQuota(c, info, info.UpstreamModelName, usage, extraContent)
+		return nil
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
@@ -66,6 +66,7 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/amount", controller.RequestAmount)
 				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
+				selfRoute.POST("/subscription/pay", middleware.CriticalRateLimit(), controller.RequestStripeSubscription)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
 
@@ -135,6 +136,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/tag/models", controller.GetTagModels)
 			channelRoute.POST("/copy/:id", controller.CopyChannel)
 			channelRoute.POST("/multi_key/manage", controller.ManageMultiKeys)
+			channelRoute.GET("/breaker", controller.GetChannelBreakers)
+			channelRoute.POST("/breaker/reset/:id", controller.ResetChannelBreaker)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -148,6 +151,28 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
 		}
 
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/", middleware.AdminAuth(), controller.GetAllOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.GET("/self", controller.GetSelfOrganizations)
+			organizationRoute.GET("/invitations", controller.GetSelfOrganizationInvitations)
+			organizationRoute.POST("/:id/accept", controller.AcceptOrganizationInvitation)
+			organizationRoute.POST("/:id/decline", controller.DeclineOrganizationInvitation)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/members", controller.AddOrganizationMember)
+			organizationRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.GET("/:id/tokens", controller.GetOrganizationTokens)
+			organizationRoute.GET("/:id/logs", controller.GetOrganizationLogs)
+			organizationRoute.GET("/:id/quota_data", controller.GetOrganizationQuotaData)
+			organizationRoute.POST("/:id/recharge", middleware.AdminAuth(), controller.AdminRechargeOrganization)
+		}
+
 		usageRoute := apiRouter.Group("/usage")
 		usageRoute.Use(middleware.CriticalRateLimit())
 		{
@@ -173,10 +198,13 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/cost_report", middleware.AdminAuth(), controller.GetCostReport)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		logRoute.GET("/capture/:request_id", middleware.AdminAuth(), controller.GetPayloadCapture)
+		logRoute.GET("/self/capture/:request_id", middleware.UserAuth(), controller.GetSelfPayloadCapture)
 
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
@@ -209,6 +237,42 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/webhook", middleware.AdminAuth(), controller.GetTaskWebhookDeliveries)
+			taskRoute.POST("/webhook/:id/redeliver", middleware.AdminAuth(), controller.RedeliverTaskWebhook)
+			taskRoute.POST("/:id/refund", middleware.AdminAuth(), controller.ForceRefundTask)
+			taskRoute.POST("/:id/complete", middleware.AdminAuth(), controller.ForceCompleteTask)
+		}
+
+		assetRoute := apiRouter.Group("/asset")
+		{
+			assetRoute.GET("/self", middleware.UserAuth(), controller.GetUserAssets)
+			assetRoute.GET("/", middleware.AdminAuth(), controller.GetAllAssets)
+		}
+
+		planRoute := apiRouter.Group("/plan")
+		{
+			planRoute.GET("/", middleware.UserAuth(), controller.GetPlans)
+			planRoute.GET("/all", middleware.AdminAuth(), controller.GetAllPlans)
+			planRoute.POST("/", middleware.AdminAuth(), controller.AddPlan)
+			planRoute.PUT("/", middleware.AdminAuth(), controller.UpdatePlan)
+			planRoute.DELETE("/:id", middleware.AdminAuth(), controller.DeletePlan)
+		}
+
+		subscriptionRoute := apiRouter.Group("/subscription")
+		{
+			subscriptionRoute.GET("/self", middleware.UserAuth(), controller.GetSelfSubscriptions)
+			subscriptionRoute.GET("/", middleware.AdminAuth(), controller.GetAllSubscriptions)
+			subscriptionRoute.POST("/", middleware.AdminAuth(), controller.AdminGrantSubscription)
+			subscriptionRoute.POST("/:id/expire", middleware.AdminAuth(), controller.AdminExpireSubscription)
+		}
+
+		statementRoute := apiRouter.Group("/statement")
+		{
+			statementRoute.GET("/self", middleware.UserAuth(), controller.GetUserBillingStatements)
+			statementRoute.GET("/self/:id/download", middleware.UserAuth(), controller.DownloadSelfBillingStatement)
+			statementRoute.GET("/", middleware.AdminAuth(), controller.GetAllBillingStatements)
+			statementRoute.GET("/:id/download", middleware.AdminAuth(), controller.DownloadBillingStatement)
+			statementRoute.POST("/regenerate", middleware.AdminAuth(), controller.RegenerateBillingStatement)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
+	"one-api/controller"
 	"os"
 	"strings"
 
@@ -15,6 +17,9 @@ func SetRouter(router *gin.Engine) {
 	SetDashboardRouter(router)
 	SetRelayRouter(router)
 	SetVideoRouter(router)
+	if constant.MetricsEnabled {
+		router.GET("/metrics", controller.Metrics)
+	}
 	frontendBaseUrl := os.Getenv("FRONTEND_BASE_URL")
 	if common.IsMasterNode && frontendBaseUrl != "" {
 		frontendBaseUrl = ""
@@ -61,25 +61,60 @@ func SetRelayRouter(router *gin.Engine) {
 		playgroundRouter.POST("/chat/completions", controller.Playground)
 	}
 	relayV1Router := router.Group("/v1")
+	relayV1Router.Use(middleware.Tracing())
 	relayV1Router.Use(middleware.TokenAuth())
 	relayV1Router.Use(middleware.ModelRequestRateLimit())
 	{
 		// WebSocket 路由（统一到 Relay）
 		wsRouter := relayV1Router.Group("")
+		wsRouter.Use(middleware.TokenRateLimit())
 		wsRouter.Use(middleware.Distribute())
 		wsRouter.GET("/realtime", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// files、batches 等管理接口不需要选择渠道，也不计入令牌限流，batch 与异步任务中的请求在执行时单独限流
+		filesRouter := relayV1Router.Group("/files")
+		filesRouter.GET("", controller.ListFiles)
+		filesRouter.POST("", controller.UploadFile)
+		filesRouter.GET("/:id", controller.RetrieveFile)
+		filesRouter.DELETE("/:id", controller.DeleteFile)
+		filesRouter.GET("/:id/content", controller.RetrieveFileContent)
+
+		// batches 路由，请求在后台执行
+		batchesRouter := relayV1Router.Group("/batches")
+		batchesRouter.POST("", controller.CreateBatch)
+		batchesRouter.GET("", controller.ListBatches)
+		batchesRouter.GET("/:id", controller.RetrieveBatch)
+		batchesRouter.POST("/:id/cancel", controller.CancelBatch)
+
+		// 网关保存的 responses
+		responsesRouter := relayV1Router.Group("/responses")
+		responsesRouter.GET("/:id", controller.RetrieveResponse)
+		responsesRouter.DELETE("/:id", controller.DeleteResponse)
+		responsesRouter.GET("/:id/input_items", controller.ListResponseInputItems)
+
+		// 统一的异步任务接口，请求在后台执行
+		tasksRouter := relayV1Router.Group("/tasks")
+		tasksRouter.POST("", controller.CreateAsyncTask)
+		tasksRouter.GET("/:id", controller.GetAsyncTask)
+		tasksRouter.DELETE("/:id", controller.CancelAsyncTask)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
+		// token 计数请求跳过 TokenRateLimit 与熔断探测，只受 CountTokensRateLimit 限制
+		httpRouter.Use(middleware.TokenRateLimit())
+		httpRouter.Use(middleware.CountTokensRateLimit())
+		httpRouter.Use(middleware.PayloadCapture())
 		httpRouter.Use(middleware.Distribute())
 
 		// claude related routes
 		httpRouter.POST("/messages", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatClaude)
 		})
+		httpRouter.POST("/messages/count_tokens", controller.CountClaudeTokens)
 
 		// chat related routes
 		httpRouter.POST("/completions", func(c *gin.Context) {
@@ -131,6 +166,10 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 		httpRouter.POST("/models/*path", func(c *gin.Context) {
+			if middleware.IsCountTokensRequest(c) {
+				controller.CountGeminiTokens(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 
@@ -141,11 +180,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
@@ -154,6 +188,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
 	}
 
+	// 转存资源的签名链接，无需令牌
+	router.GET("/asset/:id", controller.GetAssetContent)
+
 	relayMjRouter := router.Group("/mj")
 	registerMjRouterGroup(relayMjRouter)
 
@@ -162,7 +199,7 @@ func SetRelayRouter(router *gin.Engine) {
 	//relayMjRouter.Use()
 
 	relaySunoRouter := router.Group("/suno")
-	relaySunoRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relaySunoRouter.Use(middleware.Tracing(), middleware.TokenAuth(), middleware.TokenRateLimit(), middleware.Distribute())
 	{
 		relaySunoRouter.POST("/submit/:action", controller.RelayTask)
 		relaySunoRouter.POST("/fetch", controller.RelayTask)
@@ -170,12 +207,21 @@ func SetRelayRouter(router *gin.Engine) {
 	}
 
 	relayGeminiRouter := router.Group("/v1beta")
+	relayGeminiRouter.Use(middleware.Tracing())
 	relayGeminiRouter.Use(middleware.TokenAuth())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
+	// 与 /v1 相同，countTokens 请求只受 CountTokensRateLimit 限制
+	relayGeminiRouter.Use(middleware.TokenRateLimit())
+	relayGeminiRouter.Use(middleware.CountTokensRateLimit())
+	relayGeminiRouter.Use(middleware.PayloadCapture())
 	relayGeminiRouter.Use(middleware.Distribute())
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
 		relayGeminiRouter.POST("/models/*path", func(c *gin.Context) {
+			if middleware.IsCountTokensRequest(c) {
+				controller.CountGeminiTokens(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 	}
@@ -183,7 +229,7 @@ func SetRelayRouter(router *gin.Engine) {
 
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 	relayMjRouter.GET("/image/:id", relay.RelayMidjourneyImage)
-	relayMjRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relayMjRouter.Use(middleware.Tracing(), middleware.TokenAuth(), middleware.TokenRateLimit(), middleware.Distribute())
 	{
 		relayMjRouter.POST("/submit/action", controller.RelayMidjourney)
 		relayMjRouter.POST("/submit/shorten", controller.RelayMidjourney)
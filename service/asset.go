@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// MirrorTaskAsset 异步转存视频任务的生成结果，视频任务成功时结果地址保存在 FailReason 中
+func MirrorTaskAsset(task *model.Task) {
+	if task.Status != model.TaskStatusSuccess {
+		return
+	}
+	sourceUrl := task.FailReason
+	userId, channelId, sourceId := task.UserId, task.ChannelId, strconv.FormatInt(task.ID, 10)
+	gopool.Go(func() {
+		mirrorAsset(userId, channelId, model.AssetSourceTask, sourceId, sourceUrl)
+	})
+}
+
+// MirrorMidjourneyAsset 异步转存 Midjourney 任务生成的图片
+func MirrorMidjourneyAsset(task *model.Midjourney) {
+	if task.Status != "SUCCESS" {
+		return
+	}
+	sourceUrl := task.ImageUrl
+	userId, channelId, sourceId := task.UserId, task.ChannelId, strconv.Itoa(task.Id)
+	gopool.Go(func() {
+		mirrorAsset(userId, channelId, model.AssetSourceMidjourney, sourceId, sourceUrl)
+	})
+}
+
+func mirrorAsset(userId int, channelId int, sourceType string, sourceId string, sourceUrl string) {
+	assetSetting := operation_setting.GetAssetSetting()
+	if !assetSetting.Enabled {
+		return
+	}
+	if !strings.HasPrefix(sourceUrl, "http://") && !strings.HasPrefix(sourceUrl, "https://") {
+		return
+	}
+	if _, err := model.GetAssetBySource(sourceType, sourceId); err == nil {
+		return
+	}
+	userQuota := int64(assetSetting.UserQuotaMB) << 20
+	if userQuota > 0 {
+		used, err := model.SumAssetSize(userId)
+		if err != nil || used >= userQuota {
+			common.SysLog(fmt.Sprintf("skip mirroring %s #%s: user #%d asset storage is full", sourceType, sourceId, userId))
+			return
+		}
+	}
+	asset, err := saveAsset(userId, channelId, sourceType, sourceId, sourceUrl)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to mirror %s #%s asset: %v", sourceType, sourceId, err))
+		return
+	}
+	// 下载前只能检查已用空间，保存后按实际大小重新检查（同时计入并发转存的资源），超出配额时删除
+	if userQuota > 0 {
+		used, err := model.SumAssetSize(userId)
+		if err == nil && used > userQuota {
+			common.SysLog(fmt.Sprintf("discard mirrored %s #%s: user #%d asset storage quota exceeded", sourceType, sourceId, userId))
+			if err = DeleteAsset(asset); err != nil {
+				common.SysLog(fmt.Sprintf("failed to delete asset %s: %v", asset.AssetId, err))
+			}
+		}
+	}
+}
+
+func saveAsset(userId int, channelId int, sourceType string, sourceId string, sourceUrl string) (*model.Asset, error) {
+	assetSetting := operation_setting.GetAssetSetting()
+	maxSize := int64(assetSetting.MaxSizeMB) << 20
+
+	// 与 Midjourney 图片代理一致，使用渠道配置的代理下载
+	httpClient := GetHttpClient()
+	if channel, err := model.CacheGetChannel(channelId); err == nil {
+		if proxy := channel.GetSetting().Proxy; proxy != "" {
+			if httpClient, err = NewProxyHttpClient(proxy); err != nil {
+				return nil, err
+			}
+		}
+	}
+	resp, err := httpClient.Get(sourceUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status code %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("asset size %d exceeds limit %d", resp.ContentLength, maxSize)
+	}
+	return storeAsset(userId, sourceType, sourceId, sourceUrl, resp.Header.Get("Content-Type"), resp.Body)
+}
+
+// SaveTaskOutputAsset 保存异步任务的非 JSON 输出（如音频），不受转存开关影响
+func SaveTaskOutputAsset(task *model.Task, contentType string, data []byte) (*model.Asset, error) {
+	return storeAsset(task.UserId, model.AssetSourceTask, strconv.FormatInt(task.ID, 10), "", contentType, bytes.NewReader(data))
+}
+
+func storeAsset(userId int, sourceType string, sourceId string, sourceUrl string, contentType string, reader io.Reader) (*model.Asset, error) {
+	assetSetting := operation_setting.GetAssetSetting()
+	maxSize := int64(assetSetting.MaxSizeMB) << 20
+
+	storage := GetAssetStorage()
+	assetId := "asset_" + common.GetUUID()
+	size, err := storage.Save(assetId, io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		_ = storage.Delete(assetId)
+		return nil, fmt.Errorf("asset size exceeds limit %d", maxSize)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	asset := &model.Asset{
+		AssetId:     assetId,
+		UserId:      userId,
+		SourceType:  sourceType,
+		SourceId:    sourceId,
+		SourceUrl:   sourceUrl,
+		ContentType: contentType,
+		Size:        size,
+		ExpiresAt:   time.Now().AddDate(0, 0, assetSetting.RetentionDays).Unix(),
+	}
+	if err = asset.Insert(); err != nil {
+		_ = storage.Delete(assetId)
+		return nil, err
+	}
+	return asset, nil
+}
+
+func assetSignature(assetId string, expires int64) string {
+	return common.GenerateHMAC(fmt.Sprintf("asset:%s:%d", assetId, expires))
+}
+
+// GetAssetSignedUrl 生成带过期时间的资源访问链接
+func GetAssetSignedUrl(asset *model.Asset) string {
+	expires := common.GetTimestamp() + int64(operation_setting.GetAssetSetting().SignedUrlExpireSeconds)
+	if expires > asset.ExpiresAt {
+		expires = asset.ExpiresAt
+	}
+	return fmt.Sprintf("%s/asset/%s?expires=%d&signature=%s", setting.ServerAddress, asset.AssetId, expires, assetSignature(asset.AssetId, expires))
+}
+
+// VerifyAssetSignature 校验资源访问链接的签名与有效期
+func VerifyAssetSignature(assetId string, expiresStr string, signature string) error {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || signature == "" {
+		return errors.New("invalid signature")
+	}
+	if expires < common.GetTimestamp() {
+		return errors.New("signed url expired")
+	}
+	if !hmac.Equal([]byte(signature), []byte(assetSignature(assetId, expires))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// GetSourceAssetUrl 获取任务转存资源的签名链接，未转存时返回空
+func GetSourceAssetUrl(sourceType string, sourceId string) string {
+	if !operation_setting.GetAssetSetting().Enabled {
+		return ""
+	}
+	asset, err := model.GetAssetBySource(sourceType, sourceId)
+	if err != nil {
+		return ""
+	}
+	return GetAssetSignedUrl(asset)
+}
+
+func DeleteAsset(asset *model.Asset) error {
+	if err := GetAssetStorage().Delete(asset.AssetId); err != nil {
+		return err
+	}
+	return asset.Delete()
+}
+
+// CleanupExpiredAssets 定时删除超过保留期的资源
+func CleanupExpiredAssets() {
+	for {
+		deleted := 0
+		for {
+			assets, err := model.GetExpiredAssets(common.GetTimestamp(), 500)
+			if err != nil {
+				common.SysLog("failed to get expired assets: " + err.Error())
+				break
+			}
+			batchDeleted := 0
+			for _, asset := range assets {
+				if err = DeleteAsset(asset); err != nil {
+					common.SysLog(fmt.Sprintf("failed to delete asset %s: %v", asset.AssetId, err))
+					continue
+				}
+				batchDeleted++
+			}
+			deleted += batchDeleted
+			if len(assets) < 500 || batchDeleted == 0 {
+				break
+			}
+		}
+		if deleted > 0 {
+			common.SysLog(fmt.Sprintf("deleted %d expired assets", deleted))
+		}
+		time.Sleep(time.Hour)
+	}
+}
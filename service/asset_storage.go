@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/constant"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+var (
+	assetStorage     FileStorage
+	assetStorageOnce sync.Once
+)
+
+// GetAssetStorage 媒体资源存储，配置了 S3 兼容存储时使用 S3，否则使用本地磁盘
+func GetAssetStorage() FileStorage {
+	assetStorageOnce.Do(func() {
+		if constant.AssetS3Endpoint != "" && constant.AssetS3Bucket != "" {
+			assetStorage = NewS3FileStorage(constant.AssetS3Endpoint, constant.AssetS3Region, constant.AssetS3Bucket,
+				constant.AssetS3AccessKey, constant.AssetS3SecretKey)
+		} else {
+			assetStorage = NewLocalFileStorage(constant.AssetStorageDir)
+		}
+	})
+	return assetStorage
+}
+
+// S3FileStorage S3 兼容对象存储，使用 path-style 地址与 SigV4 签名
+type S3FileStorage struct {
+	endpoint    string
+	region      string
+	bucket      string
+	credentials aws.Credentials
+	signer      *v4.Signer
+}
+
+func NewS3FileStorage(endpoint string, region string, bucket string, accessKey string, secretKey string) *S3FileStorage {
+	return &S3FileStorage{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		region:   region,
+		bucket:   bucket,
+		credentials: aws.Credentials{
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretKey,
+		},
+		signer: v4.NewSigner(func(o *v4.SignerOptions) {
+			// S3 不对路径做二次转义
+			o.DisableURIPathEscaping = true
+		}),
+	}
+}
+
+// s3PartSize 分片上传的分片大小，S3 要求除最后一个分片外不小于 5MB
+const s3PartSize = 8 << 20
+
+func (s *S3FileStorage) do(method string, name string, query url.Values, body []byte) (*http.Response, error) {
+	objectUrl := fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, url.PathEscape(name))
+	if len(query) > 0 {
+		objectUrl += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, objectUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err = s.signer.SignHTTP(context.Background(), s.credentials, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return nil, err
+	}
+	return GetHttpClient().Do(req)
+}
+
+// readS3Response 读取响应内容，非 2xx 状态码时返回错误
+func readS3Response(action string, resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("s3 %s failed with status code %d: %s", action, resp.StatusCode, string(respBody))
+	}
+	return respBody, err
+}
+
+// Save 按分片读取上传内容，内存中最多保留一个分片：不超过一个分片时直接上传，否则使用分片上传
+func (s *S3FileStorage) Save(name string, reader io.Reader) (int64, error) {
+	buf := make([]byte, s3PartSize)
+	n, err := io.ReadFull(reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		resp, err := s.do(http.MethodPut, name, nil, buf[:n])
+		if err != nil {
+			return 0, err
+		}
+		if _, err = readS3Response("put object", resp); err != nil {
+			return 0, err
+		}
+		return int64(n), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return s.multipartUpload(name, buf, reader)
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+// multipartUpload 分片上传，firstPart 为已读取的第一个完整分片，其缓冲区会被复用，失败时中止上传
+func (s *S3FileStorage) multipartUpload(name string, firstPart []byte, reader io.Reader) (int64, error) {
+	resp, err := s.do(http.MethodPost, name, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return 0, err
+	}
+	respBody, err := readS3Response("create multipart upload", resp)
+	if err != nil {
+		return 0, err
+	}
+	var initiate struct {
+		UploadId string `xml:"UploadId"`
+	}
+	if err = xml.Unmarshal(respBody, &initiate); err != nil || initiate.UploadId == "" {
+		return 0, fmt.Errorf("s3 create multipart upload returned invalid response: %s", string(respBody))
+	}
+	uploadId := initiate.UploadId
+
+	total, err := s.uploadParts(name, uploadId, firstPart, reader)
+	if err != nil {
+		if resp, abortErr := s.do(http.MethodDelete, name, url.Values{"uploadId": {uploadId}}, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return 0, err
+	}
+	return total, nil
+}
+
+func (s *S3FileStorage) uploadParts(name string, uploadId string, buf []byte, reader io.Reader) (int64, error) {
+	var parts []s3CompletedPart
+	var total int64
+	part := buf
+	for len(part) > 0 {
+		partNumber := len(parts) + 1
+		resp, err := s.do(http.MethodPut, name, url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadId},
+		}, part)
+		if err != nil {
+			return 0, err
+		}
+		etag := resp.Header.Get("ETag")
+		if _, err = readS3Response("upload part", resp); err != nil {
+			return 0, err
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: partNumber, ETag: etag})
+		total += int64(len(part))
+
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		part = buf[:n]
+	}
+
+	body, err := xml.Marshal(s3CompleteMultipartUpload{Parts: parts})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.do(http.MethodPost, name, url.Values{"uploadId": {uploadId}}, body)
+	if err != nil {
+		return 0, err
+	}
+	respBody, err := readS3Response("complete multipart upload", resp)
+	if err != nil {
+		return 0, err
+	}
+	// 合并失败时 S3 可能返回 200 与错误内容
+	if bytes.Contains(respBody, []byte("<Error>")) {
+		return 0, fmt.Errorf("s3 complete multipart upload failed: %s", string(respBody))
+	}
+	return total, nil
+}
+
+func (s *S3FileStorage) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 get object failed with status code %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3FileStorage) Delete(name string) error {
+	resp, err := s.do(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return fmt.Errorf("s3 delete object failed with status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 实现测试所需的 S3 对象与分片上传接口
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	parts    map[int][]byte
+	aborted  bool
+	maxBody  int
+	failPart int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	if len(body) > f.maxBody {
+		f.maxBody = len(body)
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.parts = make(map[int][]byte)
+		_, _ = fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>")
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		if partNumber == f.failPart {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.parts[partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", partNumber))
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		var complete s3CompleteMultipartUpload
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var object []byte
+		for _, part := range complete.Parts {
+			object = append(object, f.parts[part.PartNumber]...)
+		}
+		f.objects[key] = object
+		_, _ = fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		f.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestS3FileStorageSave(t *testing.T) {
+	InitHttpClient()
+	tests := []struct {
+		name     string
+		size     int
+		failPart int
+		wantErr  bool
+	}{
+		{name: "single put", size: 1024},
+		{name: "exactly one part", size: s3PartSize},
+		{name: "multipart", size: 2*s3PartSize + 100},
+		{name: "abort on part failure", size: 2*s3PartSize + 100, failPart: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		fake := &fakeS3{objects: make(map[string][]byte), failPart: tt.failPart}
+		server := httptest.NewServer(fake)
+		storage := NewS3FileStorage(server.URL, "us-east-1", "bucket", "ak", "sk")
+		data := bytes.Repeat([]byte("0123456789"), tt.size/10+1)[:tt.size]
+
+		n, err := storage.Save("asset.bin", bytes.NewReader(data))
+		server.Close()
+		if tt.wantErr {
+			if err == nil || !fake.aborted {
+				t.Errorf("%s: expected error and aborted upload, got err %v, aborted %v", tt.name, err, fake.aborted)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if n != int64(tt.size) || !bytes.Equal(fake.objects["asset.bin"], data) {
+			t.Errorf("%s: saved %d bytes, object matches %v", tt.name, n, bytes.Equal(fake.objects["asset.bin"], data))
+		}
+		// 每次请求最多携带一个分片
+		if fake.maxBody > s3PartSize {
+			t.Errorf("%s: request body %d exceeds part size", tt.name, fake.maxBody)
+		}
+	}
+}
@@ -31,6 +31,11 @@ func parseAudio(audioBase64 string, format string) (duration float64, err error)
 	return duration, nil
 }
 
+// GetAudioDuration 计算 base64 编码音频的时长（秒）
+func GetAudioDuration(audioBase64 string, format string) (float64, error) {
+	return parseAudio(audioBase64, format)
+}
+
 func DecodeBase64AudioData(audioBase64 string) (string, error) {
 	// 检查并移除 data:audio/xxx;base64, 前缀
 	idx := strings.Index(audioBase64, ",")
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"one-api/common"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunBillingStatements 每小时检查一次，为上一个月有额度变动的用户（及令牌）生成月度账单，已生成的账单不会重复生成
+func RunBillingStatements() {
+	for {
+		if operation_setting.GetBillingStatementSetting().Enabled {
+			period := time.Now().AddDate(0, -1, 0).Format("2006-01")
+			generated, err := GenerateBillingStatements(period)
+			if err != nil {
+				common.SysLog(fmt.Sprintf("failed to generate billing statements for %s: %v", period, err))
+			}
+			if generated > 0 {
+				common.SysLog(fmt.Sprintf("generated %d billing statements for %s", generated, period))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// GenerateBillingStatements 为账期内有额度变动的用户生成尚未生成的账单，返回新生成的数量
+func GenerateBillingStatements(period string) (int, error) {
+	startTime, endTime, err := model.ParseStatementPeriod(period)
+	if err != nil {
+		return 0, err
+	}
+	userIds, tokens, err := model.GetStatementTargets(startTime.Unix(), endTime.Unix())
+	if err != nil {
+		return 0, err
+	}
+	tokenEnabled := operation_setting.GetBillingStatementSetting().TokenStatementEnabled
+	generated := 0
+	generate := func(userId int, tokenId int) {
+		if model.BillingStatementExists(userId, tokenId, period) {
+			return
+		}
+		if _, err := model.GenerateBillingStatement(userId, tokenId, period); err != nil {
+			common.SysLog(fmt.Sprintf("failed to generate billing statement for user %d token %d: %v", userId, tokenId, err))
+			return
+		}
+		generated++
+	}
+	for _, userId := range userIds {
+		generate(userId, 0)
+		if !tokenEnabled {
+			continue
+		}
+		for _, tokenId := range tokens[userId] {
+			generate(userId, tokenId)
+		}
+	}
+	return generated, nil
+}
+
+// csvTextCell 以 = + - @ 等开头的文本在表格软件中会被当作公式执行，加上单引号前缀
+func csvTextCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// RenderBillingStatementCSV 导出 CSV 账单，额度同时给出原始额度与按当前显示设置格式化后的金额
+func RenderBillingStatementCSV(statement *model.BillingStatement) ([]byte, error) {
+	var buf bytes.Buffer
+	// 写入 BOM，便于 Excel 识别 UTF-8
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+	quotaRow := func(name string, quota int64) []string {
+		return []string{name, strconv.FormatInt(quota, 10), logger.FormatQuota(int(quota))}
+	}
+	rows := [][]string{
+		{"账期", statement.Period},
+		{"用户 ID", strconv.Itoa(statement.UserId)},
+	}
+	if statement.TokenId != 0 {
+		rows = append(rows, []string{"令牌", csvTextCell(fmt.Sprintf("%s (#%d)", statement.TokenName, statement.TokenId))})
+	} else {
+		rows = append(rows,
+			quotaRow("期初余额", statement.OpeningBalance),
+			quotaRow("充值", statement.TopUpQuota),
+			[]string{"充值金额", strconv.FormatFloat(statement.TopUpMoney, 'f', 2, 64)},
+			quotaRow("兑换码", statement.RedemptionQuota),
+		)
+	}
+	rows = append(rows,
+		quotaRow("消费", statement.ConsumedQuota),
+		quotaRow("退款", statement.RefundQuota),
+	)
+	if statement.TokenId == 0 {
+		rows = append(rows,
+			quotaRow("其他调整", statement.AdjustmentQuota),
+			quotaRow("期末余额", statement.ClosingBalance),
+		)
+	}
+	rows = append(rows,
+		[]string{"请求次数", strconv.FormatInt(statement.RequestCount, 10)},
+		[]string{},
+		[]string{"模型", "请求次数", "输入 tokens", "输出 tokens", "额度", "金额"},
+	)
+	for _, item := range statement.Items {
+		rows = append(rows, []string{
+			csvTextCell(item.ModelName),
+			strconv.FormatInt(item.Count, 10),
+			strconv.FormatInt(item.PromptTokens, 10),
+			strconv.FormatInt(item.CompletionTokens, 10),
+			strconv.FormatInt(item.Quota, 10),
+			logger.FormatQuota(int(item.Quota)),
+		})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var billingStatementTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"quota": func(quota int64) string { return logger.FormatQuota(int(quota)) },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.SystemName}} 账单 {{.Statement.Period}}</title>
+<style>
+body { font-family: sans-serif; margin: 40px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 6px 12px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h2>{{.SystemName}} 月度账单</h2>
+<p>账期：{{.Statement.Period}}（{{.Start}} 至 {{.End}}）<br>
+用户 ID：{{.Statement.UserId}}{{if .Statement.TokenId}}<br>令牌：{{.Statement.TokenName}} (#{{.Statement.TokenId}}){{end}}</p>
+<table>
+{{if not .Statement.TokenId}}<tr><td>期初余额</td><td>{{quota .Statement.OpeningBalance}}</td></tr>
+<tr><td>充值</td><td>{{quota .Statement.TopUpQuota}}</td></tr>
+<tr><td>兑换码</td><td>{{quota .Statement.RedemptionQuota}}</td></tr>
+{{end}}<tr><td>消费</td><td>{{quota .Statement.ConsumedQuota}}</td></tr>
+<tr><td>退款</td><td>{{quota .Statement.RefundQuota}}</td></tr>
+{{if not .Statement.TokenId}}<tr><td>其他调整</td><td>{{quota .Statement.AdjustmentQuota}}</td></tr>
+<tr><td>期末余额</td><td>{{quota .Statement.ClosingBalance}}</td></tr>
+{{end}}<tr><td>请求次数</td><td>{{.Statement.RequestCount}}</td></tr>
+</table>
+<table>
+<tr><th>模型</th><th>请求次数</th><th>输入 tokens</th><th>输出 tokens</th><th>消费</th></tr>
+{{range .Statement.Items}}<tr><td>{{.ModelName}}</td><td>{{.Count}}</td><td>{{.PromptTokens}}</td><td>{{.CompletionTokens}}</td><td>{{quota .Quota}}</td></tr>
+{{end}}</table>
+<p>生成时间：{{.CreatedAt}}</p>
+</body>
+</html>
+`))
+
+// RenderBillingStatementHTML 导出可打印的 HTML 账单，可在浏览器中打印为 PDF
+func RenderBillingStatementHTML(statement *model.BillingStatement) ([]byte, error) {
+	const layout = "2006-01-02 15:04:05"
+	var buf bytes.Buffer
+	err := billingStatementTemplate.Execute(&buf, map[string]any{
+		"SystemName": common.SystemName,
+		"Statement":  statement,
+		"Start":      time.Unix(statement.StartTime, 0).Format(layout),
+		"End":        time.Unix(statement.EndTime, 0).Format(layout),
+		"CreatedAt":  time.Unix(statement.CreatedAt, 0).Format(layout),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/csv"
+	"one-api/model"
+	"strings"
+	"testing"
+)
+
+func TestRenderBillingStatementCSV(t *testing.T) {
+	statement := &model.BillingStatement{
+		Period:        "2025-01",
+		UserId:        1,
+		TokenId:       2,
+		TokenName:     "=HYPERLINK(\"http://example.com\")",
+		ConsumedQuota: 100,
+		RefundQuota:   -1,
+		Items: []model.StatementModelItem{
+			{ModelName: "gpt-4o", Count: 1, Quota: 60},
+			{ModelName: "@SUM(A1)", Count: 1, Quota: 40},
+		},
+	}
+	data, err := RenderBillingStatementCSV(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\xEF\xBB\xBF")))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string][]string)
+	for _, row := range rows {
+		if len(row) > 1 {
+			cells[row[0]] = row[1:]
+		}
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "令牌", want: "'=HYPERLINK(\"http://example.com\") (#2)"},
+		{key: "gpt-4o", want: "1"},
+		{key: "'@SUM(A1)", want: "1"},
+		// 数值不加前缀
+		{key: "退款", want: "-1"},
+	}
+	for _, tt := range tests {
+		if got := cells[tt.key]; len(got) == 0 || got[0] != tt.want {
+			t.Errorf("%s: got %v, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -63,6 +63,25 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 		openAITools = append(openAITools, openAITool)
 	}
 	openAIRequest.Tools = openAITools
+	if claudeRequest.ToolChoice != nil && len(openAITools) > 0 {
+		toolChoice, _ := common.Any2Type[dto.ClaudeToolChoice](claudeRequest.ToolChoice)
+		switch toolChoice.Type {
+		case "auto":
+			openAIRequest.ToolChoice = "auto"
+		case "any":
+			openAIRequest.ToolChoice = "required"
+		case "none":
+			openAIRequest.ToolChoice = "none"
+		case "tool":
+			openAIRequest.ToolChoice = map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": toolChoice.Name},
+			}
+		}
+		if toolChoice.DisableParallelToolUse {
+			openAIRequest.ParallelTooCalls = common.GetPointer(false)
+		}
+	}
 
 	// Convert messages
 	openAIMessages := make([]dto.Message, 0)
@@ -132,15 +151,10 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 						CacheControl: mediaMsg.CacheControl,
 					}
 					mediaMessages = append(mediaMessages, message)
-				case "image":
-					// Handle image conversion (base64 to URL or keep as is)
-					imageData := fmt.Sprintf("data:%s;base64,%s", mediaMsg.Source.MediaType, mediaMsg.Source.Data)
-					//textContent += fmt.Sprintf("[Image: %s]", imageData)
-					mediaMessage := dto.MediaContent{
-						Type:     "image_url",
-						ImageUrl: &dto.MessageImageUrl{Url: imageData},
+				case "image", "document":
+					if mediaMessage := claudeSourceToMediaContent(mediaMsg); mediaMessage != nil {
+						mediaMessages = append(mediaMessages, *mediaMessage)
 					}
-					mediaMessages = append(mediaMessages, mediaMessage)
 				case "tool_use":
 					toolCall := dto.ToolCallRequest{
 						ID:   mediaMsg.Id,
@@ -166,19 +180,35 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 					if mediaMsg.IsStringContent() {
 						oaiToolMessage.SetStringContent(mediaMsg.GetStringContent())
 					} else {
-						mediaContents := mediaMsg.ParseMediaContent()
-						encodeJson, _ := common.Marshal(mediaContents)
-						oaiToolMessage.SetStringContent(string(encodeJson))
+						// tool 消息只支持文本，工具返回的图片、文档放到随后的 user 消息中
+						var texts []string
+						for _, resultContent := range mediaMsg.ParseMediaContent() {
+							if resultContent.Type == "text" {
+								texts = append(texts, resultContent.GetText())
+							} else if mediaMessage := claudeSourceToMediaContent(resultContent); mediaMessage != nil {
+								mediaMessages = append(mediaMessages, *mediaMessage)
+							}
+						}
+						oaiToolMessage.SetStringContent(strings.Join(texts, "\n"))
 					}
 					openAIMessages = append(openAIMessages, oaiToolMessage)
 				}
+				// thinking、redacted_thinking 的签名只对 Claude 有效，OpenAI 格式的上游无法接收
 			}
 
 			if len(toolCalls) > 0 {
 				openAIMessage.SetToolCalls(toolCalls)
-			}
-
-			if len(mediaMessages) > 0 && len(toolCalls) == 0 {
+				// 与工具调用一起输出的文本
+				var texts []string
+				for _, mediaMessage := range mediaMessages {
+					if mediaMessage.Type == "text" {
+						texts = append(texts, mediaMessage.Text)
+					}
+				}
+				if len(texts) > 0 {
+					openAIMessage.SetStringContent(strings.Join(texts, "\n"))
+				}
+			} else if len(mediaMessages) > 0 {
 				openAIMessage.SetMediaContent(mediaMessages)
 			}
 		}
@@ -192,6 +222,52 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 	return &openAIRequest, nil
 }
 
+// claudeSourceToMediaContent 将 Claude 的 image、document 内容块转换为 OpenAI 格式
+func claudeSourceToMediaContent(mediaMsg dto.ClaudeMediaMessage) *dto.MediaContent {
+	if mediaMsg.Source == nil {
+		return nil
+	}
+	source := mediaMsg.Source
+	switch source.Type {
+	case "text":
+		return &dto.MediaContent{
+			Type:         "text",
+			Text:         common.Interface2String(source.Data),
+			CacheControl: mediaMsg.CacheControl,
+		}
+	case "url":
+		if mediaMsg.Type == "image" {
+			return &dto.MediaContent{
+				Type:     "image_url",
+				ImageUrl: &dto.MessageImageUrl{Url: source.Url},
+			}
+		}
+		// OpenAI 的 file 不支持 url，以链接形式提供给模型
+		return &dto.MediaContent{
+			Type: "text",
+			Text: fmt.Sprintf("[document](%s)", source.Url),
+		}
+	case "base64":
+	default:
+		return nil
+	}
+	data := fmt.Sprintf("data:%s;base64,%s", source.MediaType, common.Interface2String(source.Data))
+	if mediaMsg.Type == "image" {
+		return &dto.MediaContent{
+			Type:     "image_url",
+			ImageUrl: &dto.MessageImageUrl{Url: data},
+		}
+	}
+	return &dto.MediaContent{
+		Type: dto.ContentTypeFile,
+		File: &dto.MessageFile{
+			FileName: "document.pdf",
+			FileData: data,
+		},
+		CacheControl: mediaMsg.CacheControl,
+	}
+}
+
 func generateStopBlock(index int) *dto.ClaudeResponse {
 	return &dto.ClaudeResponse{
 		Type:  "content_block_stop",
@@ -234,6 +310,7 @@ func StreamResponseOpenAI2Claude(openAIResponse *dto.ChatCompletionsStreamRespon
 			}
 			resp.SetIndex(0)
 			claudeResponses = append(claudeResponses, resp)
+			info.ClaudeConvertInfo.ToolCallId = openAIResponse.GetFirstToolCall().ID
 		} else {
 
 		}
@@ -268,13 +345,8 @@ func StreamResponseOpenAI2Claude(openAIResponse *dto.ChatCompletionsStreamRespon
 			oaiUsage := info.ClaudeConvertInfo.Usage
 			if oaiUsage != nil {
 				claudeResponses = append(claudeResponses, &dto.ClaudeResponse{
-					Type: "message_delta",
-					Usage: &dto.ClaudeUsage{
-						InputTokens:              oaiUsage.PromptTokens,
-						OutputTokens:             oaiUsage.CompletionTokens,
-						CacheCreationInputTokens: oaiUsage.PromptTokensDetails.CachedCreationTokens,
-						CacheReadInputTokens:     oaiUsage.PromptTokensDetails.CachedTokens,
-					},
+					Type:  "message_delta",
+					Usage: UsageOpenAI2Claude(oaiUsage),
 					Delta: &dto.ClaudeMediaMessage{
 						StopReason: common.GetPointer[string](stopReasonOpenAI2Claude(info.FinishReason)),
 					},
@@ -299,13 +371,8 @@ func StreamResponseOpenAI2Claude(openAIResponse *dto.ChatCompletionsStreamRespon
 			oaiUsage := info.ClaudeConvertInfo.Usage
 			if oaiUsage != nil {
 				claudeResponses = append(claudeResponses, &dto.ClaudeResponse{
-					Type: "message_delta",
-					Usage: &dto.ClaudeUsage{
-						InputTokens:              oaiUsage.PromptTokens,
-						OutputTokens:             oaiUsage.CompletionTokens,
-						CacheCreationInputTokens: oaiUsage.PromptTokensDetails.CachedCreationTokens,
-						CacheReadInputTokens:     oaiUsage.PromptTokensDetails.CachedTokens,
-					},
+					Type:  "message_delta",
+					Usage: UsageOpenAI2Claude(oaiUsage),
 					Delta: &dto.ClaudeMediaMessage{
 						StopReason: common.GetPointer[string](stopReasonOpenAI2Claude(info.FinishReason)),
 					},
@@ -319,33 +386,51 @@ func StreamResponseOpenAI2Claude(openAIResponse *dto.ChatCompletionsStreamRespon
 			var isEmpty bool
 			claudeResponse.Type = "content_block_delta"
 			if len(chosenChoice.Delta.ToolCalls) > 0 {
-				if info.ClaudeConvertInfo.LastMessagesType != relaycommon.LastMessageTypeTools {
-					claudeResponses = append(claudeResponses, generateStopBlock(info.ClaudeConvertInfo.Index))
-					info.ClaudeConvertInfo.Index++
+				isEmpty = true
+				for _, toolCall := range chosenChoice.Delta.ToolCalls {
+					// 每个工具调用对应一个 tool_use 内容块，保留原始的调用 id
+					isNewCall := toolCall.ID != "" && toolCall.ID != info.ClaudeConvertInfo.ToolCallId
+					if info.ClaudeConvertInfo.LastMessagesType != relaycommon.LastMessageTypeTools || isNewCall {
+						if info.ClaudeConvertInfo.LastMessagesType != relaycommon.LastMessageTypeNone {
+							claudeResponses = append(claudeResponses, generateStopBlock(info.ClaudeConvertInfo.Index))
+							info.ClaudeConvertInfo.Index++
+						}
+						claudeResponses = append(claudeResponses, &dto.ClaudeResponse{
+							Index: common.GetPointer(info.ClaudeConvertInfo.Index),
+							Type:  "content_block_start",
+							ContentBlock: &dto.ClaudeMediaMessage{
+								Id:    toolCall.ID,
+								Type:  "tool_use",
+								Name:  toolCall.Function.Name,
+								Input: map[string]interface{}{},
+							},
+						})
+						info.ClaudeConvertInfo.LastMessagesType = relaycommon.LastMessageTypeTools
+						info.ClaudeConvertInfo.ToolCallId = toolCall.ID
+					}
+					if toolCall.Function.Arguments == "" {
+						continue
+					}
+					// tools delta
 					claudeResponses = append(claudeResponses, &dto.ClaudeResponse{
-						Index: &info.ClaudeConvertInfo.Index,
-						Type:  "content_block_start",
-						ContentBlock: &dto.ClaudeMediaMessage{
-							Id:    openAIResponse.GetFirstToolCall().ID,
-							Type:  "tool_use",
-							Name:  openAIResponse.GetFirstToolCall().Function.Name,
-							Input: map[string]interface{}{},
+						Index: common.GetPointer(info.ClaudeConvertInfo.Index),
+						Type:  "content_block_delta",
+						Delta: &dto.ClaudeMediaMessage{
+							Type:        "input_json_delta",
+							PartialJson: common.GetPointer(toolCall.Function.Arguments),
 						},
 					})
 				}
-				info.ClaudeConvertInfo.LastMessagesType = relaycommon.LastMessageTypeTools
-				// tools delta
-				claudeResponse.Delta = &dto.ClaudeMediaMessage{
-					Type:        "input_json_delta",
-					PartialJson: &chosenChoice.Delta.ToolCalls[0].Function.Arguments,
-				}
 			} else {
 				reasoning := chosenChoice.Delta.GetReasoningContent()
 				textContent := chosenChoice.Delta.GetContentString()
 				if reasoning != "" || textContent != "" {
 					if reasoning != "" {
 						if info.ClaudeConvertInfo.LastMessagesType != relaycommon.LastMessageTypeThinking {
-							//info.ClaudeConvertInfo.Index++
+							if info.ClaudeConvertInfo.LastMessagesType == relaycommon.LastMessageTypeText || info.ClaudeConvertInfo.LastMessagesType == relaycommon.LastMessageTypeTools {
+								claudeResponses = append(claudeResponses, generateStopBlock(info.ClaudeConvertInfo.Index))
+								info.ClaudeConvertInfo.Index++
+							}
 							claudeResponses = append(claudeResponses, &dto.ClaudeResponse{
 								Index: &info.ClaudeConvertInfo.Index,
 								Type:  "content_block_start",
@@ -408,37 +493,63 @@ func ResponseOpenAI2Claude(openAIResponse *dto.OpenAITextResponse, info *relayco
 	}
 	for _, choice := range openAIResponse.Choices {
 		stopReason = stopReasonOpenAI2Claude(choice.FinishReason)
-		if choice.FinishReason == "tool_calls" {
-			for _, toolUse := range choice.Message.ParseToolCalls() {
-				claudeContent := dto.ClaudeMediaMessage{}
-				claudeContent.Type = "tool_use"
-				claudeContent.Id = toolUse.ID
-				claudeContent.Name = toolUse.Function.Name
-				var mapParams map[string]interface{}
-				if err := common.Unmarshal([]byte(toolUse.Function.Arguments), &mapParams); err == nil {
-					claudeContent.Input = mapParams
-				} else {
-					claudeContent.Input = toolUse.Function.Arguments
-				}
-				contents = append(contents, claudeContent)
-			}
-		} else {
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			contents = append(contents, dto.ClaudeMediaMessage{
+				Type:     "thinking",
+				Thinking: reasoning,
+			})
+		}
+		toolCalls := choice.Message.ParseToolCalls()
+		if text := choice.Message.StringContent(); text != "" || len(toolCalls) == 0 {
 			claudeContent := dto.ClaudeMediaMessage{}
 			claudeContent.Type = "text"
-			claudeContent.SetText(choice.Message.StringContent())
+			claudeContent.SetText(text)
+			contents = append(contents, claudeContent)
+		}
+		for _, toolUse := range toolCalls {
+			claudeContent := dto.ClaudeMediaMessage{}
+			claudeContent.Type = "tool_use"
+			claudeContent.Id = toolUse.ID
+			claudeContent.Name = toolUse.Function.Name
+			var mapParams map[string]interface{}
+			if err := common.Unmarshal([]byte(toolUse.Function.Arguments), &mapParams); err == nil {
+				claudeContent.Input = mapParams
+			} else {
+				claudeContent.Input = toolUse.Function.Arguments
+			}
 			contents = append(contents, claudeContent)
 		}
+		if len(toolCalls) > 0 {
+			stopReason = "tool_use"
+		}
 	}
 	claudeResponse.Content = contents
 	claudeResponse.StopReason = stopReason
-	claudeResponse.Usage = &dto.ClaudeUsage{
-		InputTokens:  openAIResponse.PromptTokens,
-		OutputTokens: openAIResponse.CompletionTokens,
-	}
+	claudeResponse.Usage = UsageOpenAI2Claude(&openAIResponse.Usage)
 
 	return claudeResponse
 }
 
+// UsageOpenAI2Claude 转换用量，Claude 的 input_tokens 不包含缓存读取与写入的 token
+func UsageOpenAI2Claude(usage *dto.Usage) *dto.ClaudeUsage {
+	cacheRead := usage.PromptTokensDetails.CachedTokens
+	cacheCreation := usage.PromptTokensDetails.CachedCreationTokens
+	inputTokens := usage.PromptTokens - cacheRead - cacheCreation
+	if inputTokens < 0 {
+		inputTokens = usage.PromptTokens
+	}
+	return &dto.ClaudeUsage{
+		InputTokens:              inputTokens,
+		OutputTokens:             usage.CompletionTokens,
+		CacheCreationInputTokens: cacheCreation,
+		CacheReadInputTokens:     cacheRead,
+	}
+}
+
 func stopReasonOpenAI2Claude(reason string) string {
 	switch reason {
 	case "stop":
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 使用 go test ./service -update 重新生成 testdata 下的 golden 文件
+var update = flag.Bool("update", false, "update golden files")
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\n got:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func marshalIndent(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var normalized any
+	if err = json.Unmarshal(data, &normalized); err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.MarshalIndent(normalized, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data, '\n')
+}
+
+func TestClaudeToOpenAIRequest(t *testing.T) {
+	var request dto.ClaudeRequest
+	if err := json.Unmarshal(readTestdata(t, "claude_request.json"), &request); err != nil {
+		t.Fatal(err)
+	}
+	info := &relaycommon.RelayInfo{
+		OriginModelName: "gpt-4o",
+		ChannelMeta:     &relaycommon.ChannelMeta{ChannelType: constant.ChannelTypeOpenAI, UpstreamModelName: "gpt-4o"},
+	}
+	openAIRequest, err := ClaudeToOpenAIRequest(request, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "claude_request.openai.golden.json", marshalIndent(t, openAIRequest))
+}
+
+func TestResponseOpenAI2Claude(t *testing.T) {
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(readTestdata(t, "openai_response.json"), &response); err != nil {
+		t.Fatal(err)
+	}
+	claudeResponse := ResponseOpenAI2Claude(&response, &relaycommon.RelayInfo{})
+	assertGolden(t, "openai_response.claude.golden.json", marshalIndent(t, claudeResponse))
+}
+
+// TestStreamResponseOpenAI2Claude 按 openai 渠道转发 Claude 流式请求的方式逐块转换，最后以 Done 结束
+func TestStreamResponseOpenAI2Claude(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		PromptTokens: 100,
+		ClaudeConvertInfo: &relaycommon.ClaudeConvertInfo{
+			LastMessagesType: relaycommon.LastMessageTypeNone,
+		},
+	}
+	var out bytes.Buffer
+	writeEvents := func(responses []*dto.ClaudeResponse) {
+		for _, resp := range responses {
+			data, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.Write(data)
+			out.WriteByte('\n')
+		}
+	}
+
+	var last dto.ChatCompletionsStreamResponse
+	var usage *dto.Usage
+	scanner := bufio.NewScanner(bytes.NewReader(readTestdata(t, "openai_stream.txt")))
+	for scanner.Scan() {
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		info.SendResponseCount++
+		if chunk.Usage != nil {
+			info.ClaudeConvertInfo.Usage = chunk.Usage
+			usage = chunk.Usage
+		}
+		writeEvents(StreamResponseOpenAI2Claude(&chunk, info))
+		last = chunk
+	}
+	info.ClaudeConvertInfo.Done = true
+	info.ClaudeConvertInfo.Usage = usage
+	writeEvents(StreamResponseOpenAI2Claude(&last, info))
+
+	assertGolden(t, "openai_stream.claude.golden.txt", out.Bytes())
+}
+
+func TestUsageOpenAI2Claude(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage dto.Usage
+		want  dto.ClaudeUsage
+	}{
+		{name: "no cache", usage: dto.Usage{PromptTokens: 100, CompletionTokens: 20}, want: dto.ClaudeUsage{InputTokens: 100, OutputTokens: 20}},
+		{
+			name:  "cache read and creation",
+			usage: dto.Usage{PromptTokens: 100, CompletionTokens: 20, PromptTokensDetails: dto.InputTokenDetails{CachedTokens: 60, CachedCreationTokens: 30}},
+			want:  dto.ClaudeUsage{InputTokens: 10, OutputTokens: 20, CacheReadInputTokens: 60, CacheCreationInputTokens: 30},
+		},
+		// 上游的缓存 token 不计入 prompt_tokens 时保留原值
+		{
+			name:  "cache exceeds prompt",
+			usage: dto.Usage{PromptTokens: 10, PromptTokensDetails: dto.InputTokenDetails{CachedTokens: 60}},
+			want:  dto.ClaudeUsage{InputTokens: 10, CacheReadInputTokens: 60},
+		},
+	}
+	for _, tt := range tests {
+		if got := UsageOpenAI2Claude(&tt.usage); *got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
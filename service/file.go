@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+)
+
+const LocalFileIdPrefix = "file-"
+
+// IsLocalFileId 判断是否为本网关生成的文件 ID
+func IsLocalFileId(fileId string) bool {
+	return strings.HasPrefix(fileId, LocalFileIdPrefix) && len(fileId) > len(LocalFileIdPrefix)
+}
+
+func upstreamFileKey(info *relaycommon.RelayInfo) string {
+	if info.ChannelIsMultiKey {
+		return fmt.Sprintf("%d:%d", info.ChannelId, info.ChannelMultiKeyIndex)
+	}
+	return fmt.Sprintf("%d", info.ChannelId)
+}
+
+// ReadFileContent 读取本地文件的全部内容
+func ReadFileContent(file *model.File) ([]byte, error) {
+	reader, err := GetFileStorage().Open(file.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// ResolveRequestFiles 将请求消息中引用的本地文件 ID 替换为当前渠道可用的内容：
+// OpenAI 渠道在开启透传时上传文件并使用上游文件 ID，其他渠道内联为 base64 数据
+func ResolveRequestFiles(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) error {
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		if message.IsStringContent() {
+			continue
+		}
+		contents := message.ParseContent()
+		changed := false
+		for j := range contents {
+			if contents[j].Type != dto.ContentTypeFile {
+				continue
+			}
+			messageFile := contents[j].GetFile()
+			if messageFile == nil || !IsLocalFileId(messageFile.FileId) {
+				continue
+			}
+			file, err := model.GetUserFileByFileId(info.UserId, messageFile.FileId)
+			if err != nil {
+				// 不是本地文件，保持原样交给上游处理
+				continue
+			}
+			resolved, err := resolveMessageFile(info, file)
+			if err != nil {
+				return err
+			}
+			contents[j].File = resolved
+			changed = true
+		}
+		if changed {
+			message.SetMediaContent(contents)
+		}
+	}
+	return nil
+}
+
+func resolveMessageFile(info *relaycommon.RelayInfo, file *model.File) (*dto.MessageFile, error) {
+	if operation_setting.GetFileSetting().PassThroughEnabled && info.ChannelType == constant.ChannelTypeOpenAI {
+		upstreamId, err := GetOrUploadUpstreamFile(info, file)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.MessageFile{FileId: upstreamId}, nil
+	}
+	data, err := ReadFileContent(file)
+	if err != nil {
+		return nil, fmt.Errorf("read file %s failed: %w", file.FileId, err)
+	}
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return &dto.MessageFile{
+		FileName: file.Filename,
+		FileData: fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+	}, nil
+}
+
+// GetOrUploadUpstreamFile 获取文件在当前渠道上的上游文件 ID，不存在时上传到渠道并记录
+func GetOrUploadUpstreamFile(info *relaycommon.RelayInfo, file *model.File) (string, error) {
+	key := upstreamFileKey(info)
+	if upstreamId := file.GetUpstreamFileId(key); upstreamId != "" {
+		return upstreamId, nil
+	}
+	data, err := ReadFileContent(file)
+	if err != nil {
+		return "", fmt.Errorf("read file %s failed: %w", file.FileId, err)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err = writer.WriteField("purpose", file.Purpose); err != nil {
+		return "", err
+	}
+	part, err := writer.CreateFormFile("file", file.Filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err = part.Write(data); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	baseUrl := info.ChannelBaseUrl
+	if baseUrl == "" {
+		baseUrl = constant.ChannelBaseURLs[constant.ChannelTypeOpenAI]
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseUrl, "/")+"/v1/files", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+
+	client := GetHttpClient()
+	if info.ChannelSetting.Proxy != "" {
+		client, err = NewProxyHttpClient(info.ChannelSetting.Proxy)
+		if err != nil {
+			return "", fmt.Errorf("new proxy http client failed: %w", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload file to upstream failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upload file to upstream failed: status code %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	var fileObject dto.OpenAIFileObject
+	if err = common.Unmarshal(respBody, &fileObject); err != nil {
+		return "", err
+	}
+	if fileObject.Id == "" {
+		return "", errors.New("upload file to upstream failed: empty file id")
+	}
+	if err = file.SetUpstreamFileId(key, fileObject.Id); err != nil {
+		common.SysError(fmt.Sprintf("failed to save upstream file id of %s: %s", file.FileId, err.Error()))
+	}
+	return fileObject.Id, nil
+}
+
+// DeleteUpstreamFiles 删除文件透传时上传到各渠道的副本，渠道已删除或请求失败时只记录日志
+func DeleteUpstreamFiles(file *model.File) {
+	for key, upstreamId := range file.Upstream {
+		if err := deleteUpstreamFile(key, upstreamId); err != nil {
+			common.SysError(fmt.Sprintf("failed to delete upstream file %s of %s: %s", upstreamId, file.FileId, err.Error()))
+		}
+	}
+}
+
+func deleteUpstreamFile(key string, upstreamId string) error {
+	channelIdStr, keyIndexStr, isMultiKey := strings.Cut(key, ":")
+	channelId, err := strconv.Atoi(channelIdStr)
+	if err != nil {
+		return fmt.Errorf("invalid upstream file key %s", key)
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		return err
+	}
+	apiKey := channel.Key
+	if isMultiKey {
+		keys := channel.GetKeys()
+		keyIndex, err := strconv.Atoi(keyIndexStr)
+		if err != nil || keyIndex < 0 || keyIndex >= len(keys) {
+			return fmt.Errorf("invalid upstream file key %s", key)
+		}
+		apiKey = keys[keyIndex]
+	}
+	baseUrl := channel.GetBaseURL()
+	if baseUrl == "" {
+		baseUrl = constant.ChannelBaseURLs[constant.ChannelTypeOpenAI]
+	}
+	req, err := http.NewRequest(http.MethodDelete, strings.TrimSuffix(baseUrl, "/")+"/v1/files/"+upstreamId, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	client := GetHttpClient()
+	if proxy := channel.GetSetting().Proxy; proxy != "" {
+		if client, err = NewProxyHttpClient(proxy); err != nil {
+			return fmt.Errorf("new proxy http client failed: %w", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 上游已删除的文件视为删除成功
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status code %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
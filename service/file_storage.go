@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"one-api/constant"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStorage 文件内容存储接口，默认使用本地磁盘，可通过 SetFileStorage 替换为其他实现
+type FileStorage interface {
+	// Save 保存文件内容，返回写入的字节数
+	Save(name string, reader io.Reader) (int64, error)
+	Open(name string) (io.ReadCloser, error)
+	Delete(name string) error
+}
+
+var (
+	fileStorage     FileStorage
+	fileStorageOnce sync.Once
+)
+
+func SetFileStorage(storage FileStorage) {
+	fileStorageOnce.Do(func() {})
+	fileStorage = storage
+}
+
+func GetFileStorage() FileStorage {
+	fileStorageOnce.Do(func() {
+		if fileStorage == nil {
+			fileStorage = NewLocalFileStorage(constant.FileStorageDir)
+		}
+	})
+	return fileStorage
+}
+
+type LocalFileStorage struct {
+	dir string
+}
+
+func NewLocalFileStorage(dir string) *LocalFileStorage {
+	return &LocalFileStorage{dir: dir}
+}
+
+func (s *LocalFileStorage) path(name string) (string, error) {
+	if name == "" || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return "", errors.New("invalid file name")
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+func (s *LocalFileStorage) Save(name string, reader io.Reader) (int64, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(s.dir, 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, reader)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(p)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *LocalFileStorage) Open(name string) (io.ReadCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *LocalFileStorage) Delete(name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
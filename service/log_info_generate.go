@@ -21,6 +21,11 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["model_price"] = modelPrice
 	other["user_group_ratio"] = userGroupRatio
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if relayInfo.PriceData.ContextTier > 0 {
+		other["context_tier"] = relayInfo.PriceData.ContextTier
+		other["context_tier_prompt_tokens_above"] = relayInfo.PriceData.ContextTierPromptTokensAbove
+	}
+	AppendPricingRuleInfo(other, relayInfo.PriceData.PricingRule)
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
@@ -34,8 +39,27 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_system_prompt_overwritten"] = true
 	}
 
+	if batchId := common.GetContextKeyString(ctx, constant.ContextKeyBatchId); batchId != "" {
+		other["batch_id"] = batchId
+		other["batch_discount_ratio"] = ctx.GetFloat64(string(constant.ContextKeyBatchDiscountRatio))
+	}
+
+	if common.GetContextKeyBool(ctx, constant.ContextKeyResponseCacheHit) {
+		other["cache_hit"] = true
+		other["cache_hit_ratio"] = ctx.GetFloat64(string(constant.ContextKeyResponseCacheRatio))
+	}
+
+	if common.GetContextKeyBool(ctx, constant.ContextKeyPayloadCaptured) {
+		other["request_id"] = ctx.GetString(common.RequestIdKey)
+		other["payload_captured"] = true
+	}
+
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
+	if strategy := common.GetContextKeyString(ctx, constant.ContextKeyRoutingStrategy); strategy != "" {
+		adminInfo["routing_strategy"] = strategy
+		adminInfo["routing_reason"] = common.GetContextKeyString(ctx, constant.ContextKeyRoutingReason)
+	}
 	isMultiKey := common.GetContextKeyBool(ctx, constant.ContextKeyChannelIsMultiKey)
 	if isMultiKey {
 		adminInfo["is_multi_key"] = true
@@ -85,5 +109,16 @@ func GenerateMjOtherInfo(priceData types.PerCallPriceData) map[string]interface{
 	if priceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	AppendPricingRuleInfo(other, priceData.PricingRule)
 	return other
 }
+
+// AppendPricingRuleInfo 在日志中记录计费时生效的定时价格规则
+func AppendPricingRuleInfo(other map[string]interface{}, rule types.PricingRuleInfo) {
+	if rule.Id == "" {
+		return
+	}
+	other["pricing_rule_id"] = rule.Id
+	other["pricing_rule_name"] = rule.Name
+	other["pricing_rule_multiplier"] = rule.Multiplier
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	redactionRegexps     = make(map[string]*regexp.Regexp)
+	redactionRegexpsLock sync.Mutex
+)
+
+// ShouldCapturePayload 当前请求是否需要记录请求与响应内容（令牌开启或分组开启）
+func ShouldCapturePayload(c *gin.Context) bool {
+	setting := operation_setting.GetPayloadCaptureSetting()
+	if !setting.Enabled {
+		return false
+	}
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenPayloadCapture) {
+		return true
+	}
+	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	for _, g := range setting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+func getRedactionRegexp(pattern string) *regexp.Regexp {
+	redactionRegexpsLock.Lock()
+	defer redactionRegexpsLock.Unlock()
+	if re, ok := redactionRegexps[pattern]; ok {
+		return re
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		common.SysLog("invalid payload redaction pattern " + pattern + ": " + err.Error())
+	}
+	// 无效的正则同样缓存，避免重复编译
+	redactionRegexps[pattern] = re
+	return re
+}
+
+// RedactPayload 按脱敏规则处理内容
+func RedactPayload(content string) string {
+	for _, rule := range operation_setting.GetPayloadCaptureSetting().RedactionRules {
+		if rule.Pattern == "" {
+			continue
+		}
+		if re := getRedactionRegexp(rule.Pattern); re != nil {
+			content = re.ReplaceAllString(content, rule.Replacement)
+		}
+	}
+	return content
+}
+
+// PayloadCaptureWriter 记录写出的响应，只保留前 limit 字节，流式响应不会被整体缓存在内存中
+type PayloadCaptureWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func NewPayloadCaptureWriter(w gin.ResponseWriter) *PayloadCaptureWriter {
+	return &PayloadCaptureWriter{
+		ResponseWriter: w,
+		limit:          operation_setting.GetPayloadCaptureSetting().MaxResponseBodyKB * 1024,
+	}
+}
+
+func (w *PayloadCaptureWriter) capture(data []byte) {
+	if w.truncated {
+		return
+	}
+	if w.limit > 0 && w.buf.Len()+len(data) > w.limit {
+		w.buf.Write(data[:w.limit-w.buf.Len()])
+		w.truncated = true
+		return
+	}
+	w.buf.Write(data)
+}
+
+func (w *PayloadCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *PayloadCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// NewPayloadCaptureRecord 在请求结束时生成审计记录，需在请求处理协程中调用
+func NewPayloadCaptureRecord(c *gin.Context, requestBody []byte, w *PayloadCaptureWriter, startTime time.Time) *model.PayloadCapture {
+	setting := operation_setting.GetPayloadCaptureSetting()
+	requestTruncated := false
+	if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		requestBody = []byte("[multipart form data omitted]")
+	} else if limit := setting.MaxRequestBodyKB * 1024; limit > 0 && len(requestBody) > limit {
+		requestBody = requestBody[:limit]
+		requestTruncated = true
+	}
+	return &model.PayloadCapture{
+		RequestId:         c.GetString(common.RequestIdKey),
+		UserId:            c.GetInt("id"),
+		TokenId:           c.GetInt("token_id"),
+		TokenName:         c.GetString("token_name"),
+		ChannelId:         c.GetInt("channel_id"),
+		ModelName:         common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		Group:             common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		Method:            c.Request.Method,
+		Path:              c.Request.URL.Path,
+		StatusCode:        w.Status(),
+		IsStream:          strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"),
+		RequestBody:       string(requestBody),
+		ResponseBody:      w.buf.String(),
+		RequestTruncated:  requestTruncated,
+		ResponseTruncated: w.truncated,
+		DurationMs:        time.Since(startTime).Milliseconds(),
+	}
+}
+
+// SavePayloadCapture 脱敏后保存审计记录
+func SavePayloadCapture(capture *model.PayloadCapture) {
+	// 截断可能破坏 UTF-8 编码
+	capture.RequestBody = RedactPayload(strings.ToValidUTF8(capture.RequestBody, ""))
+	capture.ResponseBody = RedactPayload(strings.ToValidUTF8(capture.ResponseBody, ""))
+	if err := capture.Insert(); err != nil {
+		common.SysLog("failed to save payload capture: " + err.Error())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"math"
 	"one-api/common"
+	"one-api/common/tracing"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
@@ -89,7 +90,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetPayerQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		return err
 	}
@@ -155,6 +156,8 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
+	span := tracing.StartSpan(ctx, "post_consume_quota")
+	defer span.End()
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -218,6 +221,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	RecordTokenRateUsage(ctx, usage.InputTokens+usage.OutputTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -235,11 +239,19 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	span := tracing.StartSpan(ctx, "post_consume_quota")
+	defer span.End()
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
+	// 按实际提示 token 数（含缓存）重新选择上下文长度分段，OpenRouter 的提示 token 数已包含缓存
+	tierPromptTokens := promptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		tierPromptTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	ratio_setting.ApplyModelRatioTier(&relayInfo.PriceData, modelName, tierPromptTokens)
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := relayInfo.PriceData.CompletionRatio
@@ -320,6 +332,7 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		}
 	}
 
+	RecordTokenRateUsage(ctx, promptTokens+completionTokens)
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio, cacheCreationTokens, cacheCreationRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
@@ -361,6 +374,8 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	span := tracing.StartSpan(ctx, "post_consume_quota")
+	defer span.End()
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -447,6 +462,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	RecordTokenRateUsage(ctx, usage.PromptTokens+usage.CompletionTokens)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -490,9 +506,9 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = model.DecreasePayerQuota(relayInfo.UserId, relayInfo.OrganizationId, quota)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = model.IncreasePayerQuota(relayInfo.UserId, relayInfo.OrganizationId, -quota)
 	}
 	if err != nil {
 		return err
@@ -509,7 +525,8 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
-	if sendEmail {
+	// 组织额度不发送个人额度提醒
+	if sendEmail && relayInfo.OrganizationId == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -518,6 +535,15 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 	return nil
 }
 
+// RecordTokenRateUsage 令牌设置了每分钟 token 数限制时，累计本次请求使用的 token 数
+func RecordTokenRateUsage(c *gin.Context, tokens int) {
+	limits, ok := common.GetContextKeyType[model.TokenLimits](c, constant.ContextKeyTokenLimits)
+	if !ok || limits.Tpm <= 0 {
+		return
+	}
+	model.RecordTokenTokenUsage(c.GetInt("token_id"), tokens)
+}
+
 func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int) {
 	gopool.Go(func() {
 		userSetting := relayInfo.UserSetting
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 响应缓存：对完全相同（归一化后）的 chat completions 请求直接回放上次的响应，
+// 启用 Redis 时保存在 Redis 中，否则保存在当前节点内存中
+
+const responseCacheKeyPrefix = "response_cache:"
+
+type ResponseCacheEntry struct {
+	ContentType string    `json:"content_type"`
+	IsStream    bool      `json:"is_stream"`
+	Body        []byte    `json:"body"`
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+}
+
+type memoryResponseCacheItem struct {
+	key       string
+	entry     *ResponseCacheEntry
+	expiresAt time.Time
+}
+
+// 内存缓存按最近使用顺序淘汰，链表头部为最近使用的条目
+var (
+	memoryResponseCache     = make(map[string]*list.Element)
+	memoryResponseCacheLRU  = list.New()
+	memoryResponseCacheLock sync.Mutex
+)
+
+// GetResponseCacheKey 计算请求的缓存 key，请求不可缓存时返回 false
+func GetResponseCacheKey(c *gin.Context, request *dto.GeneralOpenAIRequest) (string, bool) {
+	setting := operation_setting.GetResponseCacheSetting()
+	if !setting.Enabled || !common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache) {
+		return "", false
+	}
+	if request == nil || len(request.Messages) == 0 || request.N > 1 {
+		return "", false
+	}
+	if setting.OnlyZeroTemperature && (request.Temperature == nil || *request.Temperature != 0) {
+		return "", false
+	}
+	normalized, err := common.DeepCopy(request)
+	if err != nil {
+		return "", false
+	}
+	// 不影响响应内容的字段不参与计算
+	normalized.User = ""
+	if normalized.StreamOptions != nil && !normalized.Stream {
+		normalized.StreamOptions = nil
+	}
+	data, err := common.Marshal(normalized)
+	if err != nil {
+		return "", false
+	}
+	// 不同分组的渠道与价格不同，缓存按分组隔离
+	scope := "group:" + common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	if !setting.SharedAcrossUsers {
+		scope = fmt.Sprintf("user:%d|%s", c.GetInt("id"), scope)
+	}
+	hash := sha256.Sum256(append([]byte(scope+"|"), data...))
+	return hex.EncodeToString(hash[:]), true
+}
+
+func GetResponseCache(key string) *ResponseCacheEntry {
+	if common.RedisEnabled {
+		data, err := common.RedisGet(responseCacheKeyPrefix + key)
+		if err != nil || data == "" {
+			return nil
+		}
+		var entry ResponseCacheEntry
+		if err := common.UnmarshalJsonStr(data, &entry); err != nil {
+			return nil
+		}
+		return &entry
+	}
+	memoryResponseCacheLock.Lock()
+	defer memoryResponseCacheLock.Unlock()
+	element, ok := memoryResponseCache[key]
+	if !ok {
+		return nil
+	}
+	item := element.Value.(*memoryResponseCacheItem)
+	if time.Now().After(item.expiresAt) {
+		removeMemoryResponseCache(element)
+		return nil
+	}
+	memoryResponseCacheLRU.MoveToFront(element)
+	return item.entry
+}
+
+// removeMemoryResponseCache 删除内存缓存条目，调用方需持有锁
+func removeMemoryResponseCache(element *list.Element) {
+	memoryResponseCacheLRU.Remove(element)
+	delete(memoryResponseCache, element.Value.(*memoryResponseCacheItem).key)
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry) error {
+	setting := operation_setting.GetResponseCacheSetting()
+	ttl := time.Duration(setting.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		return nil
+	}
+	entry.CreatedAt = common.GetTimestamp()
+	if common.RedisEnabled {
+		data, err := common.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return common.RedisSet(responseCacheKeyPrefix+key, string(data), ttl)
+	}
+	item := &memoryResponseCacheItem{key: key, entry: entry, expiresAt: time.Now().Add(ttl)}
+	memoryResponseCacheLock.Lock()
+	defer memoryResponseCacheLock.Unlock()
+	if element, ok := memoryResponseCache[key]; ok {
+		element.Value = item
+		memoryResponseCacheLRU.MoveToFront(element)
+		return nil
+	}
+	memoryResponseCache[key] = memoryResponseCacheLRU.PushFront(item)
+	// 超出条数上限时淘汰最久未使用的条目，过期条目在读取或淘汰时删除
+	for setting.MaxMemoryEntries > 0 && memoryResponseCacheLRU.Len() > setting.MaxMemoryEntries {
+		removeMemoryResponseCache(memoryResponseCacheLRU.Back())
+	}
+	return nil
+}
+
+// ResponseCaptureWriter 在写出响应的同时保存一份副本，用于写入响应缓存
+type ResponseCaptureWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+// StartResponseCapture 替换 c.Writer 开始捕获响应，结束后需调用 Stop 恢复
+func StartResponseCapture(c *gin.Context) *ResponseCaptureWriter {
+	w := &ResponseCaptureWriter{
+		ResponseWriter: c.Writer,
+		limit:          operation_setting.GetResponseCacheSetting().MaxEntrySizeKB * 1024,
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *ResponseCaptureWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.buf.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(data)
+}
+
+func (w *ResponseCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ResponseCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Stop 恢复原始的 Writer
+func (w *ResponseCaptureWriter) Stop(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+}
+
+// Entry 返回捕获到的完整响应，响应不完整或超出大小限制时返回 nil
+func (w *ResponseCaptureWriter) Entry(isStream bool, usage *dto.Usage) *ResponseCacheEntry {
+	if w.overflow || w.buf.Len() == 0 || w.Status() != 200 || usage == nil {
+		return nil
+	}
+	body := w.buf.Bytes()
+	// 流式响应必须完整结束才缓存
+	if isStream && !bytes.Contains(body, []byte("data: [DONE]")) {
+		return nil
+	}
+	return &ResponseCacheEntry{
+		ContentType: w.Header().Get("Content-Type"),
+		IsStream:    isStream,
+		Body:        bytes.Clone(body),
+		Usage:       *usage,
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCacheTestContext(userId int, group string, tokenEnabled bool) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("id", userId)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, group)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, tokenEnabled)
+	return c
+}
+
+func TestGetResponseCacheKey(t *testing.T) {
+	setting := operation_setting.GetResponseCacheSetting()
+	defer func(original operation_setting.ResponseCacheSetting) {
+		*setting = original
+	}(*setting)
+
+	zero, one := 0.0, 1.0
+	newRequest := func(modify func(r *dto.GeneralOpenAIRequest)) *dto.GeneralOpenAIRequest {
+		request := &dto.GeneralOpenAIRequest{Model: "gpt-4o", Messages: []dto.Message{{Role: "user", Content: "hi"}}, Temperature: &zero}
+		if modify != nil {
+			modify(request)
+		}
+		return request
+	}
+	base := newCacheTestContext(1, "default", true)
+
+	tests := []struct {
+		name       string
+		disabled   bool
+		shared     bool
+		c          *gin.Context
+		request    *dto.GeneralOpenAIRequest
+		wantOk     bool
+		wantSameAs bool
+	}{
+		{name: "same request", c: base, request: newRequest(nil), wantOk: true, wantSameAs: true},
+		{name: "setting disabled", disabled: true, c: base, request: newRequest(nil)},
+		{name: "token disabled", c: newCacheTestContext(1, "default", false), request: newRequest(nil)},
+		{name: "non-zero temperature", c: base, request: newRequest(func(r *dto.GeneralOpenAIRequest) { r.Temperature = &one })},
+		{name: "multiple choices", c: base, request: newRequest(func(r *dto.GeneralOpenAIRequest) { r.N = 2 })},
+		// user 字段与非流式请求的 stream_options 不影响响应
+		{name: "user ignored", c: base, request: newRequest(func(r *dto.GeneralOpenAIRequest) { r.User = "u1" }), wantOk: true, wantSameAs: true},
+		{name: "stream options ignored", c: base, request: newRequest(func(r *dto.GeneralOpenAIRequest) { r.StreamOptions = &dto.StreamOptions{IncludeUsage: true} }), wantOk: true, wantSameAs: true},
+		{name: "different message", c: base, request: newRequest(func(r *dto.GeneralOpenAIRequest) { r.Messages[0].Content = "hello" }), wantOk: true},
+		{name: "other user", c: newCacheTestContext(2, "default", true), request: newRequest(nil), wantOk: true},
+		{name: "other user shared", shared: true, c: newCacheTestContext(2, "default", true), request: newRequest(nil), wantOk: true, wantSameAs: true},
+		// 共享缓存仍按分组隔离
+		{name: "other group shared", shared: true, c: newCacheTestContext(2, "vip", true), request: newRequest(nil), wantOk: true},
+	}
+	for _, tt := range tests {
+		setting.Enabled, setting.OnlyZeroTemperature, setting.SharedAcrossUsers = !tt.disabled, true, tt.shared
+		baseKey, _ := GetResponseCacheKey(base, newRequest(nil))
+		key, ok := GetResponseCacheKey(tt.c, tt.request)
+		if ok != tt.wantOk {
+			t.Errorf("%s: cacheable %v, want %v", tt.name, ok, tt.wantOk)
+			continue
+		}
+		if ok && (key == baseKey) != tt.wantSameAs {
+			t.Errorf("%s: same key %v, want %v", tt.name, key == baseKey, tt.wantSameAs)
+		}
+	}
+}
+
+func TestMemoryResponseCache(t *testing.T) {
+	setting := operation_setting.GetResponseCacheSetting()
+	defer func(original operation_setting.ResponseCacheSetting) {
+		*setting = original
+	}(*setting)
+	setting.TTLSeconds, setting.MaxMemoryEntries = 60, 3
+
+	for i := 0; i < 3; i++ {
+		if err := SetResponseCache(fmt.Sprintf("lru_%d", i), &ResponseCacheEntry{Body: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 读取后成为最近使用的条目，超出上限时淘汰最久未使用的 lru_1
+	if entry := GetResponseCache("lru_0"); entry == nil || string(entry.Body) != "0" {
+		t.Fatalf("lru_0: %+v", entry)
+	}
+	_ = SetResponseCache("lru_3", &ResponseCacheEntry{Body: []byte("3")})
+	_ = SetResponseCache("lru_0", &ResponseCacheEntry{Body: []byte("0b")})
+	want := map[string]string{"lru_0": "0b", "lru_1": "", "lru_2": "2", "lru_3": "3"}
+	for key, body := range want {
+		entry := GetResponseCache(key)
+		if (entry == nil) != (body == "") || entry != nil && string(entry.Body) != body {
+			t.Errorf("%s: got %+v, want %q", key, entry, body)
+		}
+	}
+	if memoryResponseCacheLRU.Len() != 3 || len(memoryResponseCache) != 3 {
+		t.Errorf("cache size %d/%d, want 3", memoryResponseCacheLRU.Len(), len(memoryResponseCache))
+	}
+
+	// 过期条目在读取时删除
+	setting.TTLSeconds = 1
+	_ = SetResponseCache("lru_expired", &ResponseCacheEntry{Body: []byte("x")})
+	memoryResponseCache["lru_expired"].Value.(*memoryResponseCacheItem).expiresAt = time.Now().Add(-time.Second)
+	if GetResponseCache("lru_expired") != nil {
+		t.Errorf("expired entry returned")
+	}
+	if _, ok := memoryResponseCache["lru_expired"]; ok {
+		t.Errorf("expired entry not removed")
+	}
+}
+
+func TestResponseCaptureWriterEntry(t *testing.T) {
+	setting := operation_setting.GetResponseCacheSetting()
+	defer func(maxEntrySizeKB int) {
+		setting.MaxEntrySizeKB = maxEntrySizeKB
+	}(setting.MaxEntrySizeKB)
+	setting.MaxEntrySizeKB = 1
+
+	usage := &dto.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}
+	tests := []struct {
+		name      string
+		isStream  bool
+		status    int
+		body      []string
+		usage     *dto.Usage
+		wantEntry bool
+	}{
+		{name: "json", status: http.StatusOK, body: []string{`{"id":"1"}`}, usage: usage, wantEntry: true},
+		{name: "stream", isStream: true, status: http.StatusOK, body: []string{"data: {}\n\n", "data: [DONE]\n\n"}, usage: usage, wantEntry: true},
+		{name: "incomplete stream", isStream: true, status: http.StatusOK, body: []string{"data: {}\n\n"}, usage: usage},
+		{name: "error status", status: http.StatusBadRequest, body: []string{`{"error":{}}`}, usage: usage},
+		{name: "no usage", status: http.StatusOK, body: []string{`{"id":"1"}`}},
+		{name: "too large", status: http.StatusOK, body: []string{string(make([]byte, 600)), string(make([]byte, 600))}, usage: usage},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		w := StartResponseCapture(c)
+		c.Header("Content-Type", "application/json")
+		c.Status(tt.status)
+		for _, chunk := range tt.body {
+			_, _ = c.Writer.WriteString(chunk)
+		}
+		w.Stop(c)
+		if c.Writer == w {
+			t.Fatalf("%s: writer not restored", tt.name)
+		}
+		entry := w.Entry(tt.isStream, tt.usage)
+		if (entry != nil) != tt.wantEntry {
+			t.Errorf("%s: entry %+v", tt.name, entry)
+			continue
+		}
+		if entry != nil && (entry.ContentType != "application/json" || entry.IsStream != tt.isStream || entry.Usage != *usage) {
+			t.Errorf("%s: unexpected entry %+v", tt.name, entry)
+		}
+	}
+}
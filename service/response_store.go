@@ -0,0 +1,417 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 网关保存的 /v1/responses 响应：previous_response_id 在本地展开为完整历史，
+// 不依赖上游账号保存的对话状态，重试或切换渠道后仍可继续对话
+
+// ErrStoredResponseUnavailable 读取保存的响应时数据库或存储出错，与请求内容及渠道无关
+var ErrStoredResponseUnavailable = errors.New("stored response unavailable")
+
+// StoredResponseBody 保存在 FileStorage 中的内容
+type StoredResponseBody struct {
+	Input    []json.RawMessage `json:"input"`
+	Response json.RawMessage   `json:"response"`
+}
+
+// ShouldStoreResponse 判断本次请求的响应是否需要保存
+func ShouldStoreResponse(request *dto.OpenAIResponsesRequest) bool {
+	setting := operation_setting.GetResponsesStoreSetting()
+	if !setting.Enabled {
+		return false
+	}
+	if request.Store != nil {
+		return *request.Store
+	}
+	return setting.DefaultStore
+}
+
+// normalizeResponsesInput 将 input 统一为输入项数组，itemIdPrefix 不为空时为缺少 id 的输入项生成 id
+func normalizeResponsesInput(input json.RawMessage, itemIdPrefix string) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	switch common.GetJsonType(input) {
+	case "string":
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, err
+		}
+		item, err := common.Marshal(map[string]any{"type": "message", "role": "user", "content": text})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	case "array":
+		if err := common.Unmarshal(input, &items); err != nil {
+			return nil, err
+		}
+	}
+	if itemIdPrefix == "" {
+		return items, nil
+	}
+	for i, item := range items {
+		var fields map[string]any
+		if err := common.Unmarshal(item, &fields); err != nil {
+			continue
+		}
+		if id, _ := fields["id"].(string); id != "" {
+			continue
+		}
+		fields["id"] = fmt.Sprintf("%s_%d", itemIdPrefix, i)
+		if marshaled, err := common.Marshal(fields); err == nil {
+			items[i] = marshaled
+		}
+	}
+	return items, nil
+}
+
+// historyItem 将保存的输入/输出项转换为可以再次作为输入的形式，返回 nil 表示丢弃
+func historyItem(raw json.RawMessage, isOutput bool) json.RawMessage {
+	var fields map[string]any
+	if err := common.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	itemType, _ := fields["type"].(string)
+	switch itemType {
+	case "item_reference":
+		return nil
+	case dto.ResponsesOutputItemReasoning:
+		// 没有加密内容的推理项只能由原上游账号识别
+		if encrypted, _ := fields["encrypted_content"].(string); encrypted == "" {
+			return nil
+		}
+	case "", dto.ResponsesOutputItemMessage, dto.ResponsesOutputItemFunctionCall:
+	default:
+		// 内置工具调用由上游执行，结果已体现在后续消息中
+		if isOutput {
+			return nil
+		}
+	}
+	// id 只在原上游账号中有效
+	delete(fields, "id")
+	delete(fields, "status")
+	item, err := common.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return item
+}
+
+func storedResponseOutput(response json.RawMessage) []json.RawMessage {
+	var body struct {
+		Output []json.RawMessage `json:"output"`
+	}
+	_ = common.Unmarshal(response, &body)
+	return body.Output
+}
+
+func LoadStoredResponseBody(response *model.StoredResponse) (*StoredResponseBody, error) {
+	reader, err := GetFileStorage().Open(response.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrStoredResponseUnavailable, err.Error())
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrStoredResponseUnavailable, err.Error())
+	}
+	var body StoredResponseBody
+	if err = common.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("stored response %s is unreadable: %w", response.ResponseId, err)
+	}
+	return &body, nil
+}
+
+// DeleteStoredResponse 删除保存的响应及其内容
+func DeleteStoredResponse(response *model.StoredResponse) error {
+	if err := response.Delete(); err != nil {
+		return err
+	}
+	if err := GetFileStorage().Delete(response.StoragePath); err != nil {
+		common.SysError(fmt.Sprintf("failed to delete stored response %s from storage: %s", response.ResponseId, err.Error()))
+	}
+	return nil
+}
+
+// ResolvePreviousResponse 将 previous_response_id 引用的历史对话展开到 input 中，
+// 响应不是由网关保存的（例如保存功能开启前由上游保存的）时保持原样，返回 false。
+// 数据库或存储出错时返回的错误包含 ErrStoredResponseUnavailable，其余错误为请求或保存的内容无效
+func ResolvePreviousResponse(info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) (bool, error) {
+	setting := operation_setting.GetResponsesStoreSetting()
+	if request.PreviousResponseID == "" || !setting.Enabled {
+		return false, nil
+	}
+	var chain []*StoredResponseBody
+	responseId := request.PreviousResponseID
+	for responseId != "" && len(chain) < setting.MaxChainDepth {
+		stored, err := model.GetStoredResponse(info.TokenId, responseId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return false, fmt.Errorf("%w: %s", ErrStoredResponseUnavailable, err.Error())
+		}
+		body, err := LoadStoredResponseBody(stored)
+		if err != nil {
+			return false, fmt.Errorf("failed to load previous response %s: %w", responseId, err)
+		}
+		chain = append(chain, body)
+		responseId = stored.PreviousResponseId
+	}
+	if len(chain) == 0 {
+		return false, nil
+	}
+
+	var items []json.RawMessage
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, item := range chain[i].Input {
+			if historyItem := historyItem(item, false); historyItem != nil {
+				items = append(items, historyItem)
+			}
+		}
+		for _, item := range storedResponseOutput(chain[i].Response) {
+			if historyItem := historyItem(item, true); historyItem != nil {
+				items = append(items, historyItem)
+			}
+		}
+	}
+	currentItems, err := normalizeResponsesInput(request.Input, "")
+	if err != nil {
+		return false, fmt.Errorf("invalid input: %w", err)
+	}
+	items = append(items, currentItems...)
+	input, err := common.Marshal(items)
+	if err != nil {
+		return false, err
+	}
+	request.Input = input
+	request.PreviousResponseID = ""
+	return true, nil
+}
+
+// ResponsesStoreWriter 在写出响应的同时记录最终的响应对象，流式响应只保留 response.completed 等结束事件
+type ResponsesStoreWriter struct {
+	gin.ResponseWriter
+	isStream bool
+	limit    int
+	buf      bytes.Buffer
+	final    []byte
+	overflow bool
+}
+
+// StartResponsesStore 替换 c.Writer 开始记录响应，结束后需调用 Stop 恢复
+func StartResponsesStore(c *gin.Context, isStream bool) *ResponsesStoreWriter {
+	w := &ResponsesStoreWriter{
+		ResponseWriter: c.Writer,
+		isStream:       isStream,
+		limit:          operation_setting.GetResponsesStoreSetting().MaxResponseSizeKB * 1024,
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *ResponsesStoreWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	w.buf.Write(data)
+	if !w.isStream {
+		if w.limit > 0 && w.buf.Len() > w.limit {
+			w.overflow = true
+			w.buf.Reset()
+		}
+		return
+	}
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			remaining := append(line, w.buf.Bytes()...)
+			w.buf.Reset()
+			if w.limit > 0 && len(remaining) > w.limit {
+				w.overflow = true
+				return
+			}
+			w.buf.Write(remaining)
+			return
+		}
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type     string          `json:"type"`
+			Response json.RawMessage `json:"response"`
+		}
+		if !bytes.Contains(data, []byte(`"response.`)) || common.Unmarshal(data, &event) != nil {
+			continue
+		}
+		switch event.Type {
+		case "response.completed", "response.incomplete", "response.failed":
+			w.final = event.Response
+		}
+	}
+}
+
+func (w *ResponsesStoreWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ResponsesStoreWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Stop 恢复原始的 Writer
+func (w *ResponsesStoreWriter) Stop(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+}
+
+func (w *ResponsesStoreWriter) response() []byte {
+	if w.overflow || w.Status() != 200 {
+		return nil
+	}
+	if w.isStream {
+		return w.final
+	}
+	return w.buf.Bytes()
+}
+
+// SaveStoredResponse 保存本次响应并按大小扣除存储额度
+func SaveStoredResponse(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest, w *ResponsesStoreWriter) {
+	setting := operation_setting.GetResponsesStoreSetting()
+	response := w.response()
+	if len(response) == 0 {
+		return
+	}
+	var meta struct {
+		Id     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := common.Unmarshal(response, &meta); err != nil || meta.Id == "" {
+		return
+	}
+	input, err := normalizeResponsesInput(request.Input, "in_"+strings.TrimPrefix(meta.Id, "resp_"))
+	if err != nil {
+		logger.LogError(c, "failed to store response: "+err.Error())
+		return
+	}
+	data, err := common.Marshal(StoredResponseBody{Input: input, Response: response})
+	if err != nil {
+		logger.LogError(c, "failed to store response: "+err.Error())
+		return
+	}
+	if setting.MaxResponseSizeKB > 0 && len(data) > setting.MaxResponseSizeKB*1024 {
+		logger.LogWarn(c, fmt.Sprintf("response %s is too large to store: %d bytes", meta.Id, len(data)))
+		return
+	}
+
+	storagePath := "response_" + common.GetUUID() + ".json"
+	size, err := GetFileStorage().Save(storagePath, bytes.NewReader(data))
+	if err != nil {
+		logger.LogError(c, "failed to store response: "+err.Error())
+		return
+	}
+	retentionDays := setting.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = 30
+	}
+	quota := int(math.Ceil(float64(size) / (1024 * 1024) * float64(setting.QuotaPerMB)))
+	stored := &model.StoredResponse{
+		ResponseId:         meta.Id,
+		PreviousResponseId: request.PreviousResponseID,
+		UserId:             info.UserId,
+		TokenId:            info.TokenId,
+		OrganizationId:     info.OrganizationId,
+		ModelName:          info.OriginModelName,
+		Status:             meta.Status,
+		StoragePath:        storagePath,
+		Bytes:              size,
+		Quota:              quota,
+		CreatedAt:          common.GetTimestamp(),
+	}
+	stored.ExpiresAt = stored.CreatedAt + int64(retentionDays)*24*3600
+	if err = stored.Insert(); err != nil {
+		logger.LogError(c, "failed to store response: "+err.Error())
+		_ = GetFileStorage().Delete(storagePath)
+		return
+	}
+
+	if quota > 0 {
+		if err = PostConsumeQuota(info, quota, 0, false); err != nil {
+			logger.LogError(c, "failed to consume response storage quota: "+err.Error())
+		}
+		model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
+			ChannelId: info.ChannelId,
+			ModelName: info.OriginModelName,
+			TokenName: c.GetString("token_name"),
+			Quota:     quota,
+			Content:   fmt.Sprintf("Responses 存储 %.2f KB，保存 %d 天", float64(size)/1024, retentionDays),
+			TokenId:   info.TokenId,
+			Group:     info.UsingGroup,
+			Other: map[string]interface{}{
+				"response_storage": true,
+				"response_id":      meta.Id,
+				"storage_bytes":    size,
+			},
+		})
+	}
+
+	if setting.MaxResponsesPerToken > 0 {
+		excess, err := model.GetExcessStoredResponses(info.TokenId, setting.MaxResponsesPerToken, 100)
+		if err != nil {
+			logger.LogError(c, "failed to get excess stored responses: "+err.Error())
+			return
+		}
+		for _, response := range excess {
+			_ = DeleteStoredResponse(response)
+		}
+	}
+}
+
+// CleanupStoredResponses 定期删除过期的响应
+func CleanupStoredResponses() {
+	for {
+		if operation_setting.GetResponsesStoreSetting().Enabled {
+			deleted := 0
+			for {
+				responses, err := model.GetExpiredStoredResponses(common.GetTimestamp(), 500)
+				if err != nil {
+					common.SysLog("failed to get expired stored responses: " + err.Error())
+					break
+				}
+				batchDeleted := 0
+				for _, response := range responses {
+					if DeleteStoredResponse(response) == nil {
+						batchDeleted++
+					}
+				}
+				deleted += batchDeleted
+				if len(responses) < 500 || batchDeleted == 0 {
+					break
+				}
+			}
+			if deleted > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d expired stored responses", deleted))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
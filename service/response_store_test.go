@@ -0,0 +1,223 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveTestResponse 直接保存一条响应，input 与 response 为 JSON
+func saveTestResponse(t *testing.T, tokenId int, responseId string, previousResponseId string, input string, response string) {
+	t.Helper()
+	data, err := common.Marshal(map[string]json.RawMessage{"input": json.RawMessage(input), "response": json.RawMessage(response)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storagePath := "response_" + common.GetUUID() + ".json"
+	if _, err = GetFileStorage().Save(storagePath, strings.NewReader(string(data))); err != nil {
+		t.Fatal(err)
+	}
+	stored := &model.StoredResponse{
+		ResponseId:         responseId,
+		PreviousResponseId: previousResponseId,
+		TokenId:            tokenId,
+		StoragePath:        storagePath,
+		ExpiresAt:          common.GetTimestamp() + 3600,
+	}
+	if err = stored.Insert(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvePreviousResponse(t *testing.T) {
+	SetFileStorage(NewLocalFileStorage(t.TempDir()))
+	setting := operation_setting.GetResponsesStoreSetting()
+	defer func(enabled bool, depth int) {
+		setting.Enabled, setting.MaxChainDepth = enabled, depth
+	}(setting.Enabled, setting.MaxChainDepth)
+	setting.Enabled = true
+
+	const tokenId = 3001
+	// 第一轮：推理项没有加密内容、内置工具调用均不能作为历史发送给其他上游
+	saveTestResponse(t, tokenId, "resp_r1", "",
+		`[{"id":"in_r1_0","type":"message","role":"user","content":"hi"}]`,
+		`{"id":"resp_r1","output":[{"id":"rs_1","type":"reasoning","summary":[]},{"id":"ws_1","type":"web_search_call","status":"completed"},{"id":"fc_1","type":"function_call","status":"completed","call_id":"call_1","name":"f","arguments":"{}"}]}`)
+	saveTestResponse(t, tokenId, "resp_r2", "resp_r1",
+		`[{"id":"in_r2_0","type":"function_call_output","call_id":"call_1","output":"done"},{"type":"item_reference","id":"fc_1"}]`,
+		`{"id":"resp_r2","output":[{"id":"rs_2","type":"reasoning","encrypted_content":"enc"},{"id":"msg_2","type":"message","status":"completed","role":"assistant","content":[{"type":"output_text","text":"ok"}]}]}`)
+
+	tests := []struct {
+		name         string
+		tokenId      int
+		previousId   string
+		input        string
+		disabled     bool
+		maxDepth     int
+		wantExpanded bool
+		wantInput    string
+	}{
+		{
+			name:         "full chain",
+			tokenId:      tokenId,
+			previousId:   "resp_r2",
+			input:        `"next"`,
+			wantExpanded: true,
+			wantInput:    `[{"content":"hi","role":"user","type":"message"},{"arguments":"{}","call_id":"call_1","name":"f","type":"function_call"},{"call_id":"call_1","output":"done","type":"function_call_output"},{"encrypted_content":"enc","type":"reasoning"},{"content":[{"text":"ok","type":"output_text"}],"role":"assistant","type":"message"},{"content":"next","role":"user","type":"message"}]`,
+		},
+		{
+			name:         "chain depth limit",
+			tokenId:      tokenId,
+			previousId:   "resp_r2",
+			input:        `[{"type":"message","role":"user","content":"next"}]`,
+			maxDepth:     1,
+			wantExpanded: true,
+			wantInput:    `[{"call_id":"call_1","output":"done","type":"function_call_output"},{"encrypted_content":"enc","type":"reasoning"},{"content":[{"text":"ok","type":"output_text"}],"role":"assistant","type":"message"},{"type":"message","role":"user","content":"next"}]`,
+		},
+		// 上游保存的响应或其他令牌的响应保持原样交给上游
+		{name: "unknown response", tokenId: tokenId, previousId: "resp_upstream", input: `"next"`, wantInput: `"next"`},
+		{name: "other token", tokenId: tokenId + 1, previousId: "resp_r2", input: `"next"`, wantInput: `"next"`},
+		{name: "disabled", tokenId: tokenId, previousId: "resp_r2", input: `"next"`, disabled: true, wantInput: `"next"`},
+	}
+	for _, tt := range tests {
+		setting.Enabled = !tt.disabled
+		setting.MaxChainDepth = 100
+		if tt.maxDepth > 0 {
+			setting.MaxChainDepth = tt.maxDepth
+		}
+		request := &dto.OpenAIResponsesRequest{PreviousResponseID: tt.previousId, Input: json.RawMessage(tt.input)}
+		expanded, err := ResolvePreviousResponse(&relaycommon.RelayInfo{TokenId: tt.tokenId}, request)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if expanded != tt.wantExpanded || string(request.Input) != tt.wantInput {
+			t.Errorf("%s: expanded %v, input:\n got %s\nwant %s", tt.name, expanded, request.Input, tt.wantInput)
+		}
+		if expanded == (request.PreviousResponseID != "") {
+			t.Errorf("%s: previous_response_id %q after expanding", tt.name, request.PreviousResponseID)
+		}
+	}
+
+	// 保存的内容损坏时返回错误，存储读取失败时返回 ErrStoredResponseUnavailable
+	setting.Enabled = true
+	saveTestResponse(t, tokenId, "resp_broken", "", `[]`, `{}`)
+	stored, _ := model.GetStoredResponse(tokenId, "resp_broken")
+	_ = GetFileStorage().Delete(stored.StoragePath)
+	_, err := ResolvePreviousResponse(&relaycommon.RelayInfo{TokenId: tokenId}, &dto.OpenAIResponsesRequest{PreviousResponseID: "resp_broken"})
+	if err == nil || !strings.Contains(err.Error(), ErrStoredResponseUnavailable.Error()) {
+		t.Errorf("missing storage: got %v", err)
+	}
+}
+
+func newStoreTestContext(t *testing.T, quota int) (*gin.Context, *relaycommon.RelayInfo) {
+	t.Helper()
+	user := &model.User{Username: common.GetRandomString(10), AffCode: common.GetRandomString(8), Quota: quota, Status: common.UserStatusEnabled}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token := &model.Token{UserId: user.Id, Key: common.GetRandomString(48), Name: t.Name(), RemainQuota: quota, Status: common.TokenStatusEnabled}
+	if err := model.DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	return c, &relaycommon.RelayInfo{UserId: user.Id, TokenId: token.Id, TokenKey: token.Key, OriginModelName: "gpt-4o", ChannelMeta: &relaycommon.ChannelMeta{}}
+}
+
+func TestSaveStoredResponse(t *testing.T) {
+	SetFileStorage(NewLocalFileStorage(t.TempDir()))
+	setting := operation_setting.GetResponsesStoreSetting()
+	defer func(original operation_setting.ResponsesStoreSetting) {
+		*setting = original
+	}(*setting)
+	setting.Enabled, setting.QuotaPerMB, setting.MaxResponsesPerToken, setting.MaxResponseSizeKB = true, 500, 2, 64
+
+	completed := `{"id":"resp_s1","status":"completed","output":[]}`
+	tests := []struct {
+		name       string
+		isStream   bool
+		status     int
+		body       string
+		wantStored string
+	}{
+		{name: "json", status: http.StatusOK, body: completed, wantStored: "resp_s1"},
+		// 流式响应只保存结束事件中的响应对象
+		{
+			name:     "stream",
+			isStream: true,
+			status:   http.StatusOK,
+			body: "event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_s2\",\"status\":\"in_progress\"}}\n\n" +
+				"data: {\"type\":\"response.output_text.delta\",\"delta\":\"hi\"}\n\n" +
+				"data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_s2\",\"status\":\"completed\",\"output\":[]}}\n\ndata: [DONE]\n\n",
+			wantStored: "resp_s2",
+		},
+		{name: "stream without final event", isStream: true, status: http.StatusOK, body: "data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_s3\"}}\n\n"},
+		{name: "error status", status: http.StatusBadRequest, body: `{"id":"resp_s4","status":"failed"}`},
+		{name: "too large", status: http.StatusOK, body: `{"id":"resp_s5","status":"completed","output":"` + strings.Repeat("a", 65<<10) + `"}`},
+	}
+	for _, tt := range tests {
+		c, info := newStoreTestContext(t, 10000)
+		request := &dto.OpenAIResponsesRequest{Input: json.RawMessage(`"hi"`), PreviousResponseID: "resp_prev"}
+		w := StartResponsesStore(c, tt.isStream)
+		c.Status(tt.status)
+		// 分块写出，覆盖事件跨块的情况
+		for i := 0; i < len(tt.body); i += 7 {
+			_, _ = c.Writer.WriteString(tt.body[i:min(i+7, len(tt.body))])
+		}
+		w.Stop(c)
+		SaveStoredResponse(c, info, request, w)
+
+		var responses []*model.StoredResponse
+		model.DB.Where("token_id = ?", info.TokenId).Find(&responses)
+		if tt.wantStored == "" {
+			if len(responses) != 0 {
+				t.Errorf("%s: stored %d responses", tt.name, len(responses))
+			}
+			continue
+		}
+		if len(responses) != 1 {
+			t.Fatalf("%s: stored %d responses", tt.name, len(responses))
+		}
+		stored := responses[0]
+		if stored.ResponseId != tt.wantStored || stored.PreviousResponseId != "resp_prev" || stored.Status != "completed" || stored.Quota != 1 || stored.ExpiresAt <= stored.CreatedAt {
+			t.Errorf("%s: unexpected stored response %+v", tt.name, stored)
+		}
+		body, err := LoadStoredResponseBody(stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 输入项生成 id，作为历史展开时再去掉
+		wantInput := `[{"content":"hi","id":"in_` + strings.TrimPrefix(tt.wantStored, "resp_") + `_0","role":"user","type":"message"}]`
+		if input, _ := common.Marshal(body.Input); string(input) != wantInput {
+			t.Errorf("%s: stored input %s, want %s", tt.name, input, wantInput)
+		}
+		if userQuota, _ := model.GetUserQuota(info.UserId, true); userQuota != 10000-stored.Quota {
+			t.Errorf("%s: user quota %d after storage charge", tt.name, userQuota)
+		}
+	}
+
+	// 超出每个令牌的保存数量时删除最早的响应
+	c, info := newStoreTestContext(t, 10000)
+	for _, id := range []string{"resp_k1", "resp_k2", "resp_k3"} {
+		w := StartResponsesStore(c, false)
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString(`{"id":"` + id + `","status":"completed"}`)
+		w.Stop(c)
+		SaveStoredResponse(c, info, &dto.OpenAIResponsesRequest{Input: json.RawMessage(`"hi"`)}, w)
+	}
+	if _, err := model.GetStoredResponse(info.TokenId, "resp_k1"); err == nil {
+		t.Errorf("oldest response not deleted")
+	}
+	if _, err := model.GetStoredResponse(info.TokenId, "resp_k3"); err != nil {
+		t.Errorf("latest response: %v", err)
+	}
+}
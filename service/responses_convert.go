@@ -0,0 +1,647 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+)
+
+// Responses API 与 Chat Completions 之间的格式转换，用于不支持 /v1/responses 的渠道
+
+type responsesInputItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallId    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+}
+
+type responsesContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	Refusal  string          `json:"refusal"`
+	ImageUrl json.RawMessage `json:"image_url"`
+	Detail   string          `json:"detail"`
+	FileId   string          `json:"file_id"`
+	FileData string          `json:"file_data"`
+	FileUrl  string          `json:"file_url"`
+	Filename string          `json:"filename"`
+}
+
+type responsesTextFormat struct {
+	Format *struct {
+		Type        string          `json:"type"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Schema      any             `json:"schema"`
+		Strict      json.RawMessage `json:"strict"`
+	} `json:"format"`
+	Verbosity json.RawMessage `json:"verbosity"`
+}
+
+func responsesContentToMediaContent(raw json.RawMessage) (any, error) {
+	if len(raw) == 0 || common.GetJsonType(raw) == "null" {
+		return "", nil
+	}
+	if common.GetJsonType(raw) == "string" {
+		var text string
+		if err := common.Unmarshal(raw, &text); err != nil {
+			return nil, err
+		}
+		return text, nil
+	}
+	var parts []responsesContentPart
+	if err := common.Unmarshal(raw, &parts); err != nil {
+		return nil, fmt.Errorf("invalid input content: %w", err)
+	}
+	mediaContents := make([]dto.MediaContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text":
+			mediaContents = append(mediaContents, dto.MediaContent{Type: dto.ContentTypeText, Text: part.Text})
+		case "refusal":
+			mediaContents = append(mediaContents, dto.MediaContent{Type: dto.ContentTypeText, Text: part.Refusal})
+		case "input_image":
+			imageUrl := dto.MessageImageUrl{Detail: part.Detail}
+			if common.GetJsonType(part.ImageUrl) == "string" {
+				_ = common.Unmarshal(part.ImageUrl, &imageUrl.Url)
+			} else if len(part.ImageUrl) > 0 {
+				_ = common.Unmarshal(part.ImageUrl, &imageUrl)
+			}
+			if imageUrl.Url == "" {
+				return nil, errors.New("input_image without image_url is not supported by this channel")
+			}
+			if imageUrl.Detail == "" {
+				imageUrl.Detail = "auto"
+			}
+			mediaContents = append(mediaContents, dto.MediaContent{Type: dto.ContentTypeImageURL, ImageUrl: imageUrl})
+		case "input_file":
+			if part.FileUrl != "" {
+				return nil, errors.New("input_file with file_url is not supported by this channel")
+			}
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeFile,
+				File: dto.MessageFile{FileName: part.Filename, FileData: part.FileData, FileId: part.FileId},
+			})
+		}
+	}
+	return mediaContents, nil
+}
+
+// responsesFunctionOutput function_call_output 的 output 可以是字符串或内容数组
+func responsesFunctionOutput(raw json.RawMessage) string {
+	if common.GetJsonType(raw) == "string" {
+		var output string
+		_ = common.Unmarshal(raw, &output)
+		return output
+	}
+	var parts []responsesContentPart
+	if err := common.Unmarshal(raw, &parts); err != nil {
+		return string(raw)
+	}
+	var sb strings.Builder
+	for _, part := range parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String()
+}
+
+func responsesInputToMessages(raw json.RawMessage, defaultRole string) ([]dto.Message, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if common.GetJsonType(raw) == "string" {
+		var text string
+		if err := common.Unmarshal(raw, &text); err != nil {
+			return nil, err
+		}
+		message := dto.Message{Role: defaultRole}
+		message.SetStringContent(text)
+		return []dto.Message{message}, nil
+	}
+	var items []responsesInputItem
+	if err := common.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	var messages []dto.Message
+	var pendingToolCalls []dto.ToolCallRequest
+	// 连续的 function_call 合并到同一条 assistant 消息中
+	flushToolCalls := func() {
+		if len(pendingToolCalls) == 0 {
+			return
+		}
+		if n := len(messages); n > 0 && messages[n-1].Role == "assistant" && messages[n-1].ToolCalls == nil {
+			messages[n-1].SetToolCalls(pendingToolCalls)
+		} else {
+			message := dto.Message{Role: "assistant"}
+			message.SetNullContent()
+			message.SetToolCalls(pendingToolCalls)
+			messages = append(messages, message)
+		}
+		pendingToolCalls = nil
+	}
+
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			flushToolCalls()
+			role := item.Role
+			if role == "" {
+				role = defaultRole
+			}
+			if role == "developer" {
+				role = "system"
+			}
+			content, err := responsesContentToMediaContent(item.Content)
+			if err != nil {
+				return nil, err
+			}
+			message := dto.Message{Role: role}
+			switch v := content.(type) {
+			case string:
+				message.SetStringContent(v)
+			case []dto.MediaContent:
+				message.SetMediaContent(v)
+			}
+			messages = append(messages, message)
+		case dto.ResponsesOutputItemFunctionCall:
+			pendingToolCalls = append(pendingToolCalls, dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+		case "function_call_output":
+			flushToolCalls()
+			message := dto.Message{Role: "tool", ToolCallId: item.CallId}
+			message.SetStringContent(responsesFunctionOutput(item.Output))
+			messages = append(messages, message)
+		case dto.ResponsesOutputItemReasoning:
+			// 推理内容无法回传给其他厂商的模型，忽略
+		default:
+			return nil, fmt.Errorf("input item type %s is not supported by this channel", item.Type)
+		}
+	}
+	flushToolCalls()
+	return messages, nil
+}
+
+// ResponsesToOpenAIRequest 将 Responses API 请求转换为 Chat Completions 请求
+func ResponsesToOpenAIRequest(responsesRequest dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:     responsesRequest.Model,
+		Stream:    responsesRequest.Stream,
+		MaxTokens: responsesRequest.MaxOutputTokens,
+		TopP:      responsesRequest.TopP,
+		User:      responsesRequest.User,
+	}
+	if responsesRequest.Temperature != 0 {
+		openAIRequest.Temperature = common.GetPointer(responsesRequest.Temperature)
+	}
+	if responsesRequest.Reasoning != nil {
+		openAIRequest.ReasoningEffort = responsesRequest.Reasoning.Effort
+	}
+
+	if len(responsesRequest.Instructions) > 0 && common.GetJsonType(responsesRequest.Instructions) != "null" {
+		systemMessages, err := responsesInputToMessages(responsesRequest.Instructions, "system")
+		if err != nil {
+			return nil, err
+		}
+		openAIRequest.Messages = append(openAIRequest.Messages, systemMessages...)
+	}
+	messages, err := responsesInputToMessages(responsesRequest.Input, "user")
+	if err != nil {
+		return nil, err
+	}
+	openAIRequest.Messages = append(openAIRequest.Messages, messages...)
+
+	// 内置工具（web_search、file_search 等）只能由 OpenAI 执行，其他渠道直接忽略
+	for _, tool := range responsesRequest.GetToolsMap() {
+		if common.Interface2String(tool["type"]) != "function" {
+			continue
+		}
+		openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        common.Interface2String(tool["name"]),
+				Description: common.Interface2String(tool["description"]),
+				Parameters:  tool["parameters"],
+			},
+		})
+	}
+
+	if len(responsesRequest.ToolChoice) > 0 {
+		switch common.GetJsonType(responsesRequest.ToolChoice) {
+		case "string":
+			var toolChoice string
+			_ = common.Unmarshal(responsesRequest.ToolChoice, &toolChoice)
+			openAIRequest.ToolChoice = toolChoice
+		case "object":
+			var toolChoice map[string]any
+			_ = common.Unmarshal(responsesRequest.ToolChoice, &toolChoice)
+			if common.Interface2String(toolChoice["type"]) == "function" {
+				openAIRequest.ToolChoice = map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": toolChoice["name"]},
+				}
+			}
+		}
+	}
+
+	if len(responsesRequest.Text) > 0 {
+		var textFormat responsesTextFormat
+		if err := common.Unmarshal(responsesRequest.Text, &textFormat); err == nil {
+			openAIRequest.Verbosity = textFormat.Verbosity
+			if textFormat.Format != nil {
+				switch textFormat.Format.Type {
+				case "json_schema":
+					jsonSchema, err := common.Marshal(dto.FormatJsonSchema{
+						Description: textFormat.Format.Description,
+						Name:        textFormat.Format.Name,
+						Schema:      textFormat.Format.Schema,
+						Strict:      textFormat.Format.Strict,
+					})
+					if err != nil {
+						return nil, err
+					}
+					openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: jsonSchema}
+				case "json_object":
+					openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+				}
+			}
+		}
+	}
+	return &openAIRequest, nil
+}
+
+func stringFromJson(raw json.RawMessage) string {
+	if common.GetJsonType(raw) != "string" {
+		return ""
+	}
+	var str string
+	_ = common.Unmarshal(raw, &str)
+	return str
+}
+
+// newResponsesResponse 根据请求参数构造 Responses 对象的公共字段
+func newResponsesResponse(id string, modelName string, createdAt int64, request *dto.OpenAIResponsesRequest) *dto.OpenAIResponsesResponse {
+	response := &dto.OpenAIResponsesResponse{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          int(createdAt),
+		Status:             "in_progress",
+		Model:              modelName,
+		Output:             make([]dto.ResponsesOutput, 0),
+		ToolChoice:         "auto",
+		Tools:              make([]map[string]any, 0),
+		PreviousResponseID: request.PreviousResponseID,
+		Reasoning:          request.Reasoning,
+		Store:              request.Store != nil && *request.Store,
+		Temperature:        request.Temperature,
+		TopP:               request.TopP,
+		Truncation:         request.Truncation,
+		MaxOutputTokens:    int(request.MaxOutputTokens),
+		ParallelToolCalls:  request.ParallelToolCalls,
+		Instructions:       stringFromJson(request.Instructions),
+		Metadata:           request.Metadata,
+	}
+	if toolChoice := stringFromJson(request.ToolChoice); toolChoice != "" {
+		response.ToolChoice = toolChoice
+	}
+	if tools := request.GetToolsMap(); tools != nil {
+		response.Tools = tools
+	}
+	if request.User != "" {
+		response.User, _ = common.Marshal(request.User)
+	}
+	return response
+}
+
+func usageOpenAI2Responses(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	responsesUsage := *usage
+	responsesUsage.InputTokens = usage.PromptTokens
+	responsesUsage.OutputTokens = usage.CompletionTokens
+	responsesUsage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	responsesUsage.InputTokensDetails = &dto.InputTokenDetails{
+		CachedTokens: usage.PromptTokensDetails.CachedTokens,
+	}
+	return &responsesUsage
+}
+
+func responsesStatusFromFinishReason(finishReason string) string {
+	if finishReason == "length" {
+		return "incomplete"
+	}
+	return "completed"
+}
+
+// ResponseOpenAI2Responses 将 Chat Completions 非流式响应转换为 Responses 对象
+func ResponseOpenAI2Responses(openAIResponse *dto.OpenAITextResponse, request *dto.OpenAIResponsesRequest, id string, modelName string, createdAt int64, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	response := newResponsesResponse(id, modelName, createdAt, request)
+	response.Status = "completed"
+	response.Usage = usageOpenAI2Responses(usage)
+	if len(openAIResponse.Choices) == 0 {
+		return response
+	}
+	choice := openAIResponse.Choices[0]
+	response.Status = responsesStatusFromFinishReason(choice.FinishReason)
+	itemId := strings.TrimPrefix(id, "resp_")
+
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+	if reasoning != "" {
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type:    dto.ResponsesOutputItemReasoning,
+			ID:      fmt.Sprintf("rs_%s_%d", itemId, len(response.Output)),
+			Status:  "completed",
+			Summary: []dto.ResponsesOutputContent{{Type: "summary_text", Text: reasoning}},
+		})
+	}
+	if text := choice.Message.StringContent(); text != "" {
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type:    dto.ResponsesOutputItemMessage,
+			ID:      fmt.Sprintf("msg_%s_%d", itemId, len(response.Output)),
+			Status:  "completed",
+			Role:    "assistant",
+			Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}},
+		})
+	}
+	for _, toolCall := range choice.Message.ParseToolCalls() {
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type:      dto.ResponsesOutputItemFunctionCall,
+			ID:        fmt.Sprintf("fc_%s_%d", itemId, len(response.Output)),
+			Status:    "completed",
+			CallId:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+	return response
+}
+
+// ResponsesStreamConverter 将 Chat Completions 流式响应逐块转换为 Responses 流式事件
+type ResponsesStreamConverter struct {
+	response     *dto.OpenAIResponsesResponse
+	itemId       string
+	sequence     int
+	finishReason string
+
+	// 当前未结束的输出项在 response.Output 中的下标，-1 表示没有
+	reasoningIndex int
+	messageIndex   int
+	toolIndexes    map[int]int
+	lastToolKey    int
+	builders       map[int]*strings.Builder
+}
+
+func NewResponsesStreamConverter(id string, modelName string, createdAt int64, request *dto.OpenAIResponsesRequest) *ResponsesStreamConverter {
+	return &ResponsesStreamConverter{
+		response:       newResponsesResponse(id, modelName, createdAt, request),
+		itemId:         strings.TrimPrefix(id, "resp_"),
+		reasoningIndex: -1,
+		messageIndex:   -1,
+		toolIndexes:    make(map[int]int),
+		lastToolKey:    -1,
+		builders:       make(map[int]*strings.Builder),
+	}
+}
+
+func (s *ResponsesStreamConverter) event(event dto.ResponsesStreamResponse) dto.ResponsesStreamResponse {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return event
+}
+
+// snapshot 返回当前 response 的副本，避免后续修改影响已生成的事件
+func (s *ResponsesStreamConverter) snapshot() *dto.OpenAIResponsesResponse {
+	response := *s.response
+	response.Output = append([]dto.ResponsesOutput{}, s.response.Output...)
+	return &response
+}
+
+// Start 返回 response.created 与 response.in_progress 事件
+func (s *ResponsesStreamConverter) Start() []dto.ResponsesStreamResponse {
+	return []dto.ResponsesStreamResponse{
+		s.event(dto.ResponsesStreamResponse{Type: "response.created", Response: s.snapshot()}),
+		s.event(dto.ResponsesStreamResponse{Type: "response.in_progress", Response: s.snapshot()}),
+	}
+}
+
+func (s *ResponsesStreamConverter) addItem(item dto.ResponsesOutput) (int, dto.ResponsesStreamResponse) {
+	index := len(s.response.Output)
+	s.response.Output = append(s.response.Output, item)
+	s.builders[index] = &strings.Builder{}
+	added := item
+	return index, s.event(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: common.GetPointer(index), Item: &added})
+}
+
+func (s *ResponsesStreamConverter) closeReasoning() []dto.ResponsesStreamResponse {
+	if s.reasoningIndex < 0 {
+		return nil
+	}
+	index := s.reasoningIndex
+	s.reasoningIndex = -1
+	item := &s.response.Output[index]
+	text := s.builders[index].String()
+	part := dto.ResponsesOutputContent{Type: "summary_text", Text: text}
+	item.Status = "completed"
+	item.Summary = []dto.ResponsesOutputContent{part}
+	done := *item
+	return []dto.ResponsesStreamResponse{
+		s.event(dto.ResponsesStreamResponse{Type: "response.reasoning_summary_text.done", ItemId: item.ID, OutputIndex: common.GetPointer(index), SummaryIndex: common.GetPointer(0), Text: common.GetPointer(text)}),
+		s.event(dto.ResponsesStreamResponse{Type: "response.reasoning_summary_part.done", ItemId: item.ID, OutputIndex: common.GetPointer(index), SummaryIndex: common.GetPointer(0), Part: &part}),
+		s.event(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: common.GetPointer(index), Item: &done}),
+	}
+}
+
+func (s *ResponsesStreamConverter) closeMessage() []dto.ResponsesStreamResponse {
+	if s.messageIndex < 0 {
+		return nil
+	}
+	index := s.messageIndex
+	s.messageIndex = -1
+	item := &s.response.Output[index]
+	text := s.builders[index].String()
+	part := dto.ResponsesOutputContent{Type: "output_text", Text: text, Annotations: []interface{}{}}
+	item.Status = "completed"
+	item.Content = []dto.ResponsesOutputContent{part}
+	done := *item
+	return []dto.ResponsesStreamResponse{
+		s.event(dto.ResponsesStreamResponse{Type: "response.output_text.done", ItemId: item.ID, OutputIndex: common.GetPointer(index), ContentIndex: common.GetPointer(0), Text: common.GetPointer(text)}),
+		s.event(dto.ResponsesStreamResponse{Type: "response.content_part.done", ItemId: item.ID, OutputIndex: common.GetPointer(index), ContentIndex: common.GetPointer(0), Part: &part}),
+		s.event(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: common.GetPointer(index), Item: &done}),
+	}
+}
+
+func (s *ResponsesStreamConverter) closeToolCalls() []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	for index := range s.response.Output {
+		item := &s.response.Output[index]
+		if item.Type != dto.ResponsesOutputItemFunctionCall || item.Status == "completed" {
+			continue
+		}
+		arguments := s.builders[index].String()
+		item.Status = "completed"
+		item.Arguments = arguments
+		done := *item
+		events = append(events,
+			s.event(dto.ResponsesStreamResponse{Type: "response.function_call_arguments.done", ItemId: item.ID, OutputIndex: common.GetPointer(index), Arguments: common.GetPointer(arguments)}),
+			s.event(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: common.GetPointer(index), Item: &done}),
+		)
+	}
+	s.toolIndexes = make(map[int]int)
+	s.lastToolKey = -1
+	return events
+}
+
+func (s *ResponsesStreamConverter) reasoningDelta(delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.reasoningIndex < 0 {
+		events = append(events, s.closeMessage()...)
+		events = append(events, s.closeToolCalls()...)
+		index, added := s.addItem(dto.ResponsesOutput{
+			Type:    dto.ResponsesOutputItemReasoning,
+			ID:      fmt.Sprintf("rs_%s_%d", s.itemId, len(s.response.Output)),
+			Status:  "in_progress",
+			Summary: []dto.ResponsesOutputContent{},
+		})
+		s.reasoningIndex = index
+		events = append(events, added, s.event(dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.added",
+			ItemId:       s.response.Output[index].ID,
+			OutputIndex:  common.GetPointer(index),
+			SummaryIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesOutputContent{Type: "summary_text"},
+		}))
+	}
+	index := s.reasoningIndex
+	s.builders[index].WriteString(delta)
+	return append(events, s.event(dto.ResponsesStreamResponse{
+		Type:         "response.reasoning_summary_text.delta",
+		ItemId:       s.response.Output[index].ID,
+		OutputIndex:  common.GetPointer(index),
+		SummaryIndex: common.GetPointer(0),
+		Delta:        delta,
+	}))
+}
+
+func (s *ResponsesStreamConverter) textDelta(delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.messageIndex < 0 {
+		events = append(events, s.closeReasoning()...)
+		events = append(events, s.closeToolCalls()...)
+		index, added := s.addItem(dto.ResponsesOutput{
+			Type:    dto.ResponsesOutputItemMessage,
+			ID:      fmt.Sprintf("msg_%s_%d", s.itemId, len(s.response.Output)),
+			Status:  "in_progress",
+			Role:    "assistant",
+			Content: []dto.ResponsesOutputContent{},
+		})
+		s.messageIndex = index
+		events = append(events, added, s.event(dto.ResponsesStreamResponse{
+			Type:         "response.content_part.added",
+			ItemId:       s.response.Output[index].ID,
+			OutputIndex:  common.GetPointer(index),
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesOutputContent{Type: "output_text", Annotations: []interface{}{}},
+		}))
+	}
+	index := s.messageIndex
+	s.builders[index].WriteString(delta)
+	return append(events, s.event(dto.ResponsesStreamResponse{
+		Type:         "response.output_text.delta",
+		ItemId:       s.response.Output[index].ID,
+		OutputIndex:  common.GetPointer(index),
+		ContentIndex: common.GetPointer(0),
+		Delta:        delta,
+	}))
+}
+
+func (s *ResponsesStreamConverter) toolCallDelta(toolCall dto.ToolCallResponse) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	// 部分渠道不返回 index，新的 id 视为新的工具调用
+	key := s.lastToolKey
+	if toolCall.Index != nil {
+		key = *toolCall.Index
+	} else if toolCall.ID != "" || key < 0 {
+		key++
+	}
+	s.lastToolKey = key
+	index, ok := s.toolIndexes[key]
+	if !ok {
+		events = append(events, s.closeReasoning()...)
+		events = append(events, s.closeMessage()...)
+		callId := toolCall.ID
+		if callId == "" {
+			callId = fmt.Sprintf("call_%s_%d", s.itemId, len(s.response.Output))
+		}
+		var added dto.ResponsesStreamResponse
+		index, added = s.addItem(dto.ResponsesOutput{
+			Type:   dto.ResponsesOutputItemFunctionCall,
+			ID:     fmt.Sprintf("fc_%s_%d", s.itemId, len(s.response.Output)),
+			Status: "in_progress",
+			CallId: callId,
+			Name:   toolCall.Function.Name,
+		})
+		s.toolIndexes[key] = index
+		events = append(events, added)
+	} else if toolCall.Function.Name != "" && s.response.Output[index].Name == "" {
+		s.response.Output[index].Name = toolCall.Function.Name
+	}
+	if toolCall.Function.Arguments == "" {
+		return events
+	}
+	s.builders[index].WriteString(toolCall.Function.Arguments)
+	return append(events, s.event(dto.ResponsesStreamResponse{
+		Type:        "response.function_call_arguments.delta",
+		ItemId:      s.response.Output[index].ID,
+		OutputIndex: common.GetPointer(index),
+		Delta:       toolCall.Function.Arguments,
+	}))
+}
+
+// Convert 转换一个 Chat Completions 流式块
+func (s *ResponsesStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	choice := chunk.Choices[0]
+	var events []dto.ResponsesStreamResponse
+	if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+		events = append(events, s.reasoningDelta(reasoning)...)
+	}
+	if content := choice.Delta.GetContentString(); content != "" {
+		events = append(events, s.textDelta(content)...)
+	}
+	for _, toolCall := range choice.Delta.ToolCalls {
+		events = append(events, s.toolCallDelta(toolCall)...)
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+	return events
+}
+
+// Finish 结束所有输出项，并返回携带用量的 response.completed（或 response.incomplete）事件
+func (s *ResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	events = append(events, s.closeReasoning()...)
+	events = append(events, s.closeMessage()...)
+	events = append(events, s.closeToolCalls()...)
+	s.response.Status = responsesStatusFromFinishReason(s.finishReason)
+	s.response.Usage = usageOpenAI2Responses(usage)
+	return append(events, s.event(dto.ResponsesStreamResponse{Type: "response." + s.response.Status, Response: s.snapshot()}))
+}
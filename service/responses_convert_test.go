@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"one-api/dto"
+	"strings"
+	"testing"
+)
+
+const testResponsesId = "resp_abc123"
+
+func readResponsesRequest(t *testing.T) *dto.OpenAIResponsesRequest {
+	t.Helper()
+	var request dto.OpenAIResponsesRequest
+	if err := json.Unmarshal(readTestdata(t, "responses_request.json"), &request); err != nil {
+		t.Fatal(err)
+	}
+	return &request
+}
+
+func TestResponsesToOpenAIRequest(t *testing.T) {
+	openAIRequest, err := ResponsesToOpenAIRequest(*readResponsesRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "responses_request.openai.golden.json", marshalIndent(t, openAIRequest))
+}
+
+func TestResponsesToOpenAIRequestInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "string input",
+			request: `{"model":"m","input":"hi"}`,
+			want:    `[{"role":"user","content":"hi"}]`,
+		},
+		{
+			name:    "array instructions become system messages",
+			request: `{"model":"m","instructions":[{"role":"developer","content":"be brief"}],"input":"hi"}`,
+			want:    `[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]`,
+		},
+		// 连续的 function_call 合并到前一条没有工具调用的 assistant 消息
+		{
+			name:    "function calls merge into assistant message",
+			request: `{"model":"m","input":[{"role":"assistant","content":"ok"},{"type":"function_call","call_id":"c1","name":"f","arguments":"{}"},{"type":"function_call_output","call_id":"c1","output":"done"}]}`,
+			want:    `[{"role":"assistant","content":"ok","tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{}"}}]},{"role":"tool","content":"done","tool_call_id":"c1"}]`,
+		},
+		{
+			name:    "function call without assistant message",
+			request: `{"model":"m","input":[{"role":"user","content":"hi"},{"type":"function_call","call_id":"c1","name":"f","arguments":"{}"}]}`,
+			want:    `[{"role":"user","content":"hi"},{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{}"}}]}]`,
+		},
+		{
+			name:    "image detail defaults to auto",
+			request: `{"model":"m","input":[{"role":"user","content":[{"type":"input_image","image_url":{"url":"https://example.com/a.png"}}]}]}`,
+			want:    `[{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/a.png","detail":"auto","MimeType":""}}]}]`,
+		},
+		{
+			name:    "unsupported item type",
+			request: `{"model":"m","input":[{"type":"computer_call"}]}`,
+			wantErr: "input item type computer_call is not supported by this channel",
+		},
+		{
+			name:    "image without url",
+			request: `{"model":"m","input":[{"role":"user","content":[{"type":"input_image","file_id":"file-1"}]}]}`,
+			wantErr: "input_image without image_url is not supported by this channel",
+		},
+		{
+			name:    "file url",
+			request: `{"model":"m","input":[{"role":"user","content":[{"type":"input_file","file_url":"https://example.com/a.pdf"}]}]}`,
+			wantErr: "input_file with file_url is not supported by this channel",
+		},
+	}
+	for _, tt := range tests {
+		var request dto.OpenAIResponsesRequest
+		if err := json.Unmarshal([]byte(tt.request), &request); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		openAIRequest, err := ResponsesToOpenAIRequest(request)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, _ := json.Marshal(openAIRequest.Messages)
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponseOpenAI2Responses(t *testing.T) {
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(readTestdata(t, "openai_response.json"), &response); err != nil {
+		t.Fatal(err)
+	}
+	responsesResponse := ResponseOpenAI2Responses(&response, readResponsesRequest(t), testResponsesId, "gpt-4o", 1700000000, &response.Usage)
+	assertGolden(t, "openai_response.responses.golden.json", marshalIndent(t, responsesResponse))
+}
+
+// TestResponsesStreamConverter 按 responses 桥接的方式逐块转换，最后以 Finish 结束
+func TestResponsesStreamConverter(t *testing.T) {
+	converter := NewResponsesStreamConverter(testResponsesId, "gpt-4o", 1700000000, readResponsesRequest(t))
+	var out bytes.Buffer
+	writeEvents := func(events []dto.ResponsesStreamResponse) {
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.Write(data)
+			out.WriteByte('\n')
+		}
+	}
+
+	writeEvents(converter.Start())
+	var usage *dto.Usage
+	scanner := bufio.NewScanner(bytes.NewReader(readTestdata(t, "openai_stream.txt")))
+	for scanner.Scan() {
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		writeEvents(converter.Convert(&chunk))
+	}
+	writeEvents(converter.Finish(usage))
+
+	assertGolden(t, "openai_stream.responses.golden.txt", out.Bytes())
+}
+
+func TestResponsesStreamConverterToolCallIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		toolCalls string
+		wantCalls []string
+	}{
+		{
+			name:      "with index",
+			toolCalls: `[{"index":0,"id":"a","function":{"name":"f","arguments":"{\"x\":"}}]|[{"index":1,"id":"b","function":{"name":"g","arguments":"{}"}}]|[{"index":0,"function":{"arguments":"1}"}}]`,
+			wantCalls: []string{`a f {"x":1}`, `b g {}`},
+		},
+		// 部分渠道不返回 index，新的 id 视为新的工具调用
+		{
+			name:      "without index",
+			toolCalls: `[{"id":"a","function":{"name":"f","arguments":"{\"x\":"}}]|[{"function":{"arguments":"1}"}}]|[{"id":"b","function":{"name":"g","arguments":"{}"}}]`,
+			wantCalls: []string{`a f {"x":1}`, `b g {}`},
+		},
+		{
+			name:      "name in later chunk and generated call id",
+			toolCalls: `[{"index":0,"function":{"arguments":""}}]|[{"index":0,"function":{"name":"f","arguments":"{}"}}]`,
+			wantCalls: []string{`call_abc123_0 f {}`},
+		},
+	}
+	for _, tt := range tests {
+		converter := NewResponsesStreamConverter(testResponsesId, "gpt-4o", 1700000000, &dto.OpenAIResponsesRequest{})
+		for _, toolCalls := range strings.Split(tt.toolCalls, "|") {
+			var chunk dto.ChatCompletionsStreamResponse
+			if err := json.Unmarshal([]byte(`{"choices":[{"delta":{"tool_calls":`+toolCalls+`}}]}`), &chunk); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			converter.Convert(&chunk)
+		}
+		events := converter.Finish(nil)
+		response := events[len(events)-1].Response
+		var gotCalls []string
+		for _, item := range response.Output {
+			gotCalls = append(gotCalls, item.CallId+" "+item.Name+" "+item.Arguments)
+		}
+		if strings.Join(gotCalls, "\n") != strings.Join(tt.wantCalls, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, gotCalls, tt.wantCalls)
+		}
+	}
+}
+
+func TestResponsesStatusFromFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"":           "completed",
+		"stop":       "completed",
+		"tool_calls": "completed",
+		"length":     "incomplete",
+	}
+	for finishReason, want := range tests {
+		if got := responsesStatusFromFinishReason(finishReason); got != want {
+			t.Errorf("finish reason %q: got %s, want %s", finishReason, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+)
+
+// RunSubscriptionJobs 定时为多月订阅按月发放额度，并处理到期的订阅
+func RunSubscriptionJobs() {
+	for {
+		if operation_setting.GetSubscriptionSetting().Enabled {
+			grantSubscriptionQuotas()
+			expireSubscriptions()
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
+
+func grantSubscriptionQuotas() {
+	subscriptions, err := model.GetSubscriptionsToGrant(common.GetTimestamp(), 500)
+	if err != nil {
+		common.SysLog("failed to get subscriptions to grant: " + err.Error())
+		return
+	}
+	for _, subscription := range subscriptions {
+		if err := model.GrantMonthlySubscriptionQuota(subscription.Id); err != nil {
+			common.SysLog(fmt.Sprintf("failed to grant quota for subscription %d: %v", subscription.Id, err))
+		}
+	}
+}
+
+func expireSubscriptions() {
+	now := common.GetTimestamp()
+	graceSeconds := int64(operation_setting.GetSubscriptionSetting().GraceHours) * 3600
+	subscriptions, err := model.GetExpiredSubscriptions(now, 500)
+	if err != nil {
+		common.SysLog("failed to get expired subscriptions: " + err.Error())
+		return
+	}
+	expired := 0
+	for _, subscription := range subscriptions {
+		// 自动续费的 Stripe 订阅在宽限期内等待续费通知
+		if subscription.StripeSubscriptionId != "" && subscription.AutoRenew && subscription.CurrentPeriodEnd+graceSeconds > now {
+			continue
+		}
+		if err := model.ExpireSubscription(subscription.Id); err != nil {
+			common.SysLog(fmt.Sprintf("failed to expire subscription %d: %v", subscription.Id, err))
+			continue
+		}
+		expired++
+	}
+	if expired > 0 {
+		common.SysLog(fmt.Sprintf("expired %d subscriptions", expired))
+	}
+}
@@ -1,10 +1,124 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"one-api/common"
 	"one-api/constant"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/setting/operation_setting"
 	"strings"
 )
 
 func CoverTaskActionToModelName(platform constant.TaskPlatform, action string) string {
 	return strings.ToLower(string(platform)) + "_" + strings.ToLower(action)
 }
+
+// RefundTaskQuota 返还任务预扣的额度到用户（或组织）与令牌，并记录退款日志
+func RefundTaskQuota(task *model.Task, reason string) {
+	quota := task.Quota
+	if quota == 0 {
+		return
+	}
+	if err := model.IncreasePayerQuota(task.UserId, task.OrganizationId, quota); err != nil {
+		common.SysLog(fmt.Sprintf("failed to refund task %s quota to user #%d: %v", task.TaskID, task.UserId, err))
+	}
+	if task.TokenId != 0 {
+		token, err := model.GetTokenById(task.TokenId)
+		if err == nil {
+			err = model.IncreaseTokenQuota(token.Id, token.Key, quota)
+		}
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to refund task %s quota to token #%d: %v", task.TaskID, task.TokenId, err))
+		}
+	}
+	logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, logger.LogQuota(quota))
+	if reason != "" {
+		logContent += "，原因：" + reason
+	}
+	model.RecordRefundLog(task.UserId, task.OrganizationId, task.TokenId, quota, logContent)
+}
+
+// FailTaskWithRefund 将未失败的任务标记为失败并退款，任务已失败（已退款）时返回错误
+func FailTaskWithRefund(task *model.Task, reason string) error {
+	now := common.GetTimestamp()
+	ok, err := model.TaskUpdateUnlessStatus(task.ID, []string{model.TaskStatusFailure}, map[string]any{
+		"status":      model.TaskStatusFailure,
+		"progress":    "100%",
+		"fail_reason": reason,
+		"finish_time": now,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("任务已失败，额度已退还")
+	}
+	task.Status = model.TaskStatusFailure
+	task.Progress = "100%"
+	task.FailReason = reason
+	task.FinishTime = now
+	RefundTaskQuota(task, reason)
+	EnqueueTaskWebhook(task)
+	return nil
+}
+
+// ApplyTaskPollResult 保存轮询得到的任务状态，仅在本次更新成功时对进入失败状态的任务退款并触发回调，
+// 避免与超时回收、管理员操作并发时重复退款。返回任务状态是否发生变化
+func ApplyTaskPollResult(task *model.Task, oldStatus model.TaskStatus) (bool, error) {
+	ok, err := task.UpdateUnlessFinished()
+	if err != nil || !ok || task.Status == oldStatus {
+		return false, err
+	}
+	if task.Status == model.TaskStatusFailure {
+		RefundTaskQuota(task, task.FailReason)
+	}
+	EnqueueTaskWebhook(task)
+	return true, nil
+}
+
+// CompleteTask 将未结束的任务标记为成功，不退款
+func CompleteTask(task *model.Task) error {
+	now := common.GetTimestamp()
+	ok, err := model.TaskUpdateUnlessStatus(task.ID, []string{model.TaskStatusFailure, model.TaskStatusSuccess}, map[string]any{
+		"status":      model.TaskStatusSuccess,
+		"progress":    "100%",
+		"finish_time": now,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("任务已结束")
+	}
+	task.Status = model.TaskStatusSuccess
+	task.Progress = "100%"
+	task.FinishTime = now
+	EnqueueTaskWebhook(task)
+	return nil
+}
+
+// ReapTimeoutTasks 将超过平台最大执行时长的任务标记为失败并退款，返回仍需轮询的任务
+func ReapTimeoutTasks(tasks []*model.Task) []*model.Task {
+	if !operation_setting.GetTaskTimeoutSetting().Enabled {
+		return tasks
+	}
+	now := common.GetTimestamp()
+	pending := make([]*model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		maxMinutes := operation_setting.GetTaskMaxMinutes(string(task.Platform))
+		if maxMinutes <= 0 || task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure || task.SubmitTime == 0 ||
+			now-task.SubmitTime <= int64(maxMinutes)*60 {
+			pending = append(pending, task)
+			continue
+		}
+		reason := fmt.Sprintf("任务超过最大执行时长 %d 分钟", maxMinutes)
+		if err := FailTaskWithRefund(task, reason); err != nil {
+			common.SysLog(fmt.Sprintf("failed to reap timeout task #%d: %v", task.ID, err))
+			continue
+		}
+		common.SysLog(fmt.Sprintf("task #%d (%s) timeout after %d minutes, refunded %d", task.ID, task.TaskID, maxMinutes, task.Quota))
+	}
+	return pending
+}
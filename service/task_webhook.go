@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const taskWebhookEventType = "task.status_changed"
+
+// TaskWebhookPayload 异步任务回调负载，task 字段与任务查询接口返回一致
+type TaskWebhookPayload struct {
+	Type      string       `json:"type"`
+	Platform  string       `json:"platform"`
+	Task      *dto.TaskDto `json:"task"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// ValidateTaskCallbackUrl 回调地址只允许 http/https，为空表示不回调
+func ValidateTaskCallbackUrl(callbackUrl string) error {
+	if callbackUrl == "" {
+		return nil
+	}
+	if len(callbackUrl) > 512 {
+		return errors.New("callback_url 长度不能超过 512")
+	}
+	u, err := url.Parse(callbackUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("callback_url 必须是有效的 http/https 地址")
+	}
+	return checkCallbackHost(u.Hostname())
+}
+
+// checkCallbackHost 解析回调地址的主机，拒绝内网、回环与链路本地地址，避免回调被用于访问内部服务
+var checkCallbackHost = func(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("callback_url 主机解析失败: %v", err)
+	}
+	for _, addr := range addrs {
+		if isInternalIP(addr.IP) {
+			return fmt.Errorf("callback_url 不能指向内网地址 %s", addr.IP)
+		}
+	}
+	return nil
+}
+
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// GetTaskCallbackUrl 获取任务回调地址，请求中的 callback_url 优先，其次使用令牌配置
+func GetTaskCallbackUrl(c *gin.Context) (string, error) {
+	var callbackUrl string
+	if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			CallbackUrl string `json:"callback_url"`
+		}
+		if err := common.UnmarshalBodyReusable(c, &req); err == nil {
+			callbackUrl = req.CallbackUrl
+		}
+	} else {
+		callbackUrl = c.PostForm("callback_url")
+	}
+	if callbackUrl == "" {
+		callbackUrl = common.GetContextKeyString(c, constant.ContextKeyTokenCallbackUrl)
+	}
+	if err := ValidateTaskCallbackUrl(callbackUrl); err != nil {
+		return "", err
+	}
+	return callbackUrl, nil
+}
+
+// EnqueueTaskWebhook 任务状态变更后记录一次待投递的回调，由 RunTaskWebhookDeliveries 发送
+func EnqueueTaskWebhook(task *model.Task) {
+	if task == nil || task.CallbackUrl == "" || !operation_setting.GetTaskWebhookSetting().Enabled {
+		return
+	}
+	payload := TaskWebhookPayload{
+		Type:     taskWebhookEventType,
+		Platform: string(task.Platform),
+		Task: &dto.TaskDto{
+			TaskID:     task.TaskID,
+			Action:     task.Action,
+			Status:     string(task.Status),
+			FailReason: task.FailReason,
+			SubmitTime: task.SubmitTime,
+			StartTime:  task.StartTime,
+			FinishTime: task.FinishTime,
+			Progress:   task.Progress,
+			Data:       task.Data,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to marshal task webhook payload: %v", err))
+		return
+	}
+	delivery := &model.TaskWebhookDelivery{
+		TaskId:        task.ID,
+		UserId:        task.UserId,
+		Url:           task.CallbackUrl,
+		Event:         string(task.Status),
+		Payload:       string(payloadBytes),
+		Status:        model.TaskWebhookStatusPending,
+		NextAttemptAt: common.GetTimestamp(),
+	}
+	if err = delivery.Insert(); err != nil {
+		common.SysLog(fmt.Sprintf("failed to insert task webhook delivery: %v", err))
+	}
+}
+
+// DeliverTaskWebhook 投递一次回调并记录结果，签名使用用户通知设置中的 webhook 密钥
+func DeliverTaskWebhook(delivery *model.TaskWebhookDelivery) error {
+	webhookSetting := operation_setting.GetTaskWebhookSetting()
+	var secret string
+	if userSetting, err := model.GetUserSetting(delivery.UserId, false); err == nil {
+		secret = userSetting.WebhookSecret
+	}
+
+	now := common.GetTimestamp()
+	// 投递时重新检查地址，域名解析结果可能已经变为内网地址
+	var statusCode int
+	err := ValidateTaskCallbackUrl(delivery.Url)
+	if err == nil {
+		statusCode, err = postWebhook(delivery.Url, secret, []byte(delivery.Payload))
+	}
+	delivery.Attempts++
+	delivery.ResponseCode = statusCode
+	if err == nil {
+		delivery.Status = model.TaskWebhookStatusSuccess
+		delivery.LastError = ""
+		delivery.DeliveredAt = now
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= webhookSetting.MaxAttempts {
+			delivery.Status = model.TaskWebhookStatusFailed
+		} else {
+			// 指数退避：base * 2^(attempts-1)，最多等待 base * 1024 秒
+			delivery.Status = model.TaskWebhookStatusPending
+			delivery.NextAttemptAt = now + int64(webhookSetting.RetryBaseSeconds)<<min(delivery.Attempts-1, 10)
+		}
+	}
+	if updateErr := delivery.Update(); updateErr != nil {
+		common.SysLog(fmt.Sprintf("failed to update task webhook delivery #%d: %v", delivery.Id, updateErr))
+	}
+	return err
+}
+
+// RunTaskWebhookDeliveries 定时投递到期的任务回调，仅在主节点运行
+func RunTaskWebhookDeliveries() {
+	for {
+		time.Sleep(5 * time.Second)
+		deliveries, err := model.GetDueTaskWebhookDeliveries(100)
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to get task webhook deliveries: %v", err))
+			continue
+		}
+		for _, delivery := range deliveries {
+			if err = DeliverTaskWebhook(delivery); err != nil {
+				common.SysLog(fmt.Sprintf("task webhook delivery #%d failed (attempt %d): %v", delivery.Id, delivery.Attempts, err))
+			}
+		}
+	}
+}
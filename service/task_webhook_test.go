@@ -0,0 +1,171 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidateTaskCallbackUrl(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr string
+	}{
+		{url: ""},
+		{url: "https://8.8.8.8/callback"},
+		{url: "http://1.1.1.1:8080/callback?id=1"},
+		{url: "ftp://8.8.8.8/callback", wantErr: "有效的 http/https"},
+		{url: "http:///callback", wantErr: "有效的 http/https"},
+		{url: "http://" + strings.Repeat("a", 512) + ".com", wantErr: "长度"},
+		// 内网、回环与链路本地地址
+		{url: "http://127.0.0.1/callback", wantErr: "内网地址"},
+		{url: "http://localhost:3000/callback", wantErr: "内网地址"},
+		{url: "http://10.0.0.8/callback", wantErr: "内网地址"},
+		{url: "http://192.168.1.1/callback", wantErr: "内网地址"},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: "内网地址"},
+		{url: "http://[::1]:8080/callback", wantErr: "内网地址"},
+		{url: "http://[fd00::1]/callback", wantErr: "内网地址"},
+		{url: "http://0.0.0.0/callback", wantErr: "内网地址"},
+	}
+	for _, tt := range tests {
+		err := ValidateTaskCallbackUrl(tt.url)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: got %v, want %q", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+// allowLocalCallbacks 测试回调发送到本地测试服务器
+func allowLocalCallbacks(t *testing.T) {
+	original := checkCallbackHost
+	checkCallbackHost = func(host string) error { return nil }
+	t.Cleanup(func() { checkCallbackHost = original })
+}
+
+func TestDeliverTaskWebhookBackoff(t *testing.T) {
+	setting := operation_setting.GetTaskWebhookSetting()
+	defer func(original operation_setting.TaskWebhookSetting) {
+		*setting = original
+	}(*setting)
+	setting.MaxAttempts, setting.RetryBaseSeconds = 3, 10
+
+	var requests atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	// 地址指向内网时投递前拒绝，不发送请求
+	delivery := &model.TaskWebhookDelivery{Url: server.URL, Payload: "{}", Status: model.TaskWebhookStatusPending}
+	if err := delivery.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeliverTaskWebhook(delivery); err == nil || requests.Load() != 0 {
+		t.Fatalf("internal callback delivered: %v, %d requests", err, requests.Load())
+	}
+
+	allowLocalCallbacks(t)
+	delivery = &model.TaskWebhookDelivery{Url: server.URL, Payload: "{}", Status: model.TaskWebhookStatusPending}
+	if err := delivery.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	// 失败后按 base * 2^(attempts-1) 退避，达到最大次数后标记为失败
+	for _, want := range []struct {
+		status string
+		delay  int64
+	}{
+		{status: model.TaskWebhookStatusPending, delay: 10},
+		{status: model.TaskWebhookStatusPending, delay: 20},
+		{status: model.TaskWebhookStatusFailed},
+	} {
+		now := common.GetTimestamp()
+		if err := DeliverTaskWebhook(delivery); err == nil {
+			t.Fatalf("attempt %d: expected error", delivery.Attempts)
+		}
+		stored, _ := model.GetTaskWebhookDeliveryById(delivery.Id)
+		if stored.Status != want.status || stored.ResponseCode != http.StatusInternalServerError {
+			t.Errorf("attempt %d: status %s code %d, want %s", stored.Attempts, stored.Status, stored.ResponseCode, want.status)
+		}
+		if delay := stored.NextAttemptAt - now; want.delay != 0 && (delay < want.delay || delay > want.delay+1) {
+			t.Errorf("attempt %d: next attempt in %ds, want %ds", stored.Attempts, delay, want.delay)
+		}
+	}
+
+	fail.Store(false)
+	delivery.Status = model.TaskWebhookStatusPending
+	if err := DeliverTaskWebhook(delivery); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := model.GetTaskWebhookDeliveryById(delivery.Id); stored.Status != model.TaskWebhookStatusSuccess || stored.LastError != "" || stored.DeliveredAt == 0 {
+		t.Errorf("redelivery: %+v", stored)
+	}
+}
+
+func TestEnqueueTaskWebhookOnTransition(t *testing.T) {
+	setting := operation_setting.GetTaskWebhookSetting()
+	defer func(enabled bool) {
+		setting.Enabled = enabled
+	}(setting.Enabled)
+	setting.Enabled = true
+
+	newTask := func(callbackUrl string) *model.Task {
+		task := &model.Task{TaskID: common.GetRandomString(16), Platform: "suno", UserId: 1, Status: model.TaskStatusInProgress, CallbackUrl: callbackUrl}
+		if err := task.Insert(); err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	deliveryEvents := func(task *model.Task) string {
+		deliveries, _, _ := model.GetTaskWebhookDeliveries(task.ID, "", 0, 10)
+		events := make([]string, 0, len(deliveries))
+		for i := len(deliveries) - 1; i >= 0; i-- {
+			events = append(events, deliveries[i].Event)
+		}
+		return strings.Join(events, " ")
+	}
+
+	// 轮询结果只在状态实际变化时回调，任务结束后的重复结果不再回调
+	task := newTask("https://example.com/callback")
+	for _, status := range []model.TaskStatus{model.TaskStatusInProgress, model.TaskStatusSuccess, model.TaskStatusFailure} {
+		oldStatus := task.Status
+		task.Status = status
+		if _, err := ApplyTaskPollResult(task, oldStatus); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := deliveryEvents(task); got != model.TaskStatusSuccess {
+		t.Errorf("poll deliveries: %q", got)
+	}
+
+	// 重复标记失败时只回调一次
+	task = newTask("https://example.com/callback")
+	if err := FailTaskWithRefund(task, "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := FailTaskWithRefund(task, "timeout"); err == nil {
+		t.Errorf("second failure should be rejected")
+	}
+	if got := deliveryEvents(task); got != model.TaskStatusFailure {
+		t.Errorf("failure deliveries: %q", got)
+	}
+
+	// 没有回调地址或关闭回调时不记录
+	task = newTask("")
+	_ = FailTaskWithRefund(task, "timeout")
+	setting.Enabled = false
+	disabled := newTask("https://example.com/callback")
+	_ = FailTaskWithRefund(disabled, "timeout")
+	if deliveryEvents(task) != "" || deliveryEvents(disabled) != "" {
+		t.Errorf("unexpected deliveries")
+	}
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type AssetSetting struct {
+	// 任务成功后将生成的视频、图片转存到网关存储，关闭后返回上游原始链接
+	Enabled bool `json:"enabled"`
+	// 转存资源保留天数
+	RetentionDays int `json:"retention_days"`
+	// 单个资源大小上限（MB），超过则不转存
+	MaxSizeMB int `json:"max_size_mb"`
+	// 每个用户的存储上限（MB），0 表示不限制
+	UserQuotaMB int `json:"user_quota_mb"`
+	// 签名链接有效期（秒）
+	SignedUrlExpireSeconds int `json:"signed_url_expire_seconds"`
+}
+
+// 默认配置
+var assetSetting = AssetSetting{
+	Enabled:                false,
+	RetentionDays:          30,
+	MaxSizeMB:              500,
+	UserQuotaMB:            0,
+	SignedUrlExpireSeconds: 3600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("asset_setting", &assetSetting)
+}
+
+func GetAssetSetting() *AssetSetting {
+	return &assetSetting
+}
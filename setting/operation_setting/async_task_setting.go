@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type AsyncTaskSetting struct {
+	// 开启 /v1/tasks 后台任务，默认关闭
+	Enabled bool `json:"enabled"`
+	// 同时在后台执行的 /v1/tasks 任务数量
+	MaxConcurrentTasks int `json:"max_concurrent_tasks"`
+}
+
+// 默认配置
+var asyncTaskSetting = AsyncTaskSetting{
+	Enabled:            false,
+	MaxConcurrentTasks: 10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("async_task_setting", &asyncTaskSetting)
+}
+
+func GetAsyncTaskSetting() *AsyncTaskSetting {
+	return &asyncTaskSetting
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type BatchSetting struct {
+	// 开启 /v1/batches 批处理及后台执行器，默认关闭
+	Enabled bool `json:"enabled"`
+	// 批处理请求在模型倍率、分组倍率基础上的折扣倍率
+	DiscountRatio float64 `json:"discount_ratio"`
+	// 同时执行的批处理任务数量
+	MaxConcurrentBatches int `json:"max_concurrent_batches"`
+	// 单个批处理任务最多包含的请求数量
+	MaxRequestsPerBatch int `json:"max_requests_per_batch"`
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Enabled:              false,
+	DiscountRatio:        0.5,
+	MaxConcurrentBatches: 2,
+	MaxRequestsPerBatch:  50000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type BillingStatementSetting struct {
+	// 每月初自动为上月有额度变动的用户生成月度账单
+	Enabled bool `json:"enabled"`
+	// 同时为有消费的令牌生成令牌账单
+	TokenStatementEnabled bool `json:"token_statement_enabled"`
+}
+
+// 默认配置
+var billingStatementSetting = BillingStatementSetting{
+	Enabled:               false,
+	TokenStatementEnabled: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("billing_statement_setting", &billingStatementSetting)
+}
+
+func GetBillingStatementSetting() *BillingStatementSetting {
+	return &billingStatementSetting
+}
@@ -0,0 +1,42 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ChannelBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// 统计窗口（秒）
+	WindowSeconds int `json:"window_seconds"`
+	// 窗口内请求数达到该值后才会计算错误率
+	MinRequests int `json:"min_requests"`
+	// 错误率达到该值时熔断
+	ErrorRateThreshold float64 `json:"error_rate_threshold"`
+	// 平均延迟超过该值（毫秒）时降低渠道权重，0 表示不根据延迟降权
+	LatencyThresholdMs int `json:"latency_threshold_ms"`
+	// 首次熔断时长（秒），连续熔断时按倍数递增
+	OpenSeconds int `json:"open_seconds"`
+	// 最大熔断时长（秒）
+	MaxOpenSeconds int `json:"max_open_seconds"`
+	// 半开状态下允许同时进行的探测请求数
+	HalfOpenProbes int `json:"half_open_probes"`
+}
+
+// 默认配置
+var channelBreakerSetting = ChannelBreakerSetting{
+	Enabled:            true,
+	WindowSeconds:      60,
+	MinRequests:        10,
+	ErrorRateThreshold: 0.5,
+	LatencyThresholdMs: 0,
+	OpenSeconds:        30,
+	MaxOpenSeconds:     600,
+	HalfOpenProbes:     1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_breaker_setting", &channelBreakerSetting)
+}
+
+func GetChannelBreakerSetting() *ChannelBreakerSetting {
+	return &channelBreakerSetting
+}
@@ -0,0 +1,22 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CountTokensSetting struct {
+	// 渠道支持原生 token 计数（Claude、Gemini）时请求上游，否则在本地估算
+	UpstreamEnabled bool `json:"upstream_enabled"`
+}
+
+// 默认配置
+var countTokensSetting = CountTokensSetting{
+	UpstreamEnabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("count_tokens_setting", &countTokensSetting)
+}
+
+func GetCountTokensSetting() *CountTokensSetting {
+	return &countTokensSetting
+}
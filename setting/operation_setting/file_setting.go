@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type FileSetting struct {
+	// 单个文件最大大小（MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// 每个用户可用的文件存储空间（MB），0 表示不限制
+	UserStorageLimitMB int `json:"user_storage_limit_mb"`
+	// 请求中引用本地文件时，是否上传到 OpenAI 类渠道并使用上游文件 ID，默认关闭
+	PassThroughEnabled bool `json:"pass_through_enabled"`
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	MaxFileSizeMB:      512,
+	UserStorageLimitMB: 1024,
+	PassThroughEnabled: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
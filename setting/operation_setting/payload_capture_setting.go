@@ -0,0 +1,52 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// PayloadRedactionRule 脱敏规则，将匹配正则的内容替换为 Replacement
+type PayloadRedactionRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+type PayloadCaptureSetting struct {
+	Enabled bool `json:"enabled"`
+	// 对这些分组的所有请求开启记录，令牌也可以单独开启
+	Groups []string `json:"groups"`
+	// 请求体最大记录大小（KB），超出部分截断
+	MaxRequestBodyKB int `json:"max_request_body_kb"`
+	// 响应体最大记录大小（KB），超出部分截断，流式响应同样适用
+	MaxResponseBodyKB int `json:"max_response_body_kb"`
+	// 记录保留天数，0 表示不自动清理
+	RetentionDays int `json:"retention_days"`
+	// 是否允许用户查看自己的请求记录
+	UserViewEnabled bool `json:"user_view_enabled"`
+	// 脱敏规则
+	RedactionRules []PayloadRedactionRule `json:"redaction_rules"`
+}
+
+// 默认配置
+var payloadCaptureSetting = PayloadCaptureSetting{
+	Enabled:           false,
+	Groups:            []string{},
+	MaxRequestBodyKB:  64,
+	MaxResponseBodyKB: 256,
+	RetentionDays:     7,
+	UserViewEnabled:   true,
+	RedactionRules: []PayloadRedactionRule{
+		{Name: "api_key", Pattern: `sk-[A-Za-z0-9_\-]{16,}`, Replacement: "sk-***"},
+		{Name: "bearer", Pattern: `(?i)bearer\s+[A-Za-z0-9_\-\.=]{16,}`, Replacement: "Bearer ***"},
+		{Name: "email", Pattern: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`, Replacement: "***@***"},
+		{Name: "phone", Pattern: `\b1[3-9]\d{9}\b`, Replacement: "1**********"},
+		{Name: "id_card", Pattern: `\b\d{17}[\dXx]\b`, Replacement: "******************"},
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("payload_capture_setting", &payloadCaptureSetting)
+}
+
+func GetPayloadCaptureSetting() *PayloadCaptureSetting {
+	return &payloadCaptureSetting
+}
@@ -0,0 +1,40 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ResponseCacheSetting struct {
+	// 总开关，开启后仍需在令牌中单独开启，默认关闭
+	Enabled bool `json:"enabled"`
+	// 缓存有效期（秒）
+	TTLSeconds int `json:"ttl_seconds"`
+	// 命中缓存时的计费倍率
+	HitBillingRatio float64 `json:"hit_billing_ratio"`
+	// 仅缓存 temperature=0 的请求
+	OnlyZeroTemperature bool `json:"only_zero_temperature"`
+	// 是否在不同用户之间共享缓存
+	SharedAcrossUsers bool `json:"shared_across_users"`
+	// 单条缓存响应的最大大小（KB），超过时不缓存
+	MaxEntrySizeKB int `json:"max_entry_size_kb"`
+	// 未启用 Redis 时内存中最多保存的缓存条数
+	MaxMemoryEntries int `json:"max_memory_entries"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:             false,
+	TTLSeconds:          3600,
+	HitBillingRatio:     0.1,
+	OnlyZeroTemperature: true,
+	SharedAcrossUsers:   false,
+	MaxEntrySizeKB:      1024,
+	MaxMemoryEntries:    10000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
@@ -0,0 +1,40 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ResponsesStoreSetting struct {
+	// 是否在网关保存 /v1/responses 的响应，用于 previous_response_id 与查询接口
+	Enabled bool `json:"enabled"`
+	// 请求未指定 store 时是否保存，OpenAI 默认保存
+	DefaultStore bool `json:"default_store"`
+	// 保存天数，过期后自动删除
+	RetentionDays int `json:"retention_days"`
+	// 每个令牌最多保存的响应数，超出时删除最早的，0 表示不限制
+	MaxResponsesPerToken int `json:"max_responses_per_token"`
+	// 单个响应（含输入）最大大小（KB），超出时不保存
+	MaxResponseSizeKB int `json:"max_response_size_kb"`
+	// 每 MB 存储收取的额度（保存时一次性收取）
+	QuotaPerMB int `json:"quota_per_mb"`
+	// previous_response_id 最多向前追溯的响应数
+	MaxChainDepth int `json:"max_chain_depth"`
+}
+
+// 默认配置
+var responsesStoreSetting = ResponsesStoreSetting{
+	Enabled:              true,
+	DefaultStore:         true,
+	RetentionDays:        30,
+	MaxResponsesPerToken: 1000,
+	MaxResponseSizeKB:    2048,
+	QuotaPerMB:           500,
+	MaxChainDepth:        100,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("responses_store_setting", &responsesStoreSetting)
+}
+
+func GetResponsesStoreSetting() *ResponsesStoreSetting {
+	return &responsesStoreSetting
+}
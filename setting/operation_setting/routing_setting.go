@@ -0,0 +1,87 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/setting/config"
+	"strings"
+)
+
+const (
+	RoutingStrategyWeightedRandom = "weighted_random"
+	RoutingStrategyLowestTTFT     = "lowest_ttft"
+	RoutingStrategyLowestPrice    = "lowest_price"
+	RoutingStrategyLeastInFlight  = "least_inflight"
+	RoutingStrategyStickyUser     = "sticky_user"
+)
+
+type RoutingSetting struct {
+	// 默认路由策略
+	DefaultStrategy string `json:"default_strategy"`
+	// 按分组/模型指定路由策略，key 格式为 "分组:模型"，分组或模型可使用 * 通配，
+	// 例如 {"default:gpt-4o": "lowest_ttft", "vip:*": "least_inflight", "*:claude-3-5-sonnet": "sticky_user"}
+	Strategies map[string]string `json:"strategies"`
+}
+
+// 默认配置
+var routingSetting = RoutingSetting{
+	DefaultStrategy: RoutingStrategyWeightedRandom,
+	Strategies:      map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("routing_setting", &routingSetting)
+}
+
+func GetRoutingSetting() *RoutingSetting {
+	return &routingSetting
+}
+
+// GetRoutingStrategy 获取分组和模型对应的路由策略，优先级：分组:模型 > 分组:* > *:模型 > 默认策略
+func GetRoutingStrategy(group string, model string) string {
+	for _, key := range []string{group + ":" + model, group + ":*", "*:" + model} {
+		if strategy, ok := routingSetting.Strategies[key]; ok && strategy != "" {
+			return strategy
+		}
+	}
+	if routingSetting.DefaultStrategy == "" {
+		return RoutingStrategyWeightedRandom
+	}
+	return routingSetting.DefaultStrategy
+}
+
+// IsValidRoutingStrategy 判断路由策略名称是否有效
+func IsValidRoutingStrategy(strategy string) bool {
+	switch strategy {
+	case RoutingStrategyWeightedRandom, RoutingStrategyLowestTTFT, RoutingStrategyLowestPrice,
+		RoutingStrategyLeastInFlight, RoutingStrategyStickyUser:
+		return true
+	}
+	return false
+}
+
+// CheckRoutingDefaultStrategy 校验默认路由策略，为空时使用加权随机
+func CheckRoutingDefaultStrategy(strategy string) error {
+	if strategy != "" && !IsValidRoutingStrategy(strategy) {
+		return fmt.Errorf("未知的路由策略：%s", strategy)
+	}
+	return nil
+}
+
+// CheckRoutingStrategies 校验按分组/模型指定的路由策略配置
+func CheckRoutingStrategies(jsonStr string) error {
+	strategies := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &strategies); err != nil {
+		return err
+	}
+	for key, strategy := range strategies {
+		if group, model, ok := strings.Cut(key, ":"); !ok || group == "" || model == "" {
+			return fmt.Errorf("路由策略的 key 格式应为 \"分组:模型\"：%s", key)
+		}
+		if !IsValidRoutingStrategy(strategy) {
+			return fmt.Errorf("%s 的路由策略未知：%s", key, strategy)
+		}
+	}
+	return nil
+}
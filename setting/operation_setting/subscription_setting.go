@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type SubscriptionSetting struct {
+	// 运行订阅续期（按月发放额度）与到期任务
+	Enabled bool `json:"enabled"`
+	// Stripe 订阅周期结束后等待续费通知的宽限时长（小时），超过后订阅到期
+	GraceHours int `json:"grace_hours"`
+}
+
+// 默认配置
+var subscriptionSetting = SubscriptionSetting{
+	Enabled:    false,
+	GraceHours: 24,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("subscription_setting", &subscriptionSetting)
+}
+
+func GetSubscriptionSetting() *SubscriptionSetting {
+	return &subscriptionSetting
+}
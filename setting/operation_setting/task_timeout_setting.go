@@ -0,0 +1,38 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type TaskTimeoutSetting struct {
+	// 回收超过最大执行时长仍未完成的异步任务，标记失败并退款
+	Enabled bool `json:"enabled"`
+	// 默认最大执行时长（分钟），0 表示不限制
+	DefaultMaxMinutes int `json:"default_max_minutes"`
+	// 按平台配置最大执行时长（分钟），key 为任务平台：suno 或视频渠道类型编号（如 Kling 为 50）
+	PlatformMaxMinutes map[string]int `json:"platform_max_minutes"`
+}
+
+// 默认配置
+var taskTimeoutSetting = TaskTimeoutSetting{
+	Enabled:           true,
+	DefaultMaxMinutes: 120,
+	PlatformMaxMinutes: map[string]int{
+		"suno": 30,
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_timeout_setting", &taskTimeoutSetting)
+}
+
+func GetTaskTimeoutSetting() *TaskTimeoutSetting {
+	return &taskTimeoutSetting
+}
+
+// GetTaskMaxMinutes 获取平台的最大执行时长（分钟），未单独配置时使用默认值
+func GetTaskMaxMinutes(platform string) int {
+	if minutes, ok := taskTimeoutSetting.PlatformMaxMinutes[platform]; ok {
+		return minutes
+	}
+	return taskTimeoutSetting.DefaultMaxMinutes
+}
@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type TaskWebhookSetting struct {
+	// 异步任务状态变更时向 callback_url 发送回调
+	Enabled bool `json:"enabled"`
+	// 最大投递次数，超过后标记为失败，可由管理员手动重新投递
+	MaxAttempts int `json:"max_attempts"`
+	// 重试退避基数（秒），第 n 次失败后等待 base * 2^(n-1) 秒
+	RetryBaseSeconds int `json:"retry_base_seconds"`
+}
+
+// 默认配置
+var taskWebhookSetting = TaskWebhookSetting{
+	Enabled:          true,
+	MaxAttempts:      6,
+	RetryBaseSeconds: 30,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_webhook_setting", &taskWebhookSetting)
+}
+
+func GetTaskWebhookSetting() *TaskWebhookSetting {
+	return &taskWebhookSetting
+}
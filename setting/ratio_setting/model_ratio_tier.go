@@ -0,0 +1,125 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/types"
+	"sort"
+	"sync"
+)
+
+// ModelRatioTier 上下文长度分段倍率，提示 token 数超过 PromptTokensAbove 时生效
+type ModelRatioTier struct {
+	PromptTokensAbove int     `json:"prompt_tokens_above"`
+	ModelRatio        float64 `json:"model_ratio"`
+	// 以下倍率为 0 时沿用模型的默认补全、缓存倍率
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      float64 `json:"cache_ratio,omitempty"`
+}
+
+var (
+	modelRatioTiersMap      = make(map[string][]ModelRatioTier)
+	modelRatioTiersMapMutex = sync.RWMutex{}
+)
+
+func ModelRatioTiers2JSONString() string {
+	modelRatioTiersMapMutex.RLock()
+	defer modelRatioTiersMapMutex.RUnlock()
+	jsonBytes, err := common.Marshal(modelRatioTiersMap)
+	if err != nil {
+		common.SysError("error marshalling model ratio tiers: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// UpdateModelRatioTiersByJSONString 更新分段倍率，分段按 prompt_tokens_above 升序排列
+func UpdateModelRatioTiersByJSONString(jsonStr string) error {
+	tiersMap := make(map[string][]ModelRatioTier)
+	if err := common.Unmarshal([]byte(jsonStr), &tiersMap); err != nil {
+		return err
+	}
+	for name, tiers := range tiersMap {
+		sort.SliceStable(tiers, func(i, j int) bool {
+			return tiers[i].PromptTokensAbove < tiers[j].PromptTokensAbove
+		})
+		for i, tier := range tiers {
+			if tier.PromptTokensAbove <= 0 {
+				return fmt.Errorf("model %s tier %d: prompt_tokens_above must be greater than 0", name, i+1)
+			}
+			if i > 0 && tier.PromptTokensAbove == tiers[i-1].PromptTokensAbove {
+				return fmt.Errorf("model %s has duplicate tier prompt_tokens_above %d", name, tier.PromptTokensAbove)
+			}
+			// 分段倍率为 0 会使超长上下文请求免费
+			if tier.ModelRatio <= 0 {
+				return fmt.Errorf("model %s tier %d: model_ratio must be greater than 0", name, i+1)
+			}
+			if tier.CompletionRatio < 0 || tier.CacheRatio < 0 {
+				return fmt.Errorf("model %s tier %d: ratio must not be negative", name, i+1)
+			}
+		}
+	}
+	modelRatioTiersMapMutex.Lock()
+	modelRatioTiersMap = tiersMap
+	modelRatioTiersMapMutex.Unlock()
+	InvalidateExposedDataCache()
+	return nil
+}
+
+// GetModelRatioTiers 返回模型配置的分段倍率
+func GetModelRatioTiers(name string) []ModelRatioTier {
+	modelRatioTiersMapMutex.RLock()
+	defer modelRatioTiersMapMutex.RUnlock()
+	return modelRatioTiersMap[FormatMatchingModelName(name)]
+}
+
+func GetModelRatioTiersCopy() map[string][]ModelRatioTier {
+	modelRatioTiersMapMutex.RLock()
+	defer modelRatioTiersMapMutex.RUnlock()
+	copyMap := make(map[string][]ModelRatioTier, len(modelRatioTiersMap))
+	for k, v := range modelRatioTiersMap {
+		copyMap[k] = append([]ModelRatioTier(nil), v...)
+	}
+	return copyMap
+}
+
+// GetModelRatioTier 返回提示 token 数命中的分段及其序号（从 1 开始）
+func GetModelRatioTier(name string, promptTokens int) (ModelRatioTier, int, bool) {
+	tiers := GetModelRatioTiers(name)
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if promptTokens > tiers[i].PromptTokensAbove {
+			return tiers[i], i + 1, true
+		}
+	}
+	return ModelRatioTier{}, 0, false
+}
+
+// ApplyModelRatioTier 按提示 token 数选择分段并更新 priceData 中的倍率，分段发生变化时返回 true。
+// 预扣费阶段只能估算提示 token 数，结算时需要使用实际用量重新选择分段
+func ApplyModelRatioTier(priceData *types.PriceData, modelName string, promptTokens int) bool {
+	if priceData.UsePrice {
+		return false
+	}
+	tier, index, _ := GetModelRatioTier(modelName, promptTokens)
+	if index == priceData.ContextTier {
+		return false
+	}
+	priceData.ModelRatio, _, _ = GetModelRatio(modelName)
+	priceData.CompletionRatio = GetCompletionRatio(modelName)
+	priceData.CacheRatio, _ = GetCacheRatio(modelName)
+	priceData.ContextTier = index
+	priceData.ContextTierPromptTokensAbove = tier.PromptTokensAbove
+	if index > 0 {
+		priceData.ModelRatio = tier.ModelRatio
+		if tier.CompletionRatio > 0 {
+			priceData.CompletionRatio = tier.CompletionRatio
+		}
+		if tier.CacheRatio > 0 {
+			priceData.CacheRatio = tier.CacheRatio
+		}
+	}
+	// 保留请求时生效的定时价格规则
+	if priceData.PricingRule.Id != "" {
+		priceData.ModelRatio *= priceData.PricingRule.Multiplier
+	}
+	return true
+}
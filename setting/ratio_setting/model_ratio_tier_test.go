@@ -0,0 +1,68 @@
+package ratio_setting
+
+import (
+	"one-api/types"
+	"testing"
+)
+
+func TestApplyModelRatioTier(t *testing.T) {
+	InitRatioSettings()
+	if err := UpdateModelRatioTiersByJSONString(`{"tier-model":[
+		{"prompt_tokens_above":200000,"model_ratio":4,"completion_ratio":6},
+		{"prompt_tokens_above":128000,"model_ratio":2}
+	]}`); err != nil {
+		t.Fatalf("update tiers: %v", err)
+	}
+	defer UpdateModelRatioTiersByJSONString(`{}`)
+	if err := UpdateModelRatioByJSONString(`{"tier-model":1}`); err != nil {
+		t.Fatalf("update model ratio: %v", err)
+	}
+	if err := UpdateCompletionRatioByJSONString(`{"tier-model":3}`); err != nil {
+		t.Fatalf("update completion ratio: %v", err)
+	}
+
+	tests := []struct {
+		promptTokens    int
+		wantTier        int
+		wantRatio       float64
+		wantCompletion  float64
+		wantChanged     bool
+		startTier       int
+		startModelRatio float64
+	}{
+		{promptTokens: 1000, wantTier: 0, wantRatio: 1, wantCompletion: 3, startTier: 0, startModelRatio: 1},
+		{promptTokens: 128000, wantTier: 0, wantRatio: 1, wantCompletion: 3, startTier: 0, startModelRatio: 1},
+		{promptTokens: 128001, wantTier: 1, wantRatio: 2, wantCompletion: 3, wantChanged: true, startModelRatio: 1},
+		{promptTokens: 300000, wantTier: 2, wantRatio: 4, wantCompletion: 6, wantChanged: true, startModelRatio: 1},
+		// 预扣费命中分段但实际用量回落时恢复默认倍率
+		{promptTokens: 500, wantTier: 0, wantRatio: 1, wantCompletion: 3, wantChanged: true, startTier: 2, startModelRatio: 4},
+	}
+	for _, tt := range tests {
+		priceData := types.PriceData{ModelRatio: tt.startModelRatio, CompletionRatio: 3, ContextTier: tt.startTier}
+		changed := ApplyModelRatioTier(&priceData, "tier-model", tt.promptTokens)
+		if changed != tt.wantChanged {
+			t.Errorf("prompt %d: changed = %v, want %v", tt.promptTokens, changed, tt.wantChanged)
+		}
+		if priceData.ContextTier != tt.wantTier || priceData.ModelRatio != tt.wantRatio || priceData.CompletionRatio != tt.wantCompletion {
+			t.Errorf("prompt %d: got tier %d ratio %v completion %v, want tier %d ratio %v completion %v",
+				tt.promptTokens, priceData.ContextTier, priceData.ModelRatio, priceData.CompletionRatio,
+				tt.wantTier, tt.wantRatio, tt.wantCompletion)
+		}
+	}
+}
+
+func TestUpdateModelRatioTiersValidation(t *testing.T) {
+	invalid := []string{
+		`{"m":[{"prompt_tokens_above":0,"model_ratio":1}]}`,
+		`{"m":[{"prompt_tokens_above":100,"model_ratio":1},{"prompt_tokens_above":100,"model_ratio":2}]}`,
+		`{"m":[{"prompt_tokens_above":100,"model_ratio":-1}]}`,
+		`{"m":[{"prompt_tokens_above":100,"model_ratio":0}]}`,
+		`{"m":[{"prompt_tokens_above":100,"completion_ratio":2}]}`,
+		`{"m":[{"prompt_tokens_above":100,"model_ratio":1,"cache_ratio":-1}]}`,
+	}
+	for _, jsonStr := range invalid {
+		if err := UpdateModelRatioTiersByJSONString(jsonStr); err == nil {
+			t.Errorf("expected error for %s", jsonStr)
+		}
+	}
+}
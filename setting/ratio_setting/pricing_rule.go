@@ -0,0 +1,253 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"one-api/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PricingRuleRecurrenceNone   = ""
+	PricingRuleRecurrenceDaily  = "daily"
+	PricingRuleRecurrenceWeekly = "weekly"
+)
+
+// PricingRule 定时价格规则（闲时折扣、限时促销、分组首发价等），生效时模型倍率与按次价格乘以 Multiplier
+type PricingRule struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// 生效模型，支持以 * 结尾的前缀匹配，留空表示所有模型
+	Models []string `json:"models,omitempty"`
+	// 生效分组，留空表示所有分组
+	Groups     []string `json:"groups,omitempty"`
+	Multiplier float64  `json:"multiplier"`
+	// 规则有效期（Unix 秒），0 表示不限
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+	// 重复周期：留空表示有效期内一直生效，daily 每天、weekly 每周 weekdays 的 daily_start 至 daily_end 生效
+	Recurrence string `json:"recurrence,omitempty"`
+	// 星期几，0 表示周日
+	Weekdays []int `json:"weekdays,omitempty"`
+	// 每天的生效时段，格式 HH:MM，结束时间不大于开始时间时表示跨天
+	DailyStart string `json:"daily_start,omitempty"`
+	DailyEnd   string `json:"daily_end,omitempty"`
+	// 时段使用的时区，默认服务器时区
+	Timezone string `json:"timezone,omitempty"`
+	// 同时命中多条规则时优先级高的生效，优先级相同时取倍数最低的
+	Priority int `json:"priority,omitempty"`
+
+	location   *time.Location
+	dailyStart time.Duration
+	dailyEnd   time.Duration
+}
+
+// PricingRuleWindow 规则的一个生效时段（Unix 秒），End 为 0 表示不限
+type PricingRuleWindow struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+var (
+	pricingRules      = make([]*PricingRule, 0)
+	pricingRulesMutex = sync.RWMutex{}
+)
+
+func PricingRules2JSONString() string {
+	pricingRulesMutex.RLock()
+	defer pricingRulesMutex.RUnlock()
+	jsonBytes, err := common.Marshal(pricingRules)
+	if err != nil {
+		common.SysError("error marshalling pricing rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdatePricingRulesByJSONString(jsonStr string) error {
+	rules := make([]*PricingRule, 0)
+	if err := common.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return err
+	}
+	ids := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Id == "" {
+			return fmt.Errorf("pricing rule %d: id is required", i+1)
+		}
+		if ids[rule.Id] {
+			return fmt.Errorf("duplicate pricing rule id: %s", rule.Id)
+		}
+		ids[rule.Id] = true
+		if err := rule.prepare(); err != nil {
+			return fmt.Errorf("pricing rule %s: %w", rule.Id, err)
+		}
+	}
+	pricingRulesMutex.Lock()
+	pricingRules = rules
+	pricingRulesMutex.Unlock()
+	InvalidateExposedDataCache()
+	return nil
+}
+
+func parseDailyTime(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (rule *PricingRule) prepare() error {
+	if rule.Multiplier < 0 {
+		return fmt.Errorf("multiplier must not be negative")
+	}
+	if rule.EndTime != 0 && rule.EndTime <= rule.StartTime {
+		return fmt.Errorf("end_time must be after start_time")
+	}
+	rule.location = time.Local
+	if rule.Timezone != "" {
+		location, err := time.LoadLocation(rule.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q", rule.Timezone)
+		}
+		rule.location = location
+	}
+	switch rule.Recurrence {
+	case PricingRuleRecurrenceNone:
+		return nil
+	case PricingRuleRecurrenceDaily, PricingRuleRecurrenceWeekly:
+	default:
+		return fmt.Errorf("invalid recurrence %q", rule.Recurrence)
+	}
+	var err error
+	if rule.dailyStart, err = parseDailyTime(rule.DailyStart); err != nil {
+		return err
+	}
+	if rule.dailyEnd, err = parseDailyTime(rule.DailyEnd); err != nil {
+		return err
+	}
+	if rule.Recurrence == PricingRuleRecurrenceWeekly {
+		if len(rule.Weekdays) == 0 {
+			return fmt.Errorf("weekdays is required for weekly recurrence")
+		}
+		for _, weekday := range rule.Weekdays {
+			if weekday < 0 || weekday > 6 {
+				return fmt.Errorf("invalid weekday %d", weekday)
+			}
+		}
+	}
+	return nil
+}
+
+func (rule *PricingRule) matchModel(modelName string) bool {
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(modelName, prefix) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *PricingRule) matchGroup(group string) bool {
+	if len(rule.Groups) == 0 {
+		return true
+	}
+	for _, g := range rule.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *PricingRule) matchWeekday(weekday time.Weekday) bool {
+	if rule.Recurrence != PricingRuleRecurrenceWeekly {
+		return true
+	}
+	for _, d := range rule.Weekdays {
+		if time.Weekday(d) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// NextWindow 返回包含 now 或在 now 之后最近的生效时段，周期规则只查找未来 8 天内的时段
+func (rule *PricingRule) NextWindow(now time.Time) (PricingRuleWindow, bool) {
+	nowUnix := now.Unix()
+	if rule.EndTime != 0 && nowUnix >= rule.EndTime {
+		return PricingRuleWindow{}, false
+	}
+	if rule.Recurrence == PricingRuleRecurrenceNone {
+		return PricingRuleWindow{Start: rule.StartTime, End: rule.EndTime}, true
+	}
+	local := now.In(rule.location)
+	// 从前一天开始查找，覆盖跨天时段
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, rule.location).AddDate(0, 0, -1)
+	for i := 0; i < 9; i++ {
+		date := day.AddDate(0, 0, i)
+		if !rule.matchWeekday(date.Weekday()) {
+			continue
+		}
+		start := date.Add(rule.dailyStart)
+		end := date.Add(rule.dailyEnd)
+		if rule.dailyEnd <= rule.dailyStart {
+			end = date.AddDate(0, 0, 1).Add(rule.dailyEnd)
+		}
+		window := PricingRuleWindow{Start: start.Unix(), End: end.Unix()}
+		if rule.StartTime != 0 && window.Start < rule.StartTime {
+			window.Start = rule.StartTime
+		}
+		if rule.EndTime != 0 && window.End > rule.EndTime {
+			window.End = rule.EndTime
+		}
+		if window.End <= nowUnix || window.End <= window.Start {
+			continue
+		}
+		return window, true
+	}
+	return PricingRuleWindow{}, false
+}
+
+// ActiveAt 规则在 now 是否生效
+func (rule *PricingRule) ActiveAt(now time.Time) bool {
+	window, ok := rule.NextWindow(now)
+	return ok && window.Start <= now.Unix()
+}
+
+// GetActivePricingRule 返回模型在分组下当前生效的价格规则
+func GetActivePricingRule(modelName string, group string, now time.Time) (*PricingRule, bool) {
+	pricingRulesMutex.RLock()
+	defer pricingRulesMutex.RUnlock()
+	var active *PricingRule
+	for _, rule := range pricingRules {
+		if !rule.matchModel(modelName) || !rule.matchGroup(group) || !rule.ActiveAt(now) {
+			continue
+		}
+		if active == nil || rule.Priority > active.Priority ||
+			(rule.Priority == active.Priority && rule.Multiplier < active.Multiplier) {
+			active = rule
+		}
+	}
+	return active, active != nil
+}
+
+// GetModelPricingRules 返回适用于模型的所有规则（不区分分组）
+func GetModelPricingRules(modelName string) []*PricingRule {
+	pricingRulesMutex.RLock()
+	defer pricingRulesMutex.RUnlock()
+	rules := make([]*PricingRule, 0)
+	for _, rule := range pricingRules {
+		if rule.matchModel(modelName) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,82 @@
+package ratio_setting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPricingRuleWindows(t *testing.T) {
+	if err := UpdatePricingRulesByJSONString(`[
+		{"id":"night","models":["gpt-4o*"],"multiplier":0.5,"recurrence":"daily","daily_start":"22:00","daily_end":"06:00","timezone":"UTC"},
+		{"id":"weekend","groups":["vip"],"multiplier":0.8,"recurrence":"weekly","weekdays":[0,6],"daily_start":"00:00","daily_end":"00:00","timezone":"UTC","priority":1},
+		{"id":"launch","models":["new-model"],"multiplier":0.3,"start_time":1767225600,"end_time":1767830400}
+	]`); err != nil {
+		t.Fatalf("update rules: %v", err)
+	}
+	defer UpdatePricingRulesByJSONString(`[]`)
+
+	// 2026-01-02 是周五
+	at := func(value string) time.Time {
+		tm, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		name     string
+		model    string
+		group    string
+		now      time.Time
+		wantRule string
+	}{
+		{name: "night before midnight", model: "gpt-4o-mini", group: "default", now: at("2026-01-02T23:00:00Z"), wantRule: "night"},
+		{name: "night after midnight", model: "gpt-4o", group: "default", now: at("2026-01-02T05:59:00Z"), wantRule: "night"},
+		{name: "daytime", model: "gpt-4o", group: "default", now: at("2026-01-02T12:00:00Z"), wantRule: ""},
+		{name: "other model", model: "claude", group: "default", now: at("2026-01-02T23:00:00Z"), wantRule: ""},
+		{name: "weekend priority", model: "gpt-4o", group: "vip", now: at("2026-01-03T23:00:00Z"), wantRule: "weekend"},
+		{name: "weekend other group", model: "gpt-4o", group: "default", now: at("2026-01-03T12:00:00Z"), wantRule: ""},
+		{name: "launch before start", model: "new-model", group: "default", now: at("2025-12-31T00:00:00Z"), wantRule: ""},
+		{name: "launch active", model: "new-model", group: "default", now: at("2026-01-02T00:00:00Z"), wantRule: "launch"},
+		{name: "launch ended", model: "new-model", group: "default", now: at("2026-01-09T00:00:00Z"), wantRule: ""},
+	}
+	for _, tt := range tests {
+		rule, ok := GetActivePricingRule(tt.model, tt.group, tt.now)
+		got := ""
+		if ok {
+			got = rule.Id
+		}
+		if got != tt.wantRule {
+			t.Errorf("%s: got rule %q, want %q", tt.name, got, tt.wantRule)
+		}
+	}
+
+	// 白天查询闲时规则时返回当天晚上的时段
+	rules := GetModelPricingRules("gpt-4o")
+	for _, rule := range rules {
+		if rule.Id != "night" {
+			continue
+		}
+		window, ok := rule.NextWindow(at("2026-01-02T12:00:00Z"))
+		if !ok || window.Start != at("2026-01-02T22:00:00Z").Unix() || window.End != at("2026-01-03T06:00:00Z").Unix() {
+			t.Errorf("unexpected next window %+v", window)
+		}
+	}
+}
+
+func TestUpdatePricingRulesValidation(t *testing.T) {
+	invalid := []string{
+		`[{"multiplier":0.5}]`,
+		`[{"id":"a","multiplier":0.5},{"id":"a","multiplier":0.5}]`,
+		`[{"id":"a","multiplier":0.5,"recurrence":"hourly"}]`,
+		`[{"id":"a","multiplier":0.5,"recurrence":"daily","daily_start":"25:00","daily_end":"06:00"}]`,
+		`[{"id":"a","multiplier":0.5,"recurrence":"weekly","daily_start":"01:00","daily_end":"06:00"}]`,
+		`[{"id":"a","multiplier":0.5,"timezone":"Mars/Base"}]`,
+		`[{"id":"a","multiplier":0.5,"start_time":100,"end_time":50}]`,
+	}
+	for _, jsonStr := range invalid {
+		if err := UpdatePricingRulesByJSONString(jsonStr); err == nil {
+			t.Errorf("expected error for %s", jsonStr)
+		}
+	}
+}
@@ -83,6 +83,7 @@ type NewAPIError struct {
 	Err            error
 	RelayError     any
 	skipRetry      bool
+	upstream       bool
 	recordErrorLog *bool
 	errorType      ErrorType
 	errorCode      ErrorCode
@@ -314,6 +315,20 @@ func IsSkipRetryError(err *NewAPIError) bool {
 	return err.skipRetry
 }
 
+// IsUpstreamError 错误是否来自上游返回的错误响应
+func IsUpstreamError(err *NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	return err.upstream
+}
+
+func ErrOptionWithUpstream() NewAPIErrorOptions {
+	return func(e *NewAPIError) {
+		e.upstream = true
+	}
+}
+
 func ErrOptionWithSkipRetry() NewAPIErrorOptions {
 	return func(e *NewAPIError) {
 		e.skipRetry = true
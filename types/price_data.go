@@ -18,14 +18,27 @@ type PriceData struct {
 	UsePrice               bool
 	ShouldPreConsumedQuota int
 	GroupRatioInfo         GroupRatioInfo
+	// 命中的上下文长度分段（从 1 开始，0 表示未命中分段）及分段起点
+	ContextTier                  int
+	ContextTierPromptTokensAbove int
+	// 请求时生效的定时价格规则
+	PricingRule PricingRuleInfo
+}
+
+// PricingRuleInfo 计费时生效的定时价格规则，Id 为空表示没有规则生效
+type PricingRuleInfo struct {
+	Id         string
+	Name       string
+	Multiplier float64
 }
 
 type PerCallPriceData struct {
 	ModelPrice     float64
 	Quota          int
 	GroupRatioInfo GroupRatioInfo
+	PricingRule    PricingRuleInfo
 }
 
 func (p PriceData) ToSetting() string {
-	return fmt.Sprintf("ModelPrice: %f, ModelRatio: %f, CompletionRatio: %f, CacheRatio: %f, GroupRatio: %f, UsePrice: %t, CacheCreationRatio: %f, ShouldPreConsumedQuota: %d, ImageRatio: %f", p.ModelPrice, p.ModelRatio, p.CompletionRatio, p.CacheRatio, p.GroupRatioInfo.GroupRatio, p.UsePrice, p.CacheCreationRatio, p.ShouldPreConsumedQuota, p.ImageRatio)
+	return fmt.Sprintf("ModelPrice: %f, ModelRatio: %f, CompletionRatio: %f, CacheRatio: %f, GroupRatio: %f, UsePrice: %t, CacheCreationRatio: %f, ShouldPreConsumedQuota: %d, ImageRatio: %f, ContextTier: %d, PricingRule: %s", p.ModelPrice, p.ModelRatio, p.CompletionRatio, p.CacheRatio, p.GroupRatioInfo.GroupRatio, p.UsePrice, p.CacheCreationRatio, p.ShouldPreConsumedQuota, p.ImageRatio, p.ContextTier, p.PricingRule.Id)
 }